@@ -2,26 +2,34 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net"
+	"path/filepath"
 
 	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
 	"github.com/sharding-db/milvus-mini/pkg"
 	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/compaction"
 	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
 
 	"google.golang.org/grpc"
 )
 
+var authorizationEnabled = flag.Bool("authorization-enabled", false,
+	"whether requests must carry the credential of a user in the authorization metadata, "+
+		"and the user must have the privileges of requests")
+
 func main() {
+	flag.Parse()
 	// create listiner
 	lis, err := net.Listen("tcp", ":19530")
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	// create grpc server
-	s := grpc.NewServer()
 	ctx := context.Background()
 	log.Println("init meta table")
 	rootPath := "./tmp/milvus-mini"
@@ -29,7 +37,29 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to create meta table: %v", err)
 	}
-	miniMilvus := pkg.NewMilvusMini(new(allocator.LocalTsAllocator), metatable)
+	// create grpc server
+	var opts []grpc.ServerOption
+	if *authorizationEnabled {
+		log.Println("authorization enabled")
+		opts = append(opts, grpc.ChainUnaryInterceptor(
+			pkg.NewAuthenticator(metatable).UnaryServerInterceptor(),
+			pkg.NewPrivilegeChecker(metatable).UnaryServerInterceptor(),
+		))
+	}
+	s := grpc.NewServer(opts...)
+	idAllocator := new(allocator.LocalTsAllocator)
+	tsAllocator := new(allocator.LocalTimestampAllocator)
+	chunkManager, err := storage.NewLocalChunkManager(filepath.Join(rootPath, "data"))
+	if err != nil {
+		log.Fatalf("failed to create chunk manager: %v", err)
+	}
+	segmentManager, err := segments.NewManager(ctx, segments.DefaultConfig(), metatable, chunkManager, idAllocator)
+	if err != nil {
+		log.Fatalf("failed to create segment manager: %v", err)
+	}
+	compactor := compaction.NewCompactor(compaction.DefaultConfig(), metatable, segmentManager, chunkManager, idAllocator, tsAllocator)
+	compactor.Start(ctx)
+	miniMilvus := pkg.NewMilvusMini(idAllocator, tsAllocator, metatable, segmentManager, compactor)
 	milvuspb.RegisterMilvusServiceServer(s, miniMilvus)
 
 	log.Println("start server on 19530")
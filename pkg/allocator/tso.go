@@ -0,0 +1,43 @@
+package allocator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+)
+
+// Timestamp is alias of typeutil.Timestamp
+type Timestamp = typeutil.Timestamp
+
+// TimestampAllocator allocates hybrid timestamps, which are compatible with milvus tso:
+// the high bits are physical time in milliseconds, the low 18 bits are logical counter
+type TimestampAllocator interface {
+	AllocTimestamp() (Timestamp, error)
+}
+
+const maxLogical = int64(1 << 18)
+
+type LocalTimestampAllocator struct {
+	lock         sync.Mutex
+	lastPhysical int64
+	logical      int64
+}
+
+func (a *LocalTimestampAllocator) AllocTimestamp() (Timestamp, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	physical := time.Now().UnixMilli()
+	if physical > a.lastPhysical {
+		a.lastPhysical = physical
+		a.logical = 0
+	} else {
+		a.logical++
+		if a.logical >= maxLogical {
+			a.lastPhysical++
+			a.logical = 0
+		}
+	}
+	return tsoutil.ComposeTS(a.lastPhysical, a.logical), nil
+}
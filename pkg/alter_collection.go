@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"go.uber.org/zap"
+)
+
+type AlterCollectionTask struct {
+	meta metas.MetaTable
+
+	req *milvuspb.AlterCollectionRequest
+}
+
+func NewAlterCollectionTask(meta metas.MetaTable, request *milvuspb.AlterCollectionRequest) *AlterCollectionTask {
+	return &AlterCollectionTask{
+		meta: meta,
+		req:  request,
+	}
+}
+
+// Execute updates the collection properties, changes take effect for later reads & compactions
+func (t AlterCollectionTask) Execute(ctx context.Context) error {
+	if len(t.req.GetProperties()) == 0 {
+		return merr.WrapErrParameterInvalidMsg("no properties to alter")
+	}
+	if err := checkProperties(t.req.GetProperties()); err != nil {
+		return err
+	}
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return err
+	}
+
+	newColl, err := t.meta.AlterCollectionProperties(ctx, collection.CollectionID, t.req.GetProperties())
+	if err != nil {
+		return err
+	}
+	log.Info("collection altered", zap.String("collectionName", newColl.Name), zap.Any("properties", newColl.Properties))
+	return nil
+}
+
+// checkProperties validates the values of known properties
+func checkProperties(properties []*commonpb.KeyValuePair) error {
+	for _, kv := range properties {
+		switch kv.GetKey() {
+		case common.CollectionTTLConfigKey:
+			if _, err := segments.ParseTTL(kv.GetValue()); err != nil {
+				return err
+			}
+		case common.CollectionAutoCompactionKey:
+			if _, err := strconv.ParseBool(kv.GetValue()); err != nil {
+				return merr.WrapErrParameterInvalidMsg("invalid %s: %s", kv.GetKey(), kv.GetValue())
+			}
+		}
+	}
+	return nil
+}
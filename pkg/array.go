@@ -0,0 +1,146 @@
+package pkg
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+const (
+	// MaxCapacityKey is the type param of the max number of elements of array fields, same as milvus
+	MaxCapacityKey = "max_capacity"
+	// ElementTypeKey is the type param of the element type of array fields, used if ElementType of schema is not set
+	ElementTypeKey = "element_type"
+	// MaxArrayCapacity is the max max_capacity of array fields, same as milvus
+	MaxArrayCapacity = 4096
+)
+
+// getMaxCapacity returns the max_capacity of array field
+func getMaxCapacity(field *schemapb.FieldSchema) (int, error) {
+	for _, kv := range field.GetTypeParams() {
+		if kv.GetKey() == MaxCapacityKey {
+			capacity, err := strconv.Atoi(kv.GetValue())
+			if err != nil {
+				return 0, merr.WrapErrParameterInvalidMsg("invalid %s of array field %s: %s", MaxCapacityKey, field.GetName(), kv.GetValue())
+			}
+			return capacity, nil
+		}
+	}
+	return 0, merr.WrapErrParameterInvalidMsg("%s of array field %s is not specified", MaxCapacityKey, field.GetName())
+}
+
+// fillElementTypes sets the element types of array fields by the element_type type param,
+// the value is the name or the number of data type, e.g. Int64 or 5.
+func fillElementTypes(schema *schemapb.CollectionSchema) error {
+	for _, field := range schema.GetFields() {
+		if field.GetDataType() != schemapb.DataType_Array || field.GetElementType() != schemapb.DataType_None {
+			continue
+		}
+		for _, kv := range field.GetTypeParams() {
+			if kv.GetKey() != ElementTypeKey {
+				continue
+			}
+			elementType, ok := schemapb.DataType_value[kv.GetValue()]
+			if !ok {
+				number, err := strconv.Atoi(kv.GetValue())
+				if err != nil {
+					return merr.WrapErrParameterInvalidMsg("invalid %s of array field %s: %s", ElementTypeKey, field.GetName(), kv.GetValue())
+				}
+				elementType = int32(number)
+			}
+			field.ElementType = schemapb.DataType(elementType)
+		}
+	}
+	return nil
+}
+
+// elementField returns the schema of elements of array field, VarChar elements share the max_length of the field
+func elementField(field *schemapb.FieldSchema) *schemapb.FieldSchema {
+	return &schemapb.FieldSchema{Name: field.GetName(), DataType: field.GetElementType(), TypeParams: field.GetTypeParams()}
+}
+
+// validateArrayData checks the rows of array field match the element type and the max_capacity
+func validateArrayData(field *schemapb.FieldSchema, data storage.FieldData) error {
+	arrays, ok := data.(*storage.ArrayFieldData)
+	if !ok {
+		return nil
+	}
+	for i, row := range arrays.Data {
+		if !arrays.IsValid(i) {
+			continue
+		}
+		if err := validateArrayRow(field, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateArrayRow checks the elements of one row of array field
+func validateArrayRow(field *schemapb.FieldSchema, row *schemapb.ScalarField) error {
+	capacity, err := getMaxCapacity(field)
+	if err != nil {
+		return err
+	}
+	mismatch := merr.WrapErrParameterInvalidMsg("elements of array field %s should be %s", field.GetName(), field.GetElementType().String())
+	length := 0
+	switch field.GetElementType() {
+	case schemapb.DataType_Bool:
+		if row.GetBoolData() == nil {
+			return mismatch
+		}
+		length = len(row.GetBoolData().GetData())
+	case schemapb.DataType_Int8, schemapb.DataType_Int16, schemapb.DataType_Int32:
+		if row.GetIntData() == nil {
+			return mismatch
+		}
+		length = len(row.GetIntData().GetData())
+		lower, upper := int32(math.MinInt32), int32(math.MaxInt32)
+		switch field.GetElementType() {
+		case schemapb.DataType_Int8:
+			lower, upper = math.MinInt8, math.MaxInt8
+		case schemapb.DataType_Int16:
+			lower, upper = math.MinInt16, math.MaxInt16
+		}
+		for _, v := range row.GetIntData().GetData() {
+			if v < lower || v > upper {
+				return merr.WrapErrParameterInvalidRange(lower, upper, v, "element of array field "+field.GetName()+" out of range")
+			}
+		}
+	case schemapb.DataType_Int64:
+		if row.GetLongData() == nil {
+			return mismatch
+		}
+		length = len(row.GetLongData().GetData())
+	case schemapb.DataType_Float:
+		if row.GetFloatData() == nil {
+			return mismatch
+		}
+		length = len(row.GetFloatData().GetData())
+	case schemapb.DataType_Double:
+		if row.GetDoubleData() == nil {
+			return mismatch
+		}
+		length = len(row.GetDoubleData().GetData())
+	case schemapb.DataType_VarChar:
+		if row.GetStringData() == nil {
+			return mismatch
+		}
+		length = len(row.GetStringData().GetData())
+		for _, v := range row.GetStringData().GetData() {
+			if err := checkStringLength(elementField(field), v); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch
+	}
+	if length > capacity {
+		return merr.WrapErrParameterInvalidMsg("the number of elements %d of array field %s exceeds max capacity %d",
+			length, field.GetName(), capacity)
+	}
+	return nil
+}
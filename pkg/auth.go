@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/milvus-io/milvus/pkg/util"
+	"github.com/milvus-io/milvus/pkg/util/crypto"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator checks the credential of requests, which is base64 of `username:password` in the authorization
+// metadata as SDKs send it.
+type Authenticator struct {
+	meta metas.MetaTable
+
+	lock sync.RWMutex
+	// verified caches the sha256 of the password verified of each user, since bcrypt is slow on purpose.
+	// The cache is valid only if the bcrypt hash is not changed, so changed passwords are verified again.
+	verified map[string]verifiedPassword
+}
+
+type verifiedPassword struct {
+	encryptedPassword string
+	sha256Password    string
+}
+
+func NewAuthenticator(meta metas.MetaTable) *Authenticator {
+	return &Authenticator{meta: meta, verified: make(map[string]verifiedPassword)}
+}
+
+// Authenticate returns the user of the credential in ctx
+func (a *Authenticator) Authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(util.HeaderAuthorize)) == 0 {
+		return "", merr.ErrNeedAuthenticate
+	}
+	raw, err := crypto.Base64Decode(md.Get(util.HeaderAuthorize)[0])
+	if err != nil {
+		return "", merr.ErrNeedAuthenticate
+	}
+	username, password, ok := strings.Cut(raw, util.CredentialSeperator)
+	if !ok {
+		return "", merr.ErrNeedAuthenticate
+	}
+	credential, err := a.meta.GetCredential(ctx, username)
+	if err != nil {
+		return "", merr.ErrNeedAuthenticate
+	}
+	sha256Password := crypto.SHA256(password, username)
+	a.lock.RLock()
+	cached, ok := a.verified[username]
+	a.lock.RUnlock()
+	if ok && cached.encryptedPassword == credential.GetEncryptedPassword() && cached.sha256Password == sha256Password {
+		return username, nil
+	}
+	if !verifyPassword(credential, password) {
+		return "", merr.ErrNeedAuthenticate
+	}
+	a.lock.Lock()
+	a.verified[username] = verifiedPassword{encryptedPassword: credential.GetEncryptedPassword(), sha256Password: sha256Password}
+	a.lock.Unlock()
+	return username, nil
+}
+
+// UnaryServerInterceptor rejects the requests failed to authenticate with code Unauthenticated, same as milvus.
+// The user authenticated is carried in the context of the handler.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		username, err := a.Authenticate(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "auth check failure, please check username and password are correct")
+		}
+		return handler(context.WithValue(ctx, userKey{}, username), req)
+	}
+}
+
+type userKey struct{}
+
+// currentUser returns the user authenticated of the request, false if the request is not authenticated
+func currentUser(ctx context.Context) (string, bool) {
+	username, ok := ctx.Value(userKey{}).(string)
+	return username, ok
+}
@@ -0,0 +1,266 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+const (
+	// CalcDistanceMetricKey is the metric type param of CalcDistance, same as milvus
+	CalcDistanceMetricKey = "metric"
+	// CalcDistanceSqrtKey is the param to return the euclidean distances of L2 instead of the squared ones
+	CalcDistanceSqrtKey = "sqrt"
+	// MaxCalcDistanceVectors is the max number of vectors of each side of CalcDistance
+	MaxCalcDistanceVectors = MaxTopK
+	// MaxCalcDistanceResults is the max number of distances CalcDistance returns, 16MB of float distances
+	MaxCalcDistanceResults = 1 << 22
+)
+
+type CalcDistanceTask struct {
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.CalcDistanceRequest
+}
+
+func NewCalcDistanceTask(
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.CalcDistanceRequest) *CalcDistanceTask {
+
+	return &CalcDistanceTask{
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// Execute returns the distances between each left vector and each right vector, ordered by left vector first.
+// Vectors are given in the request or referenced by primary keys of a loaded collection.
+// Distances of HAMMING are returned as integers.
+func (t CalcDistanceTask) Execute(ctx context.Context) (*milvuspb.CalcDistanceResults, error) {
+	metricType, sqrt := "", false
+	for _, kv := range t.req.GetParams() {
+		switch kv.GetKey() {
+		case CalcDistanceMetricKey, common.MetricTypeKey:
+			metricType = strings.ToUpper(kv.GetValue())
+		case CalcDistanceSqrtKey:
+			var err error
+			sqrt, err = strconv.ParseBool(kv.GetValue())
+			if err != nil {
+				return nil, merr.WrapErrParameterInvalidMsg("invalid %s: %s", CalcDistanceSqrtKey, kv.GetValue())
+			}
+		}
+	}
+	if metricType == "" {
+		return nil, merr.WrapErrParameterInvalidMsg("%s not found in params", CalcDistanceMetricKey)
+	}
+	left, err := t.getVectors(ctx, t.req.GetOpLeft())
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.getVectors(ctx, t.req.GetOpRight())
+	if err != nil {
+		return nil, err
+	}
+	if left.GetDataType() != right.GetDataType() || vectorDim(left) != vectorDim(right) {
+		return nil, merr.WrapErrParameterInvalidMsg("vectors of %s with dim %d mismatch vectors of %s with dim %d",
+			left.GetDataType().String(), vectorDim(left), right.GetDataType().String(), vectorDim(right))
+	}
+	if left.RowNum()*right.RowNum() > MaxCalcDistanceResults {
+		return nil, merr.WrapErrParameterInvalidRange(0, MaxCalcDistanceResults, left.RowNum()*right.RowNum(), "too many distances")
+	}
+	distance, err := search.GetDistanceFunc(metricType, left.GetDataType())
+	if err != nil {
+		return nil, err
+	}
+
+	distances := make([]float32, 0, left.RowNum()*right.RowNum())
+	for i := 0; i < left.RowNum(); i++ {
+		for j := 0; j < right.RowNum(); j++ {
+			d := distance(left.GetRow(i), right.GetRow(j))
+			if sqrt && metricType == metric.L2 {
+				d = float32(math.Sqrt(float64(d)))
+			}
+			distances = append(distances, d)
+		}
+	}
+	resp := &milvuspb.CalcDistanceResults{Status: merr.Status(nil)}
+	if metricType == metric.HAMMING {
+		intDistances := make([]int32, 0, len(distances))
+		for _, d := range distances {
+			intDistances = append(intDistances, int32(d))
+		}
+		resp.Array = &milvuspb.CalcDistanceResults_IntDist{IntDist: &schemapb.IntArray{Data: intDistances}}
+	} else {
+		resp.Array = &milvuspb.CalcDistanceResults_FloatDist{FloatDist: &schemapb.FloatArray{Data: distances}}
+	}
+	return resp, nil
+}
+
+// getVectors returns the vectors of one side, which are given in the request or queried by primary keys
+func (t CalcDistanceTask) getVectors(ctx context.Context, vectors *milvuspb.VectorsArray) (storage.FieldData, error) {
+	var ret storage.FieldData
+	var err error
+	switch array := vectors.GetArray().(type) {
+	case *milvuspb.VectorsArray_DataArray:
+		ret, err = parseVectors(array.DataArray)
+	case *milvuspb.VectorsArray_IdArray:
+		ret, err = t.queryVectors(ctx, array.IdArray)
+	default:
+		return nil, merr.WrapErrParameterInvalidMsg("no vectors to calculate distance")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ret.RowNum() == 0 || ret.RowNum() > MaxCalcDistanceVectors {
+		return nil, merr.WrapErrParameterInvalidRange(1, MaxCalcDistanceVectors, ret.RowNum(), "invalid number of vectors")
+	}
+	return ret, nil
+}
+
+// parseVectors converts the vectors of request, only float, float16 & binary vectors are supported.
+// bfloat16 vectors can't be told apart from float16 ones in requests, they're referenced by ids instead.
+func parseVectors(vectors *schemapb.VectorField) (storage.FieldData, error) {
+	dim := int(vectors.GetDim())
+	if dim <= 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid dim: %d", dim)
+	}
+	switch vectors.GetData().(type) {
+	case *schemapb.VectorField_FloatVector:
+		data := vectors.GetFloatVector().GetData()
+		if len(data)%dim != 0 {
+			return nil, merr.WrapErrParameterInvalidMsg("%d floats are not vectors of dim %d", len(data), dim)
+		}
+		return &storage.FloatVectorFieldData{Data: data, Dim: dim}, nil
+	case *schemapb.VectorField_BinaryVector:
+		data := vectors.GetBinaryVector()
+		if dim%8 != 0 || len(data)%(dim/8) != 0 {
+			return nil, merr.WrapErrParameterInvalidMsg("%d bytes are not binary vectors of dim %d", len(data), dim)
+		}
+		return &storage.BinaryVectorFieldData{Data: data, Dim: dim}, nil
+	case *schemapb.VectorField_Float16Vector:
+		data := vectors.GetFloat16Vector()
+		if len(data)%(dim*2) != 0 {
+			return nil, merr.WrapErrParameterInvalidMsg("%d bytes are not float16 vectors of dim %d", len(data), dim)
+		}
+		return &storage.Float16VectorFieldData{Data: data, Dim: dim}, nil
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("only float, float16 and binary vectors are supported to calculate distance")
+}
+
+// queryVectors returns the vectors of primary keys in the order of ids, the collection must be loaded
+func (t CalcDistanceTask) queryVectors(ctx context.Context, ids *milvuspb.VectorIDs) (storage.FieldData, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, "", ids.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	var field *schemapb.FieldSchema
+	for _, f := range schema.GetFields() {
+		if storage.IsVectorType(f.GetDataType()) && (ids.GetFieldName() == "" || f.GetName() == ids.GetFieldName()) {
+			if field != nil {
+				return nil, merr.WrapErrParameterInvalidMsg("field name is required when there're multiple vector fields")
+			}
+			field = f
+		}
+	}
+	if field == nil {
+		return nil, merr.WrapErrFieldNotFound(ids.GetFieldName(), "vector field not found")
+	}
+
+	idList := make([]any, 0)
+	switch data := ids.GetIdArray().GetIdField().(type) {
+	case *schemapb.IDs_IntId:
+		for _, pk := range data.IntId.GetData() {
+			idList = append(idList, pk)
+		}
+	case *schemapb.IDs_StrId:
+		for _, pk := range data.StrId.GetData() {
+			idList = append(idList, pk)
+		}
+	}
+	values := make([]string, 0, len(idList))
+	for _, pk := range idList {
+		values = append(values, formatPK(pk))
+	}
+	if len(values) == 0 || len(values) > MaxCalcDistanceVectors {
+		return nil, merr.WrapErrParameterInvalidRange(1, MaxCalcDistanceVectors, len(values), "invalid number of ids")
+	}
+	resp, err := NewQueryTask(t.tsAllocator, t.meta, t.segmentManager, &milvuspb.QueryRequest{
+		CollectionName: ids.GetCollectionName(),
+		PartitionNames: ids.GetPartitionNames(),
+		Expr:           fmt.Sprintf("%s in [%s]", pkField.GetName(), strings.Join(values, ", ")),
+		OutputFields:   []string{field.GetName()},
+	}).Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var queried storage.FieldData
+	for _, fieldData := range resp.GetFieldsData() {
+		if fieldData.GetFieldName() == field.GetName() {
+			queried, err = storage.FieldDataFromProto(field, fieldData)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	pks := getPKs(resp.GetFieldsData(), pkField.GetName())
+	offsets := make(map[any]int, len(pks))
+	for i, pk := range pks {
+		offsets[pk] = i
+	}
+
+	ret, err := storage.NewFieldData(field)
+	if err != nil {
+		return nil, err
+	}
+	for _, pk := range idList {
+		offset, ok := offsets[pk]
+		if !ok {
+			return nil, merr.WrapErrParameterInvalidMsg("vector of id %v not found in collection %s", pk, ids.GetCollectionName())
+		}
+		if err := ret.AppendRow(queried.GetRow(offset)); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// vectorDim returns the dim of vectors, 0 if it's not dense vectors
+func vectorDim(vectors storage.FieldData) int {
+	switch v := vectors.(type) {
+	case *storage.FloatVectorFieldData:
+		return v.Dim
+	case *storage.BinaryVectorFieldData:
+		return v.Dim
+	case *storage.Float16VectorFieldData:
+		return v.Dim
+	case *storage.BFloat16VectorFieldData:
+		return v.Dim
+	}
+	return 0
+}
@@ -94,6 +94,8 @@ const (
 	MetricTypeKey  = "metric_type"
 	DimKey         = "dim"
 	MaxLengthKey   = "max_length"
+	// NullableKey is the type param of nullable scalar fields, as FieldSchema has no nullable flag
+	NullableKey = "nullable"
 )
 
 //  Collection properties key
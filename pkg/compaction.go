@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/sharding-db/milvus-mini/pkg/compaction"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+)
+
+// ManualCompaction triggers compaction of the collection, plans are generated even if auto compaction is disabled
+func ManualCompaction(ctx context.Context, meta metas.MetaTable, compactor *compaction.Compactor, req *milvuspb.ManualCompactionRequest) (*milvuspb.ManualCompactionResponse, error) {
+	collection, err := meta.GetCollectionByID(ctx, req.GetCollectionID())
+	if err != nil {
+		return nil, err
+	}
+	compactionID, planNum, err := compactor.Compact(ctx, collection.CollectionID, true)
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.ManualCompactionResponse{
+		CompactionID:        compactionID,
+		CompactionPlanCount: int32(planNum),
+	}, nil
+}
+
+func GetCompactionState(compactor *compaction.Compactor, req *milvuspb.GetCompactionStateRequest) (*milvuspb.GetCompactionStateResponse, error) {
+	state, plans, err := compactor.GetState(req.GetCompactionID())
+	if err != nil {
+		return nil, err
+	}
+	resp := &milvuspb.GetCompactionStateResponse{State: state}
+	for _, plan := range plans {
+		switch {
+		case plan.IsExecuting():
+			resp.ExecutingPlanNo++
+		case plan.IsFailed():
+			resp.FailedPlanNo++
+		default:
+			resp.CompletedPlanNo++
+		}
+	}
+	return resp, nil
+}
+
+func GetCompactionStateWithPlans(compactor *compaction.Compactor, req *milvuspb.GetCompactionPlansRequest) (*milvuspb.GetCompactionPlansResponse, error) {
+	state, plans, err := compactor.GetState(req.GetCompactionID())
+	if err != nil {
+		return nil, err
+	}
+	resp := &milvuspb.GetCompactionPlansResponse{State: state}
+	for _, plan := range plans {
+		resp.MergeInfos = append(resp.MergeInfos, &milvuspb.CompactionMergeInfo{
+			Sources: plan.GetSourceIDs(),
+			Target:  plan.Target,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,313 @@
+package compaction
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	// Interval of auto compaction & gc
+	Interval time.Duration
+	// MaxSegmentSize is the max memory size of merged segment
+	MaxSegmentSize int64
+	// segments smaller than MaxSegmentSize*SmallSegmentRatio are merged
+	SmallSegmentRatio float64
+	// segments with more deleted rows than the ratio are compacted to purge the rows
+	DeleteRatioThreshold float64
+	// StateRetention is how long the state of a finished compaction is kept for querying
+	StateRetention time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:             time.Minute,
+		MaxSegmentSize:       segments.DefaultMaxSegmentSize,
+		SmallSegmentRatio:    0.5,
+		DeleteRatioThreshold: 0.2,
+		StateRetention:       10 * time.Minute,
+	}
+}
+
+// Compactor generates & executes compaction plans, the plans of one compaction are tracked by the compaction id
+// until they're finished for StateRetention. Compactions without plans are not tracked.
+type Compactor struct {
+	lock           sync.RWMutex
+	config         Config
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+	chunkManager   storage.ChunkManager
+	idAllocator    allocator.Interface
+	tsAllocator    allocator.TimestampAllocator
+	compactions    map[UniqueID][]*Plan
+}
+
+func NewCompactor(config Config, meta metas.MetaTable, segmentManager *segments.Manager, cm storage.ChunkManager,
+	idAllocator allocator.Interface, tsAllocator allocator.TimestampAllocator) *Compactor {
+	return &Compactor{
+		config:         config,
+		meta:           meta,
+		segmentManager: segmentManager,
+		chunkManager:   cm,
+		idAllocator:    idAllocator,
+		tsAllocator:    tsAllocator,
+		compactions:    make(map[UniqueID][]*Plan),
+	}
+}
+
+// Start runs auto compaction & gc of dropped segments in background until ctx is done
+func (c *Compactor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.autoCompact(ctx)
+				c.segmentManager.GC(ctx)
+				c.expireStates(time.Now())
+			}
+		}
+	}()
+}
+
+func (c *Compactor) autoCompact(ctx context.Context) {
+	collections, err := c.meta.ListCollections(ctx)
+	if err != nil {
+		log.Warn("failed to list collections for auto compaction", zap.Error(err))
+		return
+	}
+	for _, collection := range collections {
+		if !isAutoCompactionEnabled(collection) {
+			continue
+		}
+		compactionID, planNum, err := c.Compact(ctx, collection.CollectionID, false)
+		if err != nil {
+			log.Warn("auto compaction failed", zap.Int64("collectionID", collection.CollectionID), zap.Error(err))
+			continue
+		}
+		if planNum > 0 {
+			log.Info("auto compaction triggered", zap.Int64("collectionID", collection.CollectionID),
+				zap.Int64("compactionID", compactionID), zap.Int("plans", planNum))
+		}
+	}
+}
+
+// isAutoCompactionEnabled checks the collection property, auto compaction is enabled by default
+func isAutoCompactionEnabled(collection *model.Collection) bool {
+	for _, kv := range collection.Properties {
+		if kv.GetKey() == common.CollectionAutoCompactionKey {
+			enabled, err := strconv.ParseBool(kv.GetValue())
+			if err != nil {
+				log.Warn("invalid auto compaction property", zap.String("value", kv.GetValue()))
+				return true
+			}
+			return enabled
+		}
+	}
+	return true
+}
+
+// Compact generates plans for the collection and executes them in background.
+// It returns the compaction id and the number of plans.
+func (c *Compactor) Compact(ctx context.Context, collectionID UniqueID, manual bool) (UniqueID, int, error) {
+	compactionID, err := c.idAllocator.AllocOne()
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to alloc compaction id")
+	}
+	expireTs, err := c.getExpireTimestamp(ctx, collectionID)
+	if err != nil {
+		return 0, 0, err
+	}
+	plans := make([]*Plan, 0)
+	// planSources are the source segments of each plan, they're only referenced until the plan is executed
+	planSources := make([][]*segments.Segment, 0)
+	for _, sources := range generatePlans(c.config, c.segmentManager.GetFlushedSegments(collectionID), expireTs, manual) {
+		if !c.segmentManager.MarkCompacting(sources) {
+			continue
+		}
+		ids, _, err := c.idAllocator.Alloc(2)
+		if err != nil {
+			c.segmentManager.UnmarkCompacting(sources)
+			for _, sources := range planSources {
+				c.segmentManager.UnmarkCompacting(sources)
+			}
+			return 0, 0, errors.Wrap(err, "failed to alloc plan id")
+		}
+		sourceIDs := make([]UniqueID, 0, len(sources))
+		for _, source := range sources {
+			sourceIDs = append(sourceIDs, source.ID())
+		}
+		plans = append(plans, &Plan{
+			PlanID:    ids,
+			Target:    ids + 1,
+			Sources:   sourceIDs,
+			StartTime: time.Now(),
+			state:     planExecuting,
+		})
+		planSources = append(planSources, sources)
+	}
+	if len(plans) == 0 {
+		return compactionID, 0, nil
+	}
+
+	c.lock.Lock()
+	c.compactions[compactionID] = plans
+	c.lock.Unlock()
+
+	go func() {
+		for i, plan := range plans {
+			c.execute(context.Background(), collectionID, plan, planSources[i])
+		}
+	}()
+	return compactionID, len(plans), nil
+}
+
+func (c *Compactor) execute(ctx context.Context, collectionID UniqueID, plan *Plan, sources []*segments.Segment) {
+	err := c.executePlan(ctx, collectionID, plan, sources)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	plan.EndTime = time.Now()
+	if err != nil {
+		log.Warn("compaction plan failed", zap.Int64("planID", plan.PlanID), zap.Int64s("sources", plan.GetSourceIDs()), zap.Error(err))
+		c.segmentManager.UnmarkCompacting(sources)
+		plan.state = planFailed
+		return
+	}
+	log.Info("compaction plan completed", zap.Int64("planID", plan.PlanID),
+		zap.Int64s("sources", plan.GetSourceIDs()), zap.Int64("target", plan.Target), zap.Duration("elapse", time.Since(plan.StartTime)))
+	plan.state = planCompleted
+}
+
+// getExpireTimestamp returns the timestamp before which rows are expired, it's 0 if the collection has no ttl
+func (c *Compactor) getExpireTimestamp(ctx context.Context, collectionID UniqueID) (Timestamp, error) {
+	collection, err := c.meta.GetCollectionByID(ctx, collectionID)
+	if err != nil {
+		return 0, err
+	}
+	ts, err := c.tsAllocator.AllocTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	return segments.GetExpireTimestamp(collection, ts)
+}
+
+func (c *Compactor) executePlan(ctx context.Context, collectionID UniqueID, plan *Plan, sources []*segments.Segment) error {
+	// the ttl may be changed after the plan is generated, rows expired now are purged
+	expireTs, err := c.getExpireTimestamp(ctx, collectionID)
+	if err != nil {
+		return err
+	}
+	schema := sources[0].Schema()
+	data, err := storage.NewInsertData(schema)
+	if err != nil {
+		return err
+	}
+	deleteNums := make([]int, 0, len(sources))
+	for _, source := range sources {
+		view, err := c.segmentManager.ReadView(ctx, source, expireTs, 0)
+		if err != nil {
+			return err
+		}
+		if err := view.LoadFields(ctx); err != nil {
+			return err
+		}
+		deleteNums = append(deleteNums, view.GetDeleteNum())
+		for fieldID, fieldData := range data.Data {
+			column := view.Data.Data[fieldID]
+			for i := 0; i < view.RowNum; i++ {
+				if view.Deleted[i] {
+					continue
+				}
+				if err := fieldData.AppendRow(column.GetRow(i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	var target *segments.Segment
+	if data.GetRowNum() > 0 {
+		meta := &model.Segment{
+			SegmentID:      plan.Target,
+			CollectionID:   collectionID,
+			PartitionID:    sources[0].PartitionID(),
+			State:          commonpb.SegmentState_Flushed,
+			NumOfRows:      int64(data.GetRowNum()),
+			CompactionFrom: plan.GetSourceIDs(),
+		}
+		meta.Binlogs, err = segments.WriteBinlogs(ctx, c.chunkManager, c.idAllocator, schema, meta, data)
+		if err != nil {
+			return err
+		}
+		target, err = segments.NewFlushedSegment(meta, schema, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	ts, err := c.tsAllocator.AllocTimestamp()
+	if err != nil {
+		return err
+	}
+	if err := c.segmentManager.SwapSegments(ctx, sources, deleteNums, target, ts); err != nil {
+		return err
+	}
+	// the target is searched by brute force until its indexes are built
+	if target != nil {
+		if err := c.segmentManager.BuildSegmentIndexes(ctx, target); err != nil {
+			log.Warn("failed to build indexes of compacted segment", zap.Int64("segmentID", target.ID()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// GetState returns the state of compaction and its plans. Compactions not tracked, which have no plans
+// or finished before the retention, are completed without plans, same as milvus.
+func (c *Compactor) GetState(compactionID UniqueID) (commonpb.CompactionState, []*Plan, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	plans := c.compactions[compactionID]
+	state := commonpb.CompactionState_Completed
+	ret := make([]*Plan, 0, len(plans))
+	for _, plan := range plans {
+		if plan.state == planExecuting {
+			state = commonpb.CompactionState_Executing
+		}
+		clone := *plan
+		ret = append(ret, &clone)
+	}
+	return state, ret, nil
+}
+
+// expireStates removes the states of compactions whose plans all finished before the retention
+func (c *Compactor) expireStates(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for compactionID, plans := range c.compactions {
+		expired := true
+		for _, plan := range plans {
+			if plan.state == planExecuting || now.Sub(plan.EndTime) < c.config.StateRetention {
+				expired = false
+				break
+			}
+		}
+		if expired {
+			delete(c.compactions, compactionID)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package compaction
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCollection() *model.Collection {
+	return &model.Collection{
+		CollectionID: 1,
+		Name:         "test",
+		Fields: []*model.Field{
+			{FieldID: common.RowIDField, Name: common.RowIDFieldName, DataType: schemapb.DataType_Int64},
+			{FieldID: common.TimeStampField, Name: common.TimeStampFieldName, DataType: schemapb.DataType_Int64},
+			{FieldID: 100, Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+			{FieldID: 101, Name: "vec", DataType: schemapb.DataType_FloatVector,
+				TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}},
+		},
+		Partitions: []*model.Partition{{PartitionID: 10, PartitionName: "default", CollectionID: 1}},
+	}
+}
+
+func newTestInsertData(start, num int64, ts allocator.Timestamp) *storage.InsertData {
+	pks := make([]int64, 0, num)
+	tss := make([]int64, 0, num)
+	vectors := make([]float32, 0, num*2)
+	for i := start; i < start+num; i++ {
+		pks = append(pks, i)
+		tss = append(tss, int64(ts))
+		vectors = append(vectors, float32(i), float32(i))
+	}
+	return &storage.InsertData{Data: map[storage.FieldID]storage.FieldData{
+		common.RowIDField:     &storage.Int64FieldData{Data: pks},
+		common.TimeStampField: &storage.Int64FieldData{Data: tss},
+		100:                   &storage.Int64FieldData{Data: pks},
+		101:                   &storage.FloatVectorFieldData{Data: vectors, Dim: 2},
+	}}
+}
+
+func TestCompactor(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	meta, err := metas.NewLocalDiskWithMemoryCacheMeta(ctx, rootPath)
+	assert.NoError(t, err)
+	collection := newTestCollection()
+	assert.NoError(t, meta.AddCollection(ctx, collection))
+	cm, err := storage.NewLocalChunkManager(filepath.Join(rootPath, "data"))
+	assert.NoError(t, err)
+
+	idAllocator := new(allocator.LocalTsAllocator)
+	tsAllocator := new(allocator.LocalTimestampAllocator)
+	manager, err := segments.NewManager(ctx, segments.DefaultConfig(), meta, cm, idAllocator)
+	assert.NoError(t, err)
+	compactor := NewCompactor(DefaultConfig(), meta, manager, cm, idAllocator, tsAllocator)
+	assert.NoError(t, manager.Load(ctx, collection, nil))
+
+	// 3 flushed segments of 10 rows
+	for i := int64(0); i < 3; i++ {
+		ts, err := tsAllocator.AllocTimestamp()
+		assert.NoError(t, err)
+		assert.NoError(t, manager.Insert(ctx, collection, 10, newTestInsertData(i*10, 10, ts)))
+		_, err = manager.Flush(ctx, collection.CollectionID)
+		assert.NoError(t, err)
+	}
+	ts, err := tsAllocator.AllocTimestamp()
+	assert.NoError(t, err)
+	pks := make([]storage.PrimaryKey, 0)
+	for i := int64(0); i < 5; i++ {
+		pks = append(pks, storage.NewInt64PrimaryKey(i))
+	}
+	manager.Delete(ctx, collection.CollectionID, nil, pks, ts)
+
+	snapshot := manager.Acquire(collection.CollectionID, nil)
+	assert.Len(t, snapshot.Segments, 3)
+
+	compactionID, planNum, err := compactor.Compact(ctx, collection.CollectionID, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, planNum)
+	assert.Eventually(t, func() bool {
+		state, _, err := compactor.GetState(compactionID)
+		return err == nil && state == commonpb.CompactionState_Completed
+	}, 10*time.Second, 10*time.Millisecond)
+
+	_, plans, err := compactor.GetState(compactionID)
+	assert.NoError(t, err)
+	assert.Len(t, plans[0].Sources, 3)
+	flushed := manager.GetFlushedSegments(collection.CollectionID)
+	assert.Len(t, flushed, 1)
+	assert.Equal(t, plans[0].Target, flushed[0].ID())
+	assert.Equal(t, int64(25), flushed[0].RowNum())
+
+	// the snapshot acquired before compaction is not affected
+	for _, segment := range snapshot.Segments {
+		view, err := segment.View(0)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, view.RowNum)
+	}
+	manager.GC(ctx)
+	assert.NotNil(t, manager.GetSegment(plans[0].Sources[0]))
+	snapshot.Release()
+	manager.GC(ctx)
+	assert.Nil(t, manager.GetSegment(plans[0].Sources[0]))
+
+	// states of finished compactions expire after the retention, compactions without plans are not tracked
+	compactor.expireStates(time.Now())
+	assert.Len(t, compactor.compactions, 1)
+	compactor.expireStates(time.Now().Add(DefaultConfig().StateRetention))
+	assert.Empty(t, compactor.compactions)
+	state, plans, err := compactor.GetState(compactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, commonpb.CompactionState_Completed, state)
+	assert.Empty(t, plans)
+	_, planNum, err = compactor.Compact(ctx, collection.CollectionID, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, planNum)
+	assert.Empty(t, compactor.compactions)
+
+	// recover from meta & binlogs, the collection is loaded again
+	manager, err = segments.NewManager(ctx, segments.DefaultConfig(), meta, cm, idAllocator)
+	assert.NoError(t, err)
+	flushed = manager.GetFlushedSegments(collection.CollectionID)
+	assert.Len(t, flushed, 1)
+	assert.Equal(t, int64(25), flushed[0].RowNum())
+	assert.Eventually(t, func() bool {
+		return manager.CheckLoaded(collection.CollectionID, nil) == nil
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.True(t, flushed[0].InMemory())
+
+	assert.NoError(t, manager.Release(ctx, collection.CollectionID, nil))
+	assert.False(t, flushed[0].InMemory())
+	assert.Error(t, manager.CheckLoaded(collection.CollectionID, nil))
+}
+
+func TestIsAutoCompactionEnabled(t *testing.T) {
+	collection := newTestCollection()
+	assert.True(t, isAutoCompactionEnabled(collection))
+	collection.Properties = []*commonpb.KeyValuePair{{Key: common.CollectionAutoCompactionKey, Value: "false"}}
+	assert.False(t, isAutoCompactionEnabled(collection))
+}
+
+func TestCompactorTTL(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	meta, err := metas.NewLocalDiskWithMemoryCacheMeta(ctx, rootPath)
+	assert.NoError(t, err)
+	collection := newTestCollection()
+	assert.NoError(t, meta.AddCollection(ctx, collection))
+	cm, err := storage.NewLocalChunkManager(filepath.Join(rootPath, "data"))
+	assert.NoError(t, err)
+
+	idAllocator := new(allocator.LocalTsAllocator)
+	tsAllocator := new(allocator.LocalTimestampAllocator)
+	manager, err := segments.NewManager(ctx, segments.DefaultConfig(), meta, cm, idAllocator)
+	assert.NoError(t, err)
+	compactor := NewCompactor(DefaultConfig(), meta, manager, cm, idAllocator, tsAllocator)
+
+	oldTs := tsoutil.ComposeTSByTime(time.Now().Add(-time.Hour), 0)
+	assert.NoError(t, manager.Insert(ctx, collection, 10, newTestInsertData(0, 10, oldTs)))
+	_, err = manager.Flush(ctx, collection.CollectionID)
+	assert.NoError(t, err)
+	ts, err := tsAllocator.AllocTimestamp()
+	assert.NoError(t, err)
+	assert.NoError(t, manager.Insert(ctx, collection, 10, newTestInsertData(10, 10, ts)))
+	_, err = manager.Flush(ctx, collection.CollectionID)
+	assert.NoError(t, err)
+
+	// rows are visible until ttl is set
+	expireTs, err := segments.GetExpireTimestamp(collection, ts)
+	assert.NoError(t, err)
+	assert.Equal(t, Timestamp(0), expireTs)
+
+	altered := collection.Clone()
+	altered.Properties = []*commonpb.KeyValuePair{{Key: common.CollectionTTLConfigKey, Value: "60"}}
+	assert.NoError(t, meta.AlterCollection(ctx, altered))
+	expireTs, err = segments.GetExpireTimestamp(altered, ts)
+	assert.NoError(t, err)
+	visible := 0
+	for _, segment := range manager.GetFlushedSegments(collection.CollectionID) {
+		view, err := manager.ReadView(ctx, segment, expireTs, 0)
+		assert.NoError(t, err)
+		for _, deleted := range view.Deleted {
+			if !deleted {
+				visible++
+			}
+		}
+	}
+	assert.Equal(t, 10, visible)
+
+	compactionID, planNum, err := compactor.Compact(ctx, collection.CollectionID, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, planNum)
+	assert.Eventually(t, func() bool {
+		state, _, err := compactor.GetState(compactionID)
+		return err == nil && state == commonpb.CompactionState_Completed
+	}, 10*time.Second, 10*time.Millisecond)
+	flushed := manager.GetFlushedSegments(collection.CollectionID)
+	assert.Len(t, flushed, 1)
+	assert.Equal(t, int64(10), flushed[0].RowNum())
+}
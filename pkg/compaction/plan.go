@@ -0,0 +1,105 @@
+package compaction
+
+import (
+	"sort"
+	"time"
+
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+)
+
+// UniqueID is an alias of typeutil.UniqueID.
+type UniqueID = typeutil.UniqueID
+
+// Timestamp is an alias of typeutil.Timestamp
+type Timestamp = typeutil.Timestamp
+
+type planState int
+
+const (
+	planExecuting planState = iota
+	planCompleted
+	planFailed
+)
+
+// Plan compacts the source segments of one partition into the target segment,
+// deleted rows are purged, and the target is not created if all rows are deleted.
+// Sources are the segment ids, so the dropped segments aren't kept reachable by the states of compactions.
+type Plan struct {
+	PlanID    UniqueID
+	Sources   []UniqueID
+	Target    UniqueID
+	StartTime time.Time
+	// EndTime is set once the plan completed or failed
+	EndTime time.Time
+
+	state planState
+}
+
+func (p *Plan) GetSourceIDs() []UniqueID {
+	return p.Sources
+}
+
+// IsExecuting returns whether the plan is executing
+func (p *Plan) IsExecuting() bool {
+	return p.state == planExecuting
+}
+
+// IsFailed returns whether the plan failed
+func (p *Plan) IsFailed() bool {
+	return p.state == planFailed
+}
+
+// generatePlans generates plans for the flushed segments of a collection:
+// small segments of the same partition are merged as long as the result isn't larger than max segment size,
+// other segments with too many deleted or expired rows are compacted alone to purge the rows.
+// Deleted & expired rows are purged by all plans, manual compaction purges all segments with such rows.
+func generatePlans(config Config, flushed []*segments.Segment, expireTs Timestamp, manual bool) [][]*segments.Segment {
+	ret := make([][]*segments.Segment, 0)
+	partitions := make(map[UniqueID][]*segments.Segment)
+	needPurge := func(segment *segments.Segment) bool {
+		deleted := segment.DeletedRowNum() + segment.ExpiredRowNum(expireTs)
+		rows := segment.RowNum()
+		return deleted > 0 && (manual || rows == 0 || float64(deleted)/float64(rows) >= config.DeleteRatioThreshold)
+	}
+	for _, segment := range flushed {
+		if float64(segment.MemorySize()) < float64(config.MaxSegmentSize)*config.SmallSegmentRatio {
+			partitions[segment.PartitionID()] = append(partitions[segment.PartitionID()], segment)
+			continue
+		}
+		if needPurge(segment) {
+			ret = append(ret, []*segments.Segment{segment})
+		}
+	}
+
+	for _, small := range partitions {
+		sort.Slice(small, func(i, j int) bool {
+			return small[i].MemorySize() > small[j].MemorySize()
+		})
+		// first fit decreasing
+		bins := make([][]*segments.Segment, 0)
+		sizes := make([]int64, 0)
+		for _, segment := range small {
+			size := segment.MemorySize()
+			fit := false
+			for i := range bins {
+				if sizes[i]+size <= config.MaxSegmentSize {
+					bins[i] = append(bins[i], segment)
+					sizes[i] += size
+					fit = true
+					break
+				}
+			}
+			if !fit {
+				bins = append(bins, []*segments.Segment{segment})
+				sizes = append(sizes, size)
+			}
+		}
+		for _, bin := range bins {
+			if len(bin) > 1 || needPurge(bin[0]) {
+				ret = append(ret, bin)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"context"
+	"time"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+)
+
+// DefaultBoundedStaleness is the staleness window of Bounded consistency, same as the graceful time of milvus
+const DefaultBoundedStaleness = 5 * time.Second
+
+// consistencyRequest is the consistency fields of search, query and hybrid search requests
+type consistencyRequest interface {
+	GetConsistencyLevel() commonpb.ConsistencyLevel
+	GetUseDefaultConsistency() bool
+	GetGuaranteeTimestamp() uint64
+	GetTravelTimestamp() uint64
+}
+
+// readTimestamp waits for the writes before the guarantee timestamp of the consistency level and returns the
+// timestamp to read at, which is the travel timestamp if set or the latest timestamp otherwise.
+// The level of the collection is used if the request uses the default consistency. The guarantee timestamp is
+//   - Strong: the latest timestamp, all writes done before the read are seen
+//   - Bounded: the latest timestamp minus DefaultBoundedStaleness
+//   - Session & Customized: the guarantee timestamp of request, e.g. the timestamp of the last write of the client
+//   - Eventually: none, nothing is waited for
+func readTimestamp(ctx context.Context, tsAllocator allocator.TimestampAllocator, segmentManager *segments.Manager,
+	collection *model.Collection, req consistencyRequest) (Timestamp, error) {
+	ts, err := tsAllocator.AllocTimestamp()
+	if err != nil {
+		return 0, merr.WrapErrServiceUnavailable(err.Error())
+	}
+	readTs := ts
+	if travelTs := req.GetTravelTimestamp(); travelTs > 0 {
+		if travelTs > ts {
+			return 0, merr.WrapErrParameterInvalidMsg("travel timestamp %d is later than the current timestamp %d", travelTs, ts)
+		}
+		readTs = travelTs
+	}
+
+	level := req.GetConsistencyLevel()
+	if req.GetUseDefaultConsistency() {
+		level = collection.ConsistencyLevel
+	}
+	var guaranteeTs Timestamp
+	switch level {
+	case commonpb.ConsistencyLevel_Strong:
+		guaranteeTs = ts
+	case commonpb.ConsistencyLevel_Bounded:
+		guaranteeTs = tsoutil.AddPhysicalDurationOnTs(ts, -DefaultBoundedStaleness)
+	case commonpb.ConsistencyLevel_Session, commonpb.ConsistencyLevel_Customized:
+		guaranteeTs = req.GetGuaranteeTimestamp()
+	case commonpb.ConsistencyLevel_Eventually:
+		return readTs, nil
+	default:
+		return 0, merr.WrapErrParameterInvalidMsg("unsupported consistency level %s", level.String())
+	}
+	// writes after the read timestamp are invisible anyway
+	if guaranteeTs > readTs {
+		guaranteeTs = readTs
+	}
+	if guaranteeTs == 0 {
+		return readTs, nil
+	}
+	if err := segmentManager.WaitWrites(ctx, guaranteeTs); err != nil {
+		return 0, err
+	}
+	return readTs, nil
+}
@@ -3,7 +3,6 @@ package pkg
 import (
 	"context"
 	"fmt"
-	"math"
 	"sync"
 	"time"
 
@@ -19,9 +18,23 @@ import (
 	pb "github.com/sharding-db/milvus-mini/pkg/etcdpb"
 	"github.com/sharding-db/milvus-mini/pkg/metas"
 	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
 	"go.uber.org/zap"
 )
 
+const (
+	// MaxNameLength is the max length of collection & field names, same as milvus
+	MaxNameLength = 255
+	// MaxFieldNum is the max number of fields of a collection, same as milvus
+	MaxFieldNum = 64
+	// MaxDim is the max dim of vector fields, same as milvus
+	MaxDim = 32768
+	// MaxVarCharLength is the max max_length of VarChar fields, same as milvus
+	MaxVarCharLength = 65535
+	// MaxPartitionNum is the max number of partitions of a collection, same as milvus
+	MaxPartitionNum = 4096
+)
+
 type CreateCollectionTask struct {
 	idAllocator allocator.Interface
 	meta        metas.MetaTable
@@ -85,6 +98,9 @@ func (t CreateCollectionTask) Execute(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := checkProperties(request.GetProperties()); err != nil {
+		return err
+	}
 
 	ts := uint64(time.Now().Unix())
 
@@ -124,6 +140,9 @@ func (t CreateCollectionTask) assignPartitions(request *milvuspb.CreateCollectio
 	_, err := typeutil.GetPartitionKeyFieldSchema(schema)
 	if err == nil {
 		partitionNums := request.GetNumPartitions()
+		if partitionNums == 0 {
+			partitionNums = common.DefaultPartitionsWithPartitionKey
+		}
 		// double check, default num of physical partitions should be greater than 0
 		if partitionNums <= 0 {
 			return nil, merr.WrapErrParameterInvalidMsg("the specified partitions should be greater than 0 if partition key is used")
@@ -169,16 +188,193 @@ func prepareSchema(request *milvuspb.CreateCollectionRequest) (*schemapb.Collect
 		return nil, merr.WrapErrParameterInvalid(schema.GetName(), request.GetCollectionName(), "collection name matches schema name")
 	}
 
-	err := checkDefaultValue(&schema)
+	if err := fillElementTypes(&schema); err != nil {
+		return nil, err
+	}
+	if err := validateSchema(&schema, request.GetNumPartitions()); err != nil {
+		return nil, err
+	}
+	// autoID of collection follows the primary key, which is checked by upsert
+	pkField, err := typeutil.GetPrimaryFieldSchema(&schema)
 	if err != nil {
 		return nil, err
 	}
+	schema.AutoID = pkField.GetAutoID()
+	if err := checkDefaultValue(&schema); err != nil {
+		return nil, err
+	}
 	appendDynamicField(&schema)
 	assignFieldID(&schema)
 	appendSysFields(&schema)
 	return &schema, nil
 }
 
+// validateSchema checks the schema the same as milvus: names are legal & unique, there's exactly one primary key
+// of Int64 or VarChar, vectors have valid dim, VarChar has valid max_length and at most one partition key exists.
+func validateSchema(schema *schemapb.CollectionSchema, numPartitions int64) error {
+	if err := validateName(schema.GetName(), "collection"); err != nil {
+		return err
+	}
+	if len(schema.GetFields()) > MaxFieldNum {
+		return merr.WrapErrParameterInvalidRange(1, MaxFieldNum, len(schema.GetFields()), "too many fields")
+	}
+	names := make(map[string]struct{}, len(schema.GetFields()))
+	var pkField, partitionKeyField *schemapb.FieldSchema
+	vectorNum := 0
+	for _, field := range schema.GetFields() {
+		name := field.GetName()
+		if err := validateName(name, "field"); err != nil {
+			return err
+		}
+		if _, ok := names[name]; ok {
+			return merr.WrapErrParameterInvalidMsg("duplicated field name %s", name)
+		}
+		names[name] = struct{}{}
+		if name == common.MetaFieldName || name == common.RowIDFieldName || name == common.TimeStampFieldName || field.GetIsDynamic() {
+			return merr.WrapErrParameterInvalidMsg("field name %s is reserved", name)
+		}
+		if field.GetAutoID() && !field.GetIsPrimaryKey() {
+			return merr.WrapErrParameterInvalidMsg("only primary key field can be autoID, but %s is not", name)
+		}
+		if field.GetIsPrimaryKey() {
+			if pkField != nil {
+				return merr.WrapErrParameterInvalidMsg("there're multiple primary keys %s and %s", pkField.GetName(), name)
+			}
+			if field.GetDataType() != schemapb.DataType_Int64 && field.GetDataType() != schemapb.DataType_VarChar {
+				return merr.WrapErrParameterInvalidMsg("primary key %s of %s is not supported, it must be Int64 or VarChar",
+					name, field.GetDataType().String())
+			}
+			pkField = field
+		}
+		if field.GetIsPartitionKey() {
+			if partitionKeyField != nil {
+				return merr.WrapErrParameterInvalidMsg("there're multiple partition keys %s and %s", partitionKeyField.GetName(), name)
+			}
+			if field.GetIsPrimaryKey() {
+				return merr.WrapErrParameterInvalidMsg("primary key %s can't be the partition key", name)
+			}
+			if field.GetDataType() != schemapb.DataType_Int64 && field.GetDataType() != schemapb.DataType_VarChar {
+				return merr.WrapErrParameterInvalidMsg("partition key %s of %s is not supported, it must be Int64 or VarChar",
+					name, field.GetDataType().String())
+			}
+			if numPartitions < 0 || numPartitions > MaxPartitionNum {
+				return merr.WrapErrParameterInvalidRange(1, MaxPartitionNum, numPartitions, "invalid number of partitions")
+			}
+			partitionKeyField = field
+		}
+		if err := validateFieldType(field); err != nil {
+			return err
+		}
+		if err := validateNullable(field); err != nil {
+			return err
+		}
+		if storage.IsVectorType(field.GetDataType()) {
+			vectorNum++
+		}
+	}
+	if pkField == nil {
+		return merr.WrapErrParameterInvalidMsg("primary key is not specified")
+	}
+	if schema.GetAutoID() && !pkField.GetAutoID() {
+		return merr.WrapErrParameterInvalidMsg("autoID of collection is set but primary key %s is not autoID", pkField.GetName())
+	}
+	if vectorNum == 0 {
+		return merr.WrapErrParameterInvalidMsg("schema does not contain vector field")
+	}
+	return nil
+}
+
+// validateName checks the name of collection or field, it must start with a letter or underscore
+// and contain only letters, numbers and underscores.
+func validateName(name string, kind string) error {
+	if name == "" {
+		return merr.WrapErrParameterInvalidMsg("%s name should not be empty", kind)
+	}
+	if len(name) > MaxNameLength {
+		return merr.WrapErrParameterInvalidMsg("the length of %s name %s exceeds max length %d", kind, name, MaxNameLength)
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return merr.WrapErrParameterInvalidMsg("invalid %s name %s, the name can only contain numbers, letters and underscores "+
+			"and must start with a letter or underscore", kind, name)
+	}
+	return nil
+}
+
+// validateFieldType checks the data type and the type params of field
+func validateFieldType(field *schemapb.FieldSchema) error {
+	dataType := field.GetDataType()
+	switch {
+	case storage.IsSparseVectorType(dataType):
+		for _, kv := range field.GetTypeParams() {
+			if kv.GetKey() == common.DimKey {
+				return merr.WrapErrParameterInvalidMsg("%s of sparse vector field %s should not be specified", common.DimKey, field.GetName())
+			}
+		}
+	case storage.IsVectorType(dataType):
+		dim, err := storage.GetDim(field)
+		if err != nil {
+			return merr.WrapErrParameterInvalidMsg("%s of vector field %s is invalid, %s", common.DimKey, field.GetName(), err.Error())
+		}
+		if dim <= 0 || dim > MaxDim {
+			return merr.WrapErrParameterInvalidRange(1, MaxDim, dim, "invalid dim of vector field "+field.GetName())
+		}
+		if dataType == schemapb.DataType_BinaryVector && dim%8 != 0 {
+			return merr.WrapErrParameterInvalidMsg("dim %d of binary vector field %s should be multiple of 8", dim, field.GetName())
+		}
+	case typeutil.IsStringType(dataType):
+		if dataType != schemapb.DataType_VarChar {
+			return merr.WrapErrParameterInvalidMsg("%s of field %s is not supported, use VarChar instead", dataType.String(), field.GetName())
+		}
+		maxLength, err := parameterutil.GetMaxLength(field)
+		if err != nil {
+			return merr.WrapErrParameterInvalidMsg("%s of VarChar field %s is invalid, %s", common.MaxLengthKey, field.GetName(), err.Error())
+		}
+		if maxLength <= 0 || maxLength > MaxVarCharLength {
+			return merr.WrapErrParameterInvalidRange(1, MaxVarCharLength, maxLength, "invalid max_length of field "+field.GetName())
+		}
+	case dataType == schemapb.DataType_Array:
+		elementType := field.GetElementType()
+		if elementType == schemapb.DataType_Array || elementType == schemapb.DataType_JSON || elementType == schemapb.DataType_None ||
+			elementType == schemapb.DataType_String || storage.IsVectorType(elementType) {
+			return merr.WrapErrParameterInvalidMsg("element type %s of array field %s is not supported", elementType.String(), field.GetName())
+		}
+		capacity, err := getMaxCapacity(field)
+		if err != nil {
+			return err
+		}
+		if capacity <= 0 || capacity > MaxArrayCapacity {
+			return merr.WrapErrParameterInvalidRange(1, MaxArrayCapacity, capacity, "invalid max_capacity of array field "+field.GetName())
+		}
+		if elementType == schemapb.DataType_VarChar {
+			return validateFieldType(elementField(field))
+		}
+	case dataType == schemapb.DataType_None:
+		return merr.WrapErrParameterInvalidMsg("data type of field %s is not specified", field.GetName())
+	}
+	return nil
+}
+
+// validateNullable checks the nullable type param, primary keys, partition keys and vectors can't be nullable
+func validateNullable(field *schemapb.FieldSchema) error {
+	nullable, err := storage.ParseNullable(field)
+	if err != nil {
+		return merr.WrapErrParameterInvalidMsg(err.Error())
+	}
+	switch {
+	case !nullable:
+	case field.GetIsPrimaryKey():
+		return merr.WrapErrParameterInvalidMsg("primary key %s can't be nullable", field.GetName())
+	case field.GetIsPartitionKey():
+		return merr.WrapErrParameterInvalidMsg("partition key %s can't be nullable", field.GetName())
+	case storage.IsVectorType(field.GetDataType()):
+		return merr.WrapErrParameterInvalidMsg("vector field %s can't be nullable", field.GetName())
+	}
+	return nil
+}
+
 func appendDynamicField(schema *schemapb.CollectionSchema) {
 	if schema.EnableDynamicField {
 		schema.Fields = append(schema.Fields, &schemapb.FieldSchema{
@@ -212,62 +408,3 @@ func appendSysFields(schema *schemapb.CollectionSchema) {
 		DataType:     schemapb.DataType_Int64,
 	})
 }
-
-func checkDefaultValue(schema *schemapb.CollectionSchema) error {
-	for _, fieldSchema := range schema.Fields {
-		if fieldSchema.GetDefaultValue() != nil {
-			switch fieldSchema.GetDefaultValue().Data.(type) {
-			case *schemapb.ValueField_BoolData:
-				if fieldSchema.GetDataType() != schemapb.DataType_Bool {
-					return merr.WrapErrParameterInvalid("DataType_Bool", "not match", "default value type mismatches field schema type")
-				}
-			case *schemapb.ValueField_IntData:
-				if fieldSchema.GetDataType() != schemapb.DataType_Int32 &&
-					fieldSchema.GetDataType() != schemapb.DataType_Int16 &&
-					fieldSchema.GetDataType() != schemapb.DataType_Int8 {
-					return merr.WrapErrParameterInvalid("DataType_Int", "not match", "default value type mismatches field schema type")
-				}
-				defaultValue := fieldSchema.GetDefaultValue().GetIntData()
-				if fieldSchema.GetDataType() == schemapb.DataType_Int16 {
-					if defaultValue > math.MaxInt16 || defaultValue < math.MinInt16 {
-						return merr.WrapErrParameterInvalidRange(math.MinInt16, math.MaxInt16, defaultValue, "default value out of range")
-					}
-				}
-				if fieldSchema.GetDataType() == schemapb.DataType_Int8 {
-					if defaultValue > math.MaxInt8 || defaultValue < math.MinInt8 {
-						return merr.WrapErrParameterInvalidRange(math.MinInt8, math.MaxInt8, defaultValue, "default value out of range")
-					}
-				}
-			case *schemapb.ValueField_LongData:
-				if fieldSchema.GetDataType() != schemapb.DataType_Int64 {
-					return merr.WrapErrParameterInvalid("DataType_Int64", "not match", "default value type mismatches field schema type")
-				}
-			case *schemapb.ValueField_FloatData:
-				if fieldSchema.GetDataType() != schemapb.DataType_Float {
-					return merr.WrapErrParameterInvalid("DataType_Float", "not match", "default value type mismatches field schema type")
-				}
-			case *schemapb.ValueField_DoubleData:
-				if fieldSchema.GetDataType() != schemapb.DataType_Double {
-					return merr.WrapErrParameterInvalid("DataType_Double", "not match", "default value type mismatches field schema type")
-				}
-			case *schemapb.ValueField_StringData:
-				if fieldSchema.GetDataType() != schemapb.DataType_VarChar {
-					return merr.WrapErrParameterInvalid("DataType_VarChar", "not match", "default value type mismatches field schema type")
-				}
-				maxLength, err := parameterutil.GetMaxLength(fieldSchema)
-				if err != nil {
-					return err
-				}
-				defaultValueLength := len(fieldSchema.GetDefaultValue().GetStringData())
-				if int64(defaultValueLength) > maxLength {
-					msg := fmt.Sprintf("the length (%d) of string exceeds max length (%d)", defaultValueLength, maxLength)
-					return merr.WrapErrParameterInvalid("valid length string", "string length exceeds max length", msg)
-				}
-			default:
-				panic("default value unsupport data type")
-			}
-		}
-	}
-
-	return nil
-}
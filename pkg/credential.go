@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"unicode"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus/pkg/util"
+	"github.com/milvus-io/milvus/pkg/util/crypto"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/internalpb"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MaxUsernameLength is the max length of username & role name, same as milvus
+	MaxUsernameLength = 32
+	// MinPasswordLength & MaxPasswordLength are the bounds of password length, same as milvus
+	MinPasswordLength = 6
+	MaxPasswordLength = 256
+)
+
+// CreateCredential creates the user, the password of request is encoded by base64 as SDKs send it
+func CreateCredential(ctx context.Context, meta metas.MetaTable, req *milvuspb.CreateCredentialRequest) error {
+	if err := validateEntityName(req.GetUsername(), "user"); err != nil {
+		return err
+	}
+	password, err := decodePassword(req.GetPassword())
+	if err != nil {
+		return err
+	}
+	if _, err := meta.GetCredential(ctx, req.GetUsername()); err == nil {
+		return merr.WrapErrParameterInvalidMsg("user %s already exists", req.GetUsername())
+	}
+	encrypted, err := crypto.PasswordEncrypt(password)
+	if err != nil {
+		return merr.WrapErrServiceInternal(err.Error())
+	}
+	return meta.SaveCredential(ctx, &internalpb.CredentialInfo{Username: req.GetUsername(), EncryptedPassword: encrypted})
+}
+
+// UpdateCredential changes the password of user, the old password must be correct
+func UpdateCredential(ctx context.Context, meta metas.MetaTable, req *milvuspb.UpdateCredentialRequest) error {
+	credential, err := meta.GetCredential(ctx, req.GetUsername())
+	if err != nil {
+		return err
+	}
+	oldPassword, err := crypto.Base64Decode(req.GetOldPassword())
+	if err != nil {
+		return merr.WrapErrParameterInvalidMsg("invalid old password of user %s, it must be encoded by base64", req.GetUsername())
+	}
+	if !verifyPassword(credential, oldPassword) {
+		return merr.WrapErrParameterInvalidMsg("old password of user %s is not correct", req.GetUsername())
+	}
+	password, err := decodePassword(req.GetNewPassword())
+	if err != nil {
+		return err
+	}
+	credential.EncryptedPassword, err = crypto.PasswordEncrypt(password)
+	if err != nil {
+		return merr.WrapErrServiceInternal(err.Error())
+	}
+	return meta.SaveCredential(ctx, credential)
+}
+
+// DeleteCredential deletes the user, root can't be deleted
+func DeleteCredential(ctx context.Context, meta metas.MetaTable, req *milvuspb.DeleteCredentialRequest) error {
+	if req.GetUsername() == util.UserRoot {
+		return merr.WrapErrParameterInvalidMsg("user %s can't be deleted", util.UserRoot)
+	}
+	if _, err := meta.GetCredential(ctx, req.GetUsername()); err != nil {
+		return err
+	}
+	return meta.RemoveCredential(ctx, req.GetUsername())
+}
+
+// ListCredUsers returns the usernames in order
+func ListCredUsers(ctx context.Context, meta metas.MetaTable) (*milvuspb.ListCredUsersResponse, error) {
+	usernames, err := meta.ListCredentialUsernames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.ListCredUsersResponse{Status: merr.Status(nil), Usernames: usernames}, nil
+}
+
+// validateEntityName checks the name of user or role starts with a letter and consists of letters, digits and underscores
+func validateEntityName(name string, kind string) error {
+	if name == "" {
+		return merr.WrapErrParameterInvalidMsg("%s name should not be empty", kind)
+	}
+	if len(name) > MaxUsernameLength {
+		return merr.WrapErrParameterInvalidRange(1, MaxUsernameLength, len(name), fmt.Sprintf("invalid length of %s name %s", kind, name))
+	}
+	for i, c := range name {
+		if c > unicode.MaxASCII || !(unicode.IsLetter(c) || (i > 0 && (unicode.IsDigit(c) || c == '_'))) {
+			return merr.WrapErrParameterInvalidMsg("invalid %s name %s, it must start with a letter and "+
+				"consist of letters, digits and underscores", kind, name)
+		}
+	}
+	return nil
+}
+
+// decodePassword decodes the base64 password of request and checks its length
+func decodePassword(encoded string) (string, error) {
+	password, err := crypto.Base64Decode(encoded)
+	if err != nil {
+		return "", merr.WrapErrParameterInvalidMsg("invalid password, it must be encoded by base64")
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", merr.WrapErrParameterInvalidRange(MinPasswordLength, MaxPasswordLength, len(password), "invalid length of password")
+	}
+	return password, nil
+}
+
+// verifyPassword checks the password by the bcrypt hash of credential
+func verifyPassword(credential *internalpb.CredentialInfo, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(credential.GetEncryptedPassword()), []byte(password)) == nil
+}
@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/parameterutil.go"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// checkDefaultValue checks the default values of fields are supported and match the field types
+func checkDefaultValue(schema *schemapb.CollectionSchema) error {
+	for _, field := range schema.GetFields() {
+		if field.GetDefaultValue() == nil {
+			continue
+		}
+		if _, err := defaultValueRow(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultValueRow converts the default value of field into a row of storage.FieldData.
+// JSON defaults are BytesData of a JSON document, Array defaults are BytesData of a JSON array of the element type,
+// since there's no array variant of ValueField. Primary keys and vectors can't have default values.
+func defaultValueRow(field *schemapb.FieldSchema) (any, error) {
+	if field.GetIsPrimaryKey() {
+		return nil, merr.WrapErrParameterInvalidMsg("primary key field %s can't have default value", field.GetName())
+	}
+	if storage.IsVectorType(field.GetDataType()) {
+		return nil, merr.WrapErrParameterInvalidMsg("vector field %s can't have default value", field.GetName())
+	}
+	value := field.GetDefaultValue()
+	mismatch := func() error {
+		return merr.WrapErrParameterInvalid(field.GetDataType().String(), typeOfDefaultValue(value),
+			"default value type mismatches field schema type of field "+field.GetName())
+	}
+
+	switch field.GetDataType() {
+	case schemapb.DataType_Bool:
+		if _, ok := value.GetData().(*schemapb.ValueField_BoolData); !ok {
+			return nil, mismatch()
+		}
+		return value.GetBoolData(), nil
+	case schemapb.DataType_Int8, schemapb.DataType_Int16, schemapb.DataType_Int32:
+		if _, ok := value.GetData().(*schemapb.ValueField_IntData); !ok {
+			return nil, mismatch()
+		}
+		return intDefaultValue(field, int64(value.GetIntData()))
+	case schemapb.DataType_Int64:
+		if _, ok := value.GetData().(*schemapb.ValueField_LongData); !ok {
+			return nil, mismatch()
+		}
+		return value.GetLongData(), nil
+	case schemapb.DataType_Float:
+		if _, ok := value.GetData().(*schemapb.ValueField_FloatData); !ok {
+			return nil, mismatch()
+		}
+		v := value.GetFloatData()
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, merr.WrapErrParameterInvalidMsg("default value of field %s is not a finite number", field.GetName())
+		}
+		return v, nil
+	case schemapb.DataType_Double:
+		if _, ok := value.GetData().(*schemapb.ValueField_DoubleData); !ok {
+			return nil, mismatch()
+		}
+		v := value.GetDoubleData()
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, merr.WrapErrParameterInvalidMsg("default value of field %s is not a finite number", field.GetName())
+		}
+		return v, nil
+	case schemapb.DataType_VarChar:
+		if _, ok := value.GetData().(*schemapb.ValueField_StringData); !ok {
+			return nil, mismatch()
+		}
+		if err := checkStringLength(field, value.GetStringData()); err != nil {
+			return nil, err
+		}
+		return value.GetStringData(), nil
+	case schemapb.DataType_JSON:
+		if _, ok := value.GetData().(*schemapb.ValueField_BytesData); !ok {
+			return nil, mismatch()
+		}
+		if !json.Valid(value.GetBytesData()) {
+			return nil, merr.WrapErrParameterInvalidMsg("default value of JSON field %s is not valid JSON", field.GetName())
+		}
+		return value.GetBytesData(), nil
+	case schemapb.DataType_Array:
+		if _, ok := value.GetData().(*schemapb.ValueField_BytesData); !ok {
+			return nil, mismatch()
+		}
+		row, err := arrayDefaultValue(field, value.GetBytesData())
+		if err != nil {
+			return nil, err
+		}
+		return row, validateArrayRow(field, row)
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("default value of %s field %s is not supported",
+		field.GetDataType().String(), field.GetName())
+}
+
+// fillDefaultValue returns the column of numRows default values of field
+func fillDefaultValue(field *schemapb.FieldSchema, numRows uint32) (storage.FieldData, error) {
+	row, err := defaultValueRow(field)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := storage.NewFieldData(field)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numRows; i++ {
+		if err := ret.AppendRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func typeOfDefaultValue(value *schemapb.ValueField) string {
+	switch value.GetData().(type) {
+	case *schemapb.ValueField_BoolData:
+		return "bool"
+	case *schemapb.ValueField_IntData:
+		return "int"
+	case *schemapb.ValueField_LongData:
+		return "long"
+	case *schemapb.ValueField_FloatData:
+		return "float"
+	case *schemapb.ValueField_DoubleData:
+		return "double"
+	case *schemapb.ValueField_StringData:
+		return "string"
+	case *schemapb.ValueField_BytesData:
+		return "bytes"
+	}
+	return "unknown"
+}
+
+// intDefaultValue checks the integer fits the field and converts it into the row type of field
+func intDefaultValue(field *schemapb.FieldSchema, v int64) (any, error) {
+	switch field.GetDataType() {
+	case schemapb.DataType_Int8:
+		if v > math.MaxInt8 || v < math.MinInt8 {
+			return nil, merr.WrapErrParameterInvalidRange(math.MinInt8, math.MaxInt8, v, "default value out of range")
+		}
+		return int8(v), nil
+	case schemapb.DataType_Int16:
+		if v > math.MaxInt16 || v < math.MinInt16 {
+			return nil, merr.WrapErrParameterInvalidRange(math.MinInt16, math.MaxInt16, v, "default value out of range")
+		}
+		return int16(v), nil
+	case schemapb.DataType_Int32:
+		if v > math.MaxInt32 || v < math.MinInt32 {
+			return nil, merr.WrapErrParameterInvalidRange(math.MinInt32, math.MaxInt32, v, "default value out of range")
+		}
+		return int32(v), nil
+	}
+	return v, nil
+}
+
+func checkStringLength(field *schemapb.FieldSchema, s string) error {
+	maxLength, err := parameterutil.GetMaxLength(field)
+	if err != nil {
+		return err
+	}
+	if int64(len(s)) > maxLength {
+		return merr.WrapErrParameterInvalidMsg("the length (%d) of string of field %s exceeds max length (%d)",
+			len(s), field.GetName(), maxLength)
+	}
+	return nil
+}
+
+// arrayDefaultValue parses the JSON array into the row of array field, elements must match the element type
+func arrayDefaultValue(field *schemapb.FieldSchema, data []byte) (*schemapb.ScalarField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var elements []any
+	if err := decoder.Decode(&elements); err != nil || decoder.More() {
+		return nil, merr.WrapErrParameterInvalidMsg("default value of array field %s is not a JSON array", field.GetName())
+	}
+	invalid := func(element any) error {
+		return merr.WrapErrParameterInvalidMsg("element %v of default value mismatches element type %s of array field %s",
+			element, field.GetElementType().String(), field.GetName())
+	}
+
+	switch field.GetElementType() {
+	case schemapb.DataType_Bool:
+		ret := make([]bool, 0, len(elements))
+		for _, element := range elements {
+			v, ok := element.(bool)
+			if !ok {
+				return nil, invalid(element)
+			}
+			ret = append(ret, v)
+		}
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_BoolData{BoolData: &schemapb.BoolArray{Data: ret}}}, nil
+	case schemapb.DataType_Int8, schemapb.DataType_Int16, schemapb.DataType_Int32:
+		ret := make([]int32, 0, len(elements))
+		for _, element := range elements {
+			number, ok := element.(json.Number)
+			if !ok {
+				return nil, invalid(element)
+			}
+			v, err := strconv.ParseInt(number.String(), 10, 64)
+			if err != nil {
+				return nil, invalid(element)
+			}
+			if _, err := intDefaultValue(elementField(field), v); err != nil {
+				return nil, err
+			}
+			ret = append(ret, int32(v))
+		}
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_IntData{IntData: &schemapb.IntArray{Data: ret}}}, nil
+	case schemapb.DataType_Int64:
+		ret := make([]int64, 0, len(elements))
+		for _, element := range elements {
+			number, ok := element.(json.Number)
+			if !ok {
+				return nil, invalid(element)
+			}
+			v, err := number.Int64()
+			if err != nil {
+				return nil, invalid(element)
+			}
+			ret = append(ret, v)
+		}
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: ret}}}, nil
+	case schemapb.DataType_Float:
+		ret := make([]float32, 0, len(elements))
+		for _, element := range elements {
+			number, ok := element.(json.Number)
+			if !ok {
+				return nil, invalid(element)
+			}
+			v, err := strconv.ParseFloat(number.String(), 32)
+			if err != nil {
+				return nil, invalid(element)
+			}
+			ret = append(ret, float32(v))
+		}
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_FloatData{FloatData: &schemapb.FloatArray{Data: ret}}}, nil
+	case schemapb.DataType_Double:
+		ret := make([]float64, 0, len(elements))
+		for _, element := range elements {
+			number, ok := element.(json.Number)
+			if !ok {
+				return nil, invalid(element)
+			}
+			v, err := number.Float64()
+			if err != nil {
+				return nil, invalid(element)
+			}
+			ret = append(ret, v)
+		}
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_DoubleData{DoubleData: &schemapb.DoubleArray{Data: ret}}}, nil
+	case schemapb.DataType_VarChar:
+		ret := make([]string, 0, len(elements))
+		for _, element := range elements {
+			v, ok := element.(string)
+			if !ok {
+				return nil, invalid(element)
+			}
+			if err := checkStringLength(elementField(field), v); err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: ret}}}, nil
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("default value of array field %s with element type %s is not supported",
+		field.GetName(), field.GetElementType().String())
+}
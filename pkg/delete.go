@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// DeleteTask deletes the rows matching expr, expressions other than `pk in [...]` or `pk == value`
+// are evaluated on loaded segments, so the collection must be loaded for them.
+type DeleteTask struct {
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.DeleteRequest
+}
+
+func NewDeleteTask(
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.DeleteRequest) *DeleteTask {
+
+	return &DeleteTask{
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+func (t DeleteTask) Execute(ctx context.Context) (*milvuspb.MutationResult, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPartitionKeyMode(collection, t.req.GetPartitionName()); err != nil {
+		return nil, err
+	}
+	var partitionIDs []UniqueID
+	if t.req.GetPartitionName() != "" {
+		partitionID, err := getPartitionID(collection, t.req.GetPartitionName())
+		if err != nil {
+			return nil, err
+		}
+		partitionIDs = []UniqueID{partitionID}
+	}
+	pkField, err := typeutil.GetPrimaryFieldSchema(model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema())
+	if err != nil {
+		return nil, err
+	}
+	ts, done, err := t.segmentManager.BeginWrite(t.tsAllocator)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	pks, err := parsePrimaryKeyExpr(t.req.GetExpr(), pkField)
+	if err != nil {
+		// the rows written before the delete must be seen
+		if err := t.segmentManager.WaitWrites(ctx, ts-1); err != nil {
+			return nil, err
+		}
+		pks, err = t.queryPrimaryKeys(ctx, collection, ts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	t.segmentManager.Delete(ctx, collection.CollectionID, partitionIDs, pks, ts)
+	return &milvuspb.MutationResult{
+		IDs:       storage.PrimaryKeysToIDs(pkField.GetDataType(), pks),
+		DeleteCnt: int64(len(pks)),
+		Timestamp: ts,
+	}, nil
+}
+
+// queryPrimaryKeys returns the primary keys of rows matching expr
+func (t DeleteTask) queryPrimaryKeys(ctx context.Context, collection *model.Collection, ts Timestamp) ([]storage.PrimaryKey, error) {
+	if strings.TrimSpace(t.req.GetExpr()) == "" {
+		return nil, merr.WrapErrParameterInvalidMsg("empty expression is not allowed in delete")
+	}
+	var partitionNames []string
+	if t.req.GetPartitionName() != "" {
+		partitionNames = []string{t.req.GetPartitionName()}
+	}
+	reader, err := newFilteredReader(ctx, t.segmentManager, collection, partitionNames, t.req.GetExpr(), ts)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+	rows := reader.Rows()
+	pks := make([]storage.PrimaryKey, 0, len(rows))
+	for _, row := range rows {
+		pk, err := storage.NewPrimaryKey(row.view.Data.Data[reader.pkField.GetFieldID()].GetRow(row.offset))
+		if err != nil {
+			return nil, err
+		}
+		pks = append(pks, pk)
+	}
+	return pks, nil
+}
+
+var (
+	pkInExprPattern    = regexp.MustCompile(`^\s*(\w+)\s+in\s+\[(.*)\]\s*$`)
+	pkEqualExprPattern = regexp.MustCompile(`^\s*(\w+)\s*==\s*(.+?)\s*$`)
+)
+
+// parsePrimaryKeyExpr parses the delete expr, only `pk in [...]` and `pk == value` are supported
+func parsePrimaryKeyExpr(expr string, pkField *schemapb.FieldSchema) ([]storage.PrimaryKey, error) {
+	var name string
+	var values []string
+	if matches := pkInExprPattern.FindStringSubmatch(expr); matches != nil {
+		name = matches[1]
+		if strings.TrimSpace(matches[2]) != "" {
+			values = strings.Split(matches[2], ",")
+		}
+	} else if matches := pkEqualExprPattern.FindStringSubmatch(expr); matches != nil {
+		name, values = matches[1], []string{matches[2]}
+	} else {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid expression %s, only primary key `in` or `==` is supported", expr)
+	}
+	if name != pkField.GetName() {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid expression %s, only primary key %s is supported", expr, pkField.GetName())
+	}
+
+	pks := make([]storage.PrimaryKey, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		switch pkField.GetDataType() {
+		case schemapb.DataType_Int64:
+			pk, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, merr.WrapErrParameterInvalidMsg("invalid primary key %s", value)
+			}
+			pks = append(pks, storage.NewInt64PrimaryKey(pk))
+		case schemapb.DataType_VarChar:
+			pk, err := strconv.Unquote(value)
+			if err != nil {
+				if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+					return nil, merr.WrapErrParameterInvalidMsg("invalid primary key %s", value)
+				}
+				pk = value[1 : len(value)-1]
+			}
+			pks = append(pks, storage.NewVarCharPrimaryKey(pk))
+		}
+	}
+	return pks, nil
+}
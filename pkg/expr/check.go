@@ -0,0 +1,255 @@
+package expr
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+)
+
+type function struct {
+	minArgs, maxArgs int
+	// returnsBool is false for functions which can't be used as a filter alone
+	returnsBool bool
+	eval        func(args []any) any
+	// check checks the arguments if not nil
+	check func(args []Node) error
+}
+
+// functions are the builtin functions, names are in lower case
+var functions = map[string]function{
+	"json_contains":      {minArgs: 2, maxArgs: 2, returnsBool: true, eval: containsFunc},
+	"json_contains_all":  {minArgs: 2, maxArgs: 2, returnsBool: true, eval: containsAllFunc},
+	"json_contains_any":  {minArgs: 2, maxArgs: 2, returnsBool: true, eval: containsAnyFunc},
+	"array_contains":     {minArgs: 2, maxArgs: 2, returnsBool: true, eval: containsFunc, check: checkArrayContains},
+	"array_contains_all": {minArgs: 2, maxArgs: 2, returnsBool: true, eval: containsAllFunc, check: checkArrayContainsAll},
+	"array_contains_any": {minArgs: 2, maxArgs: 2, returnsBool: true, eval: containsAnyFunc, check: checkArrayContainsAll},
+	"array_length":       {minArgs: 1, maxArgs: 1, eval: lengthFunc, check: checkArrayLength},
+}
+
+func containsFunc(args []any) any {
+	values, ok := args[0].([]any)
+	if !ok {
+		return nil
+	}
+	for _, value := range values {
+		if equal(value, args[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAllFunc(args []any) any {
+	values, ok1 := args[0].([]any)
+	targets, ok2 := args[1].([]any)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	for _, target := range targets {
+		if !containsFunc([]any{values, target}).(bool) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAnyFunc(args []any) any {
+	values, ok1 := args[0].([]any)
+	targets, ok2 := args[1].([]any)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	for _, target := range targets {
+		if containsFunc([]any{values, target}).(bool) {
+			return true
+		}
+	}
+	return false
+}
+
+func lengthFunc(args []any) any {
+	values, ok := args[0].([]any)
+	if !ok {
+		return nil
+	}
+	return int64(len(values))
+}
+
+// arrayArgument returns the first argument of array functions, which is an array field or a json field
+func arrayArgument(args []Node) (*ColumnNode, error) {
+	column, ok := args[0].(*ColumnNode)
+	if !ok || (column.Field.GetDataType() != schemapb.DataType_Array && column.Field.GetDataType() != schemapb.DataType_JSON) {
+		return nil, merr.WrapErrParameterInvalidMsg("the first argument should be an array field or a json field")
+	}
+	if column.Field.GetDataType() == schemapb.DataType_Array && len(column.Path) > 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("the first argument should be an array, but got an element of %s", column.Field.GetName())
+	}
+	return column, nil
+}
+
+// elementColumn returns the column of elements of array field, elements of json arrays are untyped
+func elementColumn(column *ColumnNode) *ColumnNode {
+	if column.Field.GetDataType() != schemapb.DataType_Array {
+		return column
+	}
+	return &ColumnNode{Field: column.Field, Path: []any{int64(0)}}
+}
+
+func checkArrayContains(args []Node) error {
+	column, err := arrayArgument(args)
+	if err != nil {
+		return err
+	}
+	return checkOperand(elementColumn(column), args[1])
+}
+
+func checkArrayContainsAll(args []Node) error {
+	column, err := arrayArgument(args)
+	if err != nil {
+		return err
+	}
+	value, ok := args[1].(*ValueNode)
+	if !ok {
+		return nil
+	}
+	values, ok := value.Value.([]any)
+	if !ok {
+		return merr.WrapErrParameterInvalidMsg("the second argument should be an array")
+	}
+	for i := range values {
+		if err := checkValue(elementColumn(column), &values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkArrayLength(args []Node) error {
+	_, err := arrayArgument(args)
+	return err
+}
+
+func checkCall(call *CallNode) error {
+	f, ok := functions[call.Name]
+	if !ok {
+		return merr.WrapErrParameterInvalidMsg("unknown function %s", call.Name)
+	}
+	if len(call.Args) < f.minArgs || len(call.Args) > f.maxArgs {
+		return merr.WrapErrParameterInvalidMsg("invalid argument number of function %s", call.Name)
+	}
+	if f.check != nil {
+		return f.check(call.Args)
+	}
+	return nil
+}
+
+// checkBool checks the node is a boolean expression, and literals are compatible with the typed fields
+func checkBool(node Node) error {
+	switch n := node.(type) {
+	case *ValueNode:
+		if _, ok := n.Value.(bool); ok {
+			return nil
+		}
+	case *ColumnNode:
+		if n.Field.GetDataType() == schemapb.DataType_Array {
+			if len(n.Path) > 0 && n.Field.GetElementType() == schemapb.DataType_Bool {
+				return nil
+			}
+		} else if n.Field.GetDataType() == schemapb.DataType_Bool || len(n.Path) > 0 || n.Field.GetDataType() == schemapb.DataType_JSON {
+			return nil
+		}
+	case *UnaryNode:
+		if n.Op == "not" {
+			return checkBool(n.Operand)
+		}
+	case *BinaryNode:
+		switch n.Op {
+		case "&&", "||":
+			if err := checkBool(n.Left); err != nil {
+				return err
+			}
+			return checkBool(n.Right)
+		case "==", "!=", "<", "<=", ">", ">=":
+			if err := checkOperand(n.Left, n.Right); err != nil {
+				return err
+			}
+			return checkOperand(n.Right, n.Left)
+		}
+	case *TermNode:
+		for i := range n.Values {
+			if err := checkValue(n.Operand, &n.Values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *LikeNode:
+		if column, ok := n.Operand.(*ColumnNode); ok && len(column.Path) == 0 &&
+			!typeutil.IsStringType(column.Field.GetDataType()) && column.Field.GetDataType() != schemapb.DataType_JSON {
+			return merr.WrapErrParameterInvalidMsg("like is only supported on string field, but got %s", column.Field.GetName())
+		}
+		return nil
+	case *NullNode:
+		if _, ok := n.Operand.(*ColumnNode); !ok {
+			return merr.WrapErrParameterInvalidMsg("is null is only supported on fields")
+		}
+		return nil
+	case *CallNode:
+		if functions[n.Name].returnsBool {
+			return nil
+		}
+	}
+	return merr.WrapErrParameterInvalidMsg("not a boolean expression")
+}
+
+func checkOperand(operand, other Node) error {
+	value, ok := other.(*ValueNode)
+	if !ok {
+		return nil
+	}
+	return checkValue(operand, &value.Value)
+}
+
+// checkValue checks the literal value is compatible with the column, elements of array fields are typed by the element type.
+// Float literals are rounded to float32 for Float fields so equality works as expected.
+func checkValue(operand Node, value *any) error {
+	column, ok := operand.(*ColumnNode)
+	if !ok {
+		return nil
+	}
+	dataType := column.Field.GetDataType()
+	if len(column.Path) > 0 {
+		if dataType != schemapb.DataType_Array {
+			return nil
+		}
+		dataType = column.Field.GetElementType()
+	}
+	mismatch := merr.WrapErrParameterInvalidMsg("value %v mismatches the type %s of field %s", *value, dataType.String(), column.Field.GetName())
+	switch {
+	case typeutil.IsIntegerType(dataType):
+		if _, ok := (*value).(int64); !ok {
+			if _, ok := (*value).(float64); !ok {
+				return mismatch
+			}
+		}
+	case typeutil.IsFloatingType(dataType):
+		switch v := (*value).(type) {
+		case int64:
+			*value = float64(v)
+		case float64:
+		default:
+			return mismatch
+		}
+		if dataType == schemapb.DataType_Float {
+			*value = float64(float32((*value).(float64)))
+		}
+	case typeutil.IsStringType(dataType):
+		if _, ok := (*value).(string); !ok {
+			return mismatch
+		}
+	case typeutil.IsBoolType(dataType):
+		if _, ok := (*value).(bool); !ok {
+			return mismatch
+		}
+	}
+	return nil
+}
@@ -0,0 +1,428 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// Evaluate returns whether each row of data matches the expression.
+// Rows whose value is missing (e.g. null or absent json key) or type mismatched never match.
+// Leaves on fields with scalar indexes are evaluated by the indexes instead of scanning rows, indexes could be nil.
+func (p *Plan) Evaluate(data *storage.InsertData, rowNum int, indexes map[int64]index.ScalarIndex) ([]bool, error) {
+	if p == nil || p.Root == nil {
+		ret := make([]bool, rowNum)
+		for i := range ret {
+			ret[i] = true
+		}
+		return ret, nil
+	}
+	return evalBool(p.Root, data, rowNum, indexes)
+}
+
+// evalBool evaluates logical nodes column-wise, and leaves by indexes or row by row
+func evalBool(node Node, data *storage.InsertData, rowNum int, indexes map[int64]index.ScalarIndex) ([]bool, error) {
+	switch n := node.(type) {
+	case *BinaryNode:
+		if n.Op == "&&" || n.Op == "||" {
+			left, err := evalBool(n.Left, data, rowNum, indexes)
+			if err != nil {
+				return nil, err
+			}
+			right, err := evalBool(n.Right, data, rowNum, indexes)
+			if err != nil {
+				return nil, err
+			}
+			for i := range left {
+				if n.Op == "&&" {
+					left[i] = left[i] && right[i]
+				} else {
+					left[i] = left[i] || right[i]
+				}
+			}
+			return left, nil
+		}
+	case *UnaryNode:
+		if n.Op == "not" {
+			ret, err := evalBool(n.Operand, data, rowNum, indexes)
+			if err != nil {
+				return nil, err
+			}
+			for i := range ret {
+				ret[i] = !ret[i]
+			}
+			return ret, nil
+		}
+	}
+
+	if ret, ok := evalIndex(node, indexes, rowNum); ok {
+		return ret, nil
+	}
+	ret := make([]bool, rowNum)
+	for i := 0; i < rowNum; i++ {
+		value, err := evalRow(node, data, i)
+		if err != nil {
+			return nil, err
+		}
+		ret[i], _ = value.(bool)
+	}
+	return ret, nil
+}
+
+// evalRow evaluates the node of the i-th row, nil means the value is missing
+func evalRow(node Node, data *storage.InsertData, i int) (any, error) {
+	switch n := node.(type) {
+	case *ValueNode:
+		return n.Value, nil
+	case *ColumnNode:
+		return getColumnValue(n, data, i)
+	case *UnaryNode:
+		operand, err := evalRow(n.Operand, data, i)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case "not":
+			b, ok := operand.(bool)
+			if !ok {
+				return nil, nil
+			}
+			return !b, nil
+		case "-":
+			switch v := operand.(type) {
+			case int64:
+				return -v, nil
+			case float64:
+				return -v, nil
+			}
+		}
+		return nil, nil
+	case *BinaryNode:
+		left, err := evalRow(n.Left, data, i)
+		if err != nil {
+			return nil, err
+		}
+		right, err := evalRow(n.Right, data, i)
+		if err != nil {
+			return nil, err
+		}
+		return evalBinary(n.Op, left, right), nil
+	case *TermNode:
+		operand, err := evalRow(n.Operand, data, i)
+		if err != nil || operand == nil {
+			return nil, err
+		}
+		for _, value := range n.Values {
+			if equal(operand, value) {
+				return !n.Not, nil
+			}
+		}
+		return n.Not, nil
+	case *LikeNode:
+		operand, err := evalRow(n.Operand, data, i)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := operand.(string)
+		if !ok {
+			return nil, nil
+		}
+		return n.regexp.MatchString(s), nil
+	case *NullNode:
+		operand, err := evalRow(n.Operand, data, i)
+		if err != nil {
+			return nil, err
+		}
+		return (operand == nil) != n.Not, nil
+	case *CallNode:
+		args := make([]any, 0, len(n.Args))
+		for _, arg := range n.Args {
+			value, err := evalRow(arg, data, i)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, value)
+		}
+		return functions[n.Name].eval(args), nil
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("unsupported expression node %T", node)
+}
+
+func getColumnValue(column *ColumnNode, data *storage.InsertData, i int) (any, error) {
+	fieldData, ok := data.Data[column.Field.GetFieldID()]
+	if !ok {
+		return nil, merr.WrapErrFieldNotFound(column.Field.GetName())
+	}
+	if !fieldData.IsValid(i) {
+		return nil, nil
+	}
+	var value any
+	switch d := fieldData.(type) {
+	case *storage.JSONFieldData:
+		decoder := json.NewDecoder(bytes.NewReader(d.Data[i]))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil
+		}
+	case *storage.ArrayFieldData:
+		value = scalarFieldToSlice(d.Data[i])
+	default:
+		value = fieldData.GetRow(i)
+	}
+	value = normalize(value)
+	for _, key := range column.Path {
+		switch v := value.(type) {
+		case map[string]any:
+			name, ok := key.(string)
+			if !ok {
+				return nil, nil
+			}
+			value = v[name]
+		case []any:
+			index, ok := key.(int64)
+			if !ok || index < 0 || index >= int64(len(v)) {
+				return nil, nil
+			}
+			value = v[index]
+		default:
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// normalize converts values into int64, float64, bool, string, []any or map[string]any
+func normalize(value any) any {
+	switch v := value.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return nil
+	case []any:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case map[string]any:
+		for key := range v {
+			v[key] = normalize(v[key])
+		}
+		return v
+	}
+	return value
+}
+
+func scalarFieldToSlice(field *schemapb.ScalarField) []any {
+	ret := make([]any, 0)
+	switch {
+	case field.GetBoolData() != nil:
+		for _, v := range field.GetBoolData().GetData() {
+			ret = append(ret, v)
+		}
+	case field.GetIntData() != nil:
+		for _, v := range field.GetIntData().GetData() {
+			ret = append(ret, int64(v))
+		}
+	case field.GetLongData() != nil:
+		for _, v := range field.GetLongData().GetData() {
+			ret = append(ret, v)
+		}
+	case field.GetFloatData() != nil:
+		for _, v := range field.GetFloatData().GetData() {
+			ret = append(ret, float64(v))
+		}
+	case field.GetDoubleData() != nil:
+		for _, v := range field.GetDoubleData().GetData() {
+			ret = append(ret, v)
+		}
+	case field.GetStringData() != nil:
+		for _, v := range field.GetStringData().GetData() {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+func evalBinary(op string, left, right any) any {
+	if left == nil || right == nil {
+		return nil
+	}
+	switch op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		if !isComparable(left, right) {
+			return nil
+		}
+		return !equal(left, right)
+	case "<", "<=", ">", ">=":
+		cmp, ok := compare(left, right)
+		if !ok {
+			return nil
+		}
+		switch op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	case "&&", "||":
+		l, ok1 := left.(bool)
+		r, ok2 := right.(bool)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		if op == "&&" {
+			return l && r
+		}
+		return l || r
+	}
+	return arithmetic(op, left, right)
+}
+
+func arithmetic(op string, left, right any) any {
+	l, lInt := left.(int64)
+	r, rInt := right.(int64)
+	if lInt && rInt && op != "**" {
+		switch op {
+		case "+":
+			return l + r
+		case "-":
+			return l - r
+		case "*":
+			return l * r
+		case "/":
+			if r == 0 {
+				return nil
+			}
+			return l / r
+		case "%":
+			if r == 0 {
+				return nil
+			}
+			return l % r
+		}
+		return nil
+	}
+	lf, ok1 := toFloat(left)
+	rf, ok2 := toFloat(right)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	switch op {
+	case "+":
+		return lf + rf
+	case "-":
+		return lf - rf
+	case "*":
+		return lf * rf
+	case "/":
+		if rf == 0 {
+			return nil
+		}
+		return lf / rf
+	case "%":
+		if rf == 0 {
+			return nil
+		}
+		return math.Mod(lf, rf)
+	case "**":
+		return math.Pow(lf, rf)
+	}
+	return nil
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func isComparable(left, right any) bool {
+	_, ok1 := toFloat(left)
+	_, ok2 := toFloat(right)
+	if ok1 && ok2 {
+		return true
+	}
+	return reflect.TypeOf(left) == reflect.TypeOf(right)
+}
+
+func equal(left, right any) bool {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			return l == r
+		}
+	}
+	lf, ok1 := toFloat(left)
+	rf, ok2 := toFloat(right)
+	if ok1 && ok2 {
+		return lf == rf
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compare returns -1, 0 or 1, ok is false if values are not comparable
+func compare(left, right any) (int, bool) {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	lf, ok1 := toFloat(left)
+	rf, ok2 := toFloat(right)
+	if ok1 && ok2 {
+		switch {
+		case lf < rf:
+			return -1, true
+		case lf > rf:
+			return 1, true
+		}
+		return 0, true
+	}
+	ls, ok1 := left.(string)
+	rs, ok2 := right.(string)
+	if ok1 && ok2 {
+		switch {
+		case ls < rs:
+			return -1, true
+		case ls > rs:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
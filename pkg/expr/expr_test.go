@@ -0,0 +1,311 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSchema() *schemapb.CollectionSchema {
+	return &schemapb.CollectionSchema{
+		Name: "test",
+		Fields: []*schemapb.FieldSchema{
+			{FieldID: 100, Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+			{FieldID: 101, Name: "name", DataType: schemapb.DataType_VarChar},
+			{FieldID: 102, Name: "score", DataType: schemapb.DataType_Float},
+			{FieldID: 103, Name: "info", DataType: schemapb.DataType_JSON},
+			{FieldID: 104, Name: "flag", DataType: schemapb.DataType_Bool},
+			{FieldID: 105, Name: "vec", DataType: schemapb.DataType_FloatVector},
+		},
+	}
+}
+
+func newTestData() *storage.InsertData {
+	return &storage.InsertData{Data: map[storage.FieldID]storage.FieldData{
+		100: &storage.Int64FieldData{Data: []int64{1, 2, 3, 4}},
+		101: &storage.StringFieldData{Data: []string{"apple", "banana", "cherry", "apricot"}, DataType: schemapb.DataType_VarChar},
+		102: &storage.FloatFieldData{Data: []float32{0.1, 0.5, 1.5, 2.5}},
+		103: &storage.JSONFieldData{Data: [][]byte{
+			[]byte(`{"color": "red", "tags": [1, 2]}`),
+			[]byte(`{"color": "blue", "tags": [3]}`),
+			[]byte(`{"size": 3}`),
+			[]byte(`{}`),
+		}},
+		104: &storage.BoolFieldData{Data: []bool{true, false, true, false}},
+	}}
+}
+
+func TestEvaluate(t *testing.T) {
+	schema := newTestSchema()
+	data := newTestData()
+	cases := []struct {
+		expr     string
+		expected []bool
+	}{
+		{"pk in [1, 3]", []bool{true, false, true, false}},
+		{"pk not in [1, 3]", []bool{false, true, false, true}},
+		{"pk >= 2 and pk < 4", []bool{false, true, true, false}},
+		{"1 < pk <= 3", []bool{false, true, true, false}},
+		{"pk == 1 || name == \"cherry\"", []bool{true, false, true, false}},
+		{"not (pk > 2)", []bool{true, true, false, false}},
+		{"pk % 2 == 0", []bool{false, true, false, true}},
+		{"pk * 2 + 1 > 5", []bool{false, false, true, true}},
+		{"name like \"ap%\"", []bool{true, false, false, true}},
+		{"name like \"_anana\"", []bool{false, true, false, false}},
+		{"score == 0.1", []bool{true, false, false, false}},
+		{"info[\"color\"] == \"red\"", []bool{true, false, false, false}},
+		{"info[\"size\"] > 2", []bool{false, false, true, false}},
+		{"info[\"tags\"][0] == 3", []bool{false, true, false, false}},
+		{"json_contains(info[\"tags\"], 2)", []bool{true, false, false, false}},
+		{"json_contains_any(info[\"tags\"], [2, 3])", []bool{true, true, false, false}},
+		{"flag", []bool{true, false, true, false}},
+		{"flag == false", []bool{false, true, false, true}},
+		{"2 ** 2 == 4 AND pk == 4", []bool{false, false, false, true}},
+	}
+	for _, c := range cases {
+		plan, err := Parse(c.expr, schema)
+		assert.NoError(t, err, c.expr)
+		ret, err := plan.Evaluate(data, 4, nil)
+		assert.NoError(t, err, c.expr)
+		assert.Equal(t, c.expected, ret, c.expr)
+	}
+}
+
+func TestEvaluateWithIndex(t *testing.T) {
+	schema := newTestSchema()
+	data := newTestData()
+	indexes := make(map[int64]index.ScalarIndex)
+	for _, field := range schema.GetFields() {
+		indexType := index.DefaultScalarIndexType(field.GetDataType())
+		if indexType == "" {
+			continue
+		}
+		scalarIndex, err := index.NewIndex(field, map[string]string{common.IndexTypeKey: indexType})
+		assert.NoError(t, err)
+		assert.NoError(t, scalarIndex.Build(data.Data[field.GetFieldID()]))
+		indexes[field.GetFieldID()] = scalarIndex.(index.ScalarIndex)
+	}
+	for _, expr := range []string{
+		"pk == 2",
+		"pk != 2",
+		"3 > pk",
+		"pk >= 2 and pk < 4",
+		"pk in [1, 3, 5]",
+		"pk not in [1, 3]",
+		"score < 1",
+		"score >= 0.5",
+		"score == 1.5",
+		"name == \"banana\"",
+		"name in [\"apple\", \"kiwi\"]",
+		"name like \"ap%\"",
+		"name like \"apple\"",
+		"name like \"%a\"",
+		"flag == false",
+		"not flag",
+	} {
+		plan, err := Parse(expr, schema)
+		assert.NoError(t, err, expr)
+		expected, err := plan.Evaluate(data, 4, nil)
+		assert.NoError(t, err, expr)
+		ret, err := plan.Evaluate(data, 4, indexes)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, ret, expr)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	schema := newTestSchema()
+	for _, expr := range []string{
+		"",
+		"pk",
+		"pk ==",
+		"unknown == 1",
+		"vec == 1",
+		"pk == \"a\"",
+		"name > 1",
+		"pk in 1",
+		"pk < 1 > 2",
+		"foo(pk)",
+		"name like 1",
+		"pk == 1)",
+		"\"unterminated",
+	} {
+		_, err := Parse(expr, schema)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, value := range []string{"", "apple", `a"b`, `a\b`, "a\nb\t'"} {
+		tokens, err := tokenize(Quote(value))
+		assert.NoError(t, err, value)
+		assert.Equal(t, value, tokens[0].value, value)
+	}
+}
+
+func TestPartitionKeyValues(t *testing.T) {
+	schema := newTestSchema()
+	name := schema.GetFields()[1]
+	cases := []struct {
+		expr   string
+		values []any
+		ok     bool
+	}{
+		{`name == "apple"`, []any{"apple"}, true},
+		{`"apple" == name`, []any{"apple"}, true},
+		{`name in ["apple", "kiwi"]`, []any{"apple", "kiwi"}, true},
+		{`name == "apple" and pk > 1`, []any{"apple"}, true},
+		{`pk > 1 && (name == "apple" or name in ["kiwi"])`, []any{"apple", "kiwi"}, true},
+		{`name == "apple" or pk > 1`, nil, false},
+		{`name != "apple"`, nil, false},
+		{`name not in ["apple"]`, nil, false},
+		{`name like "ap%"`, nil, false},
+		{`not (name == "apple")`, nil, false},
+		{`pk == 1`, nil, false},
+	}
+	for _, c := range cases {
+		plan, err := Parse(c.expr, schema)
+		assert.NoError(t, err, c.expr)
+		values, ok := plan.PartitionKeyValues(name)
+		assert.Equal(t, c.ok, ok, c.expr)
+		assert.Equal(t, c.values, values, c.expr)
+	}
+	var plan *Plan
+	_, ok := plan.PartitionKeyValues(name)
+	assert.False(t, ok)
+}
+
+func TestDynamicField(t *testing.T) {
+	schema := newTestSchema()
+	schema.Fields = append(schema.Fields, &schemapb.FieldSchema{FieldID: 106, Name: common.MetaFieldName, DataType: schemapb.DataType_JSON, IsDynamic: true})
+	data := newTestData()
+	data.Data[106] = &storage.JSONFieldData{Data: [][]byte{
+		[]byte(`{"color": "red", "size": 1}`),
+		[]byte(`{"color": "blue"}`),
+		[]byte(`{"size": 3}`),
+		[]byte(`{}`),
+	}}
+	cases := []struct {
+		expr     string
+		expected []bool
+	}{
+		{`color == "red"`, []bool{true, false, false, false}},
+		{`$meta["color"] == "red"`, []bool{true, false, false, false}},
+		{`size > 1 or color == "blue"`, []bool{false, true, true, false}},
+		{`color in ["red", "blue"] and pk > 1`, []bool{false, true, false, false}},
+		{`info["size"] == 3`, []bool{false, false, true, false}},
+	}
+	for _, c := range cases {
+		plan, err := Parse(c.expr, schema)
+		assert.NoError(t, err, c.expr)
+		ret, err := plan.Evaluate(data, 4, nil)
+		assert.NoError(t, err, c.expr)
+		assert.Equal(t, c.expected, ret, c.expr)
+	}
+	_, err := Parse("unknown == 1", newTestSchema())
+	assert.Error(t, err)
+}
+
+func TestNullable(t *testing.T) {
+	schema := newTestSchema()
+	age := &schemapb.FieldSchema{FieldID: 106, Name: "age", DataType: schemapb.DataType_Int64,
+		TypeParams: []*commonpb.KeyValuePair{{Key: "nullable", Value: "true"}}}
+	schema.Fields = append(schema.Fields, age)
+	data := newTestData()
+	ages, err := storage.NewFieldData(age)
+	assert.NoError(t, err)
+	for _, row := range []any{int64(1), nil, int64(3), nil} {
+		assert.NoError(t, ages.AppendRow(row))
+	}
+	data.Data[106] = ages
+	scalarIndex, err := index.NewIndex(age, map[string]string{common.IndexTypeKey: index.DefaultScalarIndexType(age.GetDataType())})
+	assert.NoError(t, err)
+	assert.NoError(t, scalarIndex.Build(ages))
+	indexes := map[int64]index.ScalarIndex{106: scalarIndex.(index.ScalarIndex)}
+
+	cases := []struct {
+		expr     string
+		expected []bool
+	}{
+		{"age is null", []bool{false, true, false, true}},
+		{"age IS NOT NULL", []bool{true, false, true, false}},
+		{"age > 0", []bool{true, false, true, false}},
+		{"age != 1", []bool{false, false, true, false}},
+		{"age not in [3]", []bool{true, false, false, false}},
+		{"age is null or pk == 1", []bool{true, true, false, true}},
+		{`info["size"] is not null`, []bool{false, false, true, false}},
+	}
+	for _, c := range cases {
+		plan, err := Parse(c.expr, schema)
+		assert.NoError(t, err, c.expr)
+		for _, indexes := range []map[int64]index.ScalarIndex{nil, indexes} {
+			ret, err := plan.Evaluate(data, 4, indexes)
+			assert.NoError(t, err, c.expr)
+			assert.Equal(t, c.expected, ret, c.expr)
+		}
+	}
+	for _, expr := range []string{"age is", "age is not 1", "1 is null"} {
+		_, err := Parse(expr, schema)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestArray(t *testing.T) {
+	schema := newTestSchema()
+	schema.Fields = append(schema.Fields,
+		&schemapb.FieldSchema{FieldID: 106, Name: "nums", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_Float},
+		&schemapb.FieldSchema{FieldID: 107, Name: "tags", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_VarChar})
+	data := newTestData()
+	floats := func(v ...float32) *schemapb.ScalarField {
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_FloatData{FloatData: &schemapb.FloatArray{Data: v}}}
+	}
+	strs := func(v ...string) *schemapb.ScalarField {
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: v}}}
+	}
+	data.Data[106] = &storage.ArrayFieldData{ElementType: schemapb.DataType_Float,
+		Data: []*schemapb.ScalarField{floats(0.1, 1), floats(2), floats(), floats(1, 2, 3)}}
+	data.Data[107] = &storage.ArrayFieldData{ElementType: schemapb.DataType_VarChar,
+		Data: []*schemapb.ScalarField{strs("a", "b"), strs("b"), strs("c"), strs()}}
+
+	cases := []struct {
+		expr     string
+		expected []bool
+	}{
+		{"array_contains(nums, 0.1)", []bool{true, false, false, false}},
+		{"ARRAY_CONTAINS(tags, \"b\")", []bool{true, true, false, false}},
+		{"array_contains_all(tags, [\"a\", \"b\"])", []bool{true, false, false, false}},
+		{"array_contains_any(nums, [2, 3])", []bool{false, true, false, true}},
+		{"array_length(nums) == 0", []bool{false, false, true, false}},
+		{"array_length(tags) >= 1 && array_length(nums) > 1", []bool{true, false, false, false}},
+		{"nums[0] == 0.1", []bool{true, false, false, false}},
+		{"tags[1] == \"b\"", []bool{true, false, false, false}},
+		{"nums[2] > 0", []bool{false, false, false, true}},
+		{"json_contains(info[\"tags\"], 3) or array_length(info[\"tags\"]) == 2", []bool{true, true, false, false}},
+	}
+	for _, c := range cases {
+		plan, err := Parse(c.expr, schema)
+		assert.NoError(t, err, c.expr)
+		ret, err := plan.Evaluate(data, 4, nil)
+		assert.NoError(t, err, c.expr)
+		assert.Equal(t, c.expected, ret, c.expr)
+	}
+	for _, expr := range []string{
+		"array_contains(nums, \"a\")",
+		"array_contains_all(tags, [1])",
+		"array_contains_any(tags, \"a\")",
+		"array_contains(pk, 1)",
+		"array_length(nums[0]) == 1",
+		"array_length(nums)",
+		"nums[0][1] == 1",
+		"pk[0] == 1",
+		"tags[0] == 1",
+	} {
+		_, err := Parse(expr, schema)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,152 @@
+package expr
+
+import (
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// reversedOps are the comparison operators when operands are swapped
+var reversedOps = map[string]string{
+	"==": "==",
+	"!=": "!=",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+// evalIndex evaluates the leaf node by the scalar index of its field, ok is false if no index is usable.
+// The results are the same as evaluating row by row, values of other types fall back to the evaluator.
+func evalIndex(node Node, indexes map[int64]index.ScalarIndex, rowNum int) ([]bool, bool) {
+	if len(indexes) == 0 {
+		return nil, false
+	}
+	var ret []bool
+	var ok, not bool
+	switch n := node.(type) {
+	case *BinaryNode:
+		op, isComparison := reversedOps[n.Op]
+		if !isComparison {
+			return nil, false
+		}
+		column, isColumn := n.Left.(*ColumnNode)
+		value, isValue := n.Right.(*ValueNode)
+		if !isColumn || !isValue {
+			column, isColumn = n.Right.(*ColumnNode)
+			value, isValue = n.Left.(*ValueNode)
+			if !isColumn || !isValue {
+				return nil, false
+			}
+		} else {
+			op = n.Op
+		}
+		scalarIndex := getScalarIndex(indexes, column, value.Value)
+		if scalarIndex == nil {
+			return nil, false
+		}
+		switch op {
+		case "==", "!=":
+			ret, ok = scalarIndex.In([]any{value.Value})
+			not = op == "!="
+			// null rows never match, which can't be told by the complement of index lookups
+			if not && storage.IsNullable(column.Field) {
+				return nil, false
+			}
+		case "<", "<=":
+			ret, ok = scalarIndex.Range(nil, false, value.Value, op == "<=")
+		case ">", ">=":
+			ret, ok = scalarIndex.Range(value.Value, op == ">=", nil, false)
+		}
+	case *TermNode:
+		column, isColumn := n.Operand.(*ColumnNode)
+		if !isColumn {
+			return nil, false
+		}
+		for _, value := range n.Values {
+			if getScalarIndex(indexes, column, value) == nil {
+				return nil, false
+			}
+		}
+		if len(n.Values) == 0 {
+			return nil, false
+		}
+		if n.Not && storage.IsNullable(column.Field) {
+			return nil, false
+		}
+		ret, ok = getScalarIndex(indexes, column, n.Values[0]).In(n.Values)
+		not = n.Not
+	case *LikeNode:
+		column, isColumn := n.Operand.(*ColumnNode)
+		prefix, exact, isPrefix := likePrefix(n.Pattern)
+		if !isColumn || !isPrefix {
+			return nil, false
+		}
+		scalarIndex := getScalarIndex(indexes, column, prefix)
+		if scalarIndex == nil {
+			return nil, false
+		}
+		if exact {
+			ret, ok = scalarIndex.In([]any{prefix})
+		} else {
+			ret, ok = scalarIndex.PrefixMatch(prefix)
+		}
+	}
+	if !ok || len(ret) != rowNum {
+		return nil, false
+	}
+	if not {
+		for i := range ret {
+			ret[i] = !ret[i]
+		}
+	}
+	return ret, true
+}
+
+// getScalarIndex returns the index of column if the value could be compared with the field values by the index
+func getScalarIndex(indexes map[int64]index.ScalarIndex, column *ColumnNode, value any) index.ScalarIndex {
+	if len(column.Path) > 0 {
+		return nil
+	}
+	dataType := column.Field.GetDataType()
+	var comparable bool
+	switch value.(type) {
+	case int64, float64:
+		comparable = typeutil.IsIntegerType(dataType) || typeutil.IsFloatingType(dataType)
+	case string:
+		comparable = typeutil.IsStringType(dataType)
+	case bool:
+		comparable = dataType == schemapb.DataType_Bool
+	}
+	if !comparable {
+		return nil
+	}
+	return indexes[column.Field.GetFieldID()]
+}
+
+// likePrefix returns the literal prefix of like pattern if the pattern is a prefix match like `abc%`,
+// exact is true if there's no wildcard.
+func likePrefix(pattern string) (prefix string, exact bool, ok bool) {
+	var builder strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			builder.WriteRune(runes[i])
+		case '%':
+			// only a trailing % is a prefix match
+			return builder.String(), false, i == len(runes)-1
+		case '_':
+			return "", false, false
+		default:
+			builder.WriteRune(runes[i])
+		}
+	}
+	return builder.String(), true, true
+}
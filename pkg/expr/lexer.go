@@ -0,0 +1,187 @@
+package expr
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/milvus-io/milvus/pkg/util/merr"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string // unquoted value of string literal
+	pos   int
+}
+
+// keywords are case-insensitive, they're normalized into lower case
+var keywords = map[string]string{
+	"and":   "&&",
+	"or":    "||",
+	"not":   "not",
+	"in":    "in",
+	"like":  "like",
+	"is":    "is",
+	"null":  "null",
+	"true":  "true",
+	"false": "false",
+}
+
+// operators are sorted by length, so the longest one is matched first
+var operators = []string{
+	"**", "<=", ">=", "==", "!=", "&&", "||",
+	"<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ",",
+}
+
+func tokenize(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			value, end, err := readString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[i:end]), value: value, pos: i})
+			i = end
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			kind, end := readNumber(runes, i)
+			tokens = append(tokens, token{kind: kind, text: string(runes[i:end]), pos: i})
+			i = end
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			text := string(runes[start:i])
+			if keyword, ok := keywords[strings.ToLower(text)]; ok {
+				tokens = append(tokens, token{kind: tokenOperator, text: keyword, pos: start})
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, text: text, pos: start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, merr.WrapErrParameterInvalidMsg("invalid character %q at position %d", r, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// readString reads the string literal quoted by ' or ", it returns the unquoted value and the end position
+func readString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var builder strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case quote:
+			return builder.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(runes) {
+				break
+			}
+			i++
+			switch runes[i] {
+			case 'n':
+				builder.WriteRune('\n')
+			case 't':
+				builder.WriteRune('\t')
+			case 'r':
+				builder.WriteRune('\r')
+			default:
+				builder.WriteRune(runes[i])
+			}
+		default:
+			builder.WriteRune(runes[i])
+		}
+	}
+	return "", 0, merr.WrapErrParameterInvalidMsg("unterminated string at position %d", start)
+}
+
+// Quote returns the string literal of value quoted by ", which is read back as value
+func Quote(value string) string {
+	var builder strings.Builder
+	builder.WriteRune('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			builder.WriteRune('\\')
+			builder.WriteRune(r)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '\r':
+			builder.WriteString(`\r`)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	builder.WriteRune('"')
+	return builder.String()
+}
+
+// readNumber reads an integer (decimal or hex) or a float literal, it returns the kind and the end position
+func readNumber(runes []rune, start int) (tokenKind, int) {
+	i := start
+	if i+1 < len(runes) && runes[i] == '0' && (runes[i+1] == 'x' || runes[i+1] == 'X') {
+		i += 2
+		for i < len(runes) && isHexDigit(runes[i]) {
+			i++
+		}
+		return tokenInt, i
+	}
+	kind := tokenInt
+	for i < len(runes) && unicode.IsDigit(runes[i]) {
+		i++
+	}
+	if i < len(runes) && runes[i] == '.' {
+		kind = tokenFloat
+		i++
+		for i < len(runes) && unicode.IsDigit(runes[i]) {
+			i++
+		}
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		j := i + 1
+		if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+			j++
+		}
+		if j < len(runes) && unicode.IsDigit(runes[j]) {
+			kind = tokenFloat
+			i = j
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+		}
+	}
+	return kind, i
+}
+
+func isHexDigit(r rune) bool {
+	return unicode.IsDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
@@ -0,0 +1,526 @@
+package expr
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// Node is a node of expression tree
+type Node interface {
+	node()
+}
+
+// ColumnNode is a field of collection, Path is the json keys (string) or array indexes (int64) after the field
+type ColumnNode struct {
+	Field *schemapb.FieldSchema
+	Path  []any
+}
+
+// ValueNode is a literal, the value is one of int64, float64, bool, string and []any
+type ValueNode struct {
+	Value any
+}
+
+// UnaryNode is `-x` or `not x`
+type UnaryNode struct {
+	Op      string
+	Operand Node
+}
+
+// BinaryNode is an arithmetic, comparison or logical operation
+type BinaryNode struct {
+	Op          string
+	Left, Right Node
+}
+
+// TermNode is `x in [...]` or `x not in [...]`
+type TermNode struct {
+	Operand Node
+	Values  []any
+	Not     bool
+}
+
+// LikeNode is `x like "pattern"`, % matches any characters and _ matches one character
+type LikeNode struct {
+	Operand Node
+	Pattern string
+	regexp  *regexp.Regexp
+}
+
+// NullNode is `x is null` or `x is not null`, x is null if it's a null row of nullable field or a missing json key
+type NullNode struct {
+	Operand Node
+	Not     bool
+}
+
+// CallNode is a function call
+type CallNode struct {
+	Name string
+	Args []Node
+}
+
+func (*ColumnNode) node() {}
+func (*ValueNode) node()  {}
+func (*UnaryNode) node()  {}
+func (*BinaryNode) node() {}
+func (*TermNode) node()   {}
+func (*LikeNode) node()   {}
+func (*NullNode) node()   {}
+func (*CallNode) node()   {}
+
+// Plan is a parsed boolean expression of a collection
+type Plan struct {
+	Expr   string
+	Root   Node
+	schema *schemapb.CollectionSchema
+}
+
+// Parse parses the filter expression, fields are resolved by the schema
+func Parse(expr string, schema *schemapb.CollectionSchema) (*Plan, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, expr: expr, schema: schema}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("unexpected token %s", p.peek().text)
+	}
+	if err := checkBool(root); err != nil {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid expression %s: %s", expr, err.Error())
+	}
+	return &Plan{Expr: expr, Root: root, schema: schema}, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	expr   string
+	schema *schemapb.CollectionSchema
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOperator(op) {
+		return p.errorf("expect %s but got %s", op, p.peek().text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return merr.WrapErrParameterInvalidMsg("invalid expression %s at position %d: %s",
+		p.expr, p.peek().pos, merr.WrapErrParameterInvalidMsg(format, args...).Error())
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{Op: "||", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		p.next()
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{Op: "&&", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseEquality() (Node, error) {
+	left, err := p.parseRelational()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("==", "!=") {
+		op := p.next().text
+		right, err := p.parseRelational()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseRelational parses comparisons, `in`, `like`, `is null` and range expressions like `1 < x < 10`
+func (p *parser) parseRelational() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isOperator("<", "<=", ">", ">="):
+		op := p.next().text
+		middle, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		node := Node(&BinaryNode{Op: op, Left: left, Right: middle})
+		if p.isOperator("<", "<=", ">", ">=") {
+			op2 := p.next().text
+			if (strings.HasPrefix(op, "<") && !strings.HasPrefix(op2, "<")) || (strings.HasPrefix(op, ">") && !strings.HasPrefix(op2, ">")) {
+				return nil, p.errorf("invalid range expression")
+			}
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			node = &BinaryNode{Op: "&&", Left: node, Right: &BinaryNode{Op: op2, Left: middle, Right: right}}
+		}
+		return node, nil
+	case p.isOperator("in"):
+		p.next()
+		return p.parseTerm(left, false)
+	case p.isOperator("not") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokenOperator && p.tokens[p.pos+1].text == "in":
+		p.next()
+		p.next()
+		return p.parseTerm(left, true)
+	case p.isOperator("like"):
+		p.next()
+		t := p.next()
+		if t.kind != tokenString {
+			return nil, p.errorf("like pattern should be a string")
+		}
+		re, err := likeToRegexp(t.value)
+		if err != nil {
+			return nil, p.errorf("invalid like pattern %s", t.value)
+		}
+		return &LikeNode{Operand: left, Pattern: t.value, regexp: re}, nil
+	case p.isOperator("is"):
+		p.next()
+		not := p.isOperator("not")
+		if not {
+			p.next()
+		}
+		if err := p.expect("null"); err != nil {
+			return nil, err
+		}
+		return &NullNode{Operand: left, Not: not}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm(operand Node, not bool) (Node, error) {
+	values, err := p.parseArray()
+	if err != nil {
+		return nil, err
+	}
+	return &TermNode{Operand: operand, Values: values, Not: not}, nil
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	left, err := p.parsePower()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/", "%") {
+		op := p.next().text
+		right, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parsePower() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOperator("**") {
+		p.next()
+		// right associative
+		right, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryNode{Op: "**", Left: left, Right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	switch {
+	case p.isOperator("-", "+"):
+		op := p.next().text
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return operand, nil
+		}
+		if value, ok := operand.(*ValueNode); ok {
+			switch v := value.Value.(type) {
+			case int64:
+				return &ValueNode{Value: -v}, nil
+			case float64:
+				return &ValueNode{Value: -v}, nil
+			}
+		}
+		return &UnaryNode{Op: "-", Operand: operand}, nil
+	case p.isOperator("!", "not"):
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryNode{Op: "not", Operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenInt:
+		p.next()
+		v, err := strconv.ParseInt(t.text, 0, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", t.text)
+		}
+		return &ValueNode{Value: v}, nil
+	case tokenFloat:
+		p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", t.text)
+		}
+		return &ValueNode{Value: v}, nil
+	case tokenString:
+		p.next()
+		return &ValueNode{Value: t.value}, nil
+	case tokenIdentifier:
+		p.next()
+		if p.isOperator("(") {
+			return p.parseCall(t.text)
+		}
+		return p.parseColumn(t.text)
+	case tokenOperator:
+		switch t.text {
+		case "true", "false":
+			p.next()
+			return &ValueNode{Value: t.text == "true"}, nil
+		case "(":
+			p.next()
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			values, err := p.parseArray()
+			if err != nil {
+				return nil, err
+			}
+			return &ValueNode{Value: values}, nil
+		}
+	}
+	return nil, p.errorf("unexpected token %s", t.text)
+}
+
+func (p *parser) parseCall(name string) (Node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	call := &CallNode{Name: strings.ToLower(name)}
+	for !p.isOperator(")") {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if !p.isOperator(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if err := checkCall(call); err != nil {
+		return nil, p.errorf("%s", err.Error())
+	}
+	return call, nil
+}
+
+// parseArray parses an array of literals like [1, 2, "a"]
+func (p *parser) parseArray() ([]any, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	values := make([]any, 0)
+	for !p.isOperator("]") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		value, ok := node.(*ValueNode)
+		if !ok {
+			return nil, p.errorf("array elements should be literals")
+		}
+		values = append(values, value.Value)
+		if !p.isOperator(",") {
+			break
+		}
+		p.next()
+	}
+	return values, p.expect("]")
+}
+
+// parseColumn parses the field and the keys after it, names not in schema are the keys of the dynamic field if any
+func (p *parser) parseColumn(name string) (Node, error) {
+	var column *ColumnNode
+	field, err := p.resolveField(name)
+	if err != nil {
+		field = p.dynamicField()
+		if field == nil {
+			return nil, err
+		}
+		column = &ColumnNode{Field: field, Path: []any{name}}
+	} else {
+		column = &ColumnNode{Field: field}
+	}
+	for p.isOperator("[") {
+		p.next()
+		t := p.next()
+		switch t.kind {
+		case tokenString:
+			if field.GetDataType() != schemapb.DataType_JSON {
+				return nil, p.errorf("only json field could be accessed by key")
+			}
+			column.Path = append(column.Path, t.value)
+		case tokenInt:
+			if field.GetDataType() != schemapb.DataType_JSON &&
+				(field.GetDataType() != schemapb.DataType_Array || len(column.Path) > 0) {
+				return nil, p.errorf("only json field and array field could be accessed by index")
+			}
+			index, err := strconv.ParseInt(t.text, 0, 64)
+			if err != nil {
+				return nil, p.errorf("invalid index %s", t.text)
+			}
+			column.Path = append(column.Path, index)
+		default:
+			return nil, p.errorf("invalid key %s of field %s", t.text, name)
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	if storage.IsVectorType(field.GetDataType()) {
+		return nil, p.errorf("vector field %s can't be used in expression", name)
+	}
+	return column, nil
+}
+
+func (p *parser) resolveField(name string) (*schemapb.FieldSchema, error) {
+	for _, field := range p.schema.GetFields() {
+		if field.GetName() == name {
+			return field, nil
+		}
+	}
+	return nil, merr.WrapErrFieldNotFound(name, "field not found in expression "+p.expr)
+}
+
+// dynamicField returns the $meta field of dynamic schema, nil if dynamic field is not enabled
+func (p *parser) dynamicField() *schemapb.FieldSchema {
+	for _, field := range p.schema.GetFields() {
+		if field.GetIsDynamic() {
+			return field
+		}
+	}
+	return nil
+}
+
+// likeToRegexp converts the like pattern into regexp, % & _ could be escaped by \
+func likeToRegexp(pattern string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			builder.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '%':
+			builder.WriteString("(?s:.*)")
+		case '_':
+			builder.WriteString("(?s:.)")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
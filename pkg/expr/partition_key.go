@@ -0,0 +1,76 @@
+package expr
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+)
+
+// PartitionKeyValues returns the values the expression pins the partition key field to,
+// ok is false if rows of any other value could match, e.g. `key > 1` or `key == 1 or pk == 1`.
+// The values are int64 for integer keys and string for VarChar keys.
+func (p *Plan) PartitionKeyValues(field *schemapb.FieldSchema) ([]any, bool) {
+	if p == nil {
+		return nil, false
+	}
+	return pinnedValues(p.Root, field)
+}
+
+func pinnedValues(node Node, field *schemapb.FieldSchema) ([]any, bool) {
+	switch n := node.(type) {
+	case *BinaryNode:
+		switch n.Op {
+		case "&&":
+			// rows of either side's values are all the candidates
+			if values, ok := pinnedValues(n.Left, field); ok {
+				return values, true
+			}
+			return pinnedValues(n.Right, field)
+		case "||":
+			left, ok := pinnedValues(n.Left, field)
+			if !ok {
+				return nil, false
+			}
+			right, ok := pinnedValues(n.Right, field)
+			if !ok {
+				return nil, false
+			}
+			return append(left, right...), true
+		case "==":
+			column, isColumn := n.Left.(*ColumnNode)
+			value, isValue := n.Right.(*ValueNode)
+			if !isColumn || !isValue {
+				column, isColumn = n.Right.(*ColumnNode)
+				value, isValue = n.Left.(*ValueNode)
+			}
+			if isColumn && isValue && isKeyColumn(column, field) && isKeyValue(value.Value, field) {
+				return []any{value.Value}, true
+			}
+		}
+	case *TermNode:
+		column, isColumn := n.Operand.(*ColumnNode)
+		if !isColumn || n.Not || !isKeyColumn(column, field) {
+			return nil, false
+		}
+		for _, value := range n.Values {
+			if !isKeyValue(value, field) {
+				return nil, false
+			}
+		}
+		return n.Values, true
+	}
+	return nil, false
+}
+
+func isKeyColumn(column *ColumnNode, field *schemapb.FieldSchema) bool {
+	return len(column.Path) == 0 && column.Field.GetFieldID() == field.GetFieldID()
+}
+
+func isKeyValue(value any, field *schemapb.FieldSchema) bool {
+	switch value.(type) {
+	case int64:
+		return typeutil.IsIntegerType(field.GetDataType())
+	case string:
+		return typeutil.IsStringType(field.GetDataType())
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+)
+
+type FlushTask struct {
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.FlushRequest
+}
+
+func NewFlushTask(
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.FlushRequest) *FlushTask {
+
+	return &FlushTask{
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// Execute seals the growing segments and persists them, the flush is done when it returns
+func (t FlushTask) Execute(ctx context.Context) (*milvuspb.FlushResponse, error) {
+	resp := &milvuspb.FlushResponse{
+		DbName:          t.req.GetDbName(),
+		CollSegIDs:      make(map[string]*schemapb.LongArray),
+		FlushCollSegIDs: make(map[string]*schemapb.LongArray),
+		CollSealTimes:   make(map[string]int64),
+		CollFlushTs:     make(map[string]uint64),
+	}
+	for _, collectionName := range t.req.GetCollectionNames() {
+		collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), collectionName)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := t.tsAllocator.AllocTimestamp()
+		if err != nil {
+			return nil, merr.WrapErrServiceUnavailable(err.Error())
+		}
+		sealed, err := t.segmentManager.Flush(ctx, collection.CollectionID)
+		if err != nil {
+			return nil, err
+		}
+		flushed := make([]int64, 0)
+		for _, segment := range t.segmentManager.GetFlushedSegments(collection.CollectionID) {
+			flushed = append(flushed, segment.ID())
+		}
+		physical, _ := tsoutil.ParseHybridTs(ts)
+		resp.CollSegIDs[collectionName] = &schemapb.LongArray{Data: sealed}
+		resp.FlushCollSegIDs[collectionName] = &schemapb.LongArray{Data: flushed}
+		resp.CollSealTimes[collectionName] = physical / 1000
+		resp.CollFlushTs[collectionName] = ts
+	}
+	return resp, nil
+}
+
+// GetFlushState checks whether all segments are flushed, segments compacted & removed are regarded as flushed
+func GetFlushState(segmentManager *segments.Manager, req *milvuspb.GetFlushStateRequest) *milvuspb.GetFlushStateResponse {
+	for _, segmentID := range req.GetSegmentIDs() {
+		segment := segmentManager.GetSegment(segmentID)
+		if segment == nil {
+			continue
+		}
+		if segment.State != commonpb.SegmentState_Flushed && segment.State != commonpb.SegmentState_Dropped {
+			return &milvuspb.GetFlushStateResponse{Flushed: false}
+		}
+	}
+	return &milvuspb.GetFlushStateResponse{Flushed: true}
+}
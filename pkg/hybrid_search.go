@@ -0,0 +1,308 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+const (
+	// RankTypeKey is the rank param of the ranker fusing the hits of hybrid search, same as milvus
+	RankTypeKey = "strategy"
+	// RankParamsKey is the rank param of the JSON params of the ranker, e.g. {"k": 60} or {"weights": [0.3, 0.7]}
+	RankParamsKey = "params"
+	// RRFRankType fuses the hits by reciprocal rank fusion, it's the default ranker
+	RRFRankType = "rrf"
+	// WeightedRankType fuses the hits by the weighted sum of their normalized scores
+	WeightedRankType = "weighted"
+	// RRFKKey is the param of the smoothing constant k of rrf
+	RRFKKey = "k"
+	// WeightsKey is the param of the weights of the searches of weighted ranker
+	WeightsKey = "weights"
+	// MaxRRFK is the upper bound of k of rrf, same as milvus
+	MaxRRFK = 16384
+)
+
+// HybridSearchRequest is the HybridSearchRequest of milvus missing in this version of proto,
+// it's a plain Go struct accepted by MilvusMini.HybridSearch only, not a message on the wire.
+// The searches of Requests run on the collection & partitions of the hybrid search, their collections must be the same
+// if set. The hits of the searches are fused by the ranker in RankParams, which also carry the limit, offset and
+// round_decimal of the fused hits. The consistency of the searches is the one of the hybrid search.
+type HybridSearchRequest struct {
+	DbName                string
+	CollectionName        string
+	PartitionNames        []string
+	Requests              []*milvuspb.SearchRequest
+	RankParams            []*commonpb.KeyValuePair
+	OutputFields          []string
+	GuaranteeTimestamp    uint64
+	TravelTimestamp       uint64
+	ConsistencyLevel      commonpb.ConsistencyLevel
+	UseDefaultConsistency bool
+}
+
+func (r *HybridSearchRequest) GetGuaranteeTimestamp() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.GuaranteeTimestamp
+}
+
+func (r *HybridSearchRequest) GetTravelTimestamp() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.TravelTimestamp
+}
+
+func (r *HybridSearchRequest) GetConsistencyLevel() commonpb.ConsistencyLevel {
+	if r == nil {
+		return commonpb.ConsistencyLevel_Strong
+	}
+	return r.ConsistencyLevel
+}
+
+func (r *HybridSearchRequest) GetUseDefaultConsistency() bool {
+	if r == nil {
+		return false
+	}
+	return r.UseDefaultConsistency
+}
+
+type HybridSearchTask struct {
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *HybridSearchRequest
+}
+
+func NewHybridSearchTask(
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *HybridSearchRequest) *HybridSearchTask {
+
+	return &HybridSearchTask{
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// rankParams are the parsed rank params of hybrid search
+type rankParams struct {
+	rankType     string
+	k            float64
+	weights      []float64
+	limit        int
+	offset       int
+	roundDecimal int
+}
+
+// Execute runs the searches at the same timestamp and fuses their hits of each query by the ranker,
+// larger fused score is better. The searches must have the same number of query vectors, their topK is
+// the number of hits of each search to fuse, their offsets are ignored.
+func (t HybridSearchTask) Execute(ctx context.Context) (*milvuspb.SearchResults, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.DbName, t.req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := t.meta.ListIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.req.Requests) == 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("no search request in hybrid search")
+	}
+	ranker, err := parseRankParams(t.req.RankParams, len(t.req.Requests))
+	if err != nil {
+		return nil, err
+	}
+	params := make([]*searchParams, 0, len(t.req.Requests))
+	queries := make([]storage.FieldData, 0, len(t.req.Requests))
+	for _, req := range t.req.Requests {
+		if req.GetCollectionName() != "" && req.GetCollectionName() != t.req.CollectionName {
+			return nil, merr.WrapErrParameterInvalidMsg("collection %s of search request mismatches collection %s of hybrid search",
+				req.GetCollectionName(), t.req.CollectionName)
+		}
+		reqParams, err := parseSearchParams(schema, req.GetSearchParams(), indexes)
+		if err != nil {
+			return nil, err
+		}
+		if reqParams.groupByField != nil {
+			return nil, merr.WrapErrParameterInvalidMsg("%s is not supported in hybrid search", GroupByFieldKey)
+		}
+		reqParams.offset = 0
+		reqQueries, err := parsePlaceholderGroup(reqParams.field, req.GetPlaceholderGroup())
+		if err != nil {
+			return nil, err
+		}
+		if len(queries) > 0 && reqQueries.RowNum() != queries[0].RowNum() {
+			return nil, merr.WrapErrParameterInvalidMsg("search requests of hybrid search have different numbers of query vectors, %d and %d",
+				queries[0].RowNum(), reqQueries.RowNum())
+		}
+		params = append(params, reqParams)
+		queries = append(queries, reqQueries)
+	}
+	outputFields, err := translateOutputFields(schema, t.req.OutputFields, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := readTimestamp(ctx, t.tsAllocator, t.segmentManager, collection, t.req)
+	if err != nil {
+		return nil, err
+	}
+	nq := queries[0].RowNum()
+	// results[i][q] is the hits of search i for query q, sources of hits are the indexes of views of all searches
+	results := make([][][]search.Hit, 0, len(t.req.Requests))
+	views := make([]*segments.SegmentView, 0)
+	readers := make([]*filteredReader, 0, len(t.req.Requests))
+	defer func() {
+		for _, reader := range readers {
+			reader.Release()
+		}
+	}()
+	for i, req := range t.req.Requests {
+		reader, err := newFilteredReader(ctx, t.segmentManager, collection, t.req.PartitionNames, req.GetDsl(), ts)
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, reader)
+		hits, err := searchReader(ctx, reader, queries[i], params[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, queryHits := range hits {
+			for j := range queryHits {
+				queryHits[j].Source += len(views)
+			}
+		}
+		views = append(views, reader.views...)
+		results = append(results, hits)
+	}
+
+	metricTypes := make([]string, 0, len(params))
+	for _, p := range params {
+		metricTypes = append(metricTypes, p.metricType)
+	}
+	fused := make([][]search.Hit, nq)
+	for q := range fused {
+		queryResults := make([][]search.Hit, 0, len(results))
+		for _, hits := range results {
+			queryResults = append(queryResults, hits[q])
+		}
+		var hits []search.Hit
+		if ranker.rankType == WeightedRankType {
+			hits = search.WeightedFusion(queryResults, metricTypes, ranker.weights)
+		} else {
+			hits = search.RRF(queryResults, ranker.k)
+		}
+		fused[q] = []search.Hit{}
+		if len(hits) > ranker.offset {
+			hits = hits[ranker.offset:]
+			if len(hits) > ranker.limit {
+				hits = hits[:ranker.limit]
+			}
+			fused[q] = hits
+		}
+	}
+	resultData, err := newSearchResultData(ctx, pkField, views, fused, ranker.limit, ranker.roundDecimal, outputFields)
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.SearchResults{
+		Status:         merr.Status(nil),
+		Results:        resultData,
+		CollectionName: collection.Name,
+	}, nil
+}
+
+// parseRankParams parses the rank params of hybrid search of num searches, rrf with the default k is used if strategy is not set
+func parseRankParams(kvs []*commonpb.KeyValuePair, num int) (*rankParams, error) {
+	ret := &rankParams{rankType: RRFRankType, k: search.DefaultRRFK, roundDecimal: -1}
+	var rankerParams map[string]json.RawMessage
+	for _, kv := range kvs {
+		var err error
+		switch kv.GetKey() {
+		case RankTypeKey:
+			ret.rankType = strings.ToLower(kv.GetValue())
+		case RankParamsKey:
+			if kv.GetValue() != "" {
+				err = json.Unmarshal([]byte(kv.GetValue()), &rankerParams)
+			}
+		case LimitKey:
+			ret.limit, err = strconv.Atoi(kv.GetValue())
+			if err == nil && (ret.limit <= 0 || ret.limit > MaxTopK) {
+				err = merr.WrapErrParameterInvalidRange(1, MaxTopK, ret.limit, "invalid limit")
+			}
+		case OffsetKey:
+			ret.offset, err = strconv.Atoi(kv.GetValue())
+		case RoundDecimalKey:
+			ret.roundDecimal, err = strconv.Atoi(kv.GetValue())
+			if err == nil && (ret.roundDecimal < -1 || ret.roundDecimal > 6) {
+				err = merr.WrapErrParameterInvalidRange(-1, 6, ret.roundDecimal, "invalid round_decimal")
+			}
+		}
+		if err != nil {
+			return nil, merr.WrapErrParameterInvalidMsg("invalid rank param %s: %s, %s", kv.GetKey(), kv.GetValue(), err.Error())
+		}
+	}
+	if ret.limit == 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("%s not found in rank params", LimitKey)
+	}
+	if ret.offset < 0 || ret.offset+ret.limit > MaxTopK {
+		return nil, merr.WrapErrParameterInvalidRange(0, MaxTopK-ret.limit, ret.offset, "invalid offset")
+	}
+
+	switch ret.rankType {
+	case RRFRankType:
+		if value, ok := rankerParams[RRFKKey]; ok {
+			if err := json.Unmarshal(value, &ret.k); err != nil {
+				return nil, merr.WrapErrParameterInvalidMsg("invalid %s of %s ranker: %s", RRFKKey, RRFRankType, string(value))
+			}
+			if ret.k <= 0 || ret.k >= MaxRRFK {
+				return nil, merr.WrapErrParameterInvalidRange(0, MaxRRFK, ret.k, "invalid k of rrf ranker")
+			}
+		}
+	case WeightedRankType:
+		value, ok := rankerParams[WeightsKey]
+		if !ok {
+			return nil, merr.WrapErrParameterInvalidMsg("%s of %s ranker not found", WeightsKey, WeightedRankType)
+		}
+		if err := json.Unmarshal(value, &ret.weights); err != nil {
+			return nil, merr.WrapErrParameterInvalidMsg("invalid %s of %s ranker: %s", WeightsKey, WeightedRankType, string(value))
+		}
+		if len(ret.weights) != num {
+			return nil, merr.WrapErrParameterInvalidMsg("the number of weights %d mismatches the number of search requests %d",
+				len(ret.weights), num)
+		}
+		for _, weight := range ret.weights {
+			if weight < 0 || weight > 1 {
+				return nil, merr.WrapErrParameterInvalidRange(0, 1, weight, "invalid weight of weighted ranker")
+			}
+		}
+	default:
+		return nil, merr.WrapErrParameterInvalidMsg("unsupported rank strategy %s", ret.rankType)
+	}
+	return ret, nil
+}
@@ -0,0 +1,467 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/federpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// DefaultIndexName is the prefix of index name if it's not specified, same as milvus
+const DefaultIndexName = "_default_idx"
+
+type CreateIndexTask struct {
+	idAllocator    allocator.Interface
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.CreateIndexRequest
+}
+
+func NewCreateIndexTask(
+	idAllocator allocator.Interface,
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.CreateIndexRequest) *CreateIndexTask {
+
+	return &CreateIndexTask{
+		idAllocator:    idAllocator,
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// Execute creates the index on the field and builds it on flushed segments asynchronously.
+// Creating the same index again is a no-op, a field has at most one index.
+// The index type of scalar field is chosen by its data type if not specified.
+func (t CreateIndexTask) Execute(ctx context.Context) error {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return err
+	}
+	field, err := getField(collection, t.req.GetFieldName())
+	if err != nil {
+		return err
+	}
+	params, err := parseIndexParams(t.req.GetExtraParams())
+	if err != nil {
+		return err
+	}
+	if !storage.IsVectorType(field.GetDataType()) {
+		delete(params, common.MetricTypeKey)
+		if params[common.IndexTypeKey] == "" {
+			params[common.IndexTypeKey] = index.DefaultScalarIndexType(field.GetDataType())
+		}
+	} else if params[common.MetricTypeKey] == "" {
+		params[common.MetricTypeKey] = defaultMetricType(field.GetDataType())
+	}
+	if _, err := index.NewIndex(field, params); err != nil {
+		return err
+	}
+	indexName := t.req.GetIndexName()
+	if indexName == "" {
+		indexName = fmt.Sprintf("%s_%d", DefaultIndexName, field.GetFieldID())
+	}
+	newIndex := &model.Index{
+		CollectionID: collection.CollectionID,
+		FieldID:      field.GetFieldID(),
+		IndexName:    indexName,
+		IndexParams:  sortedKeyValuePairs(params),
+	}
+
+	indexes, err := t.meta.ListIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range indexes {
+		sameParams := checkIndexParamsEqual(existing.IndexParams, newIndex.IndexParams)
+		if existing.FieldID == field.GetFieldID() {
+			if existing.IndexName == indexName && sameParams {
+				return nil
+			}
+			return merr.WrapErrParameterInvalidMsg("at most one distinct index is allowed per field, field %s has index %s",
+				field.GetName(), existing.IndexName)
+		}
+		if existing.IndexName == indexName {
+			return merr.WrapErrParameterInvalidMsg("index name %s is used by another field", indexName)
+		}
+	}
+
+	newIndex.IndexID, err = t.idAllocator.AllocOne()
+	if err != nil {
+		return merr.WrapErrServiceUnavailable(err.Error())
+	}
+	newIndex.CreateTime, err = t.tsAllocator.AllocTimestamp()
+	if err != nil {
+		return merr.WrapErrServiceUnavailable(err.Error())
+	}
+	return t.segmentManager.CreateIndex(ctx, newIndex)
+}
+
+type DropIndexTask struct {
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.DropIndexRequest
+}
+
+func NewDropIndexTask(meta metas.MetaTable, segmentManager *segments.Manager, request *milvuspb.DropIndexRequest) *DropIndexTask {
+	return &DropIndexTask{
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// Execute drops the index, it's a no-op if the index doesn't exist
+func (t DropIndexTask) Execute(ctx context.Context) error {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return err
+	}
+	indexes, err := getIndexes(ctx, t.meta, collection, t.req.GetFieldName(), t.req.GetIndexName())
+	if err != nil {
+		return err
+	}
+	if len(indexes) > 1 {
+		return merr.WrapErrParameterInvalidMsg("there're multiple indexes, please specify the index name")
+	}
+	for _, fieldIndex := range indexes {
+		if err := t.segmentManager.DropIndex(ctx, fieldIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DescribeIndex(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, req *milvuspb.DescribeIndexRequest) (*milvuspb.DescribeIndexResponse, error) {
+	collection, err := meta.GetCollectionByName(ctx, req.GetDbName(), req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := getIndexes(ctx, meta, collection, req.GetFieldName(), req.GetIndexName())
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return nil, merr.WrapErrIndexNotFound()
+	}
+	resp := &milvuspb.DescribeIndexResponse{Status: merr.Status(nil)}
+	for _, fieldIndex := range indexes {
+		description, err := describeIndex(ctx, meta, segmentManager, collection, fieldIndex)
+		if err != nil {
+			return nil, err
+		}
+		resp.IndexDescriptions = append(resp.IndexDescriptions, description)
+	}
+	return resp, nil
+}
+
+// GetIndexStatistics returns the same descriptions as DescribeIndex, filtered by the index name only
+func GetIndexStatistics(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, req *milvuspb.GetIndexStatisticsRequest) (*milvuspb.GetIndexStatisticsResponse, error) {
+	resp, err := DescribeIndex(ctx, meta, segmentManager, &milvuspb.DescribeIndexRequest{
+		DbName:         req.GetDbName(),
+		CollectionName: req.GetCollectionName(),
+		IndexName:      req.GetIndexName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.GetIndexStatisticsResponse{Status: merr.Status(nil), IndexDescriptions: resp.GetIndexDescriptions()}, nil
+}
+
+func GetIndexState(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, req *milvuspb.GetIndexStateRequest) (*milvuspb.GetIndexStateResponse, error) {
+	resp, err := DescribeIndex(ctx, meta, segmentManager, &milvuspb.DescribeIndexRequest{
+		DbName:         req.GetDbName(),
+		CollectionName: req.GetCollectionName(),
+		FieldName:      req.GetFieldName(),
+		IndexName:      req.GetIndexName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	state := commonpb.IndexState_Finished
+	var failReason string
+	for _, description := range resp.GetIndexDescriptions() {
+		// a failed index is reported before the others in progress
+		if description.GetState() != commonpb.IndexState_Finished && state != commonpb.IndexState_Failed {
+			state = description.GetState()
+			failReason = description.GetIndexStateFailReason()
+		}
+	}
+	return &milvuspb.GetIndexStateResponse{Status: merr.Status(nil), State: state, FailReason: failReason}, nil
+}
+
+// GetIndexBuildProgress returns the indexed rows of the index, the field or index name is required if there're multiple indexes
+func GetIndexBuildProgress(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, req *milvuspb.GetIndexBuildProgressRequest) (*milvuspb.GetIndexBuildProgressResponse, error) {
+	collection, err := meta.GetCollectionByName(ctx, req.GetDbName(), req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	fieldIndex, err := getIndex(ctx, meta, collection, req.GetFieldName(), req.GetIndexName())
+	if err != nil {
+		return nil, err
+	}
+	description, err := describeIndex(ctx, meta, segmentManager, collection, fieldIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.GetIndexBuildProgressResponse{
+		Status:      merr.Status(nil),
+		IndexedRows: description.GetIndexedRows(),
+		TotalRows:   description.GetTotalRows(),
+	}, nil
+}
+
+// ListIndexedSegment returns the segments whose index is built, the index name is required if there're multiple indexes
+func ListIndexedSegment(ctx context.Context, meta metas.MetaTable, req *federpb.ListIndexedSegmentRequest) (*federpb.ListIndexedSegmentResponse, error) {
+	collection, err := meta.GetCollectionByName(ctx, "", req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	fieldIndex, err := getIndex(ctx, meta, collection, "", req.GetIndexName())
+	if err != nil {
+		return nil, err
+	}
+	segmentIndexes, err := getBuiltSegmentIndexes(ctx, meta, fieldIndex)
+	if err != nil {
+		return nil, err
+	}
+	resp := &federpb.ListIndexedSegmentResponse{Status: merr.Status(nil), SegmentIDs: make([]int64, 0, len(segmentIndexes))}
+	for _, segmentIndex := range segmentIndexes {
+		resp.SegmentIDs = append(resp.SegmentIDs, segmentIndex.SegmentID)
+	}
+	return resp, nil
+}
+
+// segmentIndexData is the index data of a segment in DescribeSegmentIndexData, it's the metadata of index files
+type segmentIndexData struct {
+	BuildID       int64    `json:"build_id"`
+	NumRows       int64    `json:"num_rows"`
+	IndexFileKeys []string `json:"index_file_keys"`
+	IndexSize     int64    `json:"index_size"`
+	CreateTime    uint64   `json:"create_time"`
+}
+
+// DescribeSegmentIndexData returns the index file metadata of segments as json, all indexed segments if segment ids are empty
+func DescribeSegmentIndexData(ctx context.Context, meta metas.MetaTable, req *federpb.DescribeSegmentIndexDataRequest) (*federpb.DescribeSegmentIndexDataResponse, error) {
+	collection, err := meta.GetCollectionByName(ctx, "", req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	fieldIndex, err := getIndex(ctx, meta, collection, "", req.GetIndexName())
+	if err != nil {
+		return nil, err
+	}
+	segmentIndexes, err := getBuiltSegmentIndexes(ctx, meta, fieldIndex)
+	if err != nil {
+		return nil, err
+	}
+	built := make(map[int64]*model.SegmentIndex, len(segmentIndexes))
+	for _, segmentIndex := range segmentIndexes {
+		built[segmentIndex.SegmentID] = segmentIndex
+	}
+	segmentIDs := req.GetSegmentsIDs()
+	if len(segmentIDs) == 0 {
+		for _, segmentIndex := range segmentIndexes {
+			segmentIDs = append(segmentIDs, segmentIndex.SegmentID)
+		}
+	}
+	resp := &federpb.DescribeSegmentIndexDataResponse{
+		Status:      merr.Status(nil),
+		IndexData:   make(map[int64]*federpb.SegmentIndexData, len(segmentIDs)),
+		IndexParams: common.CloneKeyValuePairs(fieldIndex.IndexParams),
+	}
+	for _, segmentID := range segmentIDs {
+		segmentIndex, ok := built[segmentID]
+		if !ok {
+			return nil, merr.WrapErrSegmentNotFound(segmentID, fmt.Sprintf("index %s is not built on segment", fieldIndex.IndexName))
+		}
+		data, err := json.Marshal(&segmentIndexData{
+			BuildID:       segmentIndex.BuildID,
+			NumRows:       segmentIndex.NumRows,
+			IndexFileKeys: segmentIndex.IndexFileKeys,
+			IndexSize:     segmentIndex.IndexSize,
+			CreateTime:    segmentIndex.CreateTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.IndexData[segmentID] = &federpb.SegmentIndexData{SegmentID: segmentID, IndexData: string(data)}
+	}
+	return resp, nil
+}
+
+// describeIndex counts the rows of flushed segments, the index is finished if it's built on all of them.
+// It's failed if any build is failed, the reason is the error of the first failed build.
+func describeIndex(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, collection *model.Collection, fieldIndex *model.Index) (*milvuspb.IndexDescription, error) {
+	segments, err := meta.ListSegments(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	segmentIndexes, err := meta.ListSegmentIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	builds := make(map[UniqueID]*model.SegmentIndex)
+	for _, segmentIndex := range segmentIndexes {
+		if segmentIndex.IndexID == fieldIndex.IndexID {
+			builds[segmentIndex.SegmentID] = segmentIndex
+		}
+	}
+	description := &milvuspb.IndexDescription{
+		IndexName: fieldIndex.IndexName,
+		IndexID:   fieldIndex.IndexID,
+		Params:    common.CloneKeyValuePairs(fieldIndex.IndexParams),
+		State:     commonpb.IndexState_Finished,
+	}
+	for _, field := range collection.Fields {
+		if field.FieldID == fieldIndex.FieldID {
+			description.FieldName = field.Name
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].SegmentID < segments[j].SegmentID })
+	for _, segment := range segments {
+		if segment.State != commonpb.SegmentState_Flushed {
+			continue
+		}
+		description.TotalRows += segment.NumOfRows
+		segmentIndex, ok := builds[segment.SegmentID]
+		if ok && segmentIndex.EnableIndex {
+			description.IndexedRows += segment.NumOfRows
+			continue
+		}
+		if description.State == commonpb.IndexState_Failed {
+			continue
+		}
+		description.State = commonpb.IndexState_InProgress
+		if ok {
+			if err := segmentManager.GetBuildError(segmentIndex.BuildID); err != nil {
+				description.State = commonpb.IndexState_Failed
+				description.IndexStateFailReason = err.Error()
+			}
+		}
+	}
+	description.PendingIndexRows = description.TotalRows - description.IndexedRows
+	return description, nil
+}
+
+// getIndex returns the only index of collection matching the field name & index name if they're not empty
+func getIndex(ctx context.Context, meta metas.MetaTable, collection *model.Collection, fieldName, indexName string) (*model.Index, error) {
+	indexes, err := getIndexes(ctx, meta, collection, fieldName, indexName)
+	if err != nil {
+		return nil, err
+	}
+	switch len(indexes) {
+	case 0:
+		return nil, merr.WrapErrIndexNotFound()
+	case 1:
+		return indexes[0], nil
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("there're multiple indexes, please specify the index name")
+}
+
+// getBuiltSegmentIndexes returns the segment indexes of the index which are built, ordered by segment id
+func getBuiltSegmentIndexes(ctx context.Context, meta metas.MetaTable, fieldIndex *model.Index) ([]*model.SegmentIndex, error) {
+	segmentIndexes, err := meta.ListSegmentIndexes(ctx, fieldIndex.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*model.SegmentIndex, 0, len(segmentIndexes))
+	for _, segmentIndex := range segmentIndexes {
+		if segmentIndex.IndexID == fieldIndex.IndexID && segmentIndex.EnableIndex {
+			ret = append(ret, segmentIndex)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].SegmentID < ret[j].SegmentID })
+	return ret, nil
+}
+
+// getIndexes returns the indexes of collection matching the field name & index name if they're not empty
+func getIndexes(ctx context.Context, meta metas.MetaTable, collection *model.Collection, fieldName, indexName string) ([]*model.Index, error) {
+	indexes, err := meta.ListIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	fieldIDs := make(map[int64]string, len(collection.Fields))
+	for _, field := range collection.Fields {
+		fieldIDs[field.FieldID] = field.Name
+	}
+	ret := make([]*model.Index, 0, len(indexes))
+	for _, fieldIndex := range indexes {
+		if (fieldName == "" || fieldIDs[fieldIndex.FieldID] == fieldName) && (indexName == "" || fieldIndex.IndexName == indexName) {
+			ret = append(ret, fieldIndex)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].IndexID < ret[j].IndexID })
+	return ret, nil
+}
+
+func getField(collection *model.Collection, fieldName string) (*schemapb.FieldSchema, error) {
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	for _, field := range schema.GetFields() {
+		if field.GetName() == fieldName {
+			return field, nil
+		}
+	}
+	return nil, merr.WrapErrFieldNotFound(fieldName)
+}
+
+// parseIndexParams flattens the index params, the json of `params` is expanded, e.g. {"nlist": 128}
+func parseIndexParams(kvs []*commonpb.KeyValuePair) (map[string]string, error) {
+	ret := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		if kv.GetKey() != common.IndexParamsKey {
+			ret[kv.GetKey()] = kv.GetValue()
+			continue
+		}
+		params := make(map[string]any)
+		decoder := json.NewDecoder(bytes.NewReader([]byte(kv.GetValue())))
+		decoder.UseNumber()
+		if err := decoder.Decode(&params); err != nil {
+			return nil, merr.WrapErrParameterInvalidMsg("invalid index params %s: %s", kv.GetValue(), err.Error())
+		}
+		for key, value := range params {
+			ret[key] = fmt.Sprint(value)
+		}
+	}
+	for _, key := range []string{common.IndexTypeKey, common.MetricTypeKey} {
+		if value, ok := ret[key]; ok {
+			ret[key] = strings.ToUpper(value)
+		}
+	}
+	return ret, nil
+}
+
+func sortedKeyValuePairs(params map[string]string) []*commonpb.KeyValuePair {
+	ret := make([]*commonpb.KeyValuePair, 0, len(params))
+	for key, value := range params {
+		ret = append(ret, &commonpb.KeyValuePair{Key: key, Value: value})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].GetKey() < ret[j].GetKey() })
+	return ret
+}
+
+func checkIndexParamsEqual(a, b []*commonpb.KeyValuePair) bool {
+	var params common.KeyValuePairs = a
+	return params.Equal(b)
+}
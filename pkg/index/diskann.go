@@ -0,0 +1,389 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// diskannAlpha is the distance threshold of pruning in the second pass, larger alpha keeps more long links
+const diskannAlpha = 1.2
+
+// diskannData is the serialized content of diskann index kept in memory
+type diskannData struct {
+	Metric         string
+	Dim            int
+	MaxDegree      int
+	SearchListSize int
+	NumRows        int
+	// Medoid is the entry node of searches
+	Medoid int32
+	// PQ encodes the vectors to navigate the graph, it's trained with normalized vectors for COSINE
+	PQ    *productQuantizer
+	Codes []byte
+}
+
+// diskann is a Vamana graph index, see https://papers.nips.cc/paper/9527.
+// Only pq codes are in memory, each node of the disk file has the full vector & neighbors of one row.
+// Searches navigate the graph by pq distances, nodes visited are read from disk and reranked by full vectors.
+type diskann struct {
+	diskannData
+	distance search.DistanceFunc
+	// file is the content of disk file before SetDiskFile
+	file []byte
+	open func() (storage.FileReader, error)
+}
+
+func newDiskANN(metricType string, dim, maxDegree, searchListSize int) *diskann {
+	ret := &diskann{diskannData: diskannData{Metric: metricType, Dim: dim, MaxDegree: maxDegree, SearchListSize: searchListSize}}
+	ret.init()
+	return ret
+}
+
+func (idx *diskann) init() {
+	idx.distance, _ = search.GetDistanceFunc(idx.Metric, schemapb.DataType_FloatVector)
+}
+
+func (idx *diskann) IndexType() string {
+	return IndexTypeDiskANN
+}
+
+func (idx *diskann) MetricType() string {
+	return idx.Metric
+}
+
+// nodeSize is the bytes of each node in disk file, the full vector, the degree & the neighbors padded to max degree
+func (idx *diskann) nodeSize() int {
+	return (idx.Dim + 1 + idx.MaxDegree) * 4
+}
+
+// graphVector returns the vector to build graph & pq, vectors are normalized for COSINE
+func (idx *diskann) graphVector(vector []float32) []float32 {
+	if idx.Metric != metric.COSINE {
+		return vector
+	}
+	return normalizeVector(vector)
+}
+
+// vamanaVectors returns the vectors to build graph by L2, the graph vectors of L2 & COSINE are used as is.
+// For IP, the vectors are transformed to x' = (x, sqrt(M^2 - |x|^2)) where M is the max norm, so the L2 distance
+// |(q, 0) - x'|^2 = |q|^2 + M^2 - 2q·x of the query is smaller for larger inner product, and the graph is built
+// for the order of IP as searched.
+func (idx *diskann) vamanaVectors(rows [][]float32) [][]float32 {
+	if idx.Metric != metric.IP {
+		return rows
+	}
+	norms := make([]float32, len(rows))
+	var maxNorm float32
+	for i, row := range rows {
+		norms[i] = search.IP(row, row)
+		if norms[i] > maxNorm {
+			maxNorm = norms[i]
+		}
+	}
+	ret := make([][]float32, len(rows))
+	for i, row := range rows {
+		ret[i] = append(append(make([]float32, 0, len(row)+1), row...), float32(math.Sqrt(float64(maxNorm-norms[i]))))
+	}
+	return ret
+}
+
+func (idx *diskann) Build(vectors storage.FieldData) error {
+	data, ok := storage.ToFloatVectors(vectors)
+	if !ok || data.Dim != idx.Dim {
+		return errors.Errorf("%s index requires float vectors of dim %d", IndexTypeDiskANN, idx.Dim)
+	}
+	rows := make([][]float32, data.RowNum())
+	for i := range rows {
+		rows[i] = idx.graphVector(data.Data[i*idx.Dim : (i+1)*idx.Dim])
+	}
+	idx.NumRows = len(rows)
+	idx.file = make([]byte, len(rows)*idx.nodeSize())
+	if len(rows) == 0 {
+		return nil
+	}
+	idx.PQ = trainPQ(idx.Metric, rows, idx.Dim, defaultPQM(idx.Dim), DefaultNBits)
+	idx.Codes = make([]byte, 0, len(rows)*idx.PQ.M)
+	for _, row := range rows {
+		idx.Codes = append(idx.Codes, idx.PQ.encode(row)...)
+	}
+	var links [][]int32
+	links, idx.Medoid = buildVamana(idx.vamanaVectors(rows), idx.MaxDegree, idx.SearchListSize)
+	for i := range rows {
+		node := idx.file[i*idx.nodeSize() : (i+1)*idx.nodeSize()]
+		for d, v := range data.Data[i*idx.Dim : (i+1)*idx.Dim] {
+			binary.LittleEndian.PutUint32(node[d*4:], math.Float32bits(v))
+		}
+		binary.LittleEndian.PutUint32(node[idx.Dim*4:], uint32(len(links[i])))
+		for j, neighbor := range links[i] {
+			binary.LittleEndian.PutUint32(node[(idx.Dim+1+j)*4:], uint32(neighbor))
+		}
+	}
+	return nil
+}
+
+// readNode reads the full vector & neighbors of node from disk file
+func (idx *diskann) readNode(file storage.FileReader, id int32, buf []byte) ([]float32, []int32, error) {
+	if _, err := file.ReadAt(buf, int64(id)*int64(len(buf))); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read node %d of %s index", id, IndexTypeDiskANN)
+	}
+	vector := make([]float32, idx.Dim)
+	for d := range vector {
+		vector[d] = math.Float32frombits(binary.LittleEndian.Uint32(buf[d*4:]))
+	}
+	degree := int(binary.LittleEndian.Uint32(buf[idx.Dim*4:]))
+	if degree > idx.MaxDegree {
+		return nil, nil, errors.Errorf("node %d of %s index is corrupted", id, IndexTypeDiskANN)
+	}
+	neighbors := make([]int32, degree)
+	for j := range neighbors {
+		neighbors[j] = int32(binary.LittleEndian.Uint32(buf[(idx.Dim+1+j)*4:]))
+	}
+	return vector, neighbors, nil
+}
+
+func (idx *diskann) Search(queries storage.FieldData, topK int, params map[string]any, valid []bool) ([][]search.Hit, error) {
+	data, ok := storage.ToFloatVectors(queries)
+	if !ok || data.Dim != idx.Dim {
+		return nil, merr.WrapErrParameterInvalidMsg("%s index requires float vectors of dim %d", IndexTypeDiskANN, idx.Dim)
+	}
+	_, set := params[SearchListKey]
+	searchList, err := getSearchIntParam(params, SearchListKey, maxInt(topK, DefaultSearchList), 1, MaxSearchList)
+	if err != nil {
+		return nil, err
+	}
+	if set && searchList < topK {
+		return nil, merr.WrapErrParameterInvalidMsg("%s(%d) should be larger than topk(%d)", SearchListKey, searchList, topK)
+	}
+
+	ret := make([][]search.Hit, data.RowNum())
+	for q := range ret {
+		ret[q] = []search.Hit{}
+	}
+	if idx.NumRows == 0 {
+		return ret, nil
+	}
+	file, err := idx.openFile()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	buf := make([]byte, idx.nodeSize())
+	for q := range ret {
+		query := data.Data[q*idx.Dim : (q+1)*idx.Dim]
+		table := idx.PQ.distanceTable(idx.graphVector(query))
+		collector := search.NewTopK(topK, idx.Metric)
+		// pq distance to navigate, smaller is nearer
+		distance := func(id int32) float32 {
+			adc := idx.PQ.adc(table, idx.Codes[int(id)*idx.PQ.M:int(id+1)*idx.PQ.M])
+			if idx.Metric == metric.IP {
+				return -adc
+			}
+			return adc
+		}
+		expand := func(id int32) ([]int32, error) {
+			vector, neighbors, err := idx.readNode(file, id, buf)
+			if err != nil {
+				return nil, err
+			}
+			if valid == nil || (int(id) < len(valid) && valid[id]) {
+				collector.Push(int(id), idx.distance(query, vector))
+			}
+			return neighbors, nil
+		}
+		if _, err := greedySearch(idx.Medoid, searchList, distance, expand); err != nil {
+			return nil, err
+		}
+		ret[q] = collector.Sorted()
+	}
+	return ret, nil
+}
+
+func (idx *diskann) openFile() (storage.FileReader, error) {
+	if idx.file != nil {
+		return memoryFile{bytes.NewReader(idx.file)}, nil
+	}
+	if idx.open == nil {
+		return nil, errors.Errorf("disk file of %s index is not set", IndexTypeDiskANN)
+	}
+	return idx.open()
+}
+
+func (idx *diskann) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&idx.diskannData); err != nil {
+		return nil, errors.Wrap(err, "failed to serialize diskann index")
+	}
+	return buf.Bytes(), nil
+}
+
+func (idx *diskann) SerializeDiskFile() ([]byte, error) {
+	if idx.file == nil {
+		return nil, errors.Errorf("disk file of %s index is not in memory", IndexTypeDiskANN)
+	}
+	return idx.file, nil
+}
+
+func (idx *diskann) SetDiskFile(open func() (storage.FileReader, error)) {
+	idx.file = nil
+	idx.open = open
+}
+
+func (idx *diskann) Load(data []byte) error {
+	var loaded diskannData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&loaded); err != nil {
+		return errors.Wrap(err, "failed to deserialize diskann index")
+	}
+	if loaded.Metric != idx.Metric || loaded.Dim != idx.Dim {
+		return errors.Errorf("index file of %s %s dim %d mismatches index %s %s dim %d",
+			IndexTypeDiskANN, loaded.Metric, loaded.Dim, IndexTypeDiskANN, idx.Metric, idx.Dim)
+	}
+	idx.diskannData = loaded
+	idx.file = nil
+	idx.init()
+	return nil
+}
+
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
+
+// buildVamana builds the graph of rows by two passes, the first pass prunes with alpha 1 and the second with diskannAlpha.
+// It returns the neighbors of each row and the medoid.
+func buildVamana(rows [][]float32, maxDegree, searchListSize int) ([][]int32, int32) {
+	medoid := findMedoid(rows)
+	links := make([][]int32, len(rows))
+	rng := rand.New(rand.NewSource(int64(len(rows))))
+	for _, alpha := range []float32{1, diskannAlpha} {
+		for _, i := range rng.Perm(len(rows)) {
+			p := int32(i)
+			visited, _ := greedySearch(medoid, searchListSize, func(id int32) float32 {
+				return search.L2(rows[p], rows[id])
+			}, func(id int32) ([]int32, error) {
+				return links[id], nil
+			})
+			links[p] = robustPrune(rows, p, append(visited, links[p]...), alpha, maxDegree)
+			for _, neighbor := range links[p] {
+				if containsLink(links[neighbor], p) {
+					continue
+				}
+				if len(links[neighbor]) < maxDegree {
+					links[neighbor] = append(links[neighbor], p)
+				} else {
+					links[neighbor] = robustPrune(rows, neighbor, append(links[neighbor], p), alpha, maxDegree)
+				}
+			}
+		}
+	}
+	return links, medoid
+}
+
+// findMedoid returns the row nearest to the mean of rows
+func findMedoid(rows [][]float32) int32 {
+	mean := make([]float32, len(rows[0]))
+	for _, row := range rows {
+		for d, v := range row {
+			mean[d] += v / float32(len(rows))
+		}
+	}
+	return int32(nearestCentroid(rows, mean))
+}
+
+// robustPrune selects at most maxDegree neighbors of p from candidates, a candidate is pruned
+// if it's alpha times nearer to a selected neighbor than to p, so the graph has both short and long links.
+func robustPrune(rows [][]float32, p int32, candidates []int32, alpha float32, maxDegree int) []int32 {
+	seen := map[int32]struct{}{p: {}}
+	sorted := make([]candidate, 0, len(candidates))
+	for _, id := range candidates {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		sorted = append(sorted, candidate{id: id, dist: search.L2(rows[p], rows[id])})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].dist < sorted[j].dist })
+
+	ret := make([]int32, 0, maxDegree)
+	for len(sorted) > 0 && len(ret) < maxDegree {
+		nearest := sorted[0]
+		ret = append(ret, nearest.id)
+		remaining := make([]candidate, 0, len(sorted)-1)
+		for _, c := range sorted[1:] {
+			// distances are squared
+			if alpha*alpha*search.L2(rows[nearest.id], rows[c.id]) > c.dist {
+				remaining = append(remaining, c)
+			}
+		}
+		sorted = remaining
+	}
+	return ret
+}
+
+func containsLink(links []int32, id int32) bool {
+	for _, link := range links {
+		if link == id {
+			return true
+		}
+	}
+	return false
+}
+
+// greedySearch expands the nearest candidate not expanded until the listSize nearest candidates are all expanded,
+// distance returns the distance of node to the query and expand returns the neighbors of node.
+// It returns the expanded nodes.
+func greedySearch(start int32, listSize int, distance func(int32) float32, expand func(int32) ([]int32, error)) ([]int32, error) {
+	list := []candidate{{id: start, dist: distance(start)}}
+	seen := map[int32]struct{}{start: {}}
+	expanded := make(map[int32]struct{})
+	var ret []int32
+	for {
+		next := -1
+		for i, c := range list {
+			if _, ok := expanded[c.id]; !ok {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return ret, nil
+		}
+		id := list[next].id
+		expanded[id] = struct{}{}
+		ret = append(ret, id)
+		neighbors, err := expand(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, neighbor := range neighbors {
+			if _, ok := seen[neighbor]; ok {
+				continue
+			}
+			seen[neighbor] = struct{}{}
+			dist := distance(neighbor)
+			if len(list) >= listSize && dist >= list[len(list)-1].dist {
+				continue
+			}
+			pos := sort.Search(len(list), func(i int) bool { return list[i].dist > dist })
+			list = append(list, candidate{})
+			copy(list[pos+1:], list[pos:])
+			list[pos] = candidate{id: neighbor, dist: dist}
+			if len(list) > listSize {
+				list = list[:listSize]
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// flatData is the serialized content of flat index
+type flatData struct {
+	Metric  string
+	Dim     int
+	Vectors []float32
+	// Packed are the float16 or bfloat16 vectors in their own encoding
+	Packed []byte
+}
+
+// flat keeps the vectors as they're inserted and searches them by brute force, it's exact.
+// float16 and bfloat16 vectors are kept packed and decoded per distance computation.
+// Vectors could be added after built, so it's used by growing segments as well.
+type flat struct {
+	lock sync.RWMutex
+	flatData
+	dataType schemapb.DataType
+}
+
+func newFlat(metricType string, dataType schemapb.DataType, dim int) *flat {
+	return &flat{flatData: flatData{Metric: metricType, Dim: dim}, dataType: dataType}
+}
+
+func (idx *flat) IndexType() string {
+	return IndexTypeFlat
+}
+
+func (idx *flat) MetricType() string {
+	return idx.Metric
+}
+
+func (idx *flat) Build(vectors storage.FieldData) error {
+	return idx.Add(vectors)
+}
+
+// Add appends the vectors, their offsets follow the vectors added before
+func (idx *flat) Add(vectors storage.FieldData) error {
+	if isHalfVectorType(idx.dataType) {
+		packed, ok := packedVectors(vectors, idx.dataType, idx.Dim)
+		if !ok {
+			return errors.Errorf("%s index requires %s vectors of dim %d", IndexTypeFlat, idx.dataType.String(), idx.Dim)
+		}
+		idx.lock.Lock()
+		defer idx.lock.Unlock()
+		idx.Packed = append(idx.Packed, packed...)
+		return nil
+	}
+	data, ok := storage.ToFloatVectors(vectors)
+	if !ok || data.Dim != idx.Dim {
+		return errors.Errorf("%s index requires float vectors of dim %d", IndexTypeFlat, idx.Dim)
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.Vectors = append(idx.Vectors, data.Data...)
+	return nil
+}
+
+func (idx *flat) Search(queries storage.FieldData, topK int, params map[string]any, valid []bool) ([][]search.Hit, error) {
+	if isHalfVectorType(idx.dataType) {
+		if _, ok := packedVectors(queries, idx.dataType, idx.Dim); !ok {
+			return nil, merr.WrapErrParameterInvalidMsg("%s index requires %s vectors of dim %d", IndexTypeFlat, idx.dataType.String(), idx.Dim)
+		}
+		idx.lock.RLock()
+		defer idx.lock.RUnlock()
+		packed := idx.Packed
+		if valid != nil && len(valid)*idx.Dim*2 < len(packed) {
+			packed = packed[:len(valid)*idx.Dim*2]
+		}
+		return search.BruteForce(halfVectors(idx.dataType, packed, idx.Dim), queries, idx.Metric, topK, valid)
+	}
+	data, ok := storage.ToFloatVectors(queries)
+	if !ok || data.Dim != idx.Dim {
+		return nil, merr.WrapErrParameterInvalidMsg("%s index requires float vectors of dim %d", IndexTypeFlat, idx.Dim)
+	}
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	vectors := &storage.FloatVectorFieldData{Data: idx.Vectors, Dim: idx.Dim}
+	// valid only covers the rows when the view is taken, rows added later are invisible
+	if valid != nil && len(valid) < vectors.RowNum() {
+		vectors.Data = vectors.Data[:len(valid)*idx.Dim]
+	}
+	return search.BruteForce(vectors, data, idx.Metric, topK, valid)
+}
+
+func (idx *flat) Serialize() ([]byte, error) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&idx.flatData); err != nil {
+		return nil, errors.Wrap(err, "failed to serialize flat index")
+	}
+	return buf.Bytes(), nil
+}
+
+func (idx *flat) Load(data []byte) error {
+	var loaded flatData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&loaded); err != nil {
+		return errors.Wrap(err, "failed to deserialize flat index")
+	}
+	if loaded.Metric != idx.Metric || loaded.Dim != idx.Dim {
+		return errors.Errorf("index file of %s %s dim %d mismatches index %s %s dim %d",
+			IndexTypeFlat, loaded.Metric, loaded.Dim, IndexTypeFlat, idx.Metric, idx.Dim)
+	}
+	// index files of half vectors used to keep them decoded
+	if isHalfVectorType(idx.dataType) && len(loaded.Vectors) > 0 {
+		loaded.Packed, loaded.Vectors = encodeHalf(idx.dataType, loaded.Vectors), nil
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.flatData = loaded
+	return nil
+}
@@ -0,0 +1,385 @@
+package index
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/gob"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// hnswData is the serialized content of hnsw index
+type hnswData struct {
+	Metric         string
+	Dim            int
+	M              int
+	EfConstruction int
+	Vectors        []float32
+	// Packed are the float16 or bfloat16 vectors in their own encoding
+	Packed []byte
+	// Links are the neighbors of each node on each level
+	Links      [][][]int32
+	EntryPoint int32
+	MaxLevel   int
+}
+
+// hnsw is a hierarchical navigable small world graph, see https://arxiv.org/abs/1603.09320.
+// Vectors could be added after built, so it's used by growing segments as well.
+// float16 and bfloat16 vectors are kept packed and decoded per distance computation.
+type hnsw struct {
+	lock sync.RWMutex
+	hnswData
+	dataType schemapb.DataType
+	distance search.DistanceFunc
+	// positive is true if larger score is nearer, the score is negated as the distance
+	positive bool
+	rng      *rand.Rand
+}
+
+func newHNSW(metricType string, dataType schemapb.DataType, dim, m, efConstruction int) *hnsw {
+	ret := &hnsw{hnswData: hnswData{Metric: metricType, Dim: dim, M: m, EfConstruction: efConstruction, EntryPoint: -1},
+		dataType: dataType}
+	ret.init()
+	return ret
+}
+
+func (idx *hnsw) init() {
+	idx.distance, _ = search.GetDistanceFunc(idx.Metric, idx.dataType)
+	idx.positive = metric.PositivelyRelated(idx.Metric)
+	idx.rng = rand.New(rand.NewSource(int64(len(idx.Links))))
+}
+
+func (idx *hnsw) IndexType() string {
+	return IndexTypeHNSW
+}
+
+func (idx *hnsw) MetricType() string {
+	return idx.Metric
+}
+
+func (idx *hnsw) Build(vectors storage.FieldData) error {
+	return idx.Add(vectors)
+}
+
+// Add inserts the vectors into the graph, their offsets follow the vectors added before
+func (idx *hnsw) Add(vectors storage.FieldData) error {
+	if isHalfVectorType(idx.dataType) {
+		packed, ok := packedVectors(vectors, idx.dataType, idx.Dim)
+		if !ok {
+			return errors.Errorf("%s index requires %s vectors of dim %d", IndexTypeHNSW, idx.dataType.String(), idx.Dim)
+		}
+		idx.lock.Lock()
+		defer idx.lock.Unlock()
+		for i := 0; i < len(packed)/idx.Dim/2; i++ {
+			idx.Packed = append(idx.Packed, packed[i*idx.Dim*2:(i+1)*idx.Dim*2]...)
+			idx.insert(int32(len(idx.Links)))
+		}
+		return nil
+	}
+	data, ok := storage.ToFloatVectors(vectors)
+	if !ok || data.Dim != idx.Dim {
+		return errors.Errorf("%s index requires float vectors of dim %d", IndexTypeHNSW, idx.Dim)
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	for i := 0; i < data.RowNum(); i++ {
+		idx.Vectors = append(idx.Vectors, data.Data[i*idx.Dim:(i+1)*idx.Dim]...)
+		idx.insert(int32(len(idx.Links)))
+	}
+	return nil
+}
+
+// vector returns the vector of node, it's []byte of packed float16 or bfloat16 vectors, or []float32
+func (idx *hnsw) vector(id int32) any {
+	if isHalfVectorType(idx.dataType) {
+		return idx.Packed[int(id)*idx.Dim*2 : int(id+1)*idx.Dim*2]
+	}
+	return idx.Vectors[int(id)*idx.Dim : int(id+1)*idx.Dim]
+}
+
+// dist returns the distance of node to the query, smaller is nearer
+func (idx *hnsw) dist(query any, id int32) float32 {
+	score := idx.distance(query, idx.vector(id))
+	if idx.positive {
+		return -score
+	}
+	return score
+}
+
+func (idx *hnsw) maxLinks(level int) int {
+	if level == 0 {
+		return idx.M * 2
+	}
+	return idx.M
+}
+
+func (idx *hnsw) randomLevel() int {
+	return int(math.Floor(-math.Log(1-idx.rng.Float64()) / math.Log(float64(idx.M))))
+}
+
+func (idx *hnsw) insert(id int32) {
+	level := idx.randomLevel()
+	idx.Links = append(idx.Links, make([][]int32, level+1))
+	if idx.EntryPoint < 0 {
+		idx.EntryPoint, idx.MaxLevel = id, level
+		return
+	}
+	query := idx.vector(id)
+	entry := []candidate{{id: idx.EntryPoint, dist: idx.dist(query, idx.EntryPoint)}}
+	for l := idx.MaxLevel; l > level; l-- {
+		entry = idx.searchLayer(query, entry, 1, l, nil)
+	}
+	for l := minInt(level, idx.MaxLevel); l >= 0; l-- {
+		candidates := idx.searchLayer(query, entry, idx.EfConstruction, l, nil)
+		neighbors := idx.selectNeighbors(candidates, idx.M)
+		idx.Links[id][l] = make([]int32, 0, len(neighbors))
+		for _, neighbor := range neighbors {
+			idx.Links[id][l] = append(idx.Links[id][l], neighbor.id)
+			idx.connect(neighbor.id, id, l)
+		}
+		entry = candidates
+	}
+	if level > idx.MaxLevel {
+		idx.EntryPoint, idx.MaxLevel = id, level
+	}
+}
+
+// connect links node to neighbor on level, the links of node are pruned if exceeds the limit
+func (idx *hnsw) connect(node, neighbor int32, level int) {
+	links := append(idx.Links[node][level], neighbor)
+	if len(links) <= idx.maxLinks(level) {
+		idx.Links[node][level] = links
+		return
+	}
+	vector := idx.vector(node)
+	candidates := make([]candidate, 0, len(links))
+	for _, link := range links {
+		candidates = append(candidates, candidate{id: link, dist: idx.dist(vector, link)})
+	}
+	sortCandidates(candidates)
+	selected := idx.selectNeighbors(candidates, idx.maxLinks(level))
+	idx.Links[node][level] = idx.Links[node][level][:0]
+	for _, c := range selected {
+		idx.Links[node][level] = append(idx.Links[node][level], c.id)
+	}
+}
+
+// selectNeighbors selects at most m neighbors from the sorted candidates by the heuristic of the paper,
+// a candidate is skipped if it's nearer to a selected neighbor than to the query, so the links are diverse.
+func (idx *hnsw) selectNeighbors(candidates []candidate, m int) []candidate {
+	if len(candidates) <= m {
+		return candidates
+	}
+	ret := make([]candidate, 0, m)
+	for _, c := range candidates {
+		if len(ret) >= m {
+			break
+		}
+		diverse := true
+		for _, selected := range ret {
+			if idx.dist(idx.vector(selected.id), c.id) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// searchLayer returns the ef nearest nodes of query on level in ascending order of distance.
+// Nodes not accepted are traversed but not returned, accept could be nil if all nodes are accepted.
+func (idx *hnsw) searchLayer(query any, entry []candidate, ef, level int, accept func(int32) bool) []candidate {
+	visited := make([]uint64, (len(idx.Links)+63)/64)
+	isVisited := func(id int32) bool {
+		ret := visited[id/64]&(1<<(id%64)) != 0
+		visited[id/64] |= 1 << (id % 64)
+		return ret
+	}
+	candidates := &candidateHeap{}
+	results := &candidateHeap{max: true}
+	for _, c := range entry {
+		isVisited(c.id)
+		heap.Push(candidates, c)
+		if accept == nil || accept(c.id) {
+			heap.Push(results, c)
+		}
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.dist > results.items[0].dist {
+			break
+		}
+		if level >= len(idx.Links[c.id]) {
+			continue
+		}
+		for _, neighbor := range idx.Links[c.id][level] {
+			if isVisited(neighbor) {
+				continue
+			}
+			dist := idx.dist(query, neighbor)
+			if results.Len() < ef || dist < results.items[0].dist {
+				heap.Push(candidates, candidate{id: neighbor, dist: dist})
+				if accept == nil || accept(neighbor) {
+					heap.Push(results, candidate{id: neighbor, dist: dist})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+	ret := make([]candidate, results.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(results).(candidate)
+	}
+	return ret
+}
+
+func (idx *hnsw) Search(queries storage.FieldData, topK int, params map[string]any, valid []bool) ([][]search.Hit, error) {
+	if isHalfVectorType(idx.dataType) {
+		if _, ok := packedVectors(queries, idx.dataType, idx.Dim); !ok {
+			return nil, merr.WrapErrParameterInvalidMsg("%s index requires %s vectors of dim %d", IndexTypeHNSW, idx.dataType.String(), idx.Dim)
+		}
+	} else {
+		data, ok := storage.ToFloatVectors(queries)
+		if !ok || data.Dim != idx.Dim {
+			return nil, merr.WrapErrParameterInvalidMsg("%s index requires float vectors of dim %d", IndexTypeHNSW, idx.Dim)
+		}
+		queries = data
+	}
+	_, set := params[EfKey]
+	ef, err := getSearchIntParam(params, EfKey, maxInt(topK, DefaultEf), 1, MaxEf)
+	if err != nil {
+		return nil, err
+	}
+	if set && ef < topK {
+		return nil, merr.WrapErrParameterInvalidMsg("%s(%d) should be larger than topk(%d)", EfKey, ef, topK)
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	// valid only covers the rows when the view is taken, rows added later are invisible
+	accept := func(id int32) bool {
+		return int(id) < len(valid) && valid[id]
+	}
+	if valid == nil {
+		accept = nil
+	}
+	ret := make([][]search.Hit, queries.RowNum())
+	for q := range ret {
+		ret[q] = []search.Hit{}
+		if idx.EntryPoint < 0 {
+			continue
+		}
+		query := queries.GetRow(q)
+		entry := []candidate{{id: idx.EntryPoint, dist: idx.dist(query, idx.EntryPoint)}}
+		for l := idx.MaxLevel; l > 0; l-- {
+			entry = idx.searchLayer(query, entry, 1, l, nil)
+		}
+		for i, c := range idx.searchLayer(query, entry, ef, 0, accept) {
+			if i >= topK {
+				break
+			}
+			score := c.dist
+			if idx.positive {
+				score = -score
+			}
+			ret[q] = append(ret[q], search.Hit{Offset: int(c.id), Score: score})
+		}
+	}
+	return ret, nil
+}
+
+func (idx *hnsw) Serialize() ([]byte, error) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&idx.hnswData); err != nil {
+		return nil, errors.Wrap(err, "failed to serialize hnsw index")
+	}
+	return buf.Bytes(), nil
+}
+
+func (idx *hnsw) Load(data []byte) error {
+	var loaded hnswData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&loaded); err != nil {
+		return errors.Wrap(err, "failed to deserialize hnsw index")
+	}
+	if loaded.Metric != idx.Metric || loaded.Dim != idx.Dim {
+		return errors.Errorf("index file of %s %s dim %d mismatches index %s %s dim %d",
+			IndexTypeHNSW, loaded.Metric, loaded.Dim, IndexTypeHNSW, idx.Metric, idx.Dim)
+	}
+	// index files of half vectors used to keep them decoded
+	if isHalfVectorType(idx.dataType) && len(loaded.Vectors) > 0 {
+		loaded.Packed, loaded.Vectors = encodeHalf(idx.dataType, loaded.Vectors), nil
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.hnswData = loaded
+	idx.init()
+	return nil
+}
+
+type candidate struct {
+	id   int32
+	dist float32
+}
+
+func sortCandidates(candidates []candidate) {
+	h := &candidateHeap{items: candidates}
+	heap.Init(h)
+	sorted := make([]candidate, 0, len(candidates))
+	for h.Len() > 0 {
+		sorted = append(sorted, heap.Pop(h).(candidate))
+	}
+	copy(candidates, sorted)
+}
+
+// candidateHeap is a min heap of distance, or max heap if max is set
+type candidateHeap struct {
+	items []candidate
+	max   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].dist > h.items[j].dist
+	}
+	return h.items[i].dist < h.items[j].dist
+}
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)    { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
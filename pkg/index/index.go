@@ -0,0 +1,308 @@
+package index
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+const (
+	IndexTypeFlat    = "FLAT"
+	IndexTypeIvfFlat = "IVF_FLAT"
+	IndexTypeIvfSQ8  = "IVF_SQ8"
+	IndexTypeIvfPQ   = "IVF_PQ"
+	IndexTypeHNSW    = "HNSW"
+	IndexTypeDiskANN = "DISKANN"
+
+	IndexTypeSparseInverted = "SPARSE_INVERTED_INDEX"
+	IndexTypeSparseWand     = "SPARSE_WAND"
+
+	IndexTypeSorted   = "STL_SORT"
+	IndexTypeInverted = "INVERTED"
+	IndexTypeTrie     = "TRIE"
+
+	NListKey  = "nlist"
+	NProbeKey = "nprobe"
+	PQMKey    = "m"
+	NBitsKey  = "nbits"
+
+	MKey              = "M"
+	EfConstructionKey = "efConstruction"
+	EfKey             = "ef"
+
+	MaxDegreeKey      = "max_degree"
+	SearchListSizeKey = "search_list_size"
+	SearchListKey     = "search_list"
+
+	DropRatioBuildKey  = "drop_ratio_build"
+	DropRatioSearchKey = "drop_ratio_search"
+
+	DefaultNList  = 128
+	DefaultNProbe = 8
+	MaxNList      = 65536
+	DefaultNBits  = 8
+	MaxNBits      = 8
+
+	DefaultM              = 16
+	MaxM                  = 2048
+	DefaultEfConstruction = 200
+	DefaultEf             = 64
+	MaxEf                 = 32768
+
+	DefaultMaxDegree      = 56
+	MaxMaxDegree          = 512
+	DefaultSearchListSize = 100
+	DefaultSearchList     = 16
+	MaxSearchList         = 65536
+)
+
+// Index is built on the rows of one field in one segment, rows are identified by offsets
+type Index interface {
+	IndexType() string
+	// Build adds all rows, it's called once on an empty index
+	Build(data storage.FieldData) error
+	Serialize() ([]byte, error)
+	Load(data []byte) error
+}
+
+// VectorIndex is an index of vector field
+type VectorIndex interface {
+	Index
+	MetricType() string
+	// Search returns the topK nearest rows of each query, rows not valid are skipped.
+	// valid could be nil if all rows are candidates, params are the search params like nprobe.
+	Search(queries storage.FieldData, topK int, params map[string]any, valid []bool) ([][]search.Hit, error)
+}
+
+// ScalarIndex is an index of scalar field to evaluate filters without scanning rows.
+// Lookups return whether each row matches, ok is false if the index doesn't support the lookup.
+type ScalarIndex interface {
+	Index
+	// In returns the rows equal to any of the values
+	In(values []any) (ret []bool, ok bool)
+	// Range returns the rows between lower and upper, a nil bound is unbounded
+	Range(lower any, includeLower bool, upper any, includeUpper bool) (ret []bool, ok bool)
+	// PrefixMatch returns the string rows starting with prefix
+	PrefixMatch(prefix string) (ret []bool, ok bool)
+}
+
+// GrowingIndex is an index accepting vectors after built, so it could index the rows of growing segments
+type GrowingIndex interface {
+	VectorIndex
+	// Add appends the vectors, their offsets follow the vectors added before
+	Add(vectors storage.FieldData) error
+}
+
+// DiskIndex keeps the full vectors in a disk file besides the serialized index, they're read while searching
+type DiskIndex interface {
+	VectorIndex
+	// SerializeDiskFile returns the content of the disk file, it's only available before SetDiskFile
+	SerializeDiskFile() ([]byte, error)
+	// SetDiskFile releases the disk file content in memory, it's read by the readers of open afterwards
+	SetDiskFile(open func() (storage.FileReader, error))
+}
+
+// IsQuantized returns whether the index of type keeps only the compressed vectors in memory,
+// the full vectors of the fields served by them are not kept in memory but read from binlogs when needed.
+func IsQuantized(indexType string) bool {
+	switch strings.ToUpper(indexType) {
+	case IndexTypeIvfSQ8, IndexTypeIvfPQ, IndexTypeDiskANN:
+		return true
+	}
+	return false
+}
+
+// NewIndex creates an empty index of the field, params must contain index_type, and metric_type for vector field
+func NewIndex(field *schemapb.FieldSchema, params map[string]string) (Index, error) {
+	indexType := strings.ToUpper(params[common.IndexTypeKey])
+	metricType := strings.ToUpper(params[common.MetricTypeKey])
+	if !storage.IsVectorType(field.GetDataType()) {
+		return newScalarIndex(field, indexType)
+	}
+	if _, err := search.GetDistanceFunc(metricType, field.GetDataType()); err != nil {
+		return nil, err
+	}
+	if storage.IsSparseVectorType(field.GetDataType()) {
+		if indexType != IndexTypeSparseInverted && indexType != IndexTypeSparseWand {
+			return nil, merr.WrapErrParameterInvalidMsg("index type %s is not supported for field %s of %s",
+				indexType, field.GetName(), field.GetDataType().String())
+		}
+		dropRatio, err := getFloatParam(params, DropRatioBuildKey, 0, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		return newSparseInverted(indexType, metricType, dropRatio), nil
+	}
+	dim, err := storage.GetDim(field)
+	if err != nil {
+		return nil, err
+	}
+	switch indexType {
+	case IndexTypeFlat:
+		if !storage.IsFloatVectorType(field.GetDataType()) {
+			break
+		}
+		return newFlat(metricType, field.GetDataType(), int(dim)), nil
+	case IndexTypeIvfFlat, IndexTypeIvfSQ8:
+		if !storage.IsFloatVectorType(field.GetDataType()) {
+			break
+		}
+		nlist, err := getIntParam(params, NListKey, DefaultNList, 1, MaxNList)
+		if err != nil {
+			return nil, err
+		}
+		return newIVF(indexType, metricType, field.GetDataType(), int(dim), nlist), nil
+	case IndexTypeIvfPQ:
+		if !storage.IsFloatVectorType(field.GetDataType()) {
+			break
+		}
+		nlist, err := getIntParam(params, NListKey, DefaultNList, 1, MaxNList)
+		if err != nil {
+			return nil, err
+		}
+		m, err := getIntParam(params, PQMKey, defaultPQM(int(dim)), 1, int(dim))
+		if err != nil {
+			return nil, err
+		}
+		if int(dim)%m != 0 {
+			return nil, merr.WrapErrParameterInvalidMsg("dim %d should be a multiple of %s %d", dim, PQMKey, m)
+		}
+		nbits, err := getIntParam(params, NBitsKey, DefaultNBits, 1, MaxNBits)
+		if err != nil {
+			return nil, err
+		}
+		return newIVFPQ(metricType, field.GetDataType(), int(dim), nlist, m, nbits), nil
+	case IndexTypeHNSW:
+		if !storage.IsFloatVectorType(field.GetDataType()) {
+			break
+		}
+		m, err := getIntParam(params, MKey, DefaultM, 2, MaxM)
+		if err != nil {
+			return nil, err
+		}
+		efConstruction, err := getIntParam(params, EfConstructionKey, DefaultEfConstruction, 1, MaxEf)
+		if err != nil {
+			return nil, err
+		}
+		return newHNSW(metricType, field.GetDataType(), int(dim), m, efConstruction), nil
+	case IndexTypeDiskANN:
+		if !storage.IsFloatVectorType(field.GetDataType()) {
+			break
+		}
+		maxDegree, err := getIntParam(params, MaxDegreeKey, DefaultMaxDegree, 1, MaxMaxDegree)
+		if err != nil {
+			return nil, err
+		}
+		searchListSize, err := getIntParam(params, SearchListSizeKey, DefaultSearchListSize, 1, MaxSearchList)
+		if err != nil {
+			return nil, err
+		}
+		return newDiskANN(metricType, int(dim), maxDegree, searchListSize), nil
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("index type %s is not supported for field %s of %s",
+		indexType, field.GetName(), field.GetDataType().String())
+}
+
+// getIntParam returns the int value of the build param in [min, max], or the default value if not set
+func getIntParam(params map[string]string, key string, defaultValue, min, max int) (int, error) {
+	value, ok := params[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	ret, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %s", key, value)
+	}
+	if ret < min || ret > max {
+		return 0, merr.WrapErrParameterInvalidRange(min, max, ret, "invalid "+key)
+	}
+	return ret, nil
+}
+
+// getFloatParam returns the float value of the build param in [min, max), or the default value if not set
+func getFloatParam(params map[string]string, key string, defaultValue, min, max float64) (float64, error) {
+	value, ok := params[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	ret, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %s", key, value)
+	}
+	if ret < min || ret >= max {
+		return 0, merr.WrapErrParameterInvalidRange(min, max, ret, "invalid "+key)
+	}
+	return ret, nil
+}
+
+// getSearchIntParam returns the int value of the search param in [min, max], or the default value if not set.
+// Search params are decoded from json, so numbers are float64.
+func getSearchIntParam(params map[string]any, key string, defaultValue, min, max int) (int, error) {
+	value, ok := params[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	var ret int
+	switch v := value.(type) {
+	case float64:
+		ret = int(v)
+		if float64(ret) != v {
+			return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+		}
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+		}
+		ret = int(i)
+	case string:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+		}
+		ret = i
+	default:
+		return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+	}
+	if ret < min || ret > max {
+		return 0, merr.WrapErrParameterInvalidRange(min, max, ret, "invalid "+key)
+	}
+	return ret, nil
+}
+
+// getSearchFloatParam returns the float value of the search param in [min, max), or the default value if not set
+func getSearchFloatParam(params map[string]any, key string, defaultValue, min, max float64) (float64, error) {
+	value, ok := params[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	var ret float64
+	switch v := value.(type) {
+	case float64:
+		ret = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+		}
+		ret = f
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+		}
+		ret = f
+	default:
+		return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %v", key, value)
+	}
+	if ret < min || ret >= max {
+		return 0, merr.WrapErrParameterInvalidRange(min, max, ret, "invalid "+key)
+	}
+	return ret, nil
+}
@@ -0,0 +1,532 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"testing"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var testField = &schemapb.FieldSchema{
+	Name:       "vec",
+	DataType:   schemapb.DataType_FloatVector,
+	TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "8"}},
+}
+
+func randomVectors(rng *rand.Rand, num int) *storage.FloatVectorFieldData {
+	ret := &storage.FloatVectorFieldData{Dim: 8, Data: make([]float32, num*8)}
+	for i := range ret.Data {
+		ret.Data[i] = rng.Float32()
+	}
+	return ret
+}
+
+func newVectorIndex(t *testing.T, params map[string]string) VectorIndex {
+	index, err := NewIndex(testField, params)
+	assert.NoError(t, err)
+	return index.(VectorIndex)
+}
+
+func TestNewIndex(t *testing.T) {
+	_, err := NewIndex(testField, map[string]string{common.IndexTypeKey: "UNKNOWN", common.MetricTypeKey: metric.L2})
+	assert.Error(t, err)
+	_, err = NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeIvfFlat, common.MetricTypeKey: metric.HAMMING})
+	assert.Error(t, err)
+	_, err = NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeIvfFlat, common.MetricTypeKey: metric.L2, NListKey: "0"})
+	assert.Error(t, err)
+	_, err = NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeIvfPQ, common.MetricTypeKey: metric.L2, PQMKey: "3"})
+	assert.Error(t, err)
+	_, err = NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeIvfPQ, common.MetricTypeKey: metric.L2, NBitsKey: "9"})
+	assert.Error(t, err)
+	_, err = NewIndex(&schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_Int64},
+		map[string]string{common.IndexTypeKey: IndexTypeIvfFlat, common.MetricTypeKey: metric.L2})
+	assert.Error(t, err)
+}
+
+func TestIVF(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vectors := randomVectors(rng, 1000)
+	queries := randomVectors(rng, 10)
+	valid := make([]bool, vectors.RowNum())
+	for i := range valid {
+		valid[i] = i%3 != 0
+	}
+
+	for _, indexType := range []string{IndexTypeIvfFlat, IndexTypeIvfSQ8, IndexTypeIvfPQ} {
+		for _, metricType := range []string{metric.L2, metric.IP, metric.COSINE} {
+			params := map[string]string{common.IndexTypeKey: indexType, common.MetricTypeKey: metricType, NListKey: "16", PQMKey: "4"}
+			index := newVectorIndex(t, params)
+			assert.NoError(t, index.Build(vectors))
+
+			expected, err := search.BruteForce(vectors, queries, metricType, 10, valid)
+			assert.NoError(t, err)
+			// all clusters are searched
+			hits, err := index.Search(queries, 10, map[string]any{NProbeKey: float64(16)}, valid)
+			assert.NoError(t, err)
+			var recall int
+			for q := range hits {
+				assert.Len(t, hits[q], 10)
+				offsets := make(map[int]struct{})
+				for _, hit := range expected[q] {
+					offsets[hit.Offset] = struct{}{}
+				}
+				for _, hit := range hits[q] {
+					assert.True(t, valid[hit.Offset])
+					if _, ok := offsets[hit.Offset]; ok {
+						recall++
+					}
+				}
+			}
+			if indexType == IndexTypeIvfFlat {
+				assert.Equal(t, expected, hits)
+			} else {
+				assert.Greater(t, recall, 80, "%s %s", indexType, metricType)
+			}
+
+			// less clusters are searched with a smaller nprobe
+			_, err = index.Search(queries, 10, map[string]any{NProbeKey: float64(2)}, nil)
+			assert.NoError(t, err)
+			_, err = index.Search(queries, 10, map[string]any{NProbeKey: "x"}, nil)
+			assert.Error(t, err)
+
+			data, err := index.Serialize()
+			assert.NoError(t, err)
+			loaded := newVectorIndex(t, params)
+			assert.NoError(t, loaded.Load(data))
+			loadedHits, err := loaded.Search(queries, 10, map[string]any{NProbeKey: float64(16)}, valid)
+			assert.NoError(t, err)
+			assert.Equal(t, hits, loadedHits)
+
+			mismatched, err := NewIndex(testField, map[string]string{common.IndexTypeKey: indexType, common.MetricTypeKey: metric.L2})
+			assert.NoError(t, err)
+			if metricType != metric.L2 {
+				assert.Error(t, mismatched.Load(data))
+			}
+		}
+	}
+}
+
+func TestIVFSmallSegment(t *testing.T) {
+	index := newVectorIndex(t, map[string]string{common.IndexTypeKey: IndexTypeIvfSQ8, common.MetricTypeKey: metric.L2})
+	vectors := randomVectors(rand.New(rand.NewSource(0)), 3)
+	assert.NoError(t, index.Build(vectors))
+	hits, err := index.Search(vectors, 5, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, hits[0], 3)
+	assert.Equal(t, 0, hits[0][0].Offset)
+}
+
+func TestHNSW(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vectors := randomVectors(rng, 1000)
+	queries := randomVectors(rng, 10)
+	valid := make([]bool, vectors.RowNum())
+	for i := range valid {
+		valid[i] = i%3 != 0
+	}
+	_, err := NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeHNSW, common.MetricTypeKey: metric.L2, MKey: "1"})
+	assert.Error(t, err)
+
+	for _, metricType := range []string{metric.L2, metric.IP, metric.COSINE} {
+		params := map[string]string{common.IndexTypeKey: IndexTypeHNSW, common.MetricTypeKey: metricType, MKey: "8", EfConstructionKey: "64"}
+		index := newVectorIndex(t, params)
+		hits, err := index.Search(queries, 10, nil, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, hits[0])
+
+		// half of the rows are added incrementally
+		assert.NoError(t, index.Build(&storage.FloatVectorFieldData{Dim: 8, Data: vectors.Data[:500*8]}))
+		assert.NoError(t, index.(GrowingIndex).Add(&storage.FloatVectorFieldData{Dim: 8, Data: vectors.Data[500*8:]}))
+
+		expected, err := search.BruteForce(vectors, queries, metricType, 10, valid)
+		assert.NoError(t, err)
+		hits, err = index.Search(queries, 10, map[string]any{EfKey: float64(64)}, valid)
+		assert.NoError(t, err)
+		var recall int
+		for q := range hits {
+			assert.Len(t, hits[q], 10)
+			offsets := make(map[int]struct{})
+			for _, hit := range expected[q] {
+				offsets[hit.Offset] = struct{}{}
+			}
+			for _, hit := range hits[q] {
+				assert.True(t, valid[hit.Offset])
+				if _, ok := offsets[hit.Offset]; ok {
+					recall++
+				}
+			}
+		}
+		assert.Greater(t, recall, 95, metricType)
+
+		// rows out of the valid bitset are invisible
+		hits, err = index.Search(queries, 10, nil, valid[:100])
+		assert.NoError(t, err)
+		for _, hit := range hits[0] {
+			assert.Less(t, hit.Offset, 100)
+		}
+		_, err = index.Search(queries, 10, map[string]any{EfKey: float64(5)}, nil)
+		assert.Error(t, err)
+
+		data, err := index.Serialize()
+		assert.NoError(t, err)
+		loaded := newVectorIndex(t, params)
+		assert.NoError(t, loaded.Load(data))
+		loadedHits, err := loaded.Search(queries, 10, map[string]any{EfKey: float64(64)}, valid)
+		assert.NoError(t, err)
+		hits, err = index.Search(queries, 10, map[string]any{EfKey: float64(64)}, valid)
+		assert.NoError(t, err)
+		assert.Equal(t, hits, loadedHits)
+
+		mismatched, err := NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeHNSW, common.MetricTypeKey: metric.L2})
+		assert.NoError(t, err)
+		if metricType != metric.L2 {
+			assert.Error(t, mismatched.Load(data))
+		}
+	}
+}
+
+func TestHalfVectors(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vectors := randomVectors(rng, 1000)
+	queries := randomVectors(rng, 10)
+	valid := make([]bool, vectors.RowNum())
+	for i := range valid {
+		valid[i] = i%3 != 0
+	}
+
+	for _, dataType := range []schemapb.DataType{schemapb.DataType_Float16Vector, storage.DataType_BFloat16Vector} {
+		field := &schemapb.FieldSchema{Name: "vec", DataType: dataType, TypeParams: testField.GetTypeParams()}
+		encode := func(data *storage.FloatVectorFieldData) storage.FieldData {
+			if dataType == schemapb.DataType_Float16Vector {
+				return &storage.Float16VectorFieldData{Data: storage.Float32sToFloat16(data.Data), Dim: data.Dim}
+			}
+			return &storage.BFloat16VectorFieldData{Data: storage.Float32sToBFloat16(data.Data), Dim: data.Dim}
+		}
+		halfVectors, halfQueries := encode(vectors), encode(queries)
+		_, err := NewIndex(field, map[string]string{common.IndexTypeKey: IndexTypeSparseInverted, common.MetricTypeKey: metric.IP})
+		assert.Error(t, err)
+
+		for _, metricType := range []string{metric.L2, metric.IP, metric.COSINE} {
+			expected, err := search.BruteForce(halfVectors, halfQueries, metricType, 10, valid)
+			assert.NoError(t, err)
+
+			// flat index is exact, half of the rows are added incrementally
+			params := map[string]string{common.IndexTypeKey: IndexTypeFlat, common.MetricTypeKey: metricType}
+			index, err := NewIndex(field, params)
+			assert.NoError(t, err)
+			flatIndex := index.(GrowingIndex)
+			assert.NoError(t, flatIndex.Build(encode(&storage.FloatVectorFieldData{Dim: 8, Data: vectors.Data[:500*8]})))
+			assert.NoError(t, flatIndex.Add(encode(&storage.FloatVectorFieldData{Dim: 8, Data: vectors.Data[500*8:]})))
+			hits, err := flatIndex.Search(halfQueries, 10, nil, valid)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, hits)
+			// vectors are kept packed, queries must be of the field type
+			assert.Empty(t, flatIndex.(*flat).Vectors)
+			assert.Equal(t, halfVectors.GetMemorySize()-4, len(flatIndex.(*flat).Packed))
+			_, err = flatIndex.Search(queries, 10, nil, valid)
+			assert.ErrorIs(t, err, merr.ErrParameterInvalid)
+			hits, err = flatIndex.Search(halfQueries, 10, nil, valid[:100])
+			assert.NoError(t, err)
+			for _, hit := range hits[0] {
+				assert.Less(t, hit.Offset, 100)
+			}
+			data, err := flatIndex.Serialize()
+			assert.NoError(t, err)
+			loaded, err := NewIndex(field, params)
+			assert.NoError(t, err)
+			assert.NoError(t, loaded.Load(data))
+			hits, err = loaded.(VectorIndex).Search(halfQueries, 10, nil, valid)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, hits)
+			// index files keeping the decoded vectors are still loaded
+			decoded, _ := storage.ToFloatVectors(halfVectors)
+			var buf bytes.Buffer
+			assert.NoError(t, gob.NewEncoder(&buf).Encode(&flatData{Metric: metricType, Dim: 8, Vectors: decoded.Data}))
+			loaded, err = NewIndex(field, params)
+			assert.NoError(t, err)
+			assert.NoError(t, loaded.Load(buf.Bytes()))
+			hits, err = loaded.(VectorIndex).Search(halfQueries, 10, nil, valid)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, hits)
+
+			// all clusters are searched
+			index, err = NewIndex(field, map[string]string{common.IndexTypeKey: IndexTypeIvfFlat, common.MetricTypeKey: metricType, NListKey: "16"})
+			assert.NoError(t, err)
+			assert.NoError(t, index.Build(halfVectors))
+			hits, err = index.(VectorIndex).Search(halfQueries, 10, map[string]any{NProbeKey: float64(16)}, valid)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, hits)
+			for c := range index.(*ivf).Lists {
+				assert.Empty(t, index.(*ivf).Vectors[c])
+				assert.Equal(t, len(index.(*ivf).Lists[c])*8*2, len(index.(*ivf).Packed[c]))
+			}
+
+			index, err = NewIndex(field, map[string]string{common.IndexTypeKey: IndexTypeHNSW, common.MetricTypeKey: metricType, MKey: "8", EfConstructionKey: "64"})
+			assert.NoError(t, err)
+			assert.NoError(t, index.Build(halfVectors))
+			hits, err = index.(VectorIndex).Search(halfQueries, 10, map[string]any{EfKey: float64(64)}, valid)
+			assert.NoError(t, err)
+			assert.Empty(t, index.(*hnsw).Vectors)
+			assert.Equal(t, halfVectors.GetMemorySize()-4, len(index.(*hnsw).Packed))
+			var recall int
+			for q := range hits {
+				offsets := make(map[int]struct{})
+				for _, hit := range expected[q] {
+					offsets[hit.Offset] = struct{}{}
+				}
+				for _, hit := range hits[q] {
+					if _, ok := offsets[hit.Offset]; ok {
+						recall++
+					}
+				}
+			}
+			assert.Greater(t, recall, 95, "%s %s", dataType.String(), metricType)
+		}
+	}
+}
+
+func TestDiskANN(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vectors := randomVectors(rng, 1000)
+	queries := randomVectors(rng, 10)
+	valid := make([]bool, vectors.RowNum())
+	for i := range valid {
+		valid[i] = i%3 != 0
+	}
+	_, err := NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeDiskANN, common.MetricTypeKey: metric.L2, MaxDegreeKey: "0"})
+	assert.Error(t, err)
+
+	for _, metricType := range []string{metric.L2, metric.IP, metric.COSINE} {
+		params := map[string]string{common.IndexTypeKey: IndexTypeDiskANN, common.MetricTypeKey: metricType, MaxDegreeKey: "16", SearchListSizeKey: "32"}
+		index := newVectorIndex(t, params)
+		assert.NoError(t, index.Build(vectors))
+
+		expected, err := search.BruteForce(vectors, queries, metricType, 10, valid)
+		assert.NoError(t, err)
+		hits, err := index.Search(queries, 10, map[string]any{SearchListKey: float64(64)}, valid)
+		assert.NoError(t, err)
+		var recall int
+		for q := range hits {
+			assert.Len(t, hits[q], 10)
+			offsets := make(map[int]struct{})
+			for _, hit := range expected[q] {
+				offsets[hit.Offset] = struct{}{}
+			}
+			for _, hit := range hits[q] {
+				assert.True(t, valid[hit.Offset])
+				if _, ok := offsets[hit.Offset]; ok {
+					recall++
+				}
+			}
+		}
+		assert.Greater(t, recall, 90, metricType)
+		_, err = index.Search(queries, 10, map[string]any{SearchListKey: float64(5)}, nil)
+		assert.Error(t, err)
+
+		// full vectors are read from the disk file after loaded
+		data, err := index.Serialize()
+		assert.NoError(t, err)
+		diskFile, err := index.(DiskIndex).SerializeDiskFile()
+		assert.NoError(t, err)
+		loaded := newVectorIndex(t, params)
+		assert.NoError(t, loaded.Load(data))
+		_, err = loaded.Search(queries, 10, nil, valid)
+		assert.Error(t, err)
+		loaded.(DiskIndex).SetDiskFile(func() (storage.FileReader, error) {
+			return memoryFile{bytes.NewReader(diskFile)}, nil
+		})
+		_, err = loaded.(DiskIndex).SerializeDiskFile()
+		assert.Error(t, err)
+		loadedHits, err := loaded.Search(queries, 10, map[string]any{SearchListKey: float64(64)}, valid)
+		assert.NoError(t, err)
+		assert.Equal(t, hits, loadedHits)
+	}
+}
+
+func randomSparseVectors(rng *rand.Rand, num int) *storage.SparseFloatVectorFieldData {
+	ret := &storage.SparseFloatVectorFieldData{}
+	for i := 0; i < num; i++ {
+		values := make(map[uint32]float32)
+		for j := 0; j < 8; j++ {
+			values[uint32(rng.Intn(100))] = rng.Float32()
+		}
+		if err := ret.AppendRow(storage.NewSparseRow(values)); err != nil {
+			panic(err)
+		}
+	}
+	return ret
+}
+
+func TestSparseInverted(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vectors := randomSparseVectors(rng, 1000)
+	queries := randomSparseVectors(rng, 10)
+	valid := make([]bool, vectors.RowNum())
+	for i := range valid {
+		valid[i] = i%3 != 0
+	}
+	sparseField := &schemapb.FieldSchema{Name: "sparse", DataType: storage.DataType_SparseFloatVector}
+	for _, params := range []map[string]string{
+		{common.IndexTypeKey: IndexTypeSparseInverted, common.MetricTypeKey: metric.L2},
+		{common.IndexTypeKey: IndexTypeHNSW, common.MetricTypeKey: metric.IP},
+		{common.IndexTypeKey: IndexTypeSparseWand, common.MetricTypeKey: metric.IP, DropRatioBuildKey: "1"},
+	} {
+		_, err := NewIndex(sparseField, params)
+		assert.Error(t, err)
+	}
+	_, err := NewIndex(testField, map[string]string{common.IndexTypeKey: IndexTypeSparseWand, common.MetricTypeKey: metric.IP})
+	assert.Error(t, err)
+
+	expected, err := search.BruteForce(vectors, queries, metric.IP, 10, valid)
+	assert.NoError(t, err)
+	for _, indexType := range []string{IndexTypeSparseInverted, IndexTypeSparseWand} {
+		params := map[string]string{common.IndexTypeKey: indexType, common.MetricTypeKey: metric.IP}
+		index, err := NewIndex(sparseField, params)
+		assert.NoError(t, err)
+		vectorIndex := index.(VectorIndex)
+
+		// half of the rows are added incrementally
+		assert.NoError(t, vectorIndex.Build(&storage.SparseFloatVectorFieldData{Data: vectors.Data[:500]}))
+		assert.NoError(t, vectorIndex.(GrowingIndex).Add(&storage.SparseFloatVectorFieldData{Data: vectors.Data[500:]}))
+		hits, err := vectorIndex.Search(queries, 10, nil, valid)
+		assert.NoError(t, err)
+		for q := range hits {
+			assert.Len(t, hits[q], 10, indexType)
+			for i, hit := range hits[q] {
+				assert.True(t, valid[hit.Offset])
+				assert.InDelta(t, expected[q][i].Score, hit.Score, 1e-5, indexType)
+			}
+		}
+
+		// rows out of the valid bitset are invisible, rows scored 0 fill the topK
+		hits, err = vectorIndex.Search(queries, 200, nil, valid[:100])
+		assert.NoError(t, err)
+		assert.Len(t, hits[0], 66)
+		for _, hit := range hits[0] {
+			assert.Less(t, hit.Offset, 100)
+		}
+
+		// the smallest half of query elements are dropped
+		hits, err = vectorIndex.Search(queries, 10, map[string]any{DropRatioSearchKey: 0.5}, valid)
+		assert.NoError(t, err)
+		assert.Len(t, hits[0], 10)
+		_, err = vectorIndex.Search(queries, 10, map[string]any{DropRatioSearchKey: float64(1)}, valid)
+		assert.Error(t, err)
+
+		data, err := vectorIndex.Serialize()
+		assert.NoError(t, err)
+		loaded, err := NewIndex(sparseField, params)
+		assert.NoError(t, err)
+		assert.NoError(t, loaded.Load(data))
+		loadedHits, err := loaded.(VectorIndex).Search(queries, 10, nil, valid)
+		assert.NoError(t, err)
+		hits, err = vectorIndex.Search(queries, 10, nil, valid)
+		assert.NoError(t, err)
+		assert.Equal(t, hits, loadedHits)
+	}
+
+	// elements dropped at build time are not scored
+	index, err := NewIndex(sparseField, map[string]string{common.IndexTypeKey: IndexTypeSparseInverted, common.MetricTypeKey: metric.IP,
+		DropRatioBuildKey: "0.5"})
+	assert.NoError(t, err)
+	assert.NoError(t, index.Build(&storage.SparseFloatVectorFieldData{Data: [][]byte{storage.NewSparseRow(map[uint32]float32{1: 0.1, 2: 1})}}))
+	hits, err := index.(VectorIndex).Search(&storage.SparseFloatVectorFieldData{Data: [][]byte{storage.NewSparseRow(map[uint32]float32{1: 1})}},
+		1, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0), hits[0][0].Score)
+}
+
+func buildScalarIndex(t *testing.T, field *schemapb.FieldSchema, indexType string, data storage.FieldData) ScalarIndex {
+	index, err := NewIndex(field, map[string]string{common.IndexTypeKey: indexType})
+	assert.NoError(t, err)
+	assert.NoError(t, index.Build(data))
+	// lookups are the same after loaded
+	blob, err := index.Serialize()
+	assert.NoError(t, err)
+	loaded, err := NewIndex(field, map[string]string{common.IndexTypeKey: indexType})
+	assert.NoError(t, err)
+	assert.NoError(t, loaded.Load(blob))
+	return loaded.(ScalarIndex)
+}
+
+func TestScalarIndex(t *testing.T) {
+	intField := &schemapb.FieldSchema{Name: "int", DataType: schemapb.DataType_Int32}
+	floatField := &schemapb.FieldSchema{Name: "float", DataType: schemapb.DataType_Float}
+	stringField := &schemapb.FieldSchema{Name: "string", DataType: schemapb.DataType_VarChar}
+	boolField := &schemapb.FieldSchema{Name: "bool", DataType: schemapb.DataType_Bool}
+	assert.Equal(t, IndexTypeSorted, DefaultScalarIndexType(schemapb.DataType_Int32))
+	assert.Equal(t, IndexTypeTrie, DefaultScalarIndexType(schemapb.DataType_VarChar))
+	assert.Equal(t, IndexTypeInverted, DefaultScalarIndexType(schemapb.DataType_Bool))
+	assert.Equal(t, "", DefaultScalarIndexType(schemapb.DataType_JSON))
+	for _, c := range []struct {
+		field     *schemapb.FieldSchema
+		indexType string
+	}{
+		{intField, IndexTypeTrie},
+		{stringField, IndexTypeSorted},
+		{boolField, IndexTypeSorted},
+		{intField, IndexTypeHNSW},
+		{&schemapb.FieldSchema{Name: "json", DataType: schemapb.DataType_JSON}, IndexTypeInverted},
+	} {
+		_, err := NewIndex(c.field, map[string]string{common.IndexTypeKey: c.indexType})
+		assert.Error(t, err, c.indexType)
+	}
+
+	ints := &storage.Int32FieldData{Data: []int32{3, 1, 4, 1, 5}}
+	sorted := buildScalarIndex(t, intField, IndexTypeSorted, ints)
+	ret, ok := sorted.In([]any{int64(1), float64(4), int64(7)})
+	assert.True(t, ok)
+	assert.Equal(t, []bool{false, true, true, true, false}, ret)
+	ret, ok = sorted.Range(int64(1), false, float64(4.5), true)
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, false, true, false, false}, ret)
+	ret, ok = sorted.Range(nil, false, int64(3), true)
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, true, false, true, false}, ret)
+	_, ok = sorted.PrefixMatch("1")
+	assert.False(t, ok)
+
+	floats := &storage.FloatFieldData{Data: []float32{0.5, 2, 1.5}}
+	sorted = buildScalarIndex(t, floatField, IndexTypeSorted, floats)
+	ret, ok = sorted.Range(int64(1), true, nil, false)
+	assert.True(t, ok)
+	assert.Equal(t, []bool{false, true, true}, ret)
+	inverted := buildScalarIndex(t, floatField, IndexTypeInverted, floats)
+	ret, ok = inverted.In([]any{int64(2), float64(0.5)})
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, true, false}, ret)
+	_, ok = inverted.Range(int64(1), true, nil, false)
+	assert.False(t, ok)
+
+	inverted = buildScalarIndex(t, boolField, IndexTypeInverted, &storage.BoolFieldData{Data: []bool{true, false, true}})
+	ret, ok = inverted.In([]any{true})
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, false, true}, ret)
+
+	strs := &storage.StringFieldData{Data: []string{"apple", "app", "banana", "apricot", ""}, DataType: schemapb.DataType_VarChar}
+	trie := buildScalarIndex(t, stringField, IndexTypeTrie, strs)
+	ret, ok = trie.In([]any{"app", "", "cherry"})
+	assert.True(t, ok)
+	assert.Equal(t, []bool{false, true, false, false, true}, ret)
+	ret, ok = trie.PrefixMatch("app")
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, true, false, false, false}, ret)
+	ret, ok = trie.PrefixMatch("")
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, true, true, true, true}, ret)
+	_, ok = trie.Range("a", true, "b", false)
+	assert.False(t, ok)
+	inverted = buildScalarIndex(t, stringField, IndexTypeInverted, strs)
+	ret, ok = inverted.In([]any{"banana"})
+	assert.True(t, ok)
+	assert.Equal(t, []bool{false, false, true, false, false}, ret)
+}
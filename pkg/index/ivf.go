@@ -0,0 +1,315 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// ivfData is the serialized content of ivf index
+type ivfData struct {
+	Type      string
+	Metric    string
+	Dim       int
+	NList     int
+	Centroids [][]float32
+	// Lists are the row offsets of each cluster
+	Lists [][]int32
+	// Vectors are the flattened vectors of each cluster for IVF_FLAT
+	Vectors [][]float32
+	// Packed are the flattened float16 or bfloat16 vectors of each cluster for IVF_FLAT, in their own encoding
+	Packed [][]byte
+	// Codes are the flattened sq8 codes of each cluster for IVF_SQ8, or pq codes for IVF_PQ
+	Codes [][]byte
+	// Min & Scale of each dimension to decode sq8 codes
+	Min   []float32
+	Scale []float32
+	// PQ encodes the vectors of IVF_PQ, it's trained with normalized vectors for COSINE
+	PQ    *productQuantizer
+	NBits int
+}
+
+// ivf is an inverted file index, vectors are clustered by k-means and only the nprobe nearest clusters are searched.
+// IVF_SQ8 compresses each dimension of vectors into one byte by scalar quantization,
+// IVF_PQ compresses vectors into m bytes by product quantization and scores are approximate.
+// IVF_FLAT keeps float16 and bfloat16 vectors packed and decodes them per distance computation.
+type ivf struct {
+	ivfData
+	dataType schemapb.DataType
+	distance search.DistanceFunc
+}
+
+func newIVF(indexType, metricType string, dataType schemapb.DataType, dim, nlist int) *ivf {
+	ret := &ivf{ivfData: ivfData{Type: indexType, Metric: metricType, Dim: dim, NList: nlist}, dataType: dataType}
+	ret.init()
+	return ret
+}
+
+func newIVFPQ(metricType string, dataType schemapb.DataType, dim, nlist, m, nbits int) *ivf {
+	ret := &ivf{ivfData: ivfData{Type: IndexTypeIvfPQ, Metric: metricType, Dim: dim, NList: nlist,
+		PQ: &productQuantizer{Metric: metricType, Dim: dim, M: m}, NBits: nbits}, dataType: dataType}
+	ret.init()
+	return ret
+}
+
+func (idx *ivf) init() {
+	dataType := schemapb.DataType_FloatVector
+	if idx.packed() {
+		dataType = idx.dataType
+	}
+	distance, _ := search.GetDistanceFunc(idx.Metric, dataType)
+	idx.distance = distance
+}
+
+func (idx *ivf) IndexType() string {
+	return idx.Type
+}
+
+func (idx *ivf) MetricType() string {
+	return idx.Metric
+}
+
+func (idx *ivf) sq8() bool {
+	return idx.Type == IndexTypeIvfSQ8
+}
+
+func (idx *ivf) pq() bool {
+	return idx.Type == IndexTypeIvfPQ
+}
+
+// packed returns whether the vectors of IVF_FLAT are kept as packed float16 or bfloat16
+func (idx *ivf) packed() bool {
+	return idx.Type == IndexTypeIvfFlat && isHalfVectorType(idx.dataType)
+}
+
+// vectorType is the vector type required by the index in errors
+func (idx *ivf) vectorType() string {
+	if isHalfVectorType(idx.dataType) {
+		return idx.dataType.String()
+	}
+	return "float"
+}
+
+// checkVectors returns the vectors decoded into float32, half vectors must be of the field type
+func (idx *ivf) checkVectors(vectors storage.FieldData) (*storage.FloatVectorFieldData, bool) {
+	if isHalfVectorType(idx.dataType) {
+		if _, ok := packedVectors(vectors, idx.dataType, idx.Dim); !ok {
+			return nil, false
+		}
+	}
+	data, ok := storage.ToFloatVectors(vectors)
+	return data, ok && data.Dim == idx.Dim
+}
+
+func (idx *ivf) Build(vectors storage.FieldData) error {
+	// half vectors are decoded only while building
+	data, ok := idx.checkVectors(vectors)
+	if !ok {
+		return errors.Errorf("%s index requires %s vectors of dim %d", idx.Type, idx.vectorType(), idx.Dim)
+	}
+	rows := make([][]float32, data.RowNum())
+	for i := range rows {
+		rows[i] = idx.quantizerVector(data.Data[i*data.Dim : (i+1)*data.Dim])
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	// a small segment has less clusters
+	nlist := idx.NList
+	if nlist > len(rows) {
+		nlist = len(rows)
+	}
+	idx.Centroids = kmeans(rows, nlist)
+	if idx.sq8() {
+		idx.trainSQ8(data)
+	}
+	if idx.pq() {
+		idx.PQ = trainPQ(idx.Metric, rows, idx.Dim, idx.PQ.M, idx.NBits)
+	}
+
+	idx.Lists = make([][]int32, nlist)
+	idx.Vectors = make([][]float32, nlist)
+	idx.Packed = make([][]byte, nlist)
+	idx.Codes = make([][]byte, nlist)
+	for i, row := range rows {
+		c := idx.nearestCentroid(row)
+		idx.Lists[c] = append(idx.Lists[c], int32(i))
+		vector := data.Data[i*data.Dim : (i+1)*data.Dim]
+		if idx.sq8() {
+			idx.Codes[c] = append(idx.Codes[c], idx.encode(vector)...)
+		} else if idx.pq() {
+			idx.Codes[c] = append(idx.Codes[c], idx.PQ.encode(row)...)
+		} else if idx.packed() {
+			idx.Packed[c] = append(idx.Packed[c], vectors.GetRow(i).([]byte)...)
+		} else {
+			idx.Vectors[c] = append(idx.Vectors[c], vector...)
+		}
+	}
+	return nil
+}
+
+// quantizerVector returns the vector to find clusters, vectors are normalized for COSINE
+func (idx *ivf) quantizerVector(vector []float32) []float32 {
+	if idx.Metric != metric.COSINE {
+		return vector
+	}
+	return normalizeVector(vector)
+}
+
+// coarseDistance is the distance between a vector and a centroid, smaller is nearer
+func (idx *ivf) coarseDistance(centroid, vector []float32) float32 {
+	if idx.Metric == metric.IP {
+		return -search.IP(centroid, vector)
+	}
+	return search.L2(centroid, vector)
+}
+
+func (idx *ivf) nearestCentroid(vector []float32) int {
+	ret, min := 0, float32(0)
+	for i, centroid := range idx.Centroids {
+		distance := idx.coarseDistance(centroid, vector)
+		if i == 0 || distance < min {
+			ret, min = i, distance
+		}
+	}
+	return ret
+}
+
+func (idx *ivf) trainSQ8(data *storage.FloatVectorFieldData) {
+	min := make([]float32, idx.Dim)
+	max := make([]float32, idx.Dim)
+	for i := 0; i < data.RowNum(); i++ {
+		for d := 0; d < idx.Dim; d++ {
+			v := data.Data[i*idx.Dim+d]
+			if i == 0 || v < min[d] {
+				min[d] = v
+			}
+			if i == 0 || v > max[d] {
+				max[d] = v
+			}
+		}
+	}
+	idx.Min = min
+	idx.Scale = make([]float32, idx.Dim)
+	for d := range idx.Scale {
+		idx.Scale[d] = (max[d] - min[d]) / math.MaxUint8
+	}
+}
+
+func (idx *ivf) encode(vector []float32) []byte {
+	ret := make([]byte, idx.Dim)
+	for d, v := range vector {
+		if idx.Scale[d] == 0 {
+			continue
+		}
+		code := math.Round(float64((v - idx.Min[d]) / idx.Scale[d]))
+		ret[d] = byte(math.Max(0, math.Min(math.MaxUint8, code)))
+	}
+	return ret
+}
+
+// vector returns the j-th vector of cluster c, sq8 codes are decoded into buf.
+// It's []byte of packed float16 or bfloat16 vectors, or []float32.
+func (idx *ivf) vector(c, j int, buf []float32) any {
+	if idx.packed() {
+		return idx.Packed[c][j*idx.Dim*2 : (j+1)*idx.Dim*2]
+	}
+	if !idx.sq8() {
+		return idx.Vectors[c][j*idx.Dim : (j+1)*idx.Dim]
+	}
+	codes := idx.Codes[c][j*idx.Dim : (j+1)*idx.Dim]
+	for d, code := range codes {
+		buf[d] = idx.Min[d] + float32(code)*idx.Scale[d]
+	}
+	return buf
+}
+
+func (idx *ivf) Search(queries storage.FieldData, topK int, params map[string]any, valid []bool) ([][]search.Hit, error) {
+	// half queries are decoded to probe the clusters
+	data, ok := idx.checkVectors(queries)
+	if !ok {
+		return nil, merr.WrapErrParameterInvalidMsg("%s index requires %s vectors of dim %d", idx.Type, idx.vectorType(), idx.Dim)
+	}
+	nprobe, err := getSearchIntParam(params, NProbeKey, DefaultNProbe, 1, MaxNList)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([][]search.Hit, data.RowNum())
+	buf := make([]float32, idx.Dim)
+	for q := range ret {
+		query := data.Data[q*idx.Dim : (q+1)*idx.Dim]
+		collector := search.NewTopK(topK, idx.Metric)
+		var table [][]float32
+		if idx.pq() {
+			table = idx.PQ.distanceTable(idx.quantizerVector(query))
+		}
+		for _, c := range idx.probe(query, nprobe) {
+			for j, offset := range idx.Lists[c] {
+				if valid != nil && !valid[offset] {
+					continue
+				}
+				if idx.pq() {
+					collector.Push(int(offset), idx.PQ.score(table, idx.Codes[c][j*idx.PQ.M:(j+1)*idx.PQ.M]))
+				} else if idx.packed() {
+					collector.Push(int(offset), idx.distance(queries.GetRow(q), idx.vector(c, j, buf)))
+				} else {
+					collector.Push(int(offset), idx.distance(query, idx.vector(c, j, buf)))
+				}
+			}
+		}
+		ret[q] = collector.Sorted()
+	}
+	return ret, nil
+}
+
+// probe returns the nprobe nearest clusters of query
+func (idx *ivf) probe(query []float32, nprobe int) []int {
+	query = idx.quantizerVector(query)
+	collector := search.NewTopK(nprobe, metric.L2)
+	for c, centroid := range idx.Centroids {
+		collector.Push(c, idx.coarseDistance(centroid, query))
+	}
+	hits := collector.Sorted()
+	ret := make([]int, len(hits))
+	for i, hit := range hits {
+		ret[i] = hit.Offset
+	}
+	return ret
+}
+
+func (idx *ivf) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&idx.ivfData); err != nil {
+		return nil, errors.Wrap(err, "failed to serialize ivf index")
+	}
+	return buf.Bytes(), nil
+}
+
+func (idx *ivf) Load(data []byte) error {
+	var loaded ivfData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&loaded); err != nil {
+		return errors.Wrap(err, "failed to deserialize ivf index")
+	}
+	if loaded.Type != idx.Type || loaded.Metric != idx.Metric || loaded.Dim != idx.Dim {
+		return errors.Errorf("index file of %s %s dim %d mismatches index %s %s dim %d",
+			loaded.Type, loaded.Metric, loaded.Dim, idx.Type, idx.Metric, idx.Dim)
+	}
+	// index files of half vectors used to keep them decoded
+	if idx.packed() && len(loaded.Packed) == 0 && len(loaded.Vectors) > 0 {
+		loaded.Packed = make([][]byte, len(loaded.Vectors))
+		for c, vectors := range loaded.Vectors {
+			loaded.Packed[c] = encodeHalf(idx.dataType, vectors)
+		}
+		loaded.Vectors = make([][]float32, len(loaded.Packed))
+	}
+	idx.ivfData = loaded
+	idx.init()
+	return nil
+}
@@ -0,0 +1,82 @@
+package index
+
+import (
+	"math/rand"
+
+	"github.com/sharding-db/milvus-mini/pkg/search"
+)
+
+const (
+	kmeansIterations = 10
+	// at most maxPointsPerCentroid*k vectors are sampled for training, same as faiss
+	maxPointsPerCentroid = 256
+)
+
+// kmeans clusters the vectors into k centroids by Lloyd's algorithm, k must not be larger than the number of vectors.
+// The random seed is fixed, so the same vectors are always clustered the same way.
+func kmeans(vectors [][]float32, k int) [][]float32 {
+	rng := rand.New(rand.NewSource(int64(len(vectors))))
+	samples := vectors
+	if len(vectors) > k*maxPointsPerCentroid {
+		samples = make([][]float32, k*maxPointsPerCentroid)
+		for i, j := range rng.Perm(len(vectors))[:len(samples)] {
+			samples[i] = vectors[j]
+		}
+	}
+
+	dim := len(vectors[0])
+	centroids := make([][]float32, k)
+	for i, j := range rng.Perm(len(samples))[:k] {
+		centroids[i] = append([]float32{}, samples[j]...)
+	}
+	assignments := make([]int, len(samples))
+	for iter := 0; iter < kmeansIterations; iter++ {
+		changed := false
+		for i, vector := range samples {
+			nearest := nearestCentroid(centroids, vector)
+			if nearest != assignments[i] || iter == 0 {
+				changed = true
+			}
+			assignments[i] = nearest
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][]float64, k)
+		counts := make([]int, k)
+		for i := range sums {
+			sums[i] = make([]float64, dim)
+		}
+		for i, vector := range samples {
+			c := assignments[i]
+			counts[c]++
+			for d, v := range vector {
+				sums[c][d] += float64(v)
+			}
+		}
+		for c := range centroids {
+			// an empty cluster takes a random vector, so all k clusters are used
+			if counts[c] == 0 {
+				copy(centroids[c], samples[rng.Intn(len(samples))])
+				continue
+			}
+			for d := range centroids[c] {
+				centroids[c][d] = float32(sums[c][d] / float64(counts[c]))
+			}
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid returns the centroid of the min L2 distance
+func nearestCentroid(centroids [][]float32, vector []float32) int {
+	ret, min := 0, float32(0)
+	for i, centroid := range centroids {
+		distance := search.L2(centroid, vector)
+		if i == 0 || distance < min {
+			ret, min = i, distance
+		}
+	}
+	return ret
+}
@@ -0,0 +1,110 @@
+package index
+
+import (
+	"math"
+
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+)
+
+// productQuantizer splits vectors into M sub-vectors, each sub-vector is encoded as its nearest centroid in the sub-space.
+// Distances are computed by lookup tables of the query to all centroids, vectors are never decoded.
+type productQuantizer struct {
+	Metric string
+	Dim    int
+	M      int
+	// Codebooks are the centroids of each sub-space
+	Codebooks [][][]float32
+}
+
+// trainPQ trains the codebooks by k-means in each sub-space, vectors of COSINE must be normalized
+func trainPQ(metricType string, vectors [][]float32, dim, m, nbits int) *productQuantizer {
+	pq := &productQuantizer{Metric: metricType, Dim: dim, M: m, Codebooks: make([][][]float32, m)}
+	ksub := 1 << nbits
+	if ksub > len(vectors) {
+		ksub = len(vectors)
+	}
+	dsub := dim / m
+	for s := range pq.Codebooks {
+		subVectors := make([][]float32, len(vectors))
+		for i, vector := range vectors {
+			subVectors[i] = vector[s*dsub : (s+1)*dsub]
+		}
+		pq.Codebooks[s] = kmeans(subVectors, ksub)
+	}
+	return pq
+}
+
+func (pq *productQuantizer) encode(vector []float32) []byte {
+	dsub := pq.Dim / pq.M
+	ret := make([]byte, pq.M)
+	for s, codebook := range pq.Codebooks {
+		ret[s] = byte(nearestCentroid(codebook, vector[s*dsub:(s+1)*dsub]))
+	}
+	return ret
+}
+
+// distanceTable returns the distance of each sub-vector of query to each centroid, it's inner product for IP and L2 otherwise.
+// The query of COSINE must be normalized, L2 of unit vectors keeps the error of codes better than inner product.
+func (pq *productQuantizer) distanceTable(query []float32) [][]float32 {
+	dsub := pq.Dim / pq.M
+	ret := make([][]float32, pq.M)
+	for s, codebook := range pq.Codebooks {
+		subQuery := query[s*dsub : (s+1)*dsub]
+		ret[s] = make([]float32, len(codebook))
+		for k, centroid := range codebook {
+			if pq.Metric == metric.IP {
+				ret[s][k] = search.IP(subQuery, centroid)
+			} else {
+				ret[s][k] = search.L2(subQuery, centroid)
+			}
+		}
+	}
+	return ret
+}
+
+// adc returns the asymmetric distance of the encoded vector by the distance table
+func (pq *productQuantizer) adc(table [][]float32, code []byte) float32 {
+	var ret float32
+	for s, k := range code {
+		ret += table[s][k]
+	}
+	return ret
+}
+
+// score returns the approximate score of the encoded vector in the metric
+func (pq *productQuantizer) score(table [][]float32, code []byte) float32 {
+	ret := pq.adc(table, code)
+	if pq.Metric == metric.COSINE {
+		// |a-b|^2 = 2 - 2cos(a, b) for unit vectors
+		return 1 - ret/2
+	}
+	return ret
+}
+
+// defaultPQM returns the largest divisor of dim not larger than dim/4, so each sub-vector has at least 4 dimensions
+func defaultPQM(dim int) int {
+	for m := dim / 4; m > 1; m-- {
+		if dim%m == 0 {
+			return m
+		}
+	}
+	return 1
+}
+
+// normalizeVector returns the unit vector of the same direction, zero vector is returned as is
+func normalizeVector(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	ret := make([]float32, len(vector))
+	for i, v := range vector {
+		ret[i] = float32(float64(v) / norm)
+	}
+	return ret
+}
@@ -0,0 +1,425 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"sort"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// DefaultScalarIndexType returns the index type of scalar field if it's not specified, it's empty if not supported
+func DefaultScalarIndexType(dataType schemapb.DataType) string {
+	switch {
+	case typeutil.IsIntegerType(dataType) || typeutil.IsFloatingType(dataType):
+		return IndexTypeSorted
+	case typeutil.IsStringType(dataType):
+		return IndexTypeTrie
+	case typeutil.IsBoolType(dataType):
+		return IndexTypeInverted
+	}
+	return ""
+}
+
+func newScalarIndex(field *schemapb.FieldSchema, indexType string) (Index, error) {
+	dataType := field.GetDataType()
+	numeric := typeutil.IsIntegerType(dataType) || typeutil.IsFloatingType(dataType)
+	switch {
+	case indexType == IndexTypeSorted && numeric:
+		return &sortedIndex{}, nil
+	case indexType == IndexTypeTrie && typeutil.IsStringType(dataType):
+		return &trieIndex{}, nil
+	case indexType == IndexTypeInverted && (numeric || typeutil.IsStringType(dataType) || typeutil.IsBoolType(dataType)):
+		return &invertedIndex{}, nil
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("index type %s is not supported for field %s of %s",
+		indexType, field.GetName(), dataType.String())
+}
+
+// scalarValue converts the value into int64, float64, string or bool, same as the expression evaluator
+func scalarValue(value any) any {
+	switch v := value.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+// compareScalar returns -1, 0 or 1, ok is false if values are not comparable
+func compareScalar(left, right any) (int, bool) {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			return compareOrdered(l, r), true
+		}
+	}
+	lf, ok1 := toFloat(left)
+	rf, ok2 := toFloat(right)
+	if ok1 && ok2 {
+		return compareOrdered(lf, rf), true
+	}
+	ls, ok1 := left.(string)
+	rs, ok2 := right.(string)
+	if ok1 && ok2 {
+		return compareOrdered(ls, rs), true
+	}
+	return 0, false
+}
+
+func compareOrdered[T int64 | float64 | string](left, right T) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func serializeScalarIndex(indexType string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, errors.Wrapf(err, "failed to serialize %s index", indexType)
+	}
+	return buf.Bytes(), nil
+}
+
+func loadScalarIndex(indexType string, blob []byte, data any) error {
+	if err := gob.NewDecoder(bytes.NewReader(blob)).Decode(data); err != nil {
+		return errors.Wrapf(err, "failed to deserialize %s index", indexType)
+	}
+	return nil
+}
+
+// sortedData is the serialized content of sorted index
+type sortedData struct {
+	NumRows int
+	// Values are sorted in ascending order, Offsets are the rows of values
+	Values  []any
+	Offsets []int32
+}
+
+// sortedIndex sorts the values of numeric field, equality & range lookups are binary searches
+type sortedIndex struct {
+	sortedData
+}
+
+func (idx *sortedIndex) IndexType() string {
+	return IndexTypeSorted
+}
+
+func (idx *sortedIndex) Build(data storage.FieldData) error {
+	idx.NumRows = data.RowNum()
+	idx.Values = make([]any, 0, idx.NumRows)
+	idx.Offsets = make([]int32, 0, idx.NumRows)
+	for i := 0; i < idx.NumRows; i++ {
+		// null rows match no lookups
+		if !data.IsValid(i) {
+			continue
+		}
+		idx.Values = append(idx.Values, scalarValue(data.GetRow(i)))
+		idx.Offsets = append(idx.Offsets, int32(i))
+	}
+	sort.Stable(idx)
+	return nil
+}
+
+func (idx *sortedIndex) Len() int {
+	return len(idx.Values)
+}
+
+func (idx *sortedIndex) Less(i, j int) bool {
+	ret, _ := compareScalar(idx.Values[i], idx.Values[j])
+	return ret < 0
+}
+
+func (idx *sortedIndex) Swap(i, j int) {
+	idx.Values[i], idx.Values[j] = idx.Values[j], idx.Values[i]
+	idx.Offsets[i], idx.Offsets[j] = idx.Offsets[j], idx.Offsets[i]
+}
+
+// search returns the first position whose value is larger than bound, or not less than bound if inclusive
+func (idx *sortedIndex) search(bound any, inclusive bool) int {
+	return sort.Search(len(idx.Values), func(i int) bool {
+		ret, ok := compareScalar(idx.Values[i], bound)
+		return !ok || ret > 0 || (inclusive && ret == 0)
+	})
+}
+
+func (idx *sortedIndex) In(values []any) ([]bool, bool) {
+	ret := make([]bool, idx.NumRows)
+	for _, value := range values {
+		if _, ok := compareScalar(value, value); !ok {
+			continue
+		}
+		for i := idx.search(value, true); i < len(idx.Values); i++ {
+			if cmp, ok := compareScalar(idx.Values[i], value); !ok || cmp != 0 {
+				break
+			}
+			ret[idx.Offsets[i]] = true
+		}
+	}
+	return ret, true
+}
+
+func (idx *sortedIndex) Range(lower any, includeLower bool, upper any, includeUpper bool) ([]bool, bool) {
+	ret := make([]bool, idx.NumRows)
+	for _, bound := range []any{lower, upper} {
+		if _, ok := compareScalar(bound, bound); bound != nil && !ok {
+			return ret, true
+		}
+	}
+	start, end := 0, len(idx.Values)
+	if lower != nil {
+		start = idx.search(lower, includeLower)
+	}
+	if upper != nil {
+		end = idx.search(upper, !includeUpper)
+	}
+	for i := start; i < end; i++ {
+		ret[idx.Offsets[i]] = true
+	}
+	return ret, true
+}
+
+func (idx *sortedIndex) PrefixMatch(prefix string) ([]bool, bool) {
+	return nil, false
+}
+
+func (idx *sortedIndex) Serialize() ([]byte, error) {
+	return serializeScalarIndex(IndexTypeSorted, &idx.sortedData)
+}
+
+func (idx *sortedIndex) Load(data []byte) error {
+	return loadScalarIndex(IndexTypeSorted, data, &idx.sortedData)
+}
+
+// invertedData is the serialized content of inverted index
+type invertedData struct {
+	NumRows int
+	// Keys are the distinct values, Postings are the rows of each key
+	Keys     []any
+	Postings [][]int32
+}
+
+// invertedIndex maps each distinct value to its rows, it only supports equality lookups
+type invertedIndex struct {
+	invertedData
+	keys map[any]int
+}
+
+func (idx *invertedIndex) IndexType() string {
+	return IndexTypeInverted
+}
+
+func (idx *invertedIndex) Build(data storage.FieldData) error {
+	idx.NumRows = data.RowNum()
+	idx.keys = make(map[any]int)
+	for i := 0; i < idx.NumRows; i++ {
+		if !data.IsValid(i) {
+			continue
+		}
+		value := scalarValue(data.GetRow(i))
+		k, ok := idx.keys[value]
+		if !ok {
+			k = len(idx.Keys)
+			idx.keys[value] = k
+			idx.Keys = append(idx.Keys, value)
+			idx.Postings = append(idx.Postings, nil)
+		}
+		idx.Postings[k] = append(idx.Postings[k], int32(i))
+	}
+	return nil
+}
+
+// find returns the key equal to value, numbers of different types are equal if their values are equal
+func (idx *invertedIndex) find(value any) (int, bool) {
+	if k, ok := idx.keys[value]; ok {
+		return k, true
+	}
+	switch v := value.(type) {
+	case int64:
+		k, ok := idx.keys[float64(v)]
+		return k, ok
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			k, ok := idx.keys[int64(v)]
+			return k, ok
+		}
+	}
+	return 0, false
+}
+
+func (idx *invertedIndex) In(values []any) ([]bool, bool) {
+	ret := make([]bool, idx.NumRows)
+	for _, value := range values {
+		if k, ok := idx.find(value); ok {
+			for _, offset := range idx.Postings[k] {
+				ret[offset] = true
+			}
+		}
+	}
+	return ret, true
+}
+
+func (idx *invertedIndex) Range(lower any, includeLower bool, upper any, includeUpper bool) ([]bool, bool) {
+	return nil, false
+}
+
+func (idx *invertedIndex) PrefixMatch(prefix string) ([]bool, bool) {
+	return nil, false
+}
+
+func (idx *invertedIndex) Serialize() ([]byte, error) {
+	return serializeScalarIndex(IndexTypeInverted, &idx.invertedData)
+}
+
+func (idx *invertedIndex) Load(data []byte) error {
+	if err := loadScalarIndex(IndexTypeInverted, data, &idx.invertedData); err != nil {
+		return err
+	}
+	idx.keys = make(map[any]int, len(idx.Keys))
+	for k, key := range idx.Keys {
+		idx.keys[key] = k
+	}
+	return nil
+}
+
+// trieNode is a node of trie, the path from root is the key
+type trieNode struct {
+	// Labels are the sorted bytes of edges to Children
+	Labels   []byte
+	Children []int32
+	// Rows are the offsets of rows equal to the key of node
+	Rows []int32
+}
+
+// trieData is the serialized content of trie index
+type trieData struct {
+	NumRows int
+	// Nodes are all nodes of trie, the first one is the root
+	Nodes []trieNode
+}
+
+// trieIndex is a byte-wise trie of string field, it supports equality and prefix lookups
+type trieIndex struct {
+	trieData
+}
+
+func (idx *trieIndex) IndexType() string {
+	return IndexTypeTrie
+}
+
+func (idx *trieIndex) Build(data storage.FieldData) error {
+	idx.NumRows = data.RowNum()
+	idx.Nodes = []trieNode{{}}
+	for i := 0; i < idx.NumRows; i++ {
+		if !data.IsValid(i) {
+			continue
+		}
+		key, ok := data.GetRow(i).(string)
+		if !ok {
+			return errors.Errorf("%s index requires string values", IndexTypeTrie)
+		}
+		node := int32(0)
+		for j := 0; j < len(key); j++ {
+			node = idx.child(node, key[j], true)
+		}
+		idx.Nodes[node].Rows = append(idx.Nodes[node].Rows, int32(i))
+	}
+	return nil
+}
+
+// child returns the child of node by the label, it's -1 if not found and not created
+func (idx *trieIndex) child(node int32, label byte, create bool) int32 {
+	labels := idx.Nodes[node].Labels
+	pos := sort.Search(len(labels), func(i int) bool { return labels[i] >= label })
+	if pos < len(labels) && labels[pos] == label {
+		return idx.Nodes[node].Children[pos]
+	}
+	if !create {
+		return -1
+	}
+	child := int32(len(idx.Nodes))
+	idx.Nodes = append(idx.Nodes, trieNode{})
+	n := &idx.Nodes[node]
+	n.Labels = append(n.Labels[:pos], append([]byte{label}, n.Labels[pos:]...)...)
+	n.Children = append(n.Children[:pos], append([]int32{child}, n.Children[pos:]...)...)
+	return child
+}
+
+// find returns the node of key, it's -1 if not found
+func (idx *trieIndex) find(key string) int32 {
+	node := int32(0)
+	for j := 0; j < len(key) && node >= 0; j++ {
+		node = idx.child(node, key[j], false)
+	}
+	return node
+}
+
+func (idx *trieIndex) In(values []any) ([]bool, bool) {
+	ret := make([]bool, idx.NumRows)
+	for _, value := range values {
+		key, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if node := idx.find(key); node >= 0 {
+			for _, offset := range idx.Nodes[node].Rows {
+				ret[offset] = true
+			}
+		}
+	}
+	return ret, true
+}
+
+func (idx *trieIndex) Range(lower any, includeLower bool, upper any, includeUpper bool) ([]bool, bool) {
+	return nil, false
+}
+
+func (idx *trieIndex) PrefixMatch(prefix string) ([]bool, bool) {
+	ret := make([]bool, idx.NumRows)
+	node := idx.find(prefix)
+	if node < 0 {
+		return ret, true
+	}
+	stack := []int32{node}
+	for len(stack) > 0 {
+		node, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		for _, offset := range idx.Nodes[node].Rows {
+			ret[offset] = true
+		}
+		stack = append(stack, idx.Nodes[node].Children...)
+	}
+	return ret, true
+}
+
+func (idx *trieIndex) Serialize() ([]byte, error) {
+	return serializeScalarIndex(IndexTypeTrie, &idx.trieData)
+}
+
+func (idx *trieIndex) Load(data []byte) error {
+	return loadScalarIndex(IndexTypeTrie, data, &idx.trieData)
+}
@@ -0,0 +1,257 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"sync"
+
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// posting is a non-zero element of the row in the posting list of its index
+type posting struct {
+	Row   int32
+	Value float32
+}
+
+// sparseInvertedData is the serialized content of sparse inverted index
+type sparseInvertedData struct {
+	Type      string
+	Metric    string
+	DropRatio float64
+	Rows      int
+	// Postings are the rows of non-zero elements of each index in ascending order of rows
+	Postings map[uint32][]posting
+	// MaxValues are the max value of each posting list, the upper bounds of WAND
+	MaxValues map[uint32]float32
+}
+
+// sparseInverted is the inverted index of sparse float vectors, only IP is supported.
+// SPARSE_INVERTED_INDEX scores all rows of the posting lists of query term at a time,
+// SPARSE_WAND traverses the lists document at a time and skips rows which can't make into the topK by the upper bounds,
+// see https://dl.acm.org/doi/10.1145/956863.956944. The smallest drop_ratio_build of elements of each row are not indexed.
+// Rows could be added after built, so it's used by growing segments as well.
+type sparseInverted struct {
+	lock sync.RWMutex
+	sparseInvertedData
+}
+
+func newSparseInverted(indexType, metricType string, dropRatio float64) *sparseInverted {
+	return &sparseInverted{sparseInvertedData: sparseInvertedData{
+		Type:      indexType,
+		Metric:    metricType,
+		DropRatio: dropRatio,
+		Postings:  make(map[uint32][]posting),
+		MaxValues: make(map[uint32]float32),
+	}}
+}
+
+func (idx *sparseInverted) IndexType() string {
+	return idx.Type
+}
+
+func (idx *sparseInverted) MetricType() string {
+	return idx.Metric
+}
+
+func (idx *sparseInverted) Build(vectors storage.FieldData) error {
+	return idx.Add(vectors)
+}
+
+// Add appends the postings of rows, their offsets follow the rows added before
+func (idx *sparseInverted) Add(vectors storage.FieldData) error {
+	data, ok := vectors.(*storage.SparseFloatVectorFieldData)
+	if !ok {
+		return errors.Errorf("%s index requires sparse float vectors", idx.Type)
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	for _, row := range data.Data {
+		for _, element := range dropSmallest(row, idx.DropRatio) {
+			idx.Postings[element.index] = append(idx.Postings[element.index], posting{Row: int32(idx.Rows), Value: element.value})
+			if element.value > idx.MaxValues[element.index] {
+				idx.MaxValues[element.index] = element.value
+			}
+		}
+		idx.Rows++
+	}
+	return nil
+}
+
+func (idx *sparseInverted) Search(queries storage.FieldData, topK int, params map[string]any, valid []bool) ([][]search.Hit, error) {
+	data, ok := queries.(*storage.SparseFloatVectorFieldData)
+	if !ok {
+		return nil, merr.WrapErrParameterInvalidMsg("%s index requires sparse float vectors", idx.Type)
+	}
+	dropRatio, err := getSearchFloatParam(params, DropRatioSearchKey, 0, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	// valid only covers the rows when the view is taken, rows added later are invisible
+	accept := func(row int) bool {
+		return valid == nil || (row < len(valid) && valid[row])
+	}
+	ret := make([][]search.Hit, data.RowNum())
+	for q := range ret {
+		query := dropSmallest(data.Data[q], dropRatio)
+		collector := search.NewTopK(topK, idx.Metric)
+		if idx.Type == IndexTypeSparseWand {
+			idx.searchWand(query, collector, accept)
+		} else {
+			idx.searchTaat(query, collector, accept)
+		}
+		ret[q] = collector.Sorted()
+	}
+	return ret, nil
+}
+
+// searchTaat accumulates the scores of all rows by the posting lists of query terms one by one
+func (idx *sparseInverted) searchTaat(query []sparseElement, collector *search.TopK, accept func(int) bool) {
+	scores := make([]float32, idx.Rows)
+	for _, element := range query {
+		for _, p := range idx.Postings[element.index] {
+			scores[p.Row] += element.value * p.Value
+		}
+	}
+	for row, score := range scores {
+		if accept(row) {
+			collector.Push(row, score)
+		}
+	}
+}
+
+// wandCursor is the position in the posting list of a query term
+type wandCursor struct {
+	postings   []posting
+	pos        int
+	weight     float32
+	upperBound float32
+}
+
+func (c *wandCursor) row() int32 {
+	return c.postings[c.pos].Row
+}
+
+// searchWand scores the rows in ascending order, rows whose upper bound of score is not better than the k-th hit are skipped.
+// Rows not in any posting list are scored 0, they're collected at last if there're fewer than k hits.
+func (idx *sparseInverted) searchWand(query []sparseElement, collector *search.TopK, accept func(int) bool) {
+	cursors := make([]*wandCursor, 0, len(query))
+	for _, element := range query {
+		if postings := idx.Postings[element.index]; len(postings) > 0 {
+			cursors = append(cursors, &wandCursor{
+				postings:   postings,
+				weight:     element.value,
+				upperBound: element.value * idx.MaxValues[element.index],
+			})
+		}
+	}
+	scored := make(map[int32]struct{})
+	for len(cursors) > 0 {
+		sort.Slice(cursors, func(i, j int) bool { return cursors[i].row() < cursors[j].row() })
+		threshold, full := collector.Worst()
+		pivot := -1
+		var bound float32
+		for i, c := range cursors {
+			bound += c.upperBound
+			if !full || bound > threshold {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			break
+		}
+		pivotRow := cursors[pivot].row()
+		if cursors[0].row() == pivotRow {
+			var score float32
+			for _, c := range cursors {
+				if c.row() != pivotRow {
+					break
+				}
+				score += c.weight * c.postings[c.pos].Value
+				c.pos++
+			}
+			scored[pivotRow] = struct{}{}
+			if accept(int(pivotRow)) {
+				collector.Push(int(pivotRow), score)
+			}
+		} else {
+			// none of the rows before pivot could be better than the k-th hit
+			for _, c := range cursors[:pivot] {
+				c.pos += sort.Search(len(c.postings)-c.pos, func(i int) bool { return c.postings[c.pos+i].Row >= pivotRow })
+			}
+		}
+		remaining := cursors[:0]
+		for _, c := range cursors {
+			if c.pos < len(c.postings) {
+				remaining = append(remaining, c)
+			}
+		}
+		cursors = remaining
+	}
+	if _, full := collector.Worst(); full {
+		return
+	}
+	for row := 0; row < idx.Rows; row++ {
+		if _, ok := scored[int32(row)]; !ok && accept(row) {
+			collector.Push(row, 0)
+		}
+	}
+}
+
+func (idx *sparseInverted) Serialize() ([]byte, error) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&idx.sparseInvertedData); err != nil {
+		return nil, errors.Wrapf(err, "failed to serialize %s index", idx.Type)
+	}
+	return buf.Bytes(), nil
+}
+
+func (idx *sparseInverted) Load(data []byte) error {
+	var loaded sparseInvertedData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&loaded); err != nil {
+		return errors.Wrapf(err, "failed to deserialize %s index", idx.Type)
+	}
+	if loaded.Type != idx.Type || loaded.Metric != idx.Metric {
+		return errors.Errorf("index file of %s %s mismatches index %s %s", loaded.Type, loaded.Metric, idx.Type, idx.Metric)
+	}
+	if loaded.Postings == nil {
+		loaded.Postings = make(map[uint32][]posting)
+		loaded.MaxValues = make(map[uint32]float32)
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.sparseInvertedData = loaded
+	return nil
+}
+
+type sparseElement struct {
+	index uint32
+	value float32
+}
+
+// dropSmallest returns the elements of sparse row without the smallest ratio of them, in ascending order of indexes
+func dropSmallest(row []byte, ratio float64) []sparseElement {
+	ret := make([]sparseElement, 0, storage.SparseRowLen(row))
+	for i := 0; i < storage.SparseRowLen(row); i++ {
+		index, value := storage.SparseRowAt(row, i)
+		ret = append(ret, sparseElement{index: index, value: value})
+	}
+	dropped := int(float64(len(ret)) * ratio)
+	if dropped == 0 {
+		return ret
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].value > ret[j].value })
+	ret = ret[:len(ret)-dropped]
+	sort.Slice(ret, func(i, j int) bool { return ret[i].index < ret[j].index })
+	return ret
+}
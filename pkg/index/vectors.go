@@ -0,0 +1,40 @@
+package index
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// isHalfVectorType returns whether the vectors are float16 or bfloat16. Indexes keep them packed as the field data
+// and decode them per distance computation, rather than keeping a float32 copy twice as large.
+func isHalfVectorType(dataType schemapb.DataType) bool {
+	return dataType == schemapb.DataType_Float16Vector || dataType == storage.DataType_BFloat16Vector
+}
+
+// packedVectors returns the packed bytes of float16 or bfloat16 vectors, false if data is not of the type and dim
+func packedVectors(data storage.FieldData, dataType schemapb.DataType, dim int) ([]byte, bool) {
+	switch d := data.(type) {
+	case *storage.Float16VectorFieldData:
+		return d.Data, dataType == schemapb.DataType_Float16Vector && d.Dim == dim
+	case *storage.BFloat16VectorFieldData:
+		return d.Data, dataType == storage.DataType_BFloat16Vector && d.Dim == dim
+	}
+	return nil, false
+}
+
+// halfVectors wraps the packed bytes into field data of the float16 or bfloat16 type
+func halfVectors(dataType schemapb.DataType, packed []byte, dim int) storage.FieldData {
+	if dataType == storage.DataType_BFloat16Vector {
+		return &storage.BFloat16VectorFieldData{Data: packed, Dim: dim}
+	}
+	return &storage.Float16VectorFieldData{Data: packed, Dim: dim}
+}
+
+// encodeHalf packs float32 values into float16 or bfloat16, it's used to load index files
+// which kept the decoded vectors, the values were decoded from half precision so it's lossless
+func encodeHalf(dataType schemapb.DataType, values []float32) []byte {
+	if dataType == storage.DataType_BFloat16Vector {
+		return storage.Float32sToBFloat16(values)
+	}
+	return storage.Float32sToFloat16(values)
+}
@@ -0,0 +1,395 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+type InsertTask struct {
+	idAllocator    allocator.Interface
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.InsertRequest
+}
+
+func NewInsertTask(
+	idAllocator allocator.Interface,
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.InsertRequest) *InsertTask {
+
+	return &InsertTask{
+		idAllocator:    idAllocator,
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+func (t InsertTask) Execute(ctx context.Context) (*milvuspb.MutationResult, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPartitionKeyMode(collection, t.req.GetPartitionName()); err != nil {
+		return nil, err
+	}
+	ts, done, err := t.segmentManager.BeginWrite(t.tsAllocator)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	data, pks, err := buildInsertData(t.idAllocator, collection, t.req.GetFieldsData(), t.req.GetNumRows(), ts)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertRows(ctx, t.segmentManager, collection, t.req.GetPartitionName(), data); err != nil {
+		return nil, err
+	}
+	return &milvuspb.MutationResult{
+		IDs:       pks,
+		SuccIndex: succIndex(t.req.GetNumRows()),
+		InsertCnt: int64(t.req.GetNumRows()),
+		Timestamp: ts,
+	}, nil
+}
+
+type UpsertTask struct {
+	idAllocator    allocator.Interface
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.UpsertRequest
+}
+
+func NewUpsertTask(
+	idAllocator allocator.Interface,
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.UpsertRequest) *UpsertTask {
+
+	return &UpsertTask{
+		idAllocator:    idAllocator,
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// Execute deletes the rows of the same primary keys and inserts the new rows with the same timestamp,
+// the rows inserted are not deleted since only rows before the delete timestamp are deleted.
+func (t UpsertTask) Execute(ctx context.Context) (*milvuspb.MutationResult, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	if collection.AutoID {
+		return nil, merr.WrapErrParameterInvalidMsg("upsert can not be used when autoID is enabled")
+	}
+	if err := checkPartitionKeyMode(collection, t.req.GetPartitionName()); err != nil {
+		return nil, err
+	}
+	if _, err := getPartitionID(collection, t.req.GetPartitionName()); err != nil {
+		return nil, err
+	}
+	ts, done, err := t.segmentManager.BeginWrite(t.tsAllocator)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	data, ids, err := buildInsertData(t.idAllocator, collection, t.req.GetFieldsData(), t.req.GetNumRows(), ts)
+	if err != nil {
+		return nil, err
+	}
+	pkField, err := typeutil.GetPrimaryFieldSchema(model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema())
+	if err != nil {
+		return nil, err
+	}
+	pks, err := storage.GetPrimaryKeys(data.Data[pkField.GetFieldID()])
+	if err != nil {
+		return nil, err
+	}
+	t.segmentManager.Delete(ctx, collection.CollectionID, nil, pks, ts)
+	if err := insertRows(ctx, t.segmentManager, collection, t.req.GetPartitionName(), data); err != nil {
+		return nil, err
+	}
+	return &milvuspb.MutationResult{
+		IDs:       ids,
+		SuccIndex: succIndex(t.req.GetNumRows()),
+		UpsertCnt: int64(t.req.GetNumRows()),
+		Timestamp: ts,
+	}, nil
+}
+
+// insertRows inserts the rows into the partition, rows are routed to the partitions of their partition keys
+// if partition key is used. The rows of all partitions are inserted or none is.
+func insertRows(ctx context.Context, segmentManager *segments.Manager, collection *model.Collection,
+	partitionName string, data *storage.InsertData) error {
+	keyField := getPartitionKeyField(collection)
+	if keyField == nil {
+		partitionID, err := getPartitionID(collection, partitionName)
+		if err != nil {
+			return err
+		}
+		return segmentManager.Insert(ctx, collection, partitionID, data)
+	}
+	partitions, err := splitByPartitionKey(collection, keyField, data)
+	if err != nil {
+		return err
+	}
+	return segmentManager.InsertPartitions(ctx, collection, partitions)
+}
+
+// getPartitionID returns the id of partition, the default partition is used if name is empty
+func getPartitionID(collection *model.Collection, partitionName string) (UniqueID, error) {
+	if partitionName == "" {
+		if len(collection.Partitions) == 0 {
+			return 0, merr.WrapErrPartitionNotFound("default", "collection has no partition")
+		}
+		return collection.Partitions[0].PartitionID, nil
+	}
+	for _, partition := range collection.Partitions {
+		if partition.PartitionName == partitionName {
+			return partition.PartitionID, nil
+		}
+	}
+	return 0, merr.WrapErrPartitionNotFound(partitionName)
+}
+
+// buildInsertData converts the columns of request into InsertData, row ids & timestamps are filled,
+// primary keys are allocated if autoID is enabled, omitted columns are filled with default values or nulls.
+// It returns the primary keys of rows.
+func buildInsertData(idAllocator allocator.Interface, collection *model.Collection, fieldsData []*schemapb.FieldData,
+	numRows uint32, ts Timestamp) (*storage.InsertData, *schemapb.IDs, error) {
+	if numRows == 0 {
+		return nil, nil, merr.WrapErrParameterInvalidMsg("no rows to insert")
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]*schemapb.FieldData, len(fieldsData))
+	for _, fieldData := range fieldsData {
+		columns[fieldData.GetFieldName()] = fieldData
+	}
+	// columns not in schema are keys of the dynamic field, so they're rejected if it's disabled
+	if !schema.GetEnableDynamicField() {
+		fieldNames := make(map[string]struct{}, len(schema.GetFields()))
+		for _, field := range schema.GetFields() {
+			fieldNames[field.GetName()] = struct{}{}
+		}
+		for _, fieldData := range fieldsData {
+			if _, ok := fieldNames[strings.TrimSuffix(fieldData.GetFieldName(), ValidFieldSuffix)]; !ok {
+				return nil, nil, merr.WrapErrParameterInvalidMsg("field %s not found in schema, "+
+					"it can't be inserted since dynamic field is not enabled", fieldData.GetFieldName())
+			}
+		}
+	}
+
+	rowIDStart, _, err := idAllocator.Alloc(numRows)
+	if err != nil {
+		return nil, nil, merr.WrapErrServiceUnavailable(err.Error())
+	}
+	rowIDs := make([]int64, numRows)
+	tss := make([]int64, numRows)
+	for i := range rowIDs {
+		rowIDs[i] = rowIDStart + int64(i)
+		tss[i] = int64(ts)
+	}
+
+	data := &storage.InsertData{Data: make(map[storage.FieldID]storage.FieldData)}
+	for _, field := range schema.GetFields() {
+		switch {
+		case field.GetFieldID() == common.RowIDField:
+			data.Data[field.GetFieldID()] = &storage.Int64FieldData{Data: rowIDs}
+			continue
+		case field.GetFieldID() == common.TimeStampField:
+			data.Data[field.GetFieldID()] = &storage.Int64FieldData{Data: tss}
+			continue
+		case field.GetIsPrimaryKey() && field.GetAutoID():
+			if _, ok := columns[field.GetName()]; ok {
+				return nil, nil, merr.WrapErrParameterInvalidMsg("can't assign primary key %s when autoID is enabled", field.GetName())
+			}
+			if field.GetDataType() == schemapb.DataType_VarChar {
+				ids := make([]string, numRows)
+				for i, rowID := range rowIDs {
+					ids[i] = strconv.FormatInt(rowID, 10)
+				}
+				data.Data[field.GetFieldID()] = &storage.StringFieldData{Data: ids, DataType: schemapb.DataType_VarChar}
+				continue
+			}
+			data.Data[field.GetFieldID()] = &storage.Int64FieldData{Data: rowIDs}
+			continue
+		}
+
+		if field.GetIsDynamic() {
+			data.Data[field.GetFieldID()], err = buildDynamicData(schema, fieldsData, numRows)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		column, ok := columns[field.GetName()]
+		validColumn, hasValid := columns[field.GetName()+ValidFieldSuffix]
+		if hasValid && !storage.IsNullable(field) {
+			return nil, nil, merr.WrapErrParameterInvalidMsg("field %s is not nullable", field.GetName())
+		}
+		if !ok && hasValid {
+			return nil, nil, merr.WrapErrParameterInvalidMsg("field %s not found in request while its validity is given", field.GetName())
+		}
+		if !ok && field.GetDefaultValue() != nil {
+			data.Data[field.GetFieldID()], err = fillDefaultValue(field, numRows)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if !ok && storage.IsNullable(field) {
+			data.Data[field.GetFieldID()], err = fillNull(field, numRows)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if !ok {
+			return nil, nil, merr.WrapErrParameterInvalidMsg("field %s not found in request", field.GetName())
+		}
+		fieldData, err := storage.FieldDataFromProto(field, column)
+		if err != nil {
+			return nil, nil, merr.WrapErrParameterInvalidMsg(err.Error())
+		}
+		if fieldData.RowNum() != int(numRows) {
+			return nil, nil, merr.WrapErrParameterInvalid(int(numRows), fieldData.RowNum(), "the number of rows of field "+field.GetName())
+		}
+		if hasValid {
+			fieldData, err = applyValidData(field, fieldData, validColumn)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := validateArrayData(field, fieldData); err != nil {
+			return nil, nil, err
+		}
+		data.Data[field.GetFieldID()] = fieldData
+	}
+
+	pks, err := storage.GetPrimaryKeys(data.Data[pkField.GetFieldID()])
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, storage.PrimaryKeysToIDs(pkField.GetDataType(), pks), nil
+}
+
+// buildDynamicData builds the $meta json objects of rows, the columns not in schema are folded into them as keys.
+// Keys of $meta can't be the names of fields in schema.
+func buildDynamicData(schema *schemapb.CollectionSchema, fieldsData []*schemapb.FieldData, numRows uint32) (storage.FieldData, error) {
+	staticFields := make(map[string]struct{}, len(schema.GetFields()))
+	for _, field := range schema.GetFields() {
+		if !field.GetIsDynamic() {
+			staticFields[field.GetName()] = struct{}{}
+		}
+	}
+	rows := make([]map[string]json.RawMessage, numRows)
+	for i := range rows {
+		rows[i] = make(map[string]json.RawMessage)
+	}
+	set := func(row int, key string, value json.RawMessage) error {
+		if _, ok := staticFields[key]; ok {
+			return merr.WrapErrParameterInvalidMsg("key %s of dynamic field conflicts with the field of schema", key)
+		}
+		if _, ok := rows[row][key]; ok {
+			return merr.WrapErrParameterInvalidMsg("duplicated key %s of dynamic field", key)
+		}
+		rows[row][key] = value
+		return nil
+	}
+	for _, column := range fieldsData {
+		name := column.GetFieldName()
+		// columns of static fields and their validity
+		if _, ok := staticFields[strings.TrimSuffix(name, ValidFieldSuffix)]; ok {
+			continue
+		}
+		if name == common.MetaFieldName || column.GetIsDynamic() {
+			jsons := column.GetScalars().GetJsonData().GetData()
+			if len(jsons) != int(numRows) {
+				return nil, merr.WrapErrParameterInvalid(int(numRows), len(jsons), "the number of rows of dynamic field")
+			}
+			for i, value := range jsons {
+				var object map[string]json.RawMessage
+				if err := json.Unmarshal(value, &object); err != nil || object == nil {
+					return nil, merr.WrapErrParameterInvalidMsg("dynamic field should be json objects, got %s", string(value))
+				}
+				for key, v := range object {
+					if err := set(i, key, v); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+
+		if storage.IsVectorType(column.GetType()) || column.GetType() == schemapb.DataType_Array {
+			return nil, merr.WrapErrParameterInvalidMsg("%s of %s can't be a key of dynamic field", name, column.GetType().String())
+		}
+		values, err := storage.FieldDataFromProto(&schemapb.FieldSchema{Name: name, DataType: column.GetType()}, column)
+		if err != nil {
+			return nil, merr.WrapErrParameterInvalidMsg(err.Error())
+		}
+		if values.RowNum() != int(numRows) {
+			return nil, merr.WrapErrParameterInvalid(int(numRows), values.RowNum(), "the number of rows of field "+name)
+		}
+		for i := range rows {
+			var value []byte
+			if raw, ok := values.GetRow(i).([]byte); ok {
+				if !json.Valid(raw) {
+					return nil, merr.WrapErrParameterInvalidMsg("invalid json %s of %s", string(raw), name)
+				}
+				value = raw
+			} else if value, err = json.Marshal(values.GetRow(i)); err != nil {
+				return nil, merr.WrapErrParameterInvalidMsg(err.Error())
+			}
+			if err := set(i, name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ret := &storage.JSONFieldData{Data: make([][]byte, 0, numRows)}
+	for _, row := range rows {
+		value, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		ret.Data = append(ret.Data, value)
+	}
+	return ret, nil
+}
+
+func succIndex(numRows uint32) []uint32 {
+	ret := make([]uint32, numRows)
+	for i := range ret {
+		ret[i] = uint32(i)
+	}
+	return ret
+}
@@ -0,0 +1,287 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/expr"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+)
+
+// QueryIterator walks the rows matching the query in batches ordered by primary key.
+// Each batch queries the rows after the last primary key returned, so no rows are loaded all at once.
+type QueryIterator struct {
+	m         *MilvusMini
+	req       *milvuspb.QueryRequest
+	pkField   *schemapb.FieldSchema
+	batchSize int
+	// limit is the max number of rows to return, -1 if there's no limit
+	limit  int
+	offset int
+	// cursor is the last primary key returned, nil before the first batch
+	cursor   any
+	returned int
+	done     bool
+}
+
+// NewQueryIterator returns the iterator of query request, limit & offset of the request apply to all the batches
+func NewQueryIterator(ctx context.Context, m *MilvusMini, req *milvuspb.QueryRequest, batchSize int) (*QueryIterator, error) {
+	if batchSize <= 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid batch size: %d", batchSize)
+	}
+	pkField, err := getPrimaryField(ctx, m, req.GetDbName(), req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	limit, offset, err := parseLimitOffset(req.GetQueryParams())
+	if err != nil {
+		return nil, err
+	}
+	return &QueryIterator{
+		m:         m,
+		req:       req,
+		pkField:   pkField,
+		batchSize: batchSize,
+		limit:     limit,
+		offset:    offset,
+	}, nil
+}
+
+// Next returns the next batch of rows, io.EOF is returned if all rows are returned
+func (it *QueryIterator) Next(ctx context.Context) (*milvuspb.QueryResults, error) {
+	batchSize := it.batchSize
+	if it.limit >= 0 && it.limit-it.returned < batchSize {
+		batchSize = it.limit - it.returned
+	}
+	if it.done || batchSize == 0 {
+		return nil, io.EOF
+	}
+	req := proto.Clone(it.req).(*milvuspb.QueryRequest)
+	req.QueryParams = []*commonpb.KeyValuePair{{Key: LimitKey, Value: strconv.Itoa(batchSize)}}
+	if it.cursor == nil {
+		req.QueryParams = append(req.QueryParams, &commonpb.KeyValuePair{Key: OffsetKey, Value: strconv.Itoa(it.offset)})
+	} else {
+		req.Expr = andExpr(req.GetExpr(), fmt.Sprintf("%s > %s", it.pkField.GetName(), formatPK(it.cursor)))
+	}
+	resp, err := NewQueryTask(it.m.tsAllocator, it.m.meta, it.m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pks := getPKs(resp.GetFieldsData(), it.pkField.GetName())
+	if len(pks) < batchSize {
+		it.done = true
+	}
+	if len(pks) == 0 {
+		return nil, io.EOF
+	}
+	it.cursor = pks[len(pks)-1]
+	it.returned += len(pks)
+	return resp, nil
+}
+
+// SearchIterator walks the hits of one query vector in batches ordered by score.
+// Each batch is a range search of scores not better than the last score, the hits of the last score returned are excluded.
+type SearchIterator struct {
+	m          *MilvusMini
+	req        *milvuspb.SearchRequest
+	pkField    *schemapb.FieldSchema
+	metricType string
+	batchSize  int
+	// limit is the max number of hits to return, -1 if there's no limit
+	limit int
+	// params are the search params of the request without topk, radius & range_filter are set for each batch
+	params     []*commonpb.KeyValuePair
+	indexParam map[string]any
+	radius     float32
+	// lastScore is the score of the last hit returned, tiedPKs are the primary keys of the hits of lastScore
+	lastScore *float32
+	tiedPKs   []any
+	returned  int
+	done      bool
+}
+
+// NewSearchIterator returns the iterator of search request, which must search one vector.
+// topk of the request is ignored, the iterator returns limit hits in total, or all hits if limit is -1.
+func NewSearchIterator(ctx context.Context, m *MilvusMini, req *milvuspb.SearchRequest, batchSize int, limit int) (*SearchIterator, error) {
+	if batchSize <= 0 || batchSize > MaxTopK {
+		return nil, merr.WrapErrParameterInvalidRange(1, MaxTopK, batchSize, "invalid batch size")
+	}
+	if limit < -1 {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid limit: %d", limit)
+	}
+	collection, err := m.meta.GetCollectionByName(ctx, req.GetDbName(), req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := m.meta.ListIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	it := &SearchIterator{m: m, req: req, pkField: pkField, batchSize: batchSize, limit: limit, indexParam: make(map[string]any)}
+	for _, kv := range req.GetSearchParams() {
+		switch kv.GetKey() {
+		case common.TopKKey:
+		case OffsetKey, RoundDecimalKey, GroupByFieldKey:
+			return nil, merr.WrapErrParameterInvalidMsg("%s is not supported by search iterator", kv.GetKey())
+		case common.IndexParamsKey, common.SearchParamKey:
+			if kv.GetValue() != "" {
+				if err := json.Unmarshal([]byte(kv.GetValue()), &it.indexParam); err != nil {
+					return nil, merr.WrapErrParameterInvalidMsg("invalid search param %s: %s, %s", kv.GetKey(), kv.GetValue(), err.Error())
+				}
+			}
+		default:
+			it.params = append(it.params, kv)
+		}
+	}
+	params, err := parseSearchParams(schema, it.batchParams(batchSize), indexes)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := parsePlaceholderGroup(params.field, req.GetPlaceholderGroup())
+	if err != nil {
+		return nil, err
+	}
+	if queries.RowNum() != 1 {
+		return nil, merr.WrapErrParameterInvalidMsg("search iterator supports one query vector only, got %d", queries.RowNum())
+	}
+	it.metricType = params.metricType
+	it.radius = math.MaxFloat32
+	if metric.PositivelyRelated(it.metricType) {
+		it.radius = -math.MaxFloat32
+	}
+	if params.scoreRange != nil {
+		it.radius = params.scoreRange.Radius
+		it.lastScore = params.scoreRange.RangeFilter
+	}
+	return it, nil
+}
+
+// Next returns the next batch of hits, io.EOF is returned if all hits are returned
+func (it *SearchIterator) Next(ctx context.Context) (*milvuspb.SearchResults, error) {
+	batchSize := it.batchSize
+	if it.limit >= 0 && it.limit-it.returned < batchSize {
+		batchSize = it.limit - it.returned
+	}
+	if it.done || batchSize == 0 {
+		return nil, io.EOF
+	}
+	req := proto.Clone(it.req).(*milvuspb.SearchRequest)
+	req.SearchParams = it.batchParams(batchSize)
+	req.DslType = commonpb.DslType_BoolExprV1
+	if len(it.tiedPKs) > 0 {
+		values := make([]string, 0, len(it.tiedPKs))
+		for _, pk := range it.tiedPKs {
+			values = append(values, formatPK(pk))
+		}
+		req.Dsl = andExpr(req.GetDsl(), fmt.Sprintf("%s not in [%s]", it.pkField.GetName(), strings.Join(values, ", ")))
+	}
+	resp, err := NewSearchTask(it.m.tsAllocator, it.m.meta, it.m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scores := resp.GetResults().GetScores()
+	if len(scores) < batchSize {
+		it.done = true
+	}
+	if len(scores) == 0 {
+		return nil, io.EOF
+	}
+	pks := make([]any, 0, len(scores))
+	switch ids := resp.GetResults().GetIds().GetIdField().(type) {
+	case *schemapb.IDs_IntId:
+		for _, pk := range ids.IntId.GetData() {
+			pks = append(pks, pk)
+		}
+	case *schemapb.IDs_StrId:
+		for _, pk := range ids.StrId.GetData() {
+			pks = append(pks, pk)
+		}
+	}
+	last := scores[len(scores)-1]
+	if it.lastScore == nil || *it.lastScore != last {
+		it.tiedPKs = it.tiedPKs[:0]
+	}
+	for i := len(scores) - 1; i >= 0 && scores[i] == last; i-- {
+		it.tiedPKs = append(it.tiedPKs, pks[i])
+	}
+	it.lastScore = &last
+	it.returned += len(scores)
+	return resp, nil
+}
+
+// batchParams returns the search params of the batch, it's a range search after the last score
+func (it *SearchIterator) batchParams(topK int) []*commonpb.KeyValuePair {
+	indexParam := make(map[string]any, len(it.indexParam)+2)
+	for key, value := range it.indexParam {
+		indexParam[key] = value
+	}
+	if it.lastScore != nil {
+		indexParam[RadiusKey] = float64(it.radius)
+		indexParam[RangeFilterKey] = float64(*it.lastScore)
+	}
+	value, _ := json.Marshal(indexParam)
+	params := make([]*commonpb.KeyValuePair, 0, len(it.params)+2)
+	params = append(params, it.params...)
+	return append(params,
+		&commonpb.KeyValuePair{Key: common.TopKKey, Value: strconv.Itoa(topK)},
+		&commonpb.KeyValuePair{Key: common.IndexParamsKey, Value: string(value)})
+}
+
+func getPrimaryField(ctx context.Context, m *MilvusMini, dbName, collectionName string) (*schemapb.FieldSchema, error) {
+	collection, err := m.meta.GetCollectionByName(ctx, dbName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return typeutil.GetPrimaryFieldSchema(model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema())
+}
+
+// getPKs returns the primary keys of the fields data
+func getPKs(fieldsData []*schemapb.FieldData, pkName string) []any {
+	ret := make([]any, 0)
+	for _, fieldData := range fieldsData {
+		if fieldData.GetFieldName() != pkName {
+			continue
+		}
+		for _, pk := range fieldData.GetScalars().GetLongData().GetData() {
+			ret = append(ret, pk)
+		}
+		for _, pk := range fieldData.GetScalars().GetStringData().GetData() {
+			ret = append(ret, pk)
+		}
+	}
+	return ret
+}
+
+// formatPK returns the literal of primary key in expression
+func formatPK(pk any) string {
+	if s, ok := pk.(string); ok {
+		return expr.Quote(s)
+	}
+	return fmt.Sprint(pk)
+}
+
+// andExpr returns the expression matching both expressions, the first one could be empty
+func andExpr(first, second string) string {
+	if strings.TrimSpace(first) == "" {
+		return second
+	}
+	return fmt.Sprintf("(%s) and %s", first, second)
+}
@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+)
+
+type LoadTask struct {
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	dbName         string
+	collectionName string
+	partitionNames []string
+}
+
+// NewLoadCollectionTask loads all partitions of the collection
+func NewLoadCollectionTask(meta metas.MetaTable, segmentManager *segments.Manager, request *milvuspb.LoadCollectionRequest) *LoadTask {
+	return &LoadTask{
+		meta:           meta,
+		segmentManager: segmentManager,
+		dbName:         request.GetDbName(),
+		collectionName: request.GetCollectionName(),
+	}
+}
+
+func NewLoadPartitionsTask(meta metas.MetaTable, segmentManager *segments.Manager, request *milvuspb.LoadPartitionsRequest) *LoadTask {
+	return &LoadTask{
+		meta:           meta,
+		segmentManager: segmentManager,
+		dbName:         request.GetDbName(),
+		collectionName: request.GetCollectionName(),
+		partitionNames: request.GetPartitionNames(),
+	}
+}
+
+// Execute starts loading segments into memory, the progress is checked by GetLoadingProgress
+func (t LoadTask) Execute(ctx context.Context) error {
+	collection, err := t.meta.GetCollectionByName(ctx, t.dbName, t.collectionName)
+	if err != nil {
+		return err
+	}
+	partitionIDs, err := getPartitionIDs(collection, t.partitionNames)
+	if err != nil {
+		return err
+	}
+	return t.segmentManager.Load(ctx, collection, partitionIDs)
+}
+
+type ReleaseTask struct {
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	dbName         string
+	collectionName string
+	partitionNames []string
+}
+
+// NewReleaseCollectionTask releases all partitions of the collection
+func NewReleaseCollectionTask(meta metas.MetaTable, segmentManager *segments.Manager, request *milvuspb.ReleaseCollectionRequest) *ReleaseTask {
+	return &ReleaseTask{
+		meta:           meta,
+		segmentManager: segmentManager,
+		dbName:         request.GetDbName(),
+		collectionName: request.GetCollectionName(),
+	}
+}
+
+func NewReleasePartitionsTask(meta metas.MetaTable, segmentManager *segments.Manager, request *milvuspb.ReleasePartitionsRequest) *ReleaseTask {
+	return &ReleaseTask{
+		meta:           meta,
+		segmentManager: segmentManager,
+		dbName:         request.GetDbName(),
+		collectionName: request.GetCollectionName(),
+		partitionNames: request.GetPartitionNames(),
+	}
+}
+
+// Execute releases segments from memory, search & query are rejected after released
+func (t ReleaseTask) Execute(ctx context.Context) error {
+	collection, err := t.meta.GetCollectionByName(ctx, t.dbName, t.collectionName)
+	if err != nil {
+		return err
+	}
+	if t.partitionNames != nil && len(t.partitionNames) == 0 {
+		return merr.WrapErrParameterInvalidMsg("no partition to release")
+	}
+	partitionIDs, err := getPartitionIDs(collection, t.partitionNames)
+	if err != nil {
+		return err
+	}
+	return t.segmentManager.Release(ctx, collection.CollectionID, partitionIDs)
+}
+
+func GetLoadState(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, req *milvuspb.GetLoadStateRequest) (*milvuspb.GetLoadStateResponse, error) {
+	collection, err := meta.GetCollectionByName(ctx, req.GetDbName(), req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	partitionIDs, err := getPartitionIDs(collection, req.GetPartitionNames())
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.GetLoadStateResponse{
+		Status: merr.Status(nil),
+		State:  segmentManager.GetLoadState(collection.CollectionID, partitionIDs),
+	}, nil
+}
+
+func GetLoadingProgress(ctx context.Context, meta metas.MetaTable, segmentManager *segments.Manager, req *milvuspb.GetLoadingProgressRequest) (*milvuspb.GetLoadingProgressResponse, error) {
+	collection, err := meta.GetCollectionByName(ctx, req.GetDbName(), req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	partitionIDs, err := getPartitionIDs(collection, req.GetPartitionNames())
+	if err != nil {
+		return nil, err
+	}
+	progress, err := segmentManager.GetLoadingProgress(collection.CollectionID, partitionIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.GetLoadingProgressResponse{
+		Status:   merr.Status(nil),
+		Progress: progress,
+	}, nil
+}
+
+// getPartitionIDs returns the ids of partitions, it's empty if no partition names
+func getPartitionIDs(collection *model.Collection, partitionNames []string) ([]UniqueID, error) {
+	partitionIDs := make([]UniqueID, 0, len(partitionNames))
+	for _, name := range partitionNames {
+		partitionID, err := getPartitionID(collection, name)
+		if err != nil {
+			return nil, err
+		}
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	return partitionIDs, nil
+}
@@ -49,6 +49,21 @@ const (
 
 	// GranteeIDPrefix prefix for mapping among privilege and grantor
 	GranteeIDPrefix = ComponentPrefix + CommonCredentialPrefix + "/grantee-id"
+
+	// DataCoordMetaPrefix prefix for datacoord component
+	DataCoordMetaPrefix = "datacoord-meta"
+
+	// SegmentPrefix prefix for segment meta
+	SegmentPrefix = DataCoordMetaPrefix + "/s"
+
+	// CollectionLoadInfoPrefix prefix for collection load state
+	CollectionLoadInfoPrefix = "querycoord-collection-loadinfo"
+
+	// FieldIndexPrefix prefix for index meta
+	FieldIndexPrefix = "field-index"
+
+	// SegmentIndexPrefix prefix for segment index meta
+	SegmentIndexPrefix = "segment-index"
 )
 
 func BuildDatabasePrefixWithDBID(dbID int64) string {
@@ -63,6 +78,38 @@ func BuildDatabaseKey(dbID int64) string {
 	return fmt.Sprintf("%s/%d", DBInfoMetaPrefix, dbID)
 }
 
+func BuildSegmentKey(collectionID int64, partitionID int64, segmentID int64) string {
+	return fmt.Sprintf("%s/%d/%d/%d", SegmentPrefix, collectionID, partitionID, segmentID)
+}
+
+func BuildLoadInfoKey(collectionID int64) string {
+	return fmt.Sprintf("%s/%d", CollectionLoadInfoPrefix, collectionID)
+}
+
+func BuildIndexKey(collectionID, indexID int64) string {
+	return fmt.Sprintf("%s/%d/%d", FieldIndexPrefix, collectionID, indexID)
+}
+
+func BuildSegmentIndexKey(collectionID, partitionID, segmentID, buildID int64) string {
+	return fmt.Sprintf("%s/%d/%d/%d/%d", SegmentIndexPrefix, collectionID, partitionID, segmentID, buildID)
+}
+
+func BuildCredentialKey(username string) string {
+	return fmt.Sprintf("%s/%s", CredentialPrefix, username)
+}
+
+func BuildRoleKey(roleName string) string {
+	return fmt.Sprintf("%s/%s", RolePrefix, roleName)
+}
+
+func BuildRoleMappingKey(username, roleName string) string {
+	return fmt.Sprintf("%s/%s/%s", RoleMappingPrefix, username, roleName)
+}
+
+func BuildGranteeKey(roleName, objectType, dbName, objectName, privilege string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s/%s", GranteePrefix, roleName, objectType, dbName, objectName, privilege)
+}
+
 func getDatabasePrefix(dbID int64) string {
 	if dbID != util.NonDBID {
 		return BuildDatabasePrefixWithDBID(dbID)
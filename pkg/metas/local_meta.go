@@ -7,11 +7,16 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
 	"github.com/milvus-io/milvus/pkg/util"
+	"github.com/milvus-io/milvus/pkg/util/crypto"
 	"github.com/milvus-io/milvus/pkg/util/merr"
 	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/internalpb"
 	"github.com/sharding-db/milvus-mini/pkg/model"
 )
 
@@ -20,7 +25,54 @@ type Timestamp = uint64
 type MetaTable interface {
 	GetDatabaseByName(ctx context.Context, dbName string) (*model.Database, error)
 	GetCollectionByName(ctx context.Context, dbName string, collectionName string) (*model.Collection, error)
+	GetCollectionByID(ctx context.Context, collectionID int64) (*model.Collection, error)
+	ListCollections(ctx context.Context) ([]*model.Collection, error)
 	AddCollection(ctx context.Context, coll *model.Collection) error
+	// AlterCollection replaces the collection, readers holding the old one are not affected
+	AlterCollection(ctx context.Context, coll *model.Collection) error
+	// AlterCollectionProperties sets the properties of the collection, other properties are kept.
+	// They're merged in the lock of meta, so concurrent alters of different properties are not lost.
+	AlterCollectionProperties(ctx context.Context, collectionID int64, properties []*commonpb.KeyValuePair) (*model.Collection, error)
+
+	ListSegments(ctx context.Context, collectionID int64) ([]*model.Segment, error)
+	// SaveSegments saves the segments in order in one lock, so they are changed atomically for readers of meta.
+	// They're written to disk one by one, a failure leaves the segments before it saved on disk but not in cache.
+	SaveSegments(ctx context.Context, segments ...*model.Segment) error
+	RemoveSegment(ctx context.Context, segment *model.Segment) error
+
+	ListLoadInfos(ctx context.Context) ([]*model.LoadInfo, error)
+	SaveLoadInfo(ctx context.Context, info *model.LoadInfo) error
+	RemoveLoadInfo(ctx context.Context, collectionID int64) error
+
+	ListIndexes(ctx context.Context, collectionID int64) ([]*model.Index, error)
+	SaveIndex(ctx context.Context, index *model.Index) error
+	RemoveIndex(ctx context.Context, index *model.Index) error
+
+	ListSegmentIndexes(ctx context.Context, collectionID int64) ([]*model.SegmentIndex, error)
+	SaveSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) error
+	RemoveSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) error
+
+	// GetCredential returns the credential of user, the password is encrypted by bcrypt
+	GetCredential(ctx context.Context, username string) (*internalpb.CredentialInfo, error)
+	// SaveCredential adds the credential or replaces the credential of the same user
+	SaveCredential(ctx context.Context, credential *internalpb.CredentialInfo) error
+	RemoveCredential(ctx context.Context, username string) error
+	ListCredentialUsernames(ctx context.Context) ([]string, error)
+
+	// ListRoles returns the roles in order of names
+	ListRoles(ctx context.Context) ([]*model.Role, error)
+	SaveRole(ctx context.Context, role *model.Role) error
+	// RemoveRole removes the role along with its user bindings & grants
+	RemoveRole(ctx context.Context, roleName string) error
+	// ListUserRoles returns the bindings of users & roles in order
+	ListUserRoles(ctx context.Context) ([]*model.UserRole, error)
+	SaveUserRole(ctx context.Context, userRole *model.UserRole) error
+	RemoveUserRole(ctx context.Context, userRole *model.UserRole) error
+	// ListGrants returns the grants of role, grants of all roles if roleName is empty
+	ListGrants(ctx context.Context, roleName string) ([]*model.Grant, error)
+	// SaveGrant adds the grant or replaces the grant of the same role, object & privilege
+	SaveGrant(ctx context.Context, grant *model.Grant) error
+	RemoveGrant(ctx context.Context, grant *model.Grant) error
 }
 
 // LocalDiskWithMemoryCacheMeta implements MetaTable by storing metadata in local disk with cache in memory
@@ -30,6 +82,18 @@ type LocalDiskWithMemoryCacheMeta struct {
 	lock                    sync.RWMutex
 	dbIndexedByName         map[string]*model.Database
 	collectionIndexedByName map[string]*model.Collection
+	collectionIndexedByID   map[int64]*model.Collection
+	segmentsByCollection    map[int64]map[int64]*model.Segment
+	loadInfos               map[int64]*model.LoadInfo
+	// indexes & segment indexes of each collection, indexed by index id & build id
+	indexes        map[int64]map[int64]*model.Index
+	segmentIndexes map[int64]map[int64]*model.SegmentIndex
+	// credentials are indexed by username
+	credentials map[string]*internalpb.CredentialInfo
+	// roles are indexed by name, grants are indexed by their keys
+	roles     map[string]*model.Role
+	userRoles map[model.UserRole]struct{}
+	grants    map[string]*model.Grant
 
 	diskMeta *DiskMeta
 }
@@ -42,6 +106,15 @@ func NewLocalDiskWithMemoryCacheMeta(ctx context.Context, rootPath string) (*Loc
 	ret := &LocalDiskWithMemoryCacheMeta{
 		dbIndexedByName:         make(map[string]*model.Database),
 		collectionIndexedByName: make(map[string]*model.Collection),
+		collectionIndexedByID:   make(map[int64]*model.Collection),
+		segmentsByCollection:    make(map[int64]map[int64]*model.Segment),
+		loadInfos:               make(map[int64]*model.LoadInfo),
+		indexes:                 make(map[int64]map[int64]*model.Index),
+		segmentIndexes:          make(map[int64]map[int64]*model.SegmentIndex),
+		credentials:             make(map[string]*internalpb.CredentialInfo),
+		roles:                   make(map[string]*model.Role),
+		userRoles:               make(map[model.UserRole]struct{}),
+		grants:                  make(map[string]*model.Grant),
 		diskMeta:                diskMeta,
 	}
 	err = ret.Init(ctx)
@@ -51,7 +124,7 @@ func NewLocalDiskWithMemoryCacheMeta(ctx context.Context, rootPath string) (*Loc
 	return ret, nil
 }
 
-// Init	load all meta from disk create default database if not exists
+// Init	load all meta from disk create default database, root user & built-in roles if not exists
 func (m *LocalDiskWithMemoryCacheMeta) Init(ctx context.Context) error {
 	dbs, err := m.diskMeta.GetAllDatabeses(ctx)
 	if err != nil {
@@ -73,6 +146,107 @@ func (m *LocalDiskWithMemoryCacheMeta) Init(ctx context.Context) error {
 	}
 	for _, coll := range colls {
 		m.collectionIndexedByName[coll.Name] = coll
+		m.collectionIndexedByID[coll.CollectionID] = coll
+	}
+	segments, err := m.diskMeta.GetAllSegments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		m.cacheSegment(segment)
+	}
+	loadInfos, err := m.diskMeta.GetAllLoadInfos(ctx)
+	if err != nil {
+		return err
+	}
+	for _, info := range loadInfos {
+		m.loadInfos[info.CollectionID] = info
+	}
+	indexes, err := m.diskMeta.GetAllIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		m.cacheIndex(index)
+	}
+	segmentIndexes, err := m.diskMeta.GetAllSegmentIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, segmentIndex := range segmentIndexes {
+		m.cacheSegmentIndex(segmentIndex)
+	}
+	credentials, err := m.diskMeta.GetAllCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	for _, credential := range credentials {
+		m.credentials[credential.Username] = credential
+	}
+	if _, found := m.credentials[util.UserRoot]; !found {
+		encrypted, err := crypto.PasswordEncrypt(util.DefaultRootPassword)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt password of root")
+		}
+		root := &internalpb.CredentialInfo{Username: util.UserRoot, EncryptedPassword: encrypted, IsSuper: true}
+		if err := m.diskMeta.SaveCredential(ctx, root); err != nil {
+			return err
+		}
+		m.credentials[util.UserRoot] = root
+	}
+	return m.initRbac(ctx)
+}
+
+// publicGrants are the privileges granted to public role when it's created, same as milvus
+var publicGrants = []*model.Grant{
+	{ObjectType: commonpb.ObjectType_Global.String(), Privilege: commonpb.ObjectPrivilege_PrivilegeDescribeCollection.String()},
+	{ObjectType: commonpb.ObjectType_Global.String(), Privilege: commonpb.ObjectPrivilege_PrivilegeShowCollections.String()},
+	{ObjectType: commonpb.ObjectType_Collection.String(), Privilege: commonpb.ObjectPrivilege_PrivilegeIndexDetail.String()},
+}
+
+// initRbac loads roles, user bindings & grants, the built-in roles are created if not exists
+func (m *LocalDiskWithMemoryCacheMeta) initRbac(ctx context.Context) error {
+	roles, err := m.diskMeta.GetAllRoles(ctx)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		m.roles[role.Name] = role
+	}
+	userRoles, err := m.diskMeta.GetAllUserRoles(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userRole := range userRoles {
+		m.userRoles[*userRole] = struct{}{}
+	}
+	grants, err := m.diskMeta.GetAllGrants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		m.grants[buildGrantKey(grant)] = grant
+	}
+	for _, roleName := range util.DefaultRoles {
+		if _, found := m.roles[roleName]; found {
+			continue
+		}
+		role := &model.Role{Name: roleName}
+		if err := m.diskMeta.SaveRole(ctx, role); err != nil {
+			return err
+		}
+		m.roles[roleName] = role
+		if roleName != util.RolePublic {
+			continue
+		}
+		for _, grant := range publicGrants {
+			grant = grant.Clone()
+			grant.RoleName, grant.ObjectName, grant.DbName, grant.Grantor = util.RolePublic, util.AnyWord, util.AnyWord, util.UserRoot
+			if err := m.diskMeta.SaveGrant(ctx, grant); err != nil {
+				return err
+			}
+			m.grants[buildGrantKey(grant)] = grant
+		}
 	}
 	return nil
 }
@@ -112,9 +286,430 @@ func (m *LocalDiskWithMemoryCacheMeta) AddCollection(ctx context.Context, newCol
 		return err
 	}
 	m.collectionIndexedByName[newColl.Name] = newColl
+	m.collectionIndexedByID[newColl.CollectionID] = newColl
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) AlterCollection(ctx context.Context, newColl *model.Collection) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.alterCollectionLocked(ctx, newColl)
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) AlterCollectionProperties(ctx context.Context, collectionID int64,
+	properties []*commonpb.KeyValuePair) (*model.Collection, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	collection, found := m.collectionIndexedByID[collectionID]
+	if !found {
+		return nil, merr.WrapErrCollectionNotFound(collectionID)
+	}
+	newColl := collection.Clone()
+	for _, kv := range properties {
+		updated := false
+		for _, property := range newColl.Properties {
+			if property.GetKey() == kv.GetKey() {
+				property.Value = kv.GetValue()
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			newColl.Properties = append(newColl.Properties, &commonpb.KeyValuePair{Key: kv.GetKey(), Value: kv.GetValue()})
+		}
+	}
+	if err := m.alterCollectionLocked(ctx, newColl); err != nil {
+		return nil, err
+	}
+	return newColl, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) alterCollectionLocked(ctx context.Context, newColl *model.Collection) error {
+	collection, found := m.collectionIndexedByID[newColl.CollectionID]
+	if !found {
+		return merr.WrapErrCollectionNotFound(newColl.CollectionID)
+	}
+	if collection.Name != newColl.Name {
+		return merr.WrapErrParameterInvalidMsg("rename collection %s is not supported by alter", collection.Name)
+	}
+	err := m.diskMeta.AddCollection(ctx, newColl)
+	if err != nil {
+		return err
+	}
+	m.collectionIndexedByName[newColl.Name] = newColl
+	m.collectionIndexedByID[newColl.CollectionID] = newColl
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) GetCollectionByID(ctx context.Context, collectionID int64) (*model.Collection, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret, found := m.collectionIndexedByID[collectionID]
+	if !found {
+		return nil, merr.WrapErrCollectionNotFound(collectionID)
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListCollections(ctx context.Context) ([]*model.Collection, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret := make([]*model.Collection, 0, len(m.collectionIndexedByID))
+	for _, coll := range m.collectionIndexedByID {
+		ret = append(ret, coll)
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListSegments(ctx context.Context, collectionID int64) ([]*model.Segment, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	segments := m.segmentsByCollection[collectionID]
+	ret := make([]*model.Segment, 0, len(segments))
+	for _, segment := range segments {
+		ret = append(ret, segment.Clone())
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveSegments(ctx context.Context, segments ...*model.Segment) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, segment := range segments {
+		err := m.diskMeta.SaveSegment(ctx, segment)
+		if err != nil {
+			return err
+		}
+	}
+	for _, segment := range segments {
+		m.cacheSegment(segment.Clone())
+	}
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveSegment(ctx context.Context, segment *model.Segment) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.RemoveSegment(ctx, segment)
+	if err != nil {
+		return err
+	}
+	delete(m.segmentsByCollection[segment.CollectionID], segment.SegmentID)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) cacheSegment(segment *model.Segment) {
+	segments, ok := m.segmentsByCollection[segment.CollectionID]
+	if !ok {
+		segments = make(map[int64]*model.Segment)
+		m.segmentsByCollection[segment.CollectionID] = segments
+	}
+	segments[segment.SegmentID] = segment
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListLoadInfos(ctx context.Context) ([]*model.LoadInfo, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret := make([]*model.LoadInfo, 0, len(m.loadInfos))
+	for _, info := range m.loadInfos {
+		ret = append(ret, info.Clone())
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveLoadInfo(ctx context.Context, info *model.LoadInfo) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveLoadInfo(ctx, info)
+	if err != nil {
+		return err
+	}
+	m.loadInfos[info.CollectionID] = info.Clone()
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveLoadInfo(ctx context.Context, collectionID int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.RemoveObject(ctx, BuildLoadInfoKey(collectionID))
+	if err != nil {
+		return err
+	}
+	delete(m.loadInfos, collectionID)
 	return nil
 }
 
+func (m *LocalDiskWithMemoryCacheMeta) ListIndexes(ctx context.Context, collectionID int64) ([]*model.Index, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	indexes := m.indexes[collectionID]
+	ret := make([]*model.Index, 0, len(indexes))
+	for _, index := range indexes {
+		ret = append(ret, index.Clone())
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveIndex(ctx context.Context, index *model.Index) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	m.cacheIndex(index.Clone())
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveIndex(ctx context.Context, index *model.Index) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.RemoveObject(ctx, BuildIndexKey(index.CollectionID, index.IndexID))
+	if err != nil {
+		return err
+	}
+	delete(m.indexes[index.CollectionID], index.IndexID)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) cacheIndex(index *model.Index) {
+	indexes, ok := m.indexes[index.CollectionID]
+	if !ok {
+		indexes = make(map[int64]*model.Index)
+		m.indexes[index.CollectionID] = indexes
+	}
+	indexes[index.IndexID] = index
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListSegmentIndexes(ctx context.Context, collectionID int64) ([]*model.SegmentIndex, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	segmentIndexes := m.segmentIndexes[collectionID]
+	ret := make([]*model.SegmentIndex, 0, len(segmentIndexes))
+	for _, segmentIndex := range segmentIndexes {
+		ret = append(ret, segmentIndex.Clone())
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveSegmentIndex(ctx, segmentIndex)
+	if err != nil {
+		return err
+	}
+	m.cacheSegmentIndex(segmentIndex.Clone())
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := BuildSegmentIndexKey(segmentIndex.CollectionID, segmentIndex.PartitionID, segmentIndex.SegmentID, segmentIndex.BuildID)
+	err := m.diskMeta.RemoveObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	delete(m.segmentIndexes[segmentIndex.CollectionID], segmentIndex.BuildID)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) cacheSegmentIndex(segmentIndex *model.SegmentIndex) {
+	segmentIndexes, ok := m.segmentIndexes[segmentIndex.CollectionID]
+	if !ok {
+		segmentIndexes = make(map[int64]*model.SegmentIndex)
+		m.segmentIndexes[segmentIndex.CollectionID] = segmentIndexes
+	}
+	segmentIndexes[segmentIndex.BuildID] = segmentIndex
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) GetCredential(ctx context.Context, username string) (*internalpb.CredentialInfo, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	credential, found := m.credentials[username]
+	if !found {
+		return nil, merr.WrapErrParameterInvalidMsg("user %s not found", username)
+	}
+	return proto.Clone(credential).(*internalpb.CredentialInfo), nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveCredential(ctx context.Context, credential *internalpb.CredentialInfo) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveCredential(ctx, credential)
+	if err != nil {
+		return err
+	}
+	m.credentials[credential.Username] = proto.Clone(credential).(*internalpb.CredentialInfo)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveCredential(ctx context.Context, username string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.RemoveObject(ctx, BuildCredentialKey(username))
+	if err != nil {
+		return err
+	}
+	for userRole := range m.userRoles {
+		if userRole.Username != username {
+			continue
+		}
+		if err := m.diskMeta.RemoveObject(ctx, BuildRoleMappingKey(userRole.Username, userRole.RoleName)); err != nil {
+			return err
+		}
+		delete(m.userRoles, userRole)
+	}
+	delete(m.credentials, username)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListCredentialUsernames(ctx context.Context) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret := make([]string, 0, len(m.credentials))
+	for username := range m.credentials {
+		ret = append(ret, username)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListRoles(ctx context.Context) ([]*model.Role, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret := make([]*model.Role, 0, len(m.roles))
+	for _, role := range m.roles {
+		ret = append(ret, &model.Role{Name: role.Name})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveRole(ctx context.Context, role *model.Role) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveRole(ctx, role)
+	if err != nil {
+		return err
+	}
+	m.roles[role.Name] = &model.Role{Name: role.Name}
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveRole(ctx context.Context, roleName string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for userRole := range m.userRoles {
+		if userRole.RoleName != roleName {
+			continue
+		}
+		if err := m.diskMeta.RemoveObject(ctx, BuildRoleMappingKey(userRole.Username, userRole.RoleName)); err != nil {
+			return err
+		}
+		delete(m.userRoles, userRole)
+	}
+	for key, grant := range m.grants {
+		if grant.RoleName != roleName {
+			continue
+		}
+		if err := m.diskMeta.RemoveObject(ctx, key); err != nil {
+			return err
+		}
+		delete(m.grants, key)
+	}
+	err := m.diskMeta.RemoveObject(ctx, BuildRoleKey(roleName))
+	if err != nil {
+		return err
+	}
+	delete(m.roles, roleName)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListUserRoles(ctx context.Context) ([]*model.UserRole, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret := make([]*model.UserRole, 0, len(m.userRoles))
+	for userRole := range m.userRoles {
+		userRole := userRole
+		ret = append(ret, &userRole)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Username != ret[j].Username {
+			return ret[i].Username < ret[j].Username
+		}
+		return ret[i].RoleName < ret[j].RoleName
+	})
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveUserRole(ctx context.Context, userRole *model.UserRole) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveUserRole(ctx, userRole)
+	if err != nil {
+		return err
+	}
+	m.userRoles[*userRole] = struct{}{}
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveUserRole(ctx context.Context, userRole *model.UserRole) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.RemoveObject(ctx, BuildRoleMappingKey(userRole.Username, userRole.RoleName))
+	if err != nil {
+		return err
+	}
+	delete(m.userRoles, *userRole)
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) ListGrants(ctx context.Context, roleName string) ([]*model.Grant, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	keys := make([]string, 0)
+	for key, grant := range m.grants {
+		if roleName == "" || grant.RoleName == roleName {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	ret := make([]*model.Grant, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, m.grants[key].Clone())
+	}
+	return ret, nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) SaveGrant(ctx context.Context, grant *model.Grant) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.diskMeta.SaveGrant(ctx, grant)
+	if err != nil {
+		return err
+	}
+	m.grants[buildGrantKey(grant)] = grant.Clone()
+	return nil
+}
+
+func (m *LocalDiskWithMemoryCacheMeta) RemoveGrant(ctx context.Context, grant *model.Grant) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := buildGrantKey(grant)
+	err := m.diskMeta.RemoveObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	delete(m.grants, key)
+	return nil
+}
+
+func buildGrantKey(grant *model.Grant) string {
+	return BuildGranteeKey(grant.RoleName, grant.ObjectType, grant.DbName, grant.ObjectName, grant.Privilege)
+}
+
 type DiskMeta struct {
 	rootPath string
 }
@@ -144,39 +739,171 @@ func (m *DiskMeta) Init() error {
 }
 
 func (m *DiskMeta) GetAllDatabeses(ctx context.Context) ([]*model.Database, error) {
-	files, err := ioutil.ReadDir(fmt.Sprintf("%s/%s", m.rootPath, DBInfoMetaPrefix))
+	keys, err := m.ListKeys(ctx, DBInfoMetaPrefix)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to list databases in disk")
 	}
-	ret := make([]*model.Database, 0, len(files))
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
+	ret := make([]*model.Database, 0, len(keys))
+	for _, key := range keys {
 		obj := new(model.Database)
-		err = m.GetObject(ctx, fmt.Sprintf("%s/%s", DBInfoMetaPrefix, file.Name()), obj)
+		err = m.GetObject(ctx, key, obj)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get database[%s]", file.Name())
+			return nil, errors.Wrapf(err, "failed to get database[%s]", key)
 		}
+		ret = append(ret, obj)
 	}
 	return ret, nil
 }
 
 func (m *DiskMeta) GetAllCollections(ctx context.Context) ([]*model.Collection, error) {
-	files, err := ioutil.ReadDir(fmt.Sprintf("%s/%s", m.rootPath, CollectionInfoMetaPrefix))
+	keys, err := m.ListKeys(ctx, CollectionInfoMetaPrefix)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list collections in disk")
 	}
-	ret := make([]*model.Collection, 0, len(files))
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
+	ret := make([]*model.Collection, 0, len(keys))
+	for _, key := range keys {
 		obj := new(model.Collection)
-		err = m.GetObject(ctx, fmt.Sprintf("%s/%s", CollectionInfoMetaPrefix, file.Name()), obj)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get collection[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllSegments(ctx context.Context) ([]*model.Segment, error) {
+	keys, err := m.ListKeys(ctx, SegmentPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list segments in disk")
+	}
+	ret := make([]*model.Segment, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.Segment)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get segment[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllLoadInfos(ctx context.Context) ([]*model.LoadInfo, error) {
+	keys, err := m.ListKeys(ctx, CollectionLoadInfoPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list load infos in disk")
+	}
+	ret := make([]*model.LoadInfo, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.LoadInfo)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get load info[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllIndexes(ctx context.Context) ([]*model.Index, error) {
+	keys, err := m.ListKeys(ctx, FieldIndexPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list indexes in disk")
+	}
+	ret := make([]*model.Index, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.Index)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get index[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllSegmentIndexes(ctx context.Context) ([]*model.SegmentIndex, error) {
+	keys, err := m.ListKeys(ctx, SegmentIndexPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list segment indexes in disk")
+	}
+	ret := make([]*model.SegmentIndex, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.SegmentIndex)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get segment index[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllCredentials(ctx context.Context) ([]*internalpb.CredentialInfo, error) {
+	keys, err := m.ListKeys(ctx, CredentialPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list credentials in disk")
+	}
+	ret := make([]*internalpb.CredentialInfo, 0, len(keys))
+	for _, key := range keys {
+		obj := new(internalpb.CredentialInfo)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get credential[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllRoles(ctx context.Context) ([]*model.Role, error) {
+	keys, err := m.ListKeys(ctx, RolePrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list roles in disk")
+	}
+	ret := make([]*model.Role, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.Role)
+		err = m.GetObject(ctx, key, obj)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get collection[%s]", file.Name())
+			return nil, errors.Wrapf(err, "failed to get role[%s]", key)
 		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllUserRoles(ctx context.Context) ([]*model.UserRole, error) {
+	keys, err := m.ListKeys(ctx, RoleMappingPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list user roles in disk")
+	}
+	ret := make([]*model.UserRole, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.UserRole)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get user role[%s]", key)
+		}
+		ret = append(ret, obj)
+	}
+	return ret, nil
+}
+
+func (m *DiskMeta) GetAllGrants(ctx context.Context) ([]*model.Grant, error) {
+	keys, err := m.ListKeys(ctx, GranteePrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list grants in disk")
+	}
+	ret := make([]*model.Grant, 0, len(keys))
+	for _, key := range keys {
+		obj := new(model.Grant)
+		err = m.GetObject(ctx, key, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get grant[%s]", key)
+		}
+		ret = append(ret, obj)
 	}
 	return ret, nil
 }
@@ -192,6 +919,84 @@ func (m *DiskMeta) AddCollection(ctx context.Context, newColl *model.Collection)
 	return errors.Wrapf(err, "failed to add key[%s]", key)
 }
 
+func (m *DiskMeta) SaveSegment(ctx context.Context, segment *model.Segment) error {
+	key := BuildSegmentKey(segment.CollectionID, segment.PartitionID, segment.SegmentID)
+	err := m.AddObject(ctx, key, segment)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) RemoveSegment(ctx context.Context, segment *model.Segment) error {
+	key := BuildSegmentKey(segment.CollectionID, segment.PartitionID, segment.SegmentID)
+	err := m.RemoveObject(ctx, key)
+	return errors.Wrapf(err, "failed to remove key[%s]", key)
+}
+
+func (m *DiskMeta) SaveLoadInfo(ctx context.Context, info *model.LoadInfo) error {
+	key := BuildLoadInfoKey(info.CollectionID)
+	err := m.AddObject(ctx, key, info)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) SaveIndex(ctx context.Context, index *model.Index) error {
+	key := BuildIndexKey(index.CollectionID, index.IndexID)
+	err := m.AddObject(ctx, key, index)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) SaveSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) error {
+	key := BuildSegmentIndexKey(segmentIndex.CollectionID, segmentIndex.PartitionID, segmentIndex.SegmentID, segmentIndex.BuildID)
+	err := m.AddObject(ctx, key, segmentIndex)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) SaveCredential(ctx context.Context, credential *internalpb.CredentialInfo) error {
+	key := BuildCredentialKey(credential.Username)
+	err := m.AddObject(ctx, key, credential)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) SaveRole(ctx context.Context, role *model.Role) error {
+	key := BuildRoleKey(role.Name)
+	err := m.AddObject(ctx, key, role)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) SaveUserRole(ctx context.Context, userRole *model.UserRole) error {
+	key := BuildRoleMappingKey(userRole.Username, userRole.RoleName)
+	err := m.AddObject(ctx, key, userRole)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+func (m *DiskMeta) SaveGrant(ctx context.Context, grant *model.Grant) error {
+	key := buildGrantKey(grant)
+	err := m.AddObject(ctx, key, grant)
+	return errors.Wrapf(err, "failed to save key[%s]", key)
+}
+
+// ListKeys lists all object keys under prefix recursively
+func (m *DiskMeta) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	root := fmt.Sprintf("%s/%s", m.rootPath, prefix)
+	ret := make([]string, 0)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		key, err := filepath.Rel(m.rootPath, path)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, key)
+		return nil
+	})
+	return ret, err
+}
+
 func (m *DiskMeta) GetObject(ctx context.Context, key string, obj any) error {
 	fileName := fmt.Sprintf("%s/%s", m.rootPath, key)
 	file, err := os.Open(fileName)
@@ -230,3 +1035,12 @@ func (m *DiskMeta) AddObject(ctx context.Context, key string, obj any) error {
 	err = file.Sync()
 	return err
 }
+
+func (m *DiskMeta) RemoveObject(ctx context.Context, key string) error {
+	fileName := fmt.Sprintf("%s/%s", m.rootPath, key)
+	err := os.Remove(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
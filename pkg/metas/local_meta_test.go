@@ -2,9 +2,13 @@ package metas
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	"sync"
 	"testing"
 
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/sharding-db/milvus-mini/pkg/model"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,3 +18,39 @@ func TestLocalDiskWithMemoryCacheMeta(t *testing.T) {
 	_, err = NewLocalDiskWithMemoryCacheMeta(context.Background(), rootPath)
 	assert.NoError(t, err)
 }
+
+func TestAlterCollectionProperties(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	meta, err := NewLocalDiskWithMemoryCacheMeta(ctx, rootPath)
+	assert.NoError(t, err)
+	assert.NoError(t, meta.AddCollection(ctx, &model.Collection{CollectionID: 1, Name: "test",
+		Properties: []*commonpb.KeyValuePair{{Key: "a", Value: "0"}}}))
+
+	// concurrent alters of different properties are all kept
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := meta.AlterCollectionProperties(ctx, 1, []*commonpb.KeyValuePair{{Key: fmt.Sprintf("key%d", i), Value: "1"}})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	altered, err := meta.AlterCollectionProperties(ctx, 1, []*commonpb.KeyValuePair{{Key: "a", Value: "1"}})
+	assert.NoError(t, err)
+	assert.Len(t, altered.Properties, 11)
+
+	// properties are persisted
+	meta, err = NewLocalDiskWithMemoryCacheMeta(ctx, rootPath)
+	assert.NoError(t, err)
+	collection, err := meta.GetCollectionByID(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, collection.Properties, 11)
+	assert.Equal(t, "1", collection.Properties[0].GetValue())
+
+	_, err = meta.AlterCollectionProperties(ctx, 2, []*commonpb.KeyValuePair{{Key: "a", Value: "1"}})
+	assert.Error(t, err)
+}
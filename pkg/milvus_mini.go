@@ -10,18 +10,31 @@ import (
 	"github.com/milvus-io/milvus/pkg/util/merr"
 	"github.com/pkg/errors"
 	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/compaction"
 	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
 )
 
 type MilvusMini struct {
-	idAllocator allocator.Interface
-	meta        metas.MetaTable
+	idAllocator    allocator.Interface
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+	compactor      *compaction.Compactor
 }
 
-func NewMilvusMini(idAllocator allocator.Interface, meta metas.MetaTable) *MilvusMini {
+func NewMilvusMini(
+	idAllocator allocator.Interface,
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	compactor *compaction.Compactor) *MilvusMini {
 	return &MilvusMini{
-		idAllocator: idAllocator,
-		meta:        meta,
+		idAllocator:    idAllocator,
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		compactor:      compactor,
 	}
 }
 
@@ -43,11 +56,13 @@ func (m *MilvusMini) HasCollection(ctx context.Context, req *milvuspb.HasCollect
 	}
 	return &milvuspb.BoolResponse{Value: true}, nil
 }
-func (m *MilvusMini) LoadCollection(context.Context, *milvuspb.LoadCollectionRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) LoadCollection(ctx context.Context, req *milvuspb.LoadCollectionRequest) (*commonpb.Status, error) {
+	err := NewLoadCollectionTask(m.meta, m.segmentManager, req).Execute(ctx)
+	return merr.Status(err), nil
 }
-func (m *MilvusMini) ReleaseCollection(context.Context, *milvuspb.ReleaseCollectionRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) ReleaseCollection(ctx context.Context, req *milvuspb.ReleaseCollectionRequest) (*commonpb.Status, error) {
+	err := NewReleaseCollectionTask(m.meta, m.segmentManager, req).Execute(ctx)
+	return merr.Status(err), nil
 }
 func (m *MilvusMini) DescribeCollection(ctx context.Context, req *milvuspb.DescribeCollectionRequest) (*milvuspb.DescribeCollectionResponse, error) {
 	_, err := m.meta.GetCollectionByName(ctx, req.DbName, req.CollectionName)
@@ -65,8 +80,9 @@ func (m *MilvusMini) GetCollectionStatistics(context.Context, *milvuspb.GetColle
 func (m *MilvusMini) ShowCollections(context.Context, *milvuspb.ShowCollectionsRequest) (*milvuspb.ShowCollectionsResponse, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) AlterCollection(context.Context, *milvuspb.AlterCollectionRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) AlterCollection(ctx context.Context, req *milvuspb.AlterCollectionRequest) (*commonpb.Status, error) {
+	err := NewAlterCollectionTask(m.meta, req).Execute(ctx)
+	return merr.Status(err), nil
 }
 func (m *MilvusMini) CreatePartition(context.Context, *milvuspb.CreatePartitionRequest) (*commonpb.Status, error) {
 	return nil, errors.Errorf("TODO")
@@ -77,11 +93,13 @@ func (m *MilvusMini) DropPartition(context.Context, *milvuspb.DropPartitionReque
 func (m *MilvusMini) HasPartition(context.Context, *milvuspb.HasPartitionRequest) (*milvuspb.BoolResponse, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) LoadPartitions(context.Context, *milvuspb.LoadPartitionsRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) LoadPartitions(ctx context.Context, req *milvuspb.LoadPartitionsRequest) (*commonpb.Status, error) {
+	err := NewLoadPartitionsTask(m.meta, m.segmentManager, req).Execute(ctx)
+	return merr.Status(err), nil
 }
-func (m *MilvusMini) ReleasePartitions(context.Context, *milvuspb.ReleasePartitionsRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) ReleasePartitions(ctx context.Context, req *milvuspb.ReleasePartitionsRequest) (*commonpb.Status, error) {
+	err := NewReleasePartitionsTask(m.meta, m.segmentManager, req).Execute(ctx)
+	return merr.Status(err), nil
 }
 func (m *MilvusMini) GetPartitionStatistics(context.Context, *milvuspb.GetPartitionStatisticsRequest) (*milvuspb.GetPartitionStatisticsResponse, error) {
 	return nil, errors.Errorf("TODO")
@@ -89,11 +107,19 @@ func (m *MilvusMini) GetPartitionStatistics(context.Context, *milvuspb.GetPartit
 func (m *MilvusMini) ShowPartitions(context.Context, *milvuspb.ShowPartitionsRequest) (*milvuspb.ShowPartitionsResponse, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) GetLoadingProgress(context.Context, *milvuspb.GetLoadingProgressRequest) (*milvuspb.GetLoadingProgressResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetLoadingProgress(ctx context.Context, req *milvuspb.GetLoadingProgressRequest) (*milvuspb.GetLoadingProgressResponse, error) {
+	resp, err := GetLoadingProgress(ctx, m.meta, m.segmentManager, req)
+	if err != nil {
+		return &milvuspb.GetLoadingProgressResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) GetLoadState(context.Context, *milvuspb.GetLoadStateRequest) (*milvuspb.GetLoadStateResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetLoadState(ctx context.Context, req *milvuspb.GetLoadStateRequest) (*milvuspb.GetLoadStateResponse, error) {
+	resp, err := GetLoadState(ctx, m.meta, m.segmentManager, req)
+	if err != nil {
+		return &milvuspb.GetLoadStateResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 func (m *MilvusMini) CreateAlias(context.Context, *milvuspb.CreateAliasRequest) (*commonpb.Status, error) {
 	return nil, errors.Errorf("TODO")
@@ -110,54 +136,112 @@ func (m *MilvusMini) DescribeAlias(context.Context, *milvuspb.DescribeAliasReque
 func (m *MilvusMini) ListAliases(context.Context, *milvuspb.ListAliasesRequest) (*milvuspb.ListAliasesResponse, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) CreateIndex(context.Context, *milvuspb.CreateIndexRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) CreateIndex(ctx context.Context, req *milvuspb.CreateIndexRequest) (*commonpb.Status, error) {
+	err := NewCreateIndexTask(m.idAllocator, m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	return merr.Status(err), nil
 }
-func (m *MilvusMini) DescribeIndex(context.Context, *milvuspb.DescribeIndexRequest) (*milvuspb.DescribeIndexResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) DescribeIndex(ctx context.Context, req *milvuspb.DescribeIndexRequest) (*milvuspb.DescribeIndexResponse, error) {
+	resp, err := DescribeIndex(ctx, m.meta, m.segmentManager, req)
+	if err != nil {
+		return &milvuspb.DescribeIndexResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) GetIndexStatistics(context.Context, *milvuspb.GetIndexStatisticsRequest) (*milvuspb.GetIndexStatisticsResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetIndexStatistics(ctx context.Context, req *milvuspb.GetIndexStatisticsRequest) (*milvuspb.GetIndexStatisticsResponse, error) {
+	resp, err := GetIndexStatistics(ctx, m.meta, m.segmentManager, req)
+	if err != nil {
+		return &milvuspb.GetIndexStatisticsResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 
 // Deprecated: use DescribeIndex instead
-func (m *MilvusMini) GetIndexState(context.Context, *milvuspb.GetIndexStateRequest) (*milvuspb.GetIndexStateResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetIndexState(ctx context.Context, req *milvuspb.GetIndexStateRequest) (*milvuspb.GetIndexStateResponse, error) {
+	resp, err := GetIndexState(ctx, m.meta, m.segmentManager, req)
+	if err != nil {
+		return &milvuspb.GetIndexStateResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 
 // Deprecated: use DescribeIndex instead
-func (m *MilvusMini) GetIndexBuildProgress(context.Context, *milvuspb.GetIndexBuildProgressRequest) (*milvuspb.GetIndexBuildProgressResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetIndexBuildProgress(ctx context.Context, req *milvuspb.GetIndexBuildProgressRequest) (*milvuspb.GetIndexBuildProgressResponse, error) {
+	resp, err := GetIndexBuildProgress(ctx, m.meta, m.segmentManager, req)
+	if err != nil {
+		return &milvuspb.GetIndexBuildProgressResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) DropIndex(context.Context, *milvuspb.DropIndexRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) DropIndex(ctx context.Context, req *milvuspb.DropIndexRequest) (*commonpb.Status, error) {
+	err := NewDropIndexTask(m.meta, m.segmentManager, req).Execute(ctx)
+	return merr.Status(err), nil
 }
-func (m *MilvusMini) Insert(context.Context, *milvuspb.InsertRequest) (*milvuspb.MutationResult, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) Insert(ctx context.Context, req *milvuspb.InsertRequest) (*milvuspb.MutationResult, error) {
+	resp, err := NewInsertTask(m.idAllocator, m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.MutationResult{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) Delete(context.Context, *milvuspb.DeleteRequest) (*milvuspb.MutationResult, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) Delete(ctx context.Context, req *milvuspb.DeleteRequest) (*milvuspb.MutationResult, error) {
+	resp, err := NewDeleteTask(m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.MutationResult{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) Upsert(context.Context, *milvuspb.UpsertRequest) (*milvuspb.MutationResult, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) Upsert(ctx context.Context, req *milvuspb.UpsertRequest) (*milvuspb.MutationResult, error) {
+	resp, err := NewUpsertTask(m.idAllocator, m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.MutationResult{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) Search(context.Context, *milvuspb.SearchRequest) (*milvuspb.SearchResults, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) Search(ctx context.Context, req *milvuspb.SearchRequest) (*milvuspb.SearchResults, error) {
+	resp, err := NewSearchTask(m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.SearchResults{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) Flush(context.Context, *milvuspb.FlushRequest) (*milvuspb.FlushResponse, error) {
-	return nil, errors.Errorf("TODO")
+
+// HybridSearch runs several searches on the vector fields of one collection and fuses their hits by the ranker.
+// It's a Go API only: HybridSearch is not in the milvus service of this version of proto, so it's not served
+// over gRPC and clients can't call it. The interceptors of authentication and privileges don't apply to it either,
+// callers embedding MilvusMini must check them on their own.
+func (m *MilvusMini) HybridSearch(ctx context.Context, req *HybridSearchRequest) (*milvuspb.SearchResults, error) {
+	resp, err := NewHybridSearchTask(m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.SearchResults{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) Query(context.Context, *milvuspb.QueryRequest) (*milvuspb.QueryResults, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) Flush(ctx context.Context, req *milvuspb.FlushRequest) (*milvuspb.FlushResponse, error) {
+	resp, err := NewFlushTask(m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.FlushResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) CalcDistance(context.Context, *milvuspb.CalcDistanceRequest) (*milvuspb.CalcDistanceResults, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) Query(ctx context.Context, req *milvuspb.QueryRequest) (*milvuspb.QueryResults, error) {
+	resp, err := NewQueryTask(m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.QueryResults{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
+}
+func (m *MilvusMini) CalcDistance(ctx context.Context, req *milvuspb.CalcDistanceRequest) (*milvuspb.CalcDistanceResults, error) {
+	resp, err := NewCalcDistanceTask(m.tsAllocator, m.meta, m.segmentManager, req).Execute(ctx)
+	if err != nil {
+		return &milvuspb.CalcDistanceResults{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 func (m *MilvusMini) FlushAll(context.Context, *milvuspb.FlushAllRequest) (*milvuspb.FlushAllResponse, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) GetFlushState(context.Context, *milvuspb.GetFlushStateRequest) (*milvuspb.GetFlushStateResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetFlushState(ctx context.Context, req *milvuspb.GetFlushStateRequest) (*milvuspb.GetFlushStateResponse, error) {
+	return GetFlushState(m.segmentManager, req), nil
 }
 func (m *MilvusMini) GetFlushAllState(context.Context, *milvuspb.GetFlushAllStateRequest) (*milvuspb.GetFlushAllStateResponse, error) {
 	return nil, errors.Errorf("TODO")
@@ -190,14 +274,26 @@ func (m *MilvusMini) GetComponentStates(context.Context, *milvuspb.GetComponentS
 func (m *MilvusMini) LoadBalance(context.Context, *milvuspb.LoadBalanceRequest) (*commonpb.Status, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) GetCompactionState(context.Context, *milvuspb.GetCompactionStateRequest) (*milvuspb.GetCompactionStateResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetCompactionState(ctx context.Context, req *milvuspb.GetCompactionStateRequest) (*milvuspb.GetCompactionStateResponse, error) {
+	resp, err := GetCompactionState(m.compactor, req)
+	if err != nil {
+		return &milvuspb.GetCompactionStateResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) ManualCompaction(context.Context, *milvuspb.ManualCompactionRequest) (*milvuspb.ManualCompactionResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) ManualCompaction(ctx context.Context, req *milvuspb.ManualCompactionRequest) (*milvuspb.ManualCompactionResponse, error) {
+	resp, err := ManualCompaction(ctx, m.meta, m.compactor, req)
+	if err != nil {
+		return &milvuspb.ManualCompactionResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) GetCompactionStateWithPlans(context.Context, *milvuspb.GetCompactionPlansRequest) (*milvuspb.GetCompactionPlansResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) GetCompactionStateWithPlans(ctx context.Context, req *milvuspb.GetCompactionPlansRequest) (*milvuspb.GetCompactionPlansResponse, error) {
+	resp, err := GetCompactionStateWithPlans(m.compactor, req)
+	if err != nil {
+		return &milvuspb.GetCompactionPlansResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 
 // https://wiki.lfaidata.foundation/display/MIL/MEP+24+--+Support+bulk+load
@@ -212,40 +308,56 @@ func (m *MilvusMini) ListImportTasks(context.Context, *milvuspb.ListImportTasksR
 }
 
 // https://wiki.lfaidata.foundation/display/MIL/MEP+27+--+Support+Basic+Authentication
-func (m *MilvusMini) CreateCredential(context.Context, *milvuspb.CreateCredentialRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) CreateCredential(ctx context.Context, req *milvuspb.CreateCredentialRequest) (*commonpb.Status, error) {
+	return merr.Status(CreateCredential(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) UpdateCredential(context.Context, *milvuspb.UpdateCredentialRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) UpdateCredential(ctx context.Context, req *milvuspb.UpdateCredentialRequest) (*commonpb.Status, error) {
+	return merr.Status(UpdateCredential(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) DeleteCredential(context.Context, *milvuspb.DeleteCredentialRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) DeleteCredential(ctx context.Context, req *milvuspb.DeleteCredentialRequest) (*commonpb.Status, error) {
+	return merr.Status(DeleteCredential(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) ListCredUsers(context.Context, *milvuspb.ListCredUsersRequest) (*milvuspb.ListCredUsersResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) ListCredUsers(ctx context.Context, req *milvuspb.ListCredUsersRequest) (*milvuspb.ListCredUsersResponse, error) {
+	resp, err := ListCredUsers(ctx, m.meta)
+	if err != nil {
+		return &milvuspb.ListCredUsersResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 
 // https://wiki.lfaidata.foundation/display/MIL/MEP+29+--+Support+Role-Based+Access+Control
-func (m *MilvusMini) CreateRole(context.Context, *milvuspb.CreateRoleRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) CreateRole(ctx context.Context, req *milvuspb.CreateRoleRequest) (*commonpb.Status, error) {
+	return merr.Status(CreateRole(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) DropRole(context.Context, *milvuspb.DropRoleRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) DropRole(ctx context.Context, req *milvuspb.DropRoleRequest) (*commonpb.Status, error) {
+	return merr.Status(DropRole(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) OperateUserRole(context.Context, *milvuspb.OperateUserRoleRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) OperateUserRole(ctx context.Context, req *milvuspb.OperateUserRoleRequest) (*commonpb.Status, error) {
+	return merr.Status(OperateUserRole(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) SelectRole(context.Context, *milvuspb.SelectRoleRequest) (*milvuspb.SelectRoleResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) SelectRole(ctx context.Context, req *milvuspb.SelectRoleRequest) (*milvuspb.SelectRoleResponse, error) {
+	resp, err := SelectRole(ctx, m.meta, req)
+	if err != nil {
+		return &milvuspb.SelectRoleResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) SelectUser(context.Context, *milvuspb.SelectUserRequest) (*milvuspb.SelectUserResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) SelectUser(ctx context.Context, req *milvuspb.SelectUserRequest) (*milvuspb.SelectUserResponse, error) {
+	resp, err := SelectUser(ctx, m.meta, req)
+	if err != nil {
+		return &milvuspb.SelectUserResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) OperatePrivilege(context.Context, *milvuspb.OperatePrivilegeRequest) (*commonpb.Status, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) OperatePrivilege(ctx context.Context, req *milvuspb.OperatePrivilegeRequest) (*commonpb.Status, error) {
+	return merr.Status(OperatePrivilege(ctx, m.meta, req)), nil
 }
-func (m *MilvusMini) SelectGrant(context.Context, *milvuspb.SelectGrantRequest) (*milvuspb.SelectGrantResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) SelectGrant(ctx context.Context, req *milvuspb.SelectGrantRequest) (*milvuspb.SelectGrantResponse, error) {
+	resp, err := SelectGrant(ctx, m.meta, req)
+	if err != nil {
+		return &milvuspb.SelectGrantResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 func (m *MilvusMini) GetVersion(context.Context, *milvuspb.GetVersionRequest) (*milvuspb.GetVersionResponse, error) {
 	return nil, errors.Errorf("TODO")
@@ -274,11 +386,19 @@ func (m *MilvusMini) DescribeResourceGroup(context.Context, *milvuspb.DescribeRe
 func (m *MilvusMini) RenameCollection(context.Context, *milvuspb.RenameCollectionRequest) (*commonpb.Status, error) {
 	return nil, errors.Errorf("TODO")
 }
-func (m *MilvusMini) ListIndexedSegment(context.Context, *federpb.ListIndexedSegmentRequest) (*federpb.ListIndexedSegmentResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) ListIndexedSegment(ctx context.Context, req *federpb.ListIndexedSegmentRequest) (*federpb.ListIndexedSegmentResponse, error) {
+	resp, err := ListIndexedSegment(ctx, m.meta, req)
+	if err != nil {
+		return &federpb.ListIndexedSegmentResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
-func (m *MilvusMini) DescribeSegmentIndexData(context.Context, *federpb.DescribeSegmentIndexDataRequest) (*federpb.DescribeSegmentIndexDataResponse, error) {
-	return nil, errors.Errorf("TODO")
+func (m *MilvusMini) DescribeSegmentIndexData(ctx context.Context, req *federpb.DescribeSegmentIndexDataRequest) (*federpb.DescribeSegmentIndexDataResponse, error) {
+	resp, err := DescribeSegmentIndexData(ctx, m.meta, req)
+	if err != nil {
+		return &federpb.DescribeSegmentIndexDataResponse{Status: merr.Status(err)}, nil
+	}
+	return resp, nil
 }
 func (m *MilvusMini) Connect(context.Context, *milvuspb.ConnectRequest) (*milvuspb.ConnectResponse, error) {
 	return nil, errors.Errorf("TODO")
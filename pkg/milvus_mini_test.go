@@ -0,0 +1,2330 @@
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/federpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util"
+	"github.com/milvus-io/milvus/pkg/util/crypto"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/compaction"
+	"github.com/sharding-db/milvus-mini/pkg/expr"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+const testCollection = "test"
+
+func newTestMilvusMini(t *testing.T, rootPath string) *MilvusMini {
+	ctx := context.Background()
+	meta, err := metas.NewLocalDiskWithMemoryCacheMeta(ctx, rootPath)
+	assert.NoError(t, err)
+	cm, err := storage.NewLocalChunkManager(filepath.Join(rootPath, "data"))
+	assert.NoError(t, err)
+	idAllocator := new(allocator.LocalTsAllocator)
+	tsAllocator := new(allocator.LocalTimestampAllocator)
+	segmentManager, err := segments.NewManager(ctx, segments.DefaultConfig(), meta, cm, idAllocator)
+	assert.NoError(t, err)
+	compactor := compaction.NewCompactor(compaction.DefaultConfig(), meta, segmentManager, cm, idAllocator, tsAllocator)
+	return NewMilvusMini(idAllocator, tsAllocator, meta, segmentManager, compactor)
+}
+
+func createTestCollection(t *testing.T, m *MilvusMini) {
+	schema, err := proto.Marshal(&schemapb.CollectionSchema{
+		Name: testCollection,
+		Fields: []*schemapb.FieldSchema{
+			{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+			{Name: "tag", DataType: schemapb.DataType_VarChar,
+				TypeParams: []*commonpb.KeyValuePair{{Key: common.MaxLengthKey, Value: "16"}}},
+			{Name: "vec", DataType: schemapb.DataType_FloatVector,
+				TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}},
+		},
+	})
+	assert.NoError(t, err)
+	status, err := m.CreateCollection(context.Background(), &milvuspb.CreateCollectionRequest{
+		CollectionName: testCollection,
+		Schema:         schema,
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+}
+
+// insertTestRows inserts rows of pk i, tag "a" or "b", vector [i, i]
+func insertTestRows(t *testing.T, m *MilvusMini, start, num int64) {
+	pks := make([]int64, 0, num)
+	tags := make([]string, 0, num)
+	vectors := make([]float32, 0, num*2)
+	for i := start; i < start+num; i++ {
+		pks = append(pks, i)
+		tags = append(tags, []string{"a", "b"}[i%2])
+		vectors = append(vectors, float32(i), float32(i))
+	}
+	resp, err := m.Insert(context.Background(), &milvuspb.InsertRequest{
+		CollectionName: testCollection,
+		NumRows:        uint32(num),
+		FieldsData: []*schemapb.FieldData{
+			{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: pks}}}}},
+			{FieldName: "tag", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: tags}}}}},
+			{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+				Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: vectors}}}}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+}
+
+func newSearchRequest(t *testing.T, expr string, topK string, vectors ...[]float32) *milvuspb.SearchRequest {
+	placeholder := &commonpb.PlaceholderValue{Tag: "$0", Type: commonpb.PlaceholderType_FloatVector}
+	for _, vector := range vectors {
+		value := make([]byte, len(vector)*4)
+		for i, v := range vector {
+			binary.LittleEndian.PutUint32(value[i*4:], math.Float32bits(v))
+		}
+		placeholder.Values = append(placeholder.Values, value)
+	}
+	group, err := proto.Marshal(&commonpb.PlaceholderGroup{Placeholders: []*commonpb.PlaceholderValue{placeholder}})
+	assert.NoError(t, err)
+	return &milvuspb.SearchRequest{
+		CollectionName:   testCollection,
+		Dsl:              expr,
+		DslType:          commonpb.DslType_BoolExprV1,
+		PlaceholderGroup: group,
+		OutputFields:     []string{"tag"},
+		SearchParams: []*commonpb.KeyValuePair{
+			{Key: AnnsFieldKey, Value: "vec"},
+			{Key: common.TopKKey, Value: topK},
+			{Key: common.MetricTypeKey, Value: "L2"},
+			{Key: common.IndexParamsKey, Value: "{}"},
+		},
+	}
+}
+
+func waitLoaded(t *testing.T, m *MilvusMini) {
+	assert.Eventually(t, func() bool {
+		resp, err := m.GetLoadState(context.Background(), &milvuspb.GetLoadStateRequest{CollectionName: testCollection})
+		return err == nil && resp.GetState() == commonpb.LoadState_LoadStateLoaded
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+// waitIndexed waits until the indexes of test collection are built on all flushed segments
+func waitIndexed(t *testing.T, m *MilvusMini) {
+	assert.Eventually(t, func() bool {
+		resp, err := m.GetIndexState(context.Background(), &milvuspb.GetIndexStateRequest{CollectionName: testCollection})
+		return err == nil && resp.GetState() == commonpb.IndexState_Finished
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestLoadAndSearch(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 10)
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	insertTestRows(t, m, 10, 10)
+
+	// columns not in schema are rejected since dynamic field is disabled
+	insertResp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+		CollectionName: testCollection,
+		NumRows:        1,
+		FieldsData: []*schemapb.FieldData{
+			{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: []int64{100}}}}}},
+			{FieldName: "tag", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: []string{"a"}}}}}},
+			{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+				Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: []float32{1, 1}}}}}},
+			{FieldName: "color", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: []string{"red"}}}}}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(insertResp.GetStatus()), merr.ErrParameterInvalid)
+
+	// reject reads before loaded
+	searchResp, err := m.Search(ctx, newSearchRequest(t, "", "3", []float32{5, 5}))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrCollectionNotLoaded)
+	queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "pk < 3"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(queryResp.GetStatus()), merr.ErrCollectionNotLoaded)
+	stateResp, err := m.GetLoadState(ctx, &milvuspb.GetLoadStateRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.Equal(t, commonpb.LoadState_LoadStateNotLoad, stateResp.GetState())
+
+	status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+	progressResp, err := m.GetLoadingProgress(ctx, &milvuspb.GetLoadingProgressRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), progressResp.GetProgress())
+
+	// search rows of both flushed and growing segments
+	searchResp, err = m.Search(ctx, newSearchRequest(t, "", "3", []float32{5, 5}, []float32{12, 12}))
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	results := searchResp.GetResults()
+	assert.Equal(t, []int64{3, 3}, results.GetTopks())
+	assert.Equal(t, []int64{5, 4, 6, 12, 11, 13}, results.GetIds().GetIntId().GetData())
+	assert.Equal(t, float32(0), results.GetScores()[0])
+	assert.Equal(t, float32(2), results.GetScores()[1])
+	assert.Equal(t, []string{"b", "a", "a", "a", "b", "b"}, results.GetFieldsData()[0].GetScalars().GetStringData().GetData())
+
+	// filtered search
+	searchResp, err = m.Search(ctx, newSearchRequest(t, "tag == \"a\" and pk > 4", "3", []float32{5, 5}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{6, 8, 10}, searchResp.GetResults().GetIds().GetIntId().GetData())
+
+	queryResp, err = m.Query(ctx, &milvuspb.QueryRequest{
+		CollectionName: testCollection,
+		Expr:           "pk in [1, 3, 15] or tag like \"x%\"",
+		OutputFields:   []string{"tag", "vec"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(queryResp.GetStatus()), queryResp.GetStatus().GetReason())
+	assert.Equal(t, []string{"pk", "tag", "vec"}, queryResp.GetOutputFields())
+	assert.Equal(t, []int64{1, 3, 15}, queryResp.GetFieldsData()[0].GetScalars().GetLongData().GetData())
+	assert.Equal(t, []float32{1, 1, 3, 3, 15, 15}, queryResp.GetFieldsData()[2].GetVectors().GetFloatVector().GetData())
+
+	// delete by expression
+	deleteResp, err := m.Delete(ctx, &milvuspb.DeleteRequest{CollectionName: testCollection, Expr: "tag == \"a\""})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), deleteResp.GetDeleteCnt())
+	queryResp, err = m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, OutputFields: []string{CountStar}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{10}, queryResp.GetFieldsData()[0].GetScalars().GetLongData().GetData())
+
+	// the load state is recovered after restart
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	m = newTestMilvusMini(t, rootPath)
+	waitLoaded(t, m)
+	queryResp, err = m.Query(ctx, &milvuspb.QueryRequest{
+		CollectionName: testCollection,
+		QueryParams:    []*commonpb.KeyValuePair{{Key: LimitKey, Value: "3"}, {Key: OffsetKey, Value: "1"}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(queryResp.GetStatus()), queryResp.GetStatus().GetReason())
+	assert.Equal(t, []int64{3, 5, 7}, queryResp.GetFieldsData()[0].GetScalars().GetLongData().GetData())
+
+	status, err = m.ReleaseCollection(ctx, &milvuspb.ReleaseCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	queryResp, err = m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "pk < 3"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(queryResp.GetStatus()), merr.ErrCollectionNotLoaded)
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 100)
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	insertTestRows(t, m, 100, 10)
+
+	newCreateIndexRequest := func(indexType string) *milvuspb.CreateIndexRequest {
+		return &milvuspb.CreateIndexRequest{
+			CollectionName: testCollection,
+			FieldName:      "vec",
+			ExtraParams: []*commonpb.KeyValuePair{
+				{Key: common.IndexTypeKey, Value: indexType},
+				{Key: common.MetricTypeKey, Value: "L2"},
+				{Key: common.IndexParamsKey, Value: `{"nlist": 4}`},
+			},
+		}
+	}
+	status, err := m.CreateIndex(ctx, newCreateIndexRequest("UNKNOWN"))
+	assert.NoError(t, err)
+	assert.False(t, merr.Ok(status))
+	status, err = m.CreateIndex(ctx, newCreateIndexRequest(index.IndexTypeIvfFlat))
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	waitIndexed(t, m)
+	status, err = m.CreateIndex(ctx, newCreateIndexRequest(index.IndexTypeIvfFlat))
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	status, err = m.CreateIndex(ctx, newCreateIndexRequest(index.IndexTypeIvfSQ8))
+	assert.NoError(t, err)
+	assert.False(t, merr.Ok(status))
+
+	describeResp, err := m.DescribeIndex(ctx, &milvuspb.DescribeIndexRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(describeResp.GetStatus()), describeResp.GetStatus().GetReason())
+	description := describeResp.GetIndexDescriptions()[0]
+	assert.Equal(t, "vec", description.GetFieldName())
+	assert.Equal(t, commonpb.IndexState_Finished, description.GetState())
+	assert.Equal(t, int64(100), description.GetIndexedRows())
+	assert.Equal(t, int64(100), description.GetTotalRows())
+	assert.Contains(t, description.GetParams(), &commonpb.KeyValuePair{Key: index.NListKey, Value: "4"})
+
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	field, err := getField(collection, "vec")
+	assert.NoError(t, err)
+	checkIndexed := func(expected bool) {
+		for _, segment := range m.segmentManager.GetFlushedSegments(collection.CollectionID) {
+			assert.Equal(t, expected, segment.GetIndex(field.GetFieldID()) != nil)
+		}
+	}
+	search := func() {
+		req := newSearchRequest(t, "", "3", []float32{5, 5}, []float32{105, 105})
+		req.SearchParams[3].Value = `{"nprobe": 4}`
+		searchResp, err := m.Search(ctx, req)
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+		// 4 & 6 are tied, so are 104 & 106
+		ids := searchResp.GetResults().GetIds().GetIntId().GetData()
+		assert.Len(t, ids, 6)
+		assert.Equal(t, []int64{5, 105}, []int64{ids[0], ids[3]})
+		assert.ElementsMatch(t, []int64{4, 6, 104, 106}, []int64{ids[1], ids[2], ids[4], ids[5]})
+	}
+	checkIndexed(true)
+	search()
+
+	// metric type must match the index
+	req := newSearchRequest(t, "", "3", []float32{5, 5})
+	req.SearchParams[2].Value = "IP"
+	searchResp, err := m.Search(ctx, req)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+
+	// new flushed segments are indexed, indexes are loaded after restart
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	waitIndexed(t, m)
+	stateResp, err := m.GetIndexState(ctx, &milvuspb.GetIndexStateRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.Equal(t, commonpb.IndexState_Finished, stateResp.GetState())
+	m = newTestMilvusMini(t, rootPath)
+	waitLoaded(t, m)
+	checkIndexed(true)
+	search()
+
+	status, err = m.DropIndex(ctx, &milvuspb.DropIndexRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	describeResp, err = m.DescribeIndex(ctx, &milvuspb.DescribeIndexRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(describeResp.GetStatus()), merr.ErrIndexNotFound)
+	checkIndexed(false)
+	search()
+}
+
+func TestGrowingIndex(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 100)
+
+	status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+		CollectionName: testCollection,
+		FieldName:      "vec",
+		ExtraParams: []*commonpb.KeyValuePair{
+			{Key: common.IndexTypeKey, Value: index.IndexTypeHNSW},
+			{Key: common.MetricTypeKey, Value: "L2"},
+			{Key: common.IndexParamsKey, Value: `{"M": 8, "efConstruction": 32}`},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	insertTestRows(t, m, 100, 10)
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	field, err := getField(collection, "vec")
+	assert.NoError(t, err)
+	checkIndexed := func() {
+		snapshot := m.segmentManager.Acquire(collection.CollectionID, nil)
+		defer snapshot.Release()
+		assert.Len(t, snapshot.Segments, 1)
+		for _, segment := range snapshot.Segments {
+			assert.Equal(t, index.IndexTypeHNSW, segment.GetIndex(field.GetFieldID()).IndexType())
+		}
+	}
+	search := func() {
+		req := newSearchRequest(t, "", "3", []float32{5, 5}, []float32{105, 105})
+		req.SearchParams[3].Value = `{"ef": 16}`
+		searchResp, err := m.Search(ctx, req)
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+		ids := searchResp.GetResults().GetIds().GetIntId().GetData()
+		assert.Len(t, ids, 6)
+		assert.Equal(t, []int64{5, 105}, []int64{ids[0], ids[3]})
+		assert.ElementsMatch(t, []int64{4, 6, 104, 106}, []int64{ids[1], ids[2], ids[4], ids[5]})
+	}
+	// rows of the growing segment are indexed incrementally
+	checkIndexed()
+	search()
+
+	// the growing index is persisted when flushed, and loaded after restart
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	waitIndexed(t, m)
+	stateResp, err := m.GetIndexState(ctx, &milvuspb.GetIndexStateRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.Equal(t, commonpb.IndexState_Finished, stateResp.GetState())
+	checkIndexed()
+	m = newTestMilvusMini(t, rootPath)
+	waitLoaded(t, m)
+	checkIndexed()
+	search()
+}
+
+func TestQuantizedIndexes(t *testing.T) {
+	ctx := context.Background()
+	for _, indexType := range []string{index.IndexTypeIvfPQ, index.IndexTypeDiskANN} {
+		rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+		assert.NoError(t, err)
+		m := newTestMilvusMini(t, rootPath)
+		createTestCollection(t, m)
+		insertTestRows(t, m, 0, 100)
+		_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+		assert.NoError(t, err)
+
+		createReq := &milvuspb.CreateIndexRequest{
+			CollectionName: testCollection,
+			FieldName:      "vec",
+			ExtraParams: []*commonpb.KeyValuePair{
+				{Key: common.IndexTypeKey, Value: indexType},
+				{Key: common.MetricTypeKey, Value: "L2"},
+				{Key: common.IndexParamsKey, Value: `{"nlist": 4, "max_degree": 8}`},
+			},
+		}
+		status, err := m.CreateIndex(ctx, createReq)
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status), status.GetReason())
+		waitIndexed(t, m)
+		status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status))
+		waitLoaded(t, m)
+
+		collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+		assert.NoError(t, err)
+		segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, collection.CollectionID)
+		assert.NoError(t, err)
+		assert.Len(t, segmentIndexes, 1)
+		// full vectors of diskann are in a separate file
+		if indexType == index.IndexTypeDiskANN {
+			assert.Len(t, segmentIndexes[0].IndexFileKeys, 2)
+		} else {
+			assert.Len(t, segmentIndexes[0].IndexFileKeys, 1)
+		}
+
+		field, err := getField(collection, "vec")
+		assert.NoError(t, err)
+		search := func() {
+			req := newSearchRequest(t, "", "3", []float32{5, 5})
+			req.SearchParams[3].Value = `{"nprobe": 4, "search_list": 16}`
+			req.OutputFields = []string{"vec"}
+			searchResp, err := m.Search(ctx, req)
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+			ids := searchResp.GetResults().GetIds().GetIntId().GetData()
+			assert.Len(t, ids, 3)
+			assert.Equal(t, int64(5), ids[0])
+			assert.ElementsMatch(t, []int64{4, 6}, ids[1:])
+			// full vectors are read from binlogs for output
+			vectors := searchResp.GetResults().GetFieldsData()[0].GetVectors().GetFloatVector().GetData()
+			assert.Len(t, vectors, 6)
+			for i, id := range ids {
+				assert.Equal(t, []float32{float32(id), float32(id)}, vectors[i*2:i*2+2])
+			}
+		}
+		checkInMemory := func(inMemory bool) {
+			for _, segment := range m.segmentManager.GetFlushedSegments(collection.CollectionID) {
+				if inMemory {
+					assert.Nil(t, segment.GetIndex(field.GetFieldID()))
+				} else {
+					assert.Equal(t, indexType, segment.GetIndex(field.GetFieldID()).IndexType())
+				}
+				view, err := m.segmentManager.ReadView(ctx, segment, 0, 0)
+				assert.NoError(t, err)
+				_, ok := view.Data.Data[field.GetFieldID()]
+				assert.Equal(t, inMemory, ok)
+			}
+		}
+		checkInMemory(false)
+		search()
+		m = newTestMilvusMini(t, rootPath)
+		waitLoaded(t, m)
+		checkInMemory(false)
+		search()
+
+		// full vectors are read back into memory for brute force once the index is dropped
+		status, err = m.DropIndex(ctx, &milvuspb.DropIndexRequest{CollectionName: testCollection, FieldName: "vec"})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status), status.GetReason())
+		checkInMemory(true)
+		search()
+		// full vectors are released once the index is built on loaded segments
+		status, err = m.CreateIndex(ctx, createReq)
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status), status.GetReason())
+		waitIndexed(t, m)
+		checkInMemory(false)
+		search()
+	}
+}
+
+func TestScalarIndex(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 100)
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	insertTestRows(t, m, 100, 10)
+
+	// index type is chosen by the data type if not specified
+	for _, fieldName := range []string{"pk", "tag"} {
+		status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{CollectionName: testCollection, FieldName: fieldName})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status), status.GetReason())
+	}
+	waitIndexed(t, m)
+	status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+		CollectionName: testCollection,
+		FieldName:      "vec",
+		ExtraParams:    []*commonpb.KeyValuePair{{Key: common.IndexTypeKey, Value: index.IndexTypeTrie}},
+	})
+	assert.NoError(t, err)
+	assert.False(t, merr.Ok(status))
+	describeResp, err := m.DescribeIndex(ctx, &milvuspb.DescribeIndexRequest{CollectionName: testCollection, FieldName: "tag"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(describeResp.GetStatus()), describeResp.GetStatus().GetReason())
+	params := describeResp.GetIndexDescriptions()[0].GetParams()
+	assert.Contains(t, params, &commonpb.KeyValuePair{Key: common.IndexTypeKey, Value: index.IndexTypeTrie})
+	for _, kv := range params {
+		assert.NotEqual(t, common.MetricTypeKey, kv.GetKey())
+	}
+
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+	collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	for _, segment := range m.segmentManager.GetFlushedSegments(collection.CollectionID) {
+		assert.Len(t, segment.GetScalarIndexes(), 2)
+	}
+
+	// filters are the same with or without indexes
+	query := func() {
+		queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{
+			CollectionName: testCollection,
+			Expr:           "pk in [1, 2, 3, 105] and tag like \"a%\" or pk >= 98 and pk < 101",
+		})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(queryResp.GetStatus()), queryResp.GetStatus().GetReason())
+		assert.ElementsMatch(t, []int64{2, 98, 99, 100}, queryResp.GetFieldsData()[0].GetScalars().GetLongData().GetData())
+		searchResp, err := m.Search(ctx, newSearchRequest(t, "tag != \"a\" and pk > 4", "3", []float32{5, 5}))
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{5, 7, 9}, searchResp.GetResults().GetIds().GetIntId().GetData())
+	}
+	query()
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	m = newTestMilvusMini(t, rootPath)
+	waitIndexed(t, m)
+	waitLoaded(t, m)
+	query()
+	status, err = m.DropIndex(ctx, &milvuspb.DropIndexRequest{CollectionName: testCollection, FieldName: "tag"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	for _, segment := range m.segmentManager.GetFlushedSegments(collection.CollectionID) {
+		assert.Len(t, segment.GetScalarIndexes(), 1)
+	}
+	query()
+}
+
+func TestIndexBuildProgress(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 100)
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	insertTestRows(t, m, 100, 50)
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+
+	progressResp, err := m.GetIndexBuildProgress(ctx, &milvuspb.GetIndexBuildProgressRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(progressResp.GetStatus()), merr.ErrIndexNotFound)
+	for _, fieldName := range []string{"vec", "tag"} {
+		status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{CollectionName: testCollection, FieldName: fieldName, IndexName: fieldName,
+			ExtraParams: []*commonpb.KeyValuePair{{Key: common.IndexTypeKey, Value: index.IndexTypeInverted}}})
+		assert.NoError(t, err)
+		if fieldName == "vec" {
+			assert.False(t, merr.Ok(status))
+			status, err = m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{CollectionName: testCollection, FieldName: fieldName, IndexName: fieldName,
+				ExtraParams: []*commonpb.KeyValuePair{{Key: common.IndexTypeKey, Value: index.IndexTypeHNSW}}})
+			assert.NoError(t, err)
+		}
+		assert.True(t, merr.Ok(status), status.GetReason())
+	}
+	waitIndexed(t, m)
+
+	// the index name is required if there're multiple indexes
+	progressResp, err = m.GetIndexBuildProgress(ctx, &milvuspb.GetIndexBuildProgressRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(progressResp.GetStatus()), merr.ErrParameterInvalid)
+	progressResp, err = m.GetIndexBuildProgress(ctx, &milvuspb.GetIndexBuildProgressRequest{CollectionName: testCollection, IndexName: "vec"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(progressResp.GetStatus()), progressResp.GetStatus().GetReason())
+	assert.Equal(t, int64(150), progressResp.GetIndexedRows())
+	assert.Equal(t, int64(150), progressResp.GetTotalRows())
+	statsResp, err := m.GetIndexStatistics(ctx, &milvuspb.GetIndexStatisticsRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(statsResp.GetStatus()), statsResp.GetStatus().GetReason())
+	assert.Len(t, statsResp.GetIndexDescriptions(), 2)
+	for _, description := range statsResp.GetIndexDescriptions() {
+		assert.Equal(t, commonpb.IndexState_Finished, description.GetState())
+		assert.Equal(t, int64(150), description.GetIndexedRows())
+		assert.Equal(t, int64(0), description.GetPendingIndexRows())
+	}
+
+	listResp, err := m.ListIndexedSegment(ctx, &federpb.ListIndexedSegmentRequest{CollectionName: testCollection, IndexName: "vec"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(listResp.GetStatus()), listResp.GetStatus().GetReason())
+	assert.Len(t, listResp.GetSegmentIDs(), 2)
+	dataResp, err := m.DescribeSegmentIndexData(ctx, &federpb.DescribeSegmentIndexDataRequest{
+		CollectionName: testCollection,
+		IndexName:      "vec",
+		SegmentsIDs:    listResp.GetSegmentIDs()[1:],
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(dataResp.GetStatus()), dataResp.GetStatus().GetReason())
+	assert.Contains(t, dataResp.GetIndexParams(), &commonpb.KeyValuePair{Key: common.IndexTypeKey, Value: index.IndexTypeHNSW})
+	assert.Len(t, dataResp.GetIndexData(), 1)
+	indexData := dataResp.GetIndexData()[listResp.GetSegmentIDs()[1]]
+	assert.Equal(t, listResp.GetSegmentIDs()[1], indexData.GetSegmentID())
+	var fileMeta segmentIndexData
+	assert.NoError(t, json.Unmarshal([]byte(indexData.GetIndexData()), &fileMeta))
+	assert.Equal(t, int64(50), fileMeta.NumRows)
+	assert.Len(t, fileMeta.IndexFileKeys, 1)
+	assert.Greater(t, fileMeta.IndexSize, int64(0))
+	dataResp, err = m.DescribeSegmentIndexData(ctx, &federpb.DescribeSegmentIndexDataRequest{
+		CollectionName: testCollection,
+		IndexName:      "vec",
+		SegmentsIDs:    []int64{-1},
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(dataResp.GetStatus()), merr.ErrSegmentNotFound)
+
+	// unfinished builds are not listed and they're recovered after restart
+	collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, collection.CollectionID)
+	assert.NoError(t, err)
+	assert.Len(t, segmentIndexes, 4)
+	for _, segmentIndex := range segmentIndexes {
+		building := segmentIndex.Clone()
+		building.EnableIndex = false
+		assert.NoError(t, m.meta.SaveSegmentIndex(ctx, building))
+	}
+	listResp, err = m.ListIndexedSegment(ctx, &federpb.ListIndexedSegmentRequest{CollectionName: testCollection, IndexName: "vec"})
+	assert.NoError(t, err)
+	assert.Empty(t, listResp.GetSegmentIDs())
+	stateResp, err := m.GetIndexState(ctx, &milvuspb.GetIndexStateRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.Equal(t, commonpb.IndexState_InProgress, stateResp.GetState())
+	m = newTestMilvusMini(t, rootPath)
+	waitIndexed(t, m)
+	recovered, err := m.meta.ListSegmentIndexes(ctx, collection.CollectionID)
+	assert.NoError(t, err)
+	assert.Len(t, recovered, 4)
+	for _, segmentIndex := range recovered {
+		assert.True(t, segmentIndex.EnableIndex)
+	}
+	listResp, err = m.ListIndexedSegment(ctx, &federpb.ListIndexedSegmentRequest{CollectionName: testCollection, IndexName: "tag"})
+	assert.NoError(t, err)
+	assert.Len(t, listResp.GetSegmentIDs(), 2)
+}
+
+func TestFilteredSearch(t *testing.T) {
+	ctx := context.Background()
+	for _, indexType := range []string{"", index.IndexTypeIvfFlat, index.IndexTypeIvfSQ8, index.IndexTypeIvfPQ, index.IndexTypeHNSW, index.IndexTypeDiskANN} {
+		rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+		assert.NoError(t, err)
+		m := newTestMilvusMini(t, rootPath)
+		createTestCollection(t, m)
+		insertTestRows(t, m, 0, 1000)
+		_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+		assert.NoError(t, err)
+		if indexType != "" {
+			status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+				CollectionName: testCollection,
+				FieldName:      "vec",
+				ExtraParams: []*commonpb.KeyValuePair{
+					{Key: common.IndexTypeKey, Value: indexType},
+					{Key: common.IndexParamsKey, Value: `{"nlist": 16, "M": 4, "efConstruction": 16, "max_degree": 8, "search_list_size": 16}`},
+				},
+			})
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(status), status.GetReason())
+			status, err = m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{CollectionName: testCollection, FieldName: "pk"})
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(status), status.GetReason())
+			waitIndexed(t, m)
+		}
+		status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status))
+		waitLoaded(t, m)
+
+		for _, c := range []struct {
+			expr     string
+			expected []int64
+		}{
+			// mostly filtered out, searched by brute force
+			{"pk < 5", []int64{4, 3, 2}},
+			{"pk in [1, 998, 999]", []int64{998, 999, 1}},
+			{"tag == \"a\" and pk >= 900", []int64{900, 902, 904}},
+			// valid rows are far from the probed clusters, the index misses them
+			{"pk < 70", []int64{69, 68, 67}},
+			{"pk == 500 or pk > 997", []int64{500, 998, 999}},
+		} {
+			req := newSearchRequest(t, c.expr, "3", []float32{500, 500})
+			req.SearchParams[3].Value = `{"nprobe": 1, "ef": 4, "search_list": 4}`
+			searchResp, err := m.Search(ctx, req)
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+			assert.Equal(t, c.expected, searchResp.GetResults().GetIds().GetIntId().GetData(), indexType+" "+c.expr)
+		}
+		req := newSearchRequest(t, "tag == \"b\"", "3", []float32{500, 500})
+		req.SearchParams[3].Value = `{"nprobe": 16, "ef": 16, "search_list": 16}`
+		searchResp, err := m.Search(ctx, req)
+		assert.NoError(t, err)
+		ids := searchResp.GetResults().GetIds().GetIntId().GetData()
+		assert.Len(t, ids, 3, indexType)
+		for _, id := range ids {
+			assert.Equal(t, int64(1), id%2, indexType)
+		}
+	}
+}
+
+func TestRangeSearch(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		indexType  string
+		metricType string
+	}{
+		{"", "L2"},
+		{"", "IP"},
+		{index.IndexTypeIvfFlat, "L2"},
+		{index.IndexTypeHNSW, "IP"},
+	} {
+		rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+		assert.NoError(t, err)
+		m := newTestMilvusMini(t, rootPath)
+		createTestCollection(t, m)
+		insertTestRows(t, m, 0, 100)
+		_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+		assert.NoError(t, err)
+		if c.indexType != "" {
+			status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+				CollectionName: testCollection,
+				FieldName:      "vec",
+				ExtraParams: []*commonpb.KeyValuePair{
+					{Key: common.IndexTypeKey, Value: c.indexType},
+					{Key: common.MetricTypeKey, Value: c.metricType},
+					{Key: common.IndexParamsKey, Value: `{"nlist": 4}`},
+				},
+			})
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(status), status.GetReason())
+			waitIndexed(t, m)
+		}
+		status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status))
+		waitLoaded(t, m)
+
+		search := func(params string, topK string, query []float32) *milvuspb.SearchResults {
+			req := newSearchRequest(t, "", topK, query)
+			req.SearchParams[2].Value = c.metricType
+			req.SearchParams[3].Value = params
+			searchResp, err := m.Search(ctx, req)
+			assert.NoError(t, err)
+			return searchResp
+		}
+		if c.metricType == "L2" {
+			// distances of [5, 5] are 2 * (pk - 5)^2
+			searchResp := search(`{"radius": 9, "range_filter": 1}`, "10", []float32{5, 5})
+			assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+			ids := searchResp.GetResults().GetIds().GetIntId().GetData()
+			assert.Len(t, ids, 4, c.indexType)
+			assert.ElementsMatch(t, []int64{4, 6}, ids[:2], c.indexType)
+			assert.ElementsMatch(t, []int64{3, 7}, ids[2:], c.indexType)
+			assert.Equal(t, []float32{2, 2, 8, 8}, searchResp.GetResults().GetScores())
+			searchResp = search(`{"radius": 9}`, "2", []float32{5, 5})
+			ids = searchResp.GetResults().GetIds().GetIntId().GetData()
+			assert.Len(t, ids, 2, c.indexType)
+			assert.Equal(t, int64(5), ids[0], c.indexType)
+			searchResp = search(`{"radius": 9, "range_filter": 10}`, "10", []float32{5, 5})
+			assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+		} else {
+			// similarities of [1, 1] are 2 * pk
+			searchResp := search(`{"radius": 10, "range_filter": 16}`, "10", []float32{1, 1})
+			assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+			assert.Equal(t, []int64{8, 7, 6}, searchResp.GetResults().GetIds().GetIntId().GetData(), c.indexType)
+			searchResp = search(`{"radius": 10, "range_filter": 8}`, "10", []float32{1, 1})
+			assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+		}
+		searchResp := search(`{"range_filter": 1}`, "10", []float32{5, 5})
+		assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+	}
+}
+
+func TestGroupBySearch(t *testing.T) {
+	ctx := context.Background()
+	for _, indexType := range []string{"", index.IndexTypeHNSW} {
+		rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+		assert.NoError(t, err)
+		m := newTestMilvusMini(t, rootPath)
+		createTestCollection(t, m)
+		insertTestRows(t, m, 0, 100)
+		_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+		assert.NoError(t, err)
+		if indexType != "" {
+			status, err := m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+				CollectionName: testCollection,
+				FieldName:      "vec",
+				ExtraParams: []*commonpb.KeyValuePair{
+					{Key: common.IndexTypeKey, Value: indexType},
+					{Key: common.MetricTypeKey, Value: "L2"},
+				},
+			})
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(status), status.GetReason())
+			waitIndexed(t, m)
+		}
+		status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status))
+		waitLoaded(t, m)
+
+		search := func(expr string, topK string, offset string, groupBy string) *milvuspb.SearchResults {
+			req := newSearchRequest(t, expr, topK, []float32{5, 5})
+			req.SearchParams[3].Value = `{"ef": 2}`
+			req.SearchParams = append(req.SearchParams,
+				&commonpb.KeyValuePair{Key: OffsetKey, Value: offset},
+				&commonpb.KeyValuePair{Key: GroupByFieldKey, Value: groupBy})
+			searchResp, err := m.Search(ctx, req)
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+			return searchResp
+		}
+		searchResp := search("", "2", "0", "tag")
+		ids := searchResp.GetResults().GetIds().GetIntId().GetData()
+		assert.Len(t, ids, 2, indexType)
+		assert.Equal(t, int64(5), ids[0], indexType)
+		assert.Contains(t, []int64{4, 6}, ids[1], indexType)
+		assert.ElementsMatch(t, []string{"a", "b"}, searchResp.GetResults().GetFieldsData()[0].GetScalars().GetStringData().GetData())
+		// there're only 2 groups
+		searchResp = search("", "10", "0", "tag")
+		assert.Len(t, searchResp.GetResults().GetIds().GetIntId().GetData(), 2, indexType)
+		searchResp = search("", "1", "1", "tag")
+		ids = searchResp.GetResults().GetIds().GetIntId().GetData()
+		assert.Len(t, ids, 1, indexType)
+		assert.Contains(t, []int64{4, 6}, ids[0], indexType)
+		// the nearest rows are all of tag a, the only row of tag b is far away
+		searchResp = search("pk % 10 == 0 or pk == 51", "2", "0", "tag")
+		ids = searchResp.GetResults().GetIds().GetIntId().GetData()
+		assert.Len(t, ids, 2, indexType)
+		assert.Contains(t, []int64{0, 10}, ids[0], indexType)
+		assert.Equal(t, int64(51), ids[1], indexType)
+		searchResp = search("pk < 10", "3", "0", "pk")
+		assert.Len(t, searchResp.GetResults().GetIds().GetIntId().GetData(), 3, indexType)
+
+		req := newSearchRequest(t, "", "2", []float32{5, 5})
+		req.SearchParams = append(req.SearchParams, &commonpb.KeyValuePair{Key: GroupByFieldKey, Value: "vec"})
+		searchResp, err = m.Search(ctx, req)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+	}
+}
+
+func TestQueryIterator(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 50)
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	insertTestRows(t, m, 50, 50)
+	status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	iterate := func(req *milvuspb.QueryRequest, batchSize int) ([]int64, []int) {
+		it, err := NewQueryIterator(ctx, m, req, batchSize)
+		assert.NoError(t, err)
+		pks := make([]int64, 0)
+		batches := make([]int, 0)
+		for {
+			resp, err := it.Next(ctx)
+			if err == io.EOF {
+				return pks, batches
+			}
+			assert.NoError(t, err)
+			data := resp.GetFieldsData()[0].GetScalars().GetLongData().GetData()
+			pks = append(pks, data...)
+			batches = append(batches, len(data))
+		}
+	}
+	pks, batches := iterate(&milvuspb.QueryRequest{CollectionName: testCollection}, 30)
+	assert.Equal(t, []int{30, 30, 30, 10}, batches)
+	for i, pk := range pks {
+		assert.Equal(t, int64(i), pk)
+	}
+	pks, batches = iterate(&milvuspb.QueryRequest{
+		CollectionName: testCollection,
+		Expr:           `tag == "a" or pk < 0`,
+		QueryParams: []*commonpb.KeyValuePair{
+			{Key: LimitKey, Value: "20"},
+			{Key: OffsetKey, Value: "5"},
+		},
+	}, 8)
+	assert.Equal(t, []int{8, 8, 4}, batches)
+	for i, pk := range pks {
+		assert.Equal(t, int64(10+2*i), pk)
+	}
+	_, err = NewQueryIterator(ctx, m, &milvuspb.QueryRequest{CollectionName: testCollection}, 0)
+	assert.ErrorIs(t, err, merr.ErrParameterInvalid)
+}
+
+func TestSearchIterator(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 100)
+	status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	iterate := func(req *milvuspb.SearchRequest, batchSize int, limit int) ([]int64, []float32) {
+		it, err := NewSearchIterator(ctx, m, req, batchSize, limit)
+		assert.NoError(t, err)
+		pks := make([]int64, 0)
+		scores := make([]float32, 0)
+		for {
+			resp, err := it.Next(ctx)
+			if err == io.EOF {
+				return pks, scores
+			}
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(resp.GetResults().GetScores()), batchSize)
+			pks = append(pks, resp.GetResults().GetIds().GetIntId().GetData()...)
+			scores = append(scores, resp.GetResults().GetScores()...)
+		}
+	}
+	// distances of [5, 5] are 2 * (pk - 5)^2, pairs of rows are tied and split by batches
+	pks, scores := iterate(newSearchRequest(t, "", "1", []float32{5, 5}), 4, -1)
+	assert.Len(t, pks, 100)
+	seen := make(map[int64]struct{})
+	for i, pk := range pks {
+		seen[pk] = struct{}{}
+		assert.Equal(t, float32(2*(pk-5)*(pk-5)), scores[i])
+		if i > 0 {
+			assert.LessOrEqual(t, scores[i-1], scores[i])
+		}
+	}
+	assert.Len(t, seen, 100)
+
+	pks, _ = iterate(newSearchRequest(t, "pk >= 50", "1", []float32{5, 5}), 7, 10)
+	for i, pk := range pks {
+		assert.Equal(t, int64(50+i), pk)
+	}
+	assert.Len(t, pks, 10)
+
+	req := newSearchRequest(t, "", "1", []float32{5, 5})
+	req.SearchParams[3].Value = `{"radius": 9}`
+	pks, _ = iterate(req, 2, -1)
+	assert.ElementsMatch(t, []int64{3, 4, 5, 6, 7}, pks)
+
+	_, err = NewSearchIterator(ctx, m, newSearchRequest(t, "", "1", []float32{5, 5}, []float32{1, 1}), 4, -1)
+	assert.ErrorIs(t, err, merr.ErrParameterInvalid)
+	req = newSearchRequest(t, "", "1", []float32{5, 5})
+	req.SearchParams = append(req.SearchParams, &commonpb.KeyValuePair{Key: GroupByFieldKey, Value: "tag"})
+	_, err = NewSearchIterator(ctx, m, req, 4, -1)
+	assert.ErrorIs(t, err, merr.ErrParameterInvalid)
+}
+
+func TestCalcDistance(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	insertTestRows(t, m, 0, 10)
+	status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	ids := func(pks ...int64) *milvuspb.VectorsArray {
+		return &milvuspb.VectorsArray{Array: &milvuspb.VectorsArray_IdArray{IdArray: &milvuspb.VectorIDs{
+			CollectionName: testCollection,
+			FieldName:      "vec",
+			IdArray:        &schemapb.IDs{IdField: &schemapb.IDs_IntId{IntId: &schemapb.LongArray{Data: pks}}},
+		}}}
+	}
+	floats := func(dim int64, data ...float32) *milvuspb.VectorsArray {
+		return &milvuspb.VectorsArray{Array: &milvuspb.VectorsArray_DataArray{DataArray: &schemapb.VectorField{
+			Dim: dim, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: data}},
+		}}}
+	}
+	binaries := func(data ...byte) *milvuspb.VectorsArray {
+		return &milvuspb.VectorsArray{Array: &milvuspb.VectorsArray_DataArray{DataArray: &schemapb.VectorField{
+			Dim: 8, Data: &schemapb.VectorField_BinaryVector{BinaryVector: data},
+		}}}
+	}
+	calc := func(left, right *milvuspb.VectorsArray, params ...string) *milvuspb.CalcDistanceResults {
+		req := &milvuspb.CalcDistanceRequest{OpLeft: left, OpRight: right}
+		for i := 0; i < len(params); i += 2 {
+			req.Params = append(req.Params, &commonpb.KeyValuePair{Key: params[i], Value: params[i+1]})
+		}
+		resp, err := m.CalcDistance(ctx, req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp := calc(ids(3, 1), floats(2, 0, 0, 1, 1), CalcDistanceMetricKey, "L2")
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	assert.Equal(t, []float32{18, 8, 2, 0}, resp.GetFloatDist().GetData())
+	resp = calc(ids(2), ids(0, 2), CalcDistanceMetricKey, "l2", CalcDistanceSqrtKey, "true")
+	assert.Equal(t, []float32{float32(math.Sqrt(8)), 0}, resp.GetFloatDist().GetData())
+	resp = calc(floats(2, 1, 2), ids(3), common.MetricTypeKey, "IP")
+	assert.Equal(t, []float32{9}, resp.GetFloatDist().GetData())
+	resp = calc(binaries(0x0f, 0xff), binaries(0x00), CalcDistanceMetricKey, "HAMMING")
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	assert.Equal(t, []int32{4, 8}, resp.GetIntDist().GetData())
+	resp = calc(binaries(0x0f), binaries(0xff, 0x01), CalcDistanceMetricKey, "JACCARD")
+	assert.Equal(t, []float32{0.5, 0.75}, resp.GetFloatDist().GetData())
+
+	for _, resp := range []*milvuspb.CalcDistanceResults{
+		calc(ids(1), ids(2)),
+		calc(ids(1), ids(100), CalcDistanceMetricKey, "L2"),
+		calc(ids(1), floats(3, 1, 2, 3), CalcDistanceMetricKey, "L2"),
+		calc(ids(1), floats(2, 1, 2, 3), CalcDistanceMetricKey, "L2"),
+		calc(ids(1), binaries(0x01), CalcDistanceMetricKey, "L2"),
+		calc(binaries(0x01), binaries(0x01), CalcDistanceMetricKey, "L2"),
+		calc(ids(), ids(1), CalcDistanceMetricKey, "L2"),
+		calc(nil, ids(1), CalcDistanceMetricKey, "L2"),
+	} {
+		assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, resp.GetStatus().GetReason())
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	schema, err := proto.Marshal(&schemapb.CollectionSchema{
+		Name: testCollection,
+		Fields: []*schemapb.FieldSchema{
+			{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+			{Name: "tag", DataType: schemapb.DataType_VarChar, IsPartitionKey: true,
+				TypeParams: []*commonpb.KeyValuePair{{Key: common.MaxLengthKey, Value: "16"}}},
+			{Name: "vec", DataType: schemapb.DataType_FloatVector,
+				TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}},
+		},
+	})
+	assert.NoError(t, err)
+	status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{
+		CollectionName: testCollection,
+		Schema:         schema,
+		NumPartitions:  4,
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	insertTestRows(t, m, 0, 100)
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	// rows are routed to the partitions hashed from tags
+	collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	assert.Len(t, collection.Partitions, 4)
+	names := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		names = append(names, fmt.Sprintf("default_%d", i))
+	}
+	hashed, err := typeutil.HashKey2Partitions(&schemapb.FieldData{Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+		Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: []string{"a", "b"}}}}}}, names)
+	assert.NoError(t, err)
+	for i, tag := range []string{"a", "b"} {
+		partitionID, err := getPartitionID(collection, names[hashed[i]])
+		assert.NoError(t, err)
+		snapshot := m.segmentManager.Acquire(collection.CollectionID, []UniqueID{partitionID})
+		rows := 0
+		for _, segment := range snapshot.Segments {
+			rows += int(segment.RowNum())
+		}
+		snapshot.Release()
+		if hashed[0] == hashed[1] {
+			assert.Equal(t, 100, rows, tag)
+		} else {
+			assert.Equal(t, 50, rows, tag)
+		}
+	}
+
+	partitionIDs := make([]UniqueID, 0, 4)
+	for _, partition := range collection.Partitions {
+		partitionIDs = append(partitionIDs, partition.PartitionID)
+	}
+	plan, err := expr.Parse(`tag == "a" and pk > 1`, model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema())
+	assert.NoError(t, err)
+	pruned, err := prunePartitions(collection, plan, partitionIDs)
+	assert.NoError(t, err)
+	assert.Len(t, pruned, 1)
+
+	query := func(expr string) []int64 {
+		resp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: expr})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+		return resp.GetFieldsData()[0].GetScalars().GetLongData().GetData()
+	}
+	assert.Len(t, query(`tag == "a"`), 50)
+	assert.Equal(t, []int64{1, 3}, query(`tag in ["b", "c"] and pk < 5`))
+	assert.Len(t, query(`tag == "a" or tag == "b"`), 100)
+	assert.Len(t, query(`tag == "c"`), 0)
+	assert.Len(t, query(`pk >= 90`), 10)
+
+	searchResp, err := m.Search(ctx, newSearchRequest(t, `tag == "b"`, "100", []float32{0, 0}))
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	assert.Len(t, searchResp.GetResults().GetIds().GetIntId().GetData(), 50)
+
+	deleteResp, err := m.Delete(ctx, &milvuspb.DeleteRequest{CollectionName: testCollection, Expr: `tag == "a" and pk < 10`})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(deleteResp.GetStatus()), deleteResp.GetStatus().GetReason())
+	assert.Equal(t, int64(5), deleteResp.GetDeleteCnt())
+	assert.Len(t, query(`tag == "a"`), 45)
+
+	// partitions can't be specified in partition key mode
+	resp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "pk > 0", PartitionNames: []string{"default_0"}})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid)
+	insertResp, err := m.Insert(ctx, &milvuspb.InsertRequest{CollectionName: testCollection, PartitionName: "default_0", NumRows: 1})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(insertResp.GetStatus()), merr.ErrParameterInvalid)
+
+	// no row is inserted if the insert into any partition fails
+	ts, err := m.tsAllocator.AllocTimestamp()
+	assert.NoError(t, err)
+	pks := []int64{1000, 1001, 1002, 1003}
+	data, _, err := buildInsertData(m.idAllocator, collection, []*schemapb.FieldData{
+		{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: pks}}}}},
+		{FieldName: "tag", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: []string{"a", "b", "c", "d"}}}}}},
+		{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+			Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: make([]float32, 8)}}}}},
+	}, 4, ts)
+	assert.NoError(t, err)
+	partitions, err := splitByPartitionKey(collection, getPartitionKeyField(collection), data)
+	assert.NoError(t, err)
+	assert.Greater(t, len(partitions), 1)
+	var last UniqueID
+	for partitionID := range partitions {
+		if partitionID > last {
+			last = partitionID
+		}
+	}
+	vecField, err := getField(collection, "vec")
+	assert.NoError(t, err)
+	delete(partitions[last].Data, vecField.GetFieldID())
+	assert.Error(t, m.segmentManager.InsertPartitions(ctx, collection, partitions))
+	assert.Len(t, query(`pk >= 1000`), 0)
+	assert.Len(t, query(`pk >= 0`), 95)
+}
+
+func TestDynamicField(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	schema, err := proto.Marshal(&schemapb.CollectionSchema{
+		Name:               testCollection,
+		EnableDynamicField: true,
+		Fields: []*schemapb.FieldSchema{
+			{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+			{Name: "vec", DataType: schemapb.DataType_FloatVector,
+				TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}},
+		},
+	})
+	assert.NoError(t, err)
+	status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: testCollection, Schema: schema})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+
+	// rows of pk i, vector [i, i], color "red" or "blue", size i and $meta {"extra": i}
+	insert := func(num int, extraColumns ...*schemapb.FieldData) *milvuspb.MutationResult {
+		pks := make([]int64, 0, num)
+		vectors := make([]float32, 0, num*2)
+		for i := 0; i < num; i++ {
+			pks = append(pks, int64(i))
+			vectors = append(vectors, float32(i), float32(i))
+		}
+		resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+			CollectionName: testCollection,
+			NumRows:        uint32(num),
+			FieldsData: append([]*schemapb.FieldData{
+				{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: pks}}}}},
+				{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+					Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: vectors}}}}},
+			}, extraColumns...),
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	jsonColumn := func(name string, values ...string) *schemapb.FieldData {
+		data := make([][]byte, 0, len(values))
+		for _, value := range values {
+			data = append(data, []byte(value))
+		}
+		return &schemapb.FieldData{FieldName: name, Type: schemapb.DataType_JSON, IsDynamic: name == common.MetaFieldName,
+			Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_JsonData{JsonData: &schemapb.JSONArray{Data: data}}}}}
+	}
+	colors := make([]string, 0, 10)
+	sizes := make([]int64, 0, 10)
+	metas := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		colors = append(colors, []string{"red", "blue"}[i%2])
+		sizes = append(sizes, int64(i))
+		metas = append(metas, fmt.Sprintf(`{"extra": %d}`, i))
+	}
+	resp := insert(10,
+		&schemapb.FieldData{FieldName: "color", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: colors}}}}},
+		&schemapb.FieldData{FieldName: "size", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: sizes}}}}},
+		jsonColumn(common.MetaFieldName, metas...))
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	query := func(expr string, outputFields ...string) *milvuspb.QueryResults {
+		resp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: expr, OutputFields: outputFields})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+		return resp
+	}
+	queryResp := query(`color == "red" and size > 4`, "size")
+	assert.Equal(t, []string{"pk", "size"}, queryResp.GetOutputFields())
+	assert.Equal(t, []int64{6, 8}, queryResp.GetFieldsData()[0].GetScalars().GetLongData().GetData())
+	assert.True(t, queryResp.GetFieldsData()[1].GetIsDynamic())
+	assert.Equal(t, [][]byte{[]byte(`{"size":6}`), []byte(`{"size":8}`)}, queryResp.GetFieldsData()[1].GetScalars().GetJsonData().GetData())
+	queryResp = query(`$meta["extra"] == 3`, "*")
+	assert.Equal(t, []string{"pk", "vec", common.MetaFieldName}, queryResp.GetOutputFields())
+	assert.Equal(t, [][]byte{[]byte(`{"color":"blue","extra":3,"size":3}`)}, queryResp.GetFieldsData()[2].GetScalars().GetJsonData().GetData())
+	queryResp = query(`pk == 2`, "color", "unknown")
+	assert.Equal(t, [][]byte{[]byte(`{"color":"red"}`)}, queryResp.GetFieldsData()[1].GetScalars().GetJsonData().GetData())
+
+	req := newSearchRequest(t, `color == "blue"`, "2", []float32{0, 0})
+	req.OutputFields = []string{"size", "extra"}
+	searchResp, err := m.Search(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	assert.Equal(t, []int64{1, 3}, searchResp.GetResults().GetIds().GetIntId().GetData())
+	assert.Equal(t, []string{"size", "extra"}, searchResp.GetResults().GetOutputFields())
+	assert.Equal(t, [][]byte{[]byte(`{"extra":1,"size":1}`), []byte(`{"extra":3,"size":3}`)},
+		searchResp.GetResults().GetFieldsData()[0].GetScalars().GetJsonData().GetData())
+
+	// keys of $meta can't be the fields of schema or duplicated
+	for _, columns := range [][]*schemapb.FieldData{
+		{jsonColumn(common.MetaFieldName, `{"pk": 1}`)},
+		{jsonColumn(common.MetaFieldName, `[1]`)},
+		{jsonColumn(common.MetaFieldName, `{"color": 1}`), jsonColumn("color", `"red"`)},
+	} {
+		resp := insert(1, columns...)
+		assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, resp.GetStatus().GetReason())
+	}
+	resp = insert(1, jsonColumn("info", `{"a": [1, 2]}`))
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+}
+
+func TestSchemaValidation(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	params := func(key, value string) []*commonpb.KeyValuePair {
+		return []*commonpb.KeyValuePair{{Key: key, Value: value}}
+	}
+	pk := &schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true}
+	vec := &schemapb.FieldSchema{Name: "vec", DataType: schemapb.DataType_FloatVector, TypeParams: params(common.DimKey, "2")}
+	create := func(name string, numPartitions int64, fields ...*schemapb.FieldSchema) *commonpb.Status {
+		schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: name, Fields: fields})
+		assert.NoError(t, err)
+		status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: name, Schema: schema, NumPartitions: numPartitions})
+		assert.NoError(t, err)
+		return status
+	}
+	for i, c := range []struct {
+		name   string
+		fields []*schemapb.FieldSchema
+	}{
+		{"", []*schemapb.FieldSchema{pk, vec}},
+		{"1test", []*schemapb.FieldSchema{pk, vec}},
+		{"te-st", []*schemapb.FieldSchema{pk, vec}},
+		{"test", []*schemapb.FieldSchema{vec}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "pk2", DataType: schemapb.DataType_Int64, IsPrimaryKey: true}}},
+		{"test", []*schemapb.FieldSchema{{Name: "pk", DataType: schemapb.DataType_Float, IsPrimaryKey: true}, vec}},
+		{"test", []*schemapb.FieldSchema{pk}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "vec", DataType: schemapb.DataType_Int64}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "a b", DataType: schemapb.DataType_Int64}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: common.MetaFieldName, DataType: schemapb.DataType_JSON}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "a", DataType: schemapb.DataType_Int64, AutoID: true}}},
+		{"test", []*schemapb.FieldSchema{pk, {Name: "vec", DataType: schemapb.DataType_FloatVector}}},
+		{"test", []*schemapb.FieldSchema{pk, {Name: "vec", DataType: schemapb.DataType_FloatVector, TypeParams: params(common.DimKey, "x")}}},
+		{"test", []*schemapb.FieldSchema{pk, {Name: "vec", DataType: schemapb.DataType_FloatVector, TypeParams: params(common.DimKey, "0")}}},
+		{"test", []*schemapb.FieldSchema{pk, {Name: "vec", DataType: schemapb.DataType_FloatVector, TypeParams: params(common.DimKey, "40000")}}},
+		{"test", []*schemapb.FieldSchema{pk, {Name: "vec", DataType: schemapb.DataType_BinaryVector, TypeParams: params(common.DimKey, "12")}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "s", DataType: schemapb.DataType_VarChar}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "s", DataType: schemapb.DataType_VarChar, TypeParams: params(common.MaxLengthKey, "70000")}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "s", DataType: schemapb.DataType_String}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "a", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_JSON}}},
+		{"test", []*schemapb.FieldSchema{pk, vec, {Name: "a", DataType: schemapb.DataType_Float, IsPartitionKey: true}}},
+		{"test", []*schemapb.FieldSchema{{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true, IsPartitionKey: true}, vec}},
+		{"test", []*schemapb.FieldSchema{pk, vec,
+			{Name: "a", DataType: schemapb.DataType_Int64, IsPartitionKey: true},
+			{Name: "b", DataType: schemapb.DataType_Int64, IsPartitionKey: true}}},
+	} {
+		status := create(c.name, 0, c.fields...)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, "case %d: %s", i, status.GetReason())
+	}
+	status := create("test", 5000, pk, vec, &schemapb.FieldSchema{Name: "a", DataType: schemapb.DataType_Int64, IsPartitionKey: true})
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, status.GetReason())
+
+	status = create("_test1", 0, &schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_VarChar, IsPrimaryKey: true, AutoID: true,
+		TypeParams: params(common.MaxLengthKey, "64")}, vec)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	collection, err := m.meta.GetCollectionByName(ctx, "", "_test1")
+	assert.NoError(t, err)
+	assert.True(t, collection.AutoID)
+}
+
+func TestDefaultValue(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	pk := &schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true}
+	vec := &schemapb.FieldSchema{Name: "vec", DataType: schemapb.DataType_FloatVector,
+		TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}}
+	maxLength := []*commonpb.KeyValuePair{{Key: common.MaxLengthKey, Value: "4"}}
+	maxCapacity := []*commonpb.KeyValuePair{{Key: MaxCapacityKey, Value: "4"}}
+	create := func(name string, fields ...*schemapb.FieldSchema) *commonpb.Status {
+		schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: name, Fields: append([]*schemapb.FieldSchema{pk, vec}, fields...)})
+		assert.NoError(t, err)
+		status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: name, Schema: schema})
+		assert.NoError(t, err)
+		return status
+	}
+	for i, field := range []*schemapb.FieldSchema{
+		{Name: "a", DataType: schemapb.DataType_Int8, DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_IntData{IntData: 200}}},
+		{Name: "a", DataType: schemapb.DataType_Int64, DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_IntData{IntData: 1}}},
+		{Name: "a", DataType: schemapb.DataType_Double, DefaultValue: &schemapb.ValueField{}},
+		{Name: "a", DataType: schemapb.DataType_VarChar, TypeParams: maxLength,
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_StringData{StringData: "too long"}}},
+		{Name: "a", DataType: schemapb.DataType_JSON, DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_BytesData{BytesData: []byte(`{`)}}},
+		{Name: "a", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_Int64, TypeParams: maxCapacity,
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_BytesData{BytesData: []byte(`[1, "2"]`)}}},
+		{Name: "a", DataType: schemapb.DataType_FloatVector, TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}},
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_BytesData{BytesData: []byte{0}}}},
+	} {
+		status := create(fmt.Sprintf("invalid%d", i), field)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, "case %d: %s", i, status.GetReason())
+	}
+
+	status := create(testCollection,
+		&schemapb.FieldSchema{Name: "i8", DataType: schemapb.DataType_Int8,
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_IntData{IntData: -3}}},
+		&schemapb.FieldSchema{Name: "s", DataType: schemapb.DataType_VarChar, TypeParams: maxLength,
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_StringData{StringData: "none"}}},
+		&schemapb.FieldSchema{Name: "j", DataType: schemapb.DataType_JSON,
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_BytesData{BytesData: []byte(`{"a":1}`)}}},
+		&schemapb.FieldSchema{Name: "arr", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_Int64, TypeParams: maxCapacity,
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_BytesData{BytesData: []byte(`[1, 2]`)}}})
+	assert.True(t, merr.Ok(status), status.GetReason())
+	collection, err := m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	assert.Equal(t, "none", collection.Fields[3].DefaultValue.GetStringData())
+
+	// the columns with default values are omitted, s is given
+	resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+		CollectionName: testCollection,
+		NumRows:        2,
+		FieldsData: []*schemapb.FieldData{
+			{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: []int64{1, 2}}}}}},
+			{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+				Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: []float32{1, 1, 2, 2}}}}}},
+			{FieldName: "s", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: []string{"x", "y"}}}}}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "i8 == -3",
+		OutputFields: []string{"i8", "s", "j", "arr"}})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(queryResp.GetStatus()), queryResp.GetStatus().GetReason())
+	fields := make(map[string]*schemapb.FieldData)
+	for _, fieldData := range queryResp.GetFieldsData() {
+		fields[fieldData.GetFieldName()] = fieldData
+	}
+	assert.Equal(t, []int64{1, 2}, fields["pk"].GetScalars().GetLongData().GetData())
+	assert.Equal(t, []int32{-3, -3}, fields["i8"].GetScalars().GetIntData().GetData())
+	assert.Equal(t, []string{"x", "y"}, fields["s"].GetScalars().GetStringData().GetData())
+	assert.Equal(t, [][]byte{[]byte(`{"a":1}`), []byte(`{"a":1}`)}, fields["j"].GetScalars().GetJsonData().GetData())
+	arrays := fields["arr"].GetScalars().GetArrayData().GetData()
+	assert.Len(t, arrays, 2)
+	assert.Equal(t, []int64{1, 2}, arrays[1].GetLongData().GetData())
+
+	// default values are persisted
+	m = newTestMilvusMini(t, rootPath)
+	collection, err = m.meta.GetCollectionByName(ctx, "", testCollection)
+	assert.NoError(t, err)
+	assert.Equal(t, "none", collection.Fields[3].DefaultValue.GetStringData())
+	assert.Equal(t, int32(-3), collection.Fields[2].DefaultValue.GetIntData())
+	assert.Nil(t, collection.Fields[0].DefaultValue)
+}
+
+func TestNullable(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	nullable := &commonpb.KeyValuePair{Key: "nullable", Value: "true"}
+	pk := &schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true}
+	vec := &schemapb.FieldSchema{Name: "vec", DataType: schemapb.DataType_FloatVector,
+		TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}}
+	create := func(fields ...*schemapb.FieldSchema) *commonpb.Status {
+		schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: testCollection, Fields: fields})
+		assert.NoError(t, err)
+		status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: testCollection, Schema: schema})
+		assert.NoError(t, err)
+		return status
+	}
+	for i, fields := range [][]*schemapb.FieldSchema{
+		{{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true, TypeParams: []*commonpb.KeyValuePair{nullable}}, vec},
+		{pk, {Name: "vec", DataType: schemapb.DataType_FloatVector,
+			TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}, nullable}}},
+		{pk, vec, {Name: "a", DataType: schemapb.DataType_Int64, IsPartitionKey: true, TypeParams: []*commonpb.KeyValuePair{nullable}}},
+		{pk, vec, {Name: "a", DataType: schemapb.DataType_Int64, TypeParams: []*commonpb.KeyValuePair{{Key: "nullable", Value: "x"}}}},
+	} {
+		status := create(fields...)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, "case %d: %s", i, status.GetReason())
+	}
+	status := create(pk, vec,
+		&schemapb.FieldSchema{Name: "age", DataType: schemapb.DataType_Int64, TypeParams: []*commonpb.KeyValuePair{nullable}},
+		&schemapb.FieldSchema{Name: "note", DataType: schemapb.DataType_VarChar,
+			TypeParams:   []*commonpb.KeyValuePair{{Key: common.MaxLengthKey, Value: "8"}, nullable},
+			DefaultValue: &schemapb.ValueField{Data: &schemapb.ValueField_StringData{StringData: "none"}}},
+		&schemapb.FieldSchema{Name: "info", DataType: schemapb.DataType_JSON, TypeParams: []*commonpb.KeyValuePair{nullable}})
+	assert.True(t, merr.Ok(status), status.GetReason())
+
+	bools := func(name string, values ...bool) *schemapb.FieldData {
+		return &schemapb.FieldData{FieldName: name, Type: schemapb.DataType_Bool, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_BoolData{BoolData: &schemapb.BoolArray{Data: values}}}}}
+	}
+	insert := func(columns ...*schemapb.FieldData) *milvuspb.MutationResult {
+		resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+			CollectionName: testCollection,
+			NumRows:        4,
+			FieldsData: append([]*schemapb.FieldData{
+				{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: []int64{1, 2, 3, 4}}}}}},
+				{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+					Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: make([]float32, 8)}}}}},
+			}, columns...),
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	// info is omitted so it's all null, the null note is filled with the default value
+	resp := insert(
+		&schemapb.FieldData{FieldName: "age", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: []int64{10, 0, 30, 0}}}}}},
+		bools("age"+ValidFieldSuffix, true, false, true, false),
+		&schemapb.FieldData{FieldName: "note", Type: schemapb.DataType_VarChar, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: []string{"a", "", "c", "d"}}}}}},
+		bools("note"+ValidFieldSuffix, true, false, true, true))
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	for _, columns := range [][]*schemapb.FieldData{
+		{bools("pk"+ValidFieldSuffix, true, true, true, true)},
+		{bools("age"+ValidFieldSuffix, true, true, true, true)},
+		{bools("info"+ValidFieldSuffix, true), &schemapb.FieldData{FieldName: "info", Type: schemapb.DataType_JSON,
+			Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{Data: &schemapb.ScalarField_JsonData{
+				JsonData: &schemapb.JSONArray{Data: [][]byte{[]byte("{}"), []byte("{}"), []byte("{}"), []byte("{}")}}}}}}},
+	} {
+		resp := insert(columns...)
+		assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, resp.GetStatus().GetReason())
+	}
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	check := func() {
+		queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "age is null or age > 20",
+			OutputFields: []string{"age", "note", "info"}})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(queryResp.GetStatus()), queryResp.GetStatus().GetReason())
+		fields := make(map[string]*schemapb.FieldData)
+		for _, fieldData := range queryResp.GetFieldsData() {
+			fields[fieldData.GetFieldName()] = fieldData
+		}
+		assert.Equal(t, []int64{2, 3, 4}, fields["pk"].GetScalars().GetLongData().GetData())
+		assert.Equal(t, []int64{0, 30, 0}, fields["age"].GetScalars().GetLongData().GetData())
+		assert.Equal(t, []bool{false, true, false}, fields["age"+ValidFieldSuffix].GetScalars().GetBoolData().GetData())
+		assert.Equal(t, []string{"none", "c", "d"}, fields["note"].GetScalars().GetStringData().GetData())
+		assert.Equal(t, []bool{true, true, true}, fields["note"+ValidFieldSuffix].GetScalars().GetBoolData().GetData())
+		assert.Equal(t, []bool{false, false, false}, fields["info"+ValidFieldSuffix].GetScalars().GetBoolData().GetData())
+
+		queryResp, err = m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "age != 10"})
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3}, queryResp.GetFieldsData()[0].GetScalars().GetLongData().GetData())
+	}
+	check()
+
+	// nulls are kept in binlogs
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	m = newTestMilvusMini(t, rootPath)
+	waitLoaded(t, m)
+	check()
+}
+
+func TestArray(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	pk := &schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true}
+	vec := &schemapb.FieldSchema{Name: "vec", DataType: schemapb.DataType_FloatVector,
+		TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}}
+	create := func(fields ...*schemapb.FieldSchema) *commonpb.Status {
+		schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: testCollection, Fields: fields})
+		assert.NoError(t, err)
+		status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: testCollection, Schema: schema})
+		assert.NoError(t, err)
+		return status
+	}
+	for i, field := range []*schemapb.FieldSchema{
+		{Name: "a", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_Int64},
+		{Name: "a", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_Int64,
+			TypeParams: []*commonpb.KeyValuePair{{Key: MaxCapacityKey, Value: "4097"}}},
+		{Name: "a", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_VarChar,
+			TypeParams: []*commonpb.KeyValuePair{{Key: MaxCapacityKey, Value: "4"}}},
+		{Name: "a", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_JSON,
+			TypeParams: []*commonpb.KeyValuePair{{Key: MaxCapacityKey, Value: "4"}}},
+		{Name: "a", DataType: schemapb.DataType_Array,
+			TypeParams: []*commonpb.KeyValuePair{{Key: MaxCapacityKey, Value: "4"}, {Key: ElementTypeKey, Value: "Unknown"}}},
+	} {
+		status := create(pk, vec, field)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, "case %d: %s", i, status.GetReason())
+	}
+	// the element type of tags is given by the type param
+	status := create(pk, vec,
+		&schemapb.FieldSchema{Name: "nums", DataType: schemapb.DataType_Array, ElementType: schemapb.DataType_Int16,
+			TypeParams: []*commonpb.KeyValuePair{{Key: MaxCapacityKey, Value: "3"}}},
+		&schemapb.FieldSchema{Name: "tags", DataType: schemapb.DataType_Array, TypeParams: []*commonpb.KeyValuePair{
+			{Key: MaxCapacityKey, Value: "2"}, {Key: ElementTypeKey, Value: "VarChar"}, {Key: common.MaxLengthKey, Value: "4"}}})
+	assert.True(t, merr.Ok(status), status.GetReason())
+
+	ints := func(v ...int32) *schemapb.ScalarField {
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_IntData{IntData: &schemapb.IntArray{Data: v}}}
+	}
+	strs := func(v ...string) *schemapb.ScalarField {
+		return &schemapb.ScalarField{Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: v}}}
+	}
+	array := func(name string, elementType schemapb.DataType, rows ...*schemapb.ScalarField) *schemapb.FieldData {
+		return &schemapb.FieldData{FieldName: name, Type: schemapb.DataType_Array, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_ArrayData{ArrayData: &schemapb.ArrayArray{Data: rows, ElementType: elementType}}}}}
+	}
+	insert := func(nums, tags *schemapb.FieldData) *milvuspb.MutationResult {
+		resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+			CollectionName: testCollection,
+			NumRows:        3,
+			FieldsData: []*schemapb.FieldData{
+				{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: []int64{1, 2, 3}}}}}},
+				{FieldName: "vec", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+					Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: make([]float32, 6)}}}}},
+				nums, tags,
+			},
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	tags := array("tags", schemapb.DataType_VarChar, strs("red", "blue"), strs("red"), strs())
+	for i, nums := range []*schemapb.FieldData{
+		array("nums", schemapb.DataType_Int16, ints(1, 2, 3, 4), ints(), ints()),
+		array("nums", schemapb.DataType_Int16, ints(1<<16), ints(), ints()),
+		array("nums", schemapb.DataType_Int16, strs("1"), ints(), ints()),
+	} {
+		resp := insert(nums, tags)
+		assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, "case %d: %s", i, resp.GetStatus().GetReason())
+	}
+	resp := insert(array("nums", schemapb.DataType_Int16, ints(1, 2, 3), ints(2), ints()),
+		array("tags", schemapb.DataType_VarChar, strs("green"), strs(), strs()))
+	assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, resp.GetStatus().GetReason())
+	resp = insert(array("nums", schemapb.DataType_Int16, ints(1, 2, 3), ints(2), ints()), tags)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	for expr, expected := range map[string][]int64{
+		"array_contains(nums, 2)":                       {1, 2},
+		"array_contains_all(tags, [\"red\", \"blue\"])": {1},
+		"array_contains_any(tags, [\"blue\", \"red\"])": {1, 2},
+		"array_length(nums) == 0":                       {3},
+		"nums[0] == 2 or tags[1] == \"blue\"":           {1, 2},
+	} {
+		queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: expr, OutputFields: []string{"nums"}})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(queryResp.GetStatus()), queryResp.GetStatus().GetReason())
+		for _, fieldData := range queryResp.GetFieldsData() {
+			if fieldData.GetFieldName() == "pk" {
+				assert.Equal(t, expected, fieldData.GetScalars().GetLongData().GetData(), expr)
+			}
+			if fieldData.GetFieldName() == "nums" {
+				assert.Len(t, fieldData.GetScalars().GetArrayData().GetData(), len(expected), expr)
+			}
+		}
+	}
+	queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "array_contains(nums, \"a\")"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(queryResp.GetStatus()), merr.ErrParameterInvalid)
+}
+
+func TestSparseVector(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	pk := &schemapb.FieldSchema{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true}
+	create := func(fields ...*schemapb.FieldSchema) *commonpb.Status {
+		schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: testCollection, Fields: fields})
+		assert.NoError(t, err)
+		status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: testCollection, Schema: schema})
+		assert.NoError(t, err)
+		return status
+	}
+	status := create(pk, &schemapb.FieldSchema{Name: "sparse", DataType: storage.DataType_SparseFloatVector,
+		TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "8"}}})
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, status.GetReason())
+	status = create(pk, &schemapb.FieldSchema{Name: "sparse", DataType: storage.DataType_SparseFloatVector})
+	assert.True(t, merr.Ok(status), status.GetReason())
+
+	insert := func(pks []int64, rows [][]byte) *milvuspb.MutationResult {
+		resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+			CollectionName: testCollection,
+			NumRows:        uint32(len(pks)),
+			FieldsData: []*schemapb.FieldData{
+				{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: pks}}}}},
+				{FieldName: "sparse", Type: storage.DataType_SparseFloatVector, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_BytesData{BytesData: &schemapb.BytesArray{Data: rows}}}}},
+			},
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	resp := insert([]int64{1}, [][]byte{storage.NewSparseRow(map[uint32]float32{1: -1})})
+	assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, resp.GetStatus().GetReason())
+	// the score of row i to query {0: 1} is i
+	pks := make([]int64, 0)
+	rows := make([][]byte, 0)
+	for i := int64(1); i <= 20; i++ {
+		pks = append(pks, i)
+		rows = append(rows, storage.NewSparseRow(map[uint32]float32{0: float32(i), uint32(100 + i): 1}))
+	}
+	resp = insert(pks, rows)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+
+	status, err = m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+		CollectionName: testCollection,
+		FieldName:      "sparse",
+		ExtraParams:    []*commonpb.KeyValuePair{{Key: common.IndexTypeKey, Value: index.IndexTypeSparseInverted}, {Key: common.MetricTypeKey, Value: "L2"}},
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, status.GetReason())
+	status, err = m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+		CollectionName: testCollection,
+		FieldName:      "sparse",
+		ExtraParams: []*commonpb.KeyValuePair{
+			{Key: common.IndexTypeKey, Value: index.IndexTypeSparseWand},
+			{Key: common.IndexParamsKey, Value: `{"drop_ratio_build": 0.1}`},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	waitIndexed(t, m)
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	newSparseSearchRequest := func(expr string, row []byte) *milvuspb.SearchRequest {
+		placeholder := &commonpb.PlaceholderValue{Tag: "$0", Type: storage.PlaceholderType_SparseFloatVector, Values: [][]byte{row}}
+		group, err := proto.Marshal(&commonpb.PlaceholderGroup{Placeholders: []*commonpb.PlaceholderValue{placeholder}})
+		assert.NoError(t, err)
+		return &milvuspb.SearchRequest{
+			CollectionName:   testCollection,
+			Dsl:              expr,
+			PlaceholderGroup: group,
+			OutputFields:     []string{"sparse"},
+			SearchParams: []*commonpb.KeyValuePair{
+				{Key: common.TopKKey, Value: "3"},
+				{Key: common.SearchParamKey, Value: `{"drop_ratio_search": 0.2}`},
+			},
+		}
+	}
+	check := func() {
+		searchResp, err := m.Search(ctx, newSparseSearchRequest("pk != 19", storage.NewSparseRow(map[uint32]float32{0: 1})))
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+		assert.Equal(t, []int64{20, 18, 17}, searchResp.GetResults().GetIds().GetIntId().GetData())
+		assert.Equal(t, []float32{20, 18, 17}, searchResp.GetResults().GetScores())
+		sparse := searchResp.GetResults().GetFieldsData()[0]
+		assert.Equal(t, storage.DataType_SparseFloatVector, sparse.GetType())
+		assert.Equal(t, [][]byte{rows[19], rows[17], rows[16]}, sparse.GetScalars().GetBytesData().GetData())
+
+		searchResp, err = m.Search(ctx, newSparseSearchRequest("", []byte{1, 2, 3}))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+	}
+	check()
+
+	m = newTestMilvusMini(t, rootPath)
+	waitLoaded(t, m)
+	check()
+}
+
+func TestHalfVectors(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	dimParams := []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}
+	schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: testCollection, Fields: []*schemapb.FieldSchema{
+		{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+		{Name: "f16", DataType: schemapb.DataType_Float16Vector, TypeParams: dimParams},
+		{Name: "bf16", DataType: storage.DataType_BFloat16Vector, TypeParams: dimParams},
+	}})
+	assert.NoError(t, err)
+	status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: testCollection, Schema: schema})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+
+	// vectors of row i are (i, i), which are exact in both float16 & bfloat16
+	insert := func(start, num int64, bfloat16 []byte) *milvuspb.MutationResult {
+		pks := make([]int64, 0, num)
+		values := make([]float32, 0, num*2)
+		for i := start; i < start+num; i++ {
+			pks = append(pks, i)
+			values = append(values, float32(i), float32(i))
+		}
+		if bfloat16 == nil {
+			bfloat16 = storage.Float32sToBFloat16(values)
+		}
+		resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+			CollectionName: testCollection,
+			NumRows:        uint32(num),
+			FieldsData: []*schemapb.FieldData{
+				{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: pks}}}}},
+				{FieldName: "f16", Type: schemapb.DataType_Float16Vector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+					Dim: 2, Data: &schemapb.VectorField_Float16Vector{Float16Vector: storage.Float32sToFloat16(values)}}}},
+				{FieldName: "bf16", Type: storage.DataType_BFloat16Vector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+					Dim: 2, Data: &schemapb.VectorField_Float16Vector{Float16Vector: bfloat16}}}},
+			},
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	resp := insert(0, 2, []byte{1, 2, 3})
+	assert.ErrorIs(t, merr.Error(resp.GetStatus()), merr.ErrParameterInvalid, resp.GetStatus().GetReason())
+	resp = insert(0, 20, nil)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+
+	for field, indexType := range map[string]string{"f16": index.IndexTypeFlat, "bf16": index.IndexTypeHNSW} {
+		status, err = m.CreateIndex(ctx, &milvuspb.CreateIndexRequest{
+			CollectionName: testCollection,
+			FieldName:      field,
+			ExtraParams:    []*commonpb.KeyValuePair{{Key: common.IndexTypeKey, Value: indexType}, {Key: common.MetricTypeKey, Value: "L2"}},
+		})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status), status.GetReason())
+	}
+	waitIndexed(t, m)
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+	// rows of the growing segment are searched as well
+	resp = insert(20, 10, nil)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+
+	newHalfSearchRequest := func(field string, placeholderType commonpb.PlaceholderType, value []byte) *milvuspb.SearchRequest {
+		placeholder := &commonpb.PlaceholderValue{Tag: "$0", Type: placeholderType, Values: [][]byte{value}}
+		group, err := proto.Marshal(&commonpb.PlaceholderGroup{Placeholders: []*commonpb.PlaceholderValue{placeholder}})
+		assert.NoError(t, err)
+		return &milvuspb.SearchRequest{
+			CollectionName:   testCollection,
+			Dsl:              "pk != 21",
+			PlaceholderGroup: group,
+			OutputFields:     []string{"f16", "bf16"},
+			SearchParams: []*commonpb.KeyValuePair{
+				{Key: AnnsFieldKey, Value: field},
+				{Key: common.TopKKey, Value: "3"},
+				{Key: common.MetricTypeKey, Value: "L2"},
+			},
+		}
+	}
+	check := func() {
+		for field, request := range map[string]*milvuspb.SearchRequest{
+			"f16":  newHalfSearchRequest("f16", commonpb.PlaceholderType_Float16Vector, storage.Float32sToFloat16([]float32{21.25, 21.25})),
+			"bf16": newHalfSearchRequest("bf16", storage.PlaceholderType_BFloat16Vector, storage.Float32sToBFloat16([]float32{21.25, 21.25})),
+		} {
+			searchResp, err := m.Search(ctx, request)
+			assert.NoError(t, err)
+			assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+			assert.Equal(t, []int64{22, 20, 23}, searchResp.GetResults().GetIds().GetIntId().GetData(), field)
+			assert.Equal(t, []float32{1.125, 3.125, 6.125}, searchResp.GetResults().GetScores(), field)
+			for _, fieldData := range searchResp.GetResults().GetFieldsData() {
+				if fieldData.GetFieldName() == "f16" {
+					assert.Equal(t, schemapb.DataType_Float16Vector, fieldData.GetType())
+					assert.Equal(t, storage.Float32sToFloat16([]float32{22, 22, 20, 20, 23, 23}), fieldData.GetVectors().GetFloat16Vector())
+				} else {
+					assert.Equal(t, storage.DataType_BFloat16Vector, fieldData.GetType())
+					assert.Equal(t, storage.Float32sToBFloat16([]float32{22, 22, 20, 20, 23, 23}), fieldData.GetVectors().GetFloat16Vector())
+				}
+			}
+		}
+
+		// query vectors must match the field
+		searchResp, err := m.Search(ctx, newHalfSearchRequest("bf16", commonpb.PlaceholderType_Float16Vector, storage.Float32sToFloat16([]float32{1, 1})))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+		searchResp, err = m.Search(ctx, newHalfSearchRequest("f16", commonpb.PlaceholderType_Float16Vector, []byte{1, 2}))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+	}
+	check()
+
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	m = newTestMilvusMini(t, rootPath)
+	waitLoaded(t, m)
+	check()
+}
+
+func TestHybridSearch(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	dimParams := []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}
+	schema, err := proto.Marshal(&schemapb.CollectionSchema{Name: testCollection, Fields: []*schemapb.FieldSchema{
+		{Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+		{Name: "a", DataType: schemapb.DataType_FloatVector, TypeParams: dimParams},
+		{Name: "b", DataType: schemapb.DataType_FloatVector, TypeParams: dimParams},
+	}})
+	assert.NoError(t, err)
+	status, err := m.CreateCollection(ctx, &milvuspb.CreateCollectionRequest{CollectionName: testCollection, Schema: schema})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+
+	// a of row i is (i, 0), b of row i is (9 - i, 0)
+	pks := make([]int64, 0)
+	a := make([]float32, 0)
+	b := make([]float32, 0)
+	for i := 0; i < 10; i++ {
+		pks = append(pks, int64(i))
+		a = append(a, float32(i), 0)
+		b = append(b, float32(9-i), 0)
+	}
+	resp, err := m.Insert(ctx, &milvuspb.InsertRequest{
+		CollectionName: testCollection,
+		NumRows:        10,
+		FieldsData: []*schemapb.FieldData{
+			{FieldName: "pk", Type: schemapb.DataType_Int64, Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+				Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: pks}}}}},
+			{FieldName: "a", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+				Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: a}}}}},
+			{FieldName: "b", Type: schemapb.DataType_FloatVector, Field: &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+				Dim: 2, Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: b}}}}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+	status, err = m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+
+	newRequest := func(field string, expr string, vectors ...[]float32) *milvuspb.SearchRequest {
+		req := newSearchRequest(t, expr, "3", vectors...)
+		req.SearchParams[0].Value = field
+		return req
+	}
+	hybridSearch := func(rankParams map[string]string, requests ...*milvuspb.SearchRequest) *milvuspb.SearchResults {
+		req := &HybridSearchRequest{CollectionName: testCollection, Requests: requests, OutputFields: []string{"a"}}
+		for key, value := range rankParams {
+			req.RankParams = append(req.RankParams, &commonpb.KeyValuePair{Key: key, Value: value})
+		}
+		resp, err := m.HybridSearch(ctx, req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	// a hits 0, 1, 2, b hits 2, 1, 3
+	searchResp := hybridSearch(map[string]string{LimitKey: "3"}, newRequest("a", "", []float32{0, 0}), newRequest("b", "", []float32{7.2, 0}))
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	assert.Equal(t, []int64{2, 1, 0}, searchResp.GetResults().GetIds().GetIntId().GetData())
+	assert.InDeltaSlice(t, []float32{1.0/63 + 1.0/61, 2.0 / 62, 1.0 / 61}, searchResp.GetResults().GetScores(), 1e-6)
+	assert.Equal(t, []float32{2, 0, 1, 0, 0, 0}, searchResp.GetResults().GetFieldsData()[0].GetVectors().GetFloatVector().GetData())
+
+	// filters apply to their own searches, b hits 1, 3, 0 without 2
+	searchResp = hybridSearch(map[string]string{RankTypeKey: RRFRankType, RankParamsKey: `{"k": 60}`, LimitKey: "3"},
+		newRequest("a", "", []float32{0, 0}), newRequest("b", "pk != 2", []float32{7.2, 0}))
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	assert.Equal(t, []int64{1, 0, 3}, searchResp.GetResults().GetIds().GetIntId().GetData())
+
+	// distances of a are 0, 1, 4, distances of b are 0.04, 0.64, 1.44
+	searchResp = hybridSearch(map[string]string{RankTypeKey: WeightedRankType, RankParamsKey: `{"weights": [0.8, 0.2]}`, LimitKey: "3", OffsetKey: "1"},
+		newRequest("a", "", []float32{0, 0}), newRequest("b", "", []float32{7.2, 0}))
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	assert.Equal(t, []int64{1, 2, 3}, searchResp.GetResults().GetIds().GetIntId().GetData())
+
+	// multiple query vectors are fused query by query
+	searchResp = hybridSearch(map[string]string{LimitKey: "1"},
+		newRequest("a", "", []float32{0, 0}, []float32{9, 0}), newRequest("b", "", []float32{9, 0}, []float32{0, 0}))
+	assert.True(t, merr.Ok(searchResp.GetStatus()), searchResp.GetStatus().GetReason())
+	assert.Equal(t, []int64{0, 9}, searchResp.GetResults().GetIds().GetIntId().GetData())
+	assert.Equal(t, []int64{1, 1}, searchResp.GetResults().GetTopks())
+
+	for _, rankParams := range []map[string]string{
+		{},
+		{LimitKey: "3", RankTypeKey: "unknown"},
+		{LimitKey: "3", RankTypeKey: WeightedRankType, RankParamsKey: `{"weights": [1]}`},
+		{LimitKey: "3", RankTypeKey: WeightedRankType, RankParamsKey: `{"weights": [1, 2]}`},
+		{LimitKey: "3", RankParamsKey: `{"k": 0}`},
+	} {
+		searchResp = hybridSearch(rankParams, newRequest("a", "", []float32{0, 0}), newRequest("b", "", []float32{7.2, 0}))
+		assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid, rankParams)
+	}
+	searchResp = hybridSearch(map[string]string{LimitKey: "3"}, newRequest("a", "", []float32{0, 0}), newRequest("b", "", []float32{7.2, 0}, []float32{1, 0}))
+	assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+	searchResp = hybridSearch(map[string]string{LimitKey: "3"})
+	assert.ErrorIs(t, merr.Error(searchResp.GetStatus()), merr.ErrParameterInvalid)
+}
+
+func TestConsistency(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	createTestCollection(t, m)
+	status, err := m.LoadCollection(ctx, &milvuspb.LoadCollectionRequest{CollectionName: testCollection})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status))
+	waitLoaded(t, m)
+	count := func(req *milvuspb.QueryRequest) int64 {
+		req.CollectionName = testCollection
+		req.OutputFields = []string{CountStar}
+		resp, err := m.Query(ctx, req)
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+		return resp.GetFieldsData()[0].GetScalars().GetLongData().GetData()[0]
+	}
+
+	// time travel to the rows before the delete & insert
+	insertTestRows(t, m, 0, 10)
+	insertTs, err := m.tsAllocator.AllocTimestamp()
+	assert.NoError(t, err)
+	deleteResp, err := m.Delete(ctx, &milvuspb.DeleteRequest{CollectionName: testCollection, Expr: "pk in [0, 1]"})
+	assert.NoError(t, err)
+	deleteTs := deleteResp.GetTimestamp()
+	insertTestRows(t, m, 10, 5)
+	assert.Equal(t, int64(13), count(&milvuspb.QueryRequest{}))
+	assert.Equal(t, int64(10), count(&milvuspb.QueryRequest{TravelTimestamp: insertTs}))
+	assert.Equal(t, int64(8), count(&milvuspb.QueryRequest{TravelTimestamp: deleteTs}))
+	_, err = m.Flush(ctx, &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), count(&milvuspb.QueryRequest{TravelTimestamp: insertTs}))
+	searchReq := newSearchRequest(t, "", "1", []float32{0, 0})
+	searchReq.TravelTimestamp = insertTs
+	searchResp, err := m.Search(ctx, searchReq)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0}, searchResp.GetResults().GetIds().GetIntId().GetData())
+	searchReq.TravelTimestamp = 0
+	searchResp, err = m.Search(ctx, searchReq)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, searchResp.GetResults().GetIds().GetIntId().GetData())
+	queryResp, err := m.Query(ctx, &milvuspb.QueryRequest{CollectionName: testCollection, Expr: "pk > 0",
+		TravelTimestamp: insertTs + 1<<30})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(queryResp.GetStatus()), merr.ErrParameterInvalid)
+
+	// reads wait for the writes in flight before their guarantee timestamps
+	writeTs, done, err := m.segmentManager.BeginWrite(m.tsAllocator)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), count(&milvuspb.QueryRequest{ConsistencyLevel: commonpb.ConsistencyLevel_Eventually}))
+	assert.Equal(t, int64(13), count(&milvuspb.QueryRequest{ConsistencyLevel: commonpb.ConsistencyLevel_Bounded}))
+	assert.Equal(t, int64(13), count(&milvuspb.QueryRequest{ConsistencyLevel: commonpb.ConsistencyLevel_Session,
+		GuaranteeTimestamp: writeTs - 1}))
+	assert.Equal(t, int64(10), count(&milvuspb.QueryRequest{ConsistencyLevel: commonpb.ConsistencyLevel_Strong,
+		TravelTimestamp: insertTs}))
+	for _, req := range []*milvuspb.QueryRequest{
+		{ConsistencyLevel: commonpb.ConsistencyLevel_Strong},
+		{ConsistencyLevel: commonpb.ConsistencyLevel_Session, GuaranteeTimestamp: writeTs},
+		// the collection is of strong consistency
+		{UseDefaultConsistency: true, ConsistencyLevel: commonpb.ConsistencyLevel_Eventually},
+	} {
+		req.CollectionName = testCollection
+		req.Expr = "pk > 0"
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		queryResp, err = m.Query(timeoutCtx, req)
+		cancel()
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(queryResp.GetStatus()), merr.ErrServiceUnavailable)
+	}
+	counted := make(chan int64)
+	go func() {
+		counted <- count(&milvuspb.QueryRequest{ConsistencyLevel: commonpb.ConsistencyLevel_Strong})
+	}()
+	select {
+	case <-counted:
+		t.Fatal("strong read doesn't wait for the write in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	done()
+	assert.Equal(t, int64(13), <-counted)
+}
+
+func TestCredential(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	listUsers := func() []string {
+		resp, err := m.ListCredUsers(ctx, &milvuspb.ListCredUsersRequest{})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+		return resp.GetUsernames()
+	}
+	// root is created on first start
+	assert.Equal(t, []string{"root"}, listUsers())
+
+	status, err := m.CreateCredential(ctx, &milvuspb.CreateCredentialRequest{Username: "alice", Password: crypto.Base64Encode("123456")})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	for _, req := range []*milvuspb.CreateCredentialRequest{
+		{Username: "alice", Password: crypto.Base64Encode("123456")},
+		{Username: "1bob", Password: crypto.Base64Encode("123456")},
+		{Username: "bob-1", Password: crypto.Base64Encode("123456")},
+		{Username: "bob", Password: crypto.Base64Encode("12345")},
+		{Username: "bob", Password: "not base64"},
+	} {
+		status, err = m.CreateCredential(ctx, req)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, req.String())
+	}
+	assert.Equal(t, []string{"alice", "root"}, listUsers())
+
+	authenticator := NewAuthenticator(m.meta)
+	interceptor := authenticator.UnaryServerInterceptor()
+	call := func(username, password string) error {
+		md := metadata.Pairs(util.HeaderAuthorize, crypto.Base64Encode(username+":"+password))
+		_, err := interceptor(metadata.NewIncomingContext(ctx, md), nil, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, req any) (any, error) { return nil, nil })
+		return err
+	}
+	assert.NoError(t, call("alice", "123456"))
+	assert.NoError(t, call("alice", "123456"))
+	assert.NoError(t, call("root", "Milvus"))
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(call("alice", "1234567")))
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(call("carol", "123456")))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) { return nil, nil })
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(err))
+
+	// the old password must be correct
+	status, err = m.UpdateCredential(ctx, &milvuspb.UpdateCredentialRequest{Username: "alice",
+		OldPassword: crypto.Base64Encode("1234567"), NewPassword: crypto.Base64Encode("abcdef")})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid)
+	status, err = m.UpdateCredential(ctx, &milvuspb.UpdateCredentialRequest{Username: "alice",
+		OldPassword: crypto.Base64Encode("123456"), NewPassword: crypto.Base64Encode("abcdef")})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(call("alice", "123456")))
+	assert.NoError(t, call("alice", "abcdef"))
+
+	// credentials are persisted
+	m = newTestMilvusMini(t, rootPath)
+	authenticator = NewAuthenticator(m.meta)
+	interceptor = authenticator.UnaryServerInterceptor()
+	assert.NoError(t, call("alice", "abcdef"))
+	assert.NoError(t, call("root", "Milvus"))
+
+	status, err = m.DeleteCredential(ctx, &milvuspb.DeleteCredentialRequest{Username: "root"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid)
+	status, err = m.DeleteCredential(ctx, &milvuspb.DeleteCredentialRequest{Username: "alice"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.Equal(t, []string{"root"}, listUsers())
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(call("alice", "abcdef")))
+}
+
+func TestRBAC(t *testing.T) {
+	ctx := context.Background()
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	m := newTestMilvusMini(t, rootPath)
+	for _, username := range []string{"alice", "bob"} {
+		status, err := m.CreateCredential(ctx, &milvuspb.CreateCredentialRequest{Username: username, Password: crypto.Base64Encode("123456")})
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(status), status.GetReason())
+	}
+	selectRoles := func(req *milvuspb.SelectRoleRequest) []*milvuspb.RoleResult {
+		resp, err := m.SelectRole(ctx, req)
+		assert.NoError(t, err)
+		assert.True(t, merr.Ok(resp.GetStatus()), resp.GetStatus().GetReason())
+		return resp.GetResults()
+	}
+	// the built-in roles are created on first start
+	assert.Equal(t, []*milvuspb.RoleResult{{Role: &milvuspb.RoleEntity{Name: "admin"}}, {Role: &milvuspb.RoleEntity{Name: "public"}}},
+		selectRoles(&milvuspb.SelectRoleRequest{}))
+
+	status, err := m.CreateRole(ctx, &milvuspb.CreateRoleRequest{Entity: &milvuspb.RoleEntity{Name: "team_a"}})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	for _, name := range []string{"team_a", "", "team-a"} {
+		status, err = m.CreateRole(ctx, &milvuspb.CreateRoleRequest{Entity: &milvuspb.RoleEntity{Name: name}})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, name)
+	}
+	status, err = m.OperateUserRole(ctx, &milvuspb.OperateUserRoleRequest{Username: "alice", RoleName: "team_a"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	status, err = m.OperateUserRole(ctx, &milvuspb.OperateUserRoleRequest{Username: "carol", RoleName: "team_a"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid)
+	status, err = m.OperateUserRole(ctx, &milvuspb.OperateUserRoleRequest{Username: "bob", RoleName: "team_b"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid)
+	assert.Equal(t, []*milvuspb.RoleResult{{Role: &milvuspb.RoleEntity{Name: "team_a"}, Users: []*milvuspb.UserEntity{{Name: "alice"}}}},
+		selectRoles(&milvuspb.SelectRoleRequest{Role: &milvuspb.RoleEntity{Name: "team_a"}, IncludeUserInfo: true}))
+	userResp, err := m.SelectUser(ctx, &milvuspb.SelectUserRequest{User: &milvuspb.UserEntity{Name: "alice"}, IncludeRoleInfo: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []*milvuspb.UserResult{{User: &milvuspb.UserEntity{Name: "alice"}, Roles: []*milvuspb.RoleEntity{{Name: "team_a"}}}},
+		userResp.GetResults())
+
+	operatePrivilege := func(roleName, objectType, objectName, privilege string, operateType milvuspb.OperatePrivilegeType) *commonpb.Status {
+		status, err := m.OperatePrivilege(context.WithValue(ctx, userKey{}, "root"), &milvuspb.OperatePrivilegeRequest{
+			Entity: &milvuspb.GrantEntity{
+				Role:       &milvuspb.RoleEntity{Name: roleName},
+				Object:     &milvuspb.ObjectEntity{Name: objectType},
+				ObjectName: objectName,
+				Grantor:    &milvuspb.GrantorEntity{Privilege: &milvuspb.PrivilegeEntity{Name: privilege}},
+			},
+			Type: operateType,
+		})
+		assert.NoError(t, err)
+		return status
+	}
+	status = operatePrivilege("team_a", "Collection", testCollection, "Search", milvuspb.OperatePrivilegeType_Grant)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	for _, status = range []*commonpb.Status{
+		operatePrivilege("team_b", "Collection", testCollection, "Search", milvuspb.OperatePrivilegeType_Grant),
+		operatePrivilege("team_a", "Table", testCollection, "Search", milvuspb.OperatePrivilegeType_Grant),
+		operatePrivilege("team_a", "Collection", "", "Search", milvuspb.OperatePrivilegeType_Grant),
+		operatePrivilege("team_a", "Collection", testCollection, "CreateCollection", milvuspb.OperatePrivilegeType_Grant),
+		// names escaping the grant key
+		operatePrivilege("team_a", "Collection", "../../x", "Search", milvuspb.OperatePrivilegeType_Grant),
+		operatePrivilege("team_a", "Collection", "a/b", "Search", milvuspb.OperatePrivilegeType_Revoke),
+	} {
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid)
+	}
+	status, err = m.OperatePrivilege(ctx, &milvuspb.OperatePrivilegeRequest{
+		Entity: &milvuspb.GrantEntity{
+			Role:       &milvuspb.RoleEntity{Name: "team_a"},
+			Object:     &milvuspb.ObjectEntity{Name: "Collection"},
+			ObjectName: testCollection,
+			DbName:     "../x",
+			Grantor:    &milvuspb.GrantorEntity{Privilege: &milvuspb.PrivilegeEntity{Name: "Search"}},
+		},
+		Type: milvuspb.OperatePrivilegeType_Grant,
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid)
+	grantResp, err := m.SelectGrant(ctx, &milvuspb.SelectGrantRequest{Entity: &milvuspb.GrantEntity{Role: &milvuspb.RoleEntity{Name: "team_a"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []*milvuspb.GrantEntity{{
+		Role:       &milvuspb.RoleEntity{Name: "team_a"},
+		Object:     &milvuspb.ObjectEntity{Name: "Collection"},
+		ObjectName: testCollection,
+		DbName:     "default",
+		Grantor: &milvuspb.GrantorEntity{
+			User:      &milvuspb.UserEntity{Name: "root"},
+			Privilege: &milvuspb.PrivilegeEntity{Name: "Search"},
+		},
+	}}, grantResp.GetEntities())
+
+	interceptor := NewPrivilegeChecker(m.meta).UnaryServerInterceptor()
+	check := func(username string, req any) error {
+		_, err := interceptor(context.WithValue(ctx, userKey{}, username), req, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, req any) (any, error) { return nil, nil })
+		return err
+	}
+	assert.NoError(t, check("alice", &milvuspb.SearchRequest{CollectionName: testCollection}))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.SearchRequest{CollectionName: "other"})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.SearchRequest{CollectionName: testCollection, DbName: "db"})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.QueryRequest{CollectionName: testCollection})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("bob", &milvuspb.SearchRequest{CollectionName: testCollection})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.CreateCollectionRequest{CollectionName: "other"})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})))
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(NewPrivilegeChecker(m.meta).Check(ctx, &milvuspb.SearchRequest{})))
+	// privileges of public role, requests without privilege, users themselves and their own roles
+	assert.NoError(t, check("bob", &milvuspb.ShowCollectionsRequest{}))
+	assert.NoError(t, check("bob", &milvuspb.DescribeIndexRequest{CollectionName: testCollection}))
+	assert.NoError(t, check("bob", &milvuspb.GetVersionRequest{}))
+	assert.NoError(t, check("bob", &milvuspb.UpdateCredentialRequest{Username: "bob"}))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("bob", &milvuspb.UpdateCredentialRequest{Username: "alice"})))
+	assert.NoError(t, check("alice", &milvuspb.SelectGrantRequest{Entity: &milvuspb.GrantEntity{Role: &milvuspb.RoleEntity{Name: "team_a"}}}))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(
+		check("alice", &milvuspb.SelectGrantRequest{Entity: &milvuspb.GrantEntity{Role: &milvuspb.RoleEntity{Name: "admin"}}})))
+	// root and admins have all privileges
+	assert.NoError(t, check("root", &milvuspb.CreateCollectionRequest{CollectionName: "other"}))
+	status, err = m.OperateUserRole(ctx, &milvuspb.OperateUserRoleRequest{Username: "bob", RoleName: "admin"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.NoError(t, check("bob", &milvuspb.CreateCollectionRequest{CollectionName: "other"}))
+
+	// privilege All grants all privileges on the objects of the grant
+	status = operatePrivilege("team_a", "Collection", "*", "*", milvuspb.OperatePrivilegeType_Grant)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.NoError(t, check("alice", &milvuspb.FlushRequest{CollectionNames: []string{testCollection, "other"}}))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.CreateCollectionRequest{CollectionName: "other"})))
+	status = operatePrivilege("team_a", "Collection", "*", "All", milvuspb.OperatePrivilegeType_Revoke)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.FlushRequest{CollectionNames: []string{testCollection}})))
+	status = operatePrivilege("team_a", "Collection", testCollection, "*", milvuspb.OperatePrivilegeType_Grant)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.NoError(t, check("alice", &milvuspb.QueryRequest{CollectionName: testCollection}))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.SearchRequest{CollectionName: "other"})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.SearchRequest{CollectionName: testCollection, DbName: "db"})))
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.CreateCollectionRequest{CollectionName: "other"})))
+	status = operatePrivilege("team_a", "Collection", testCollection, "*", milvuspb.OperatePrivilegeType_Revoke)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.QueryRequest{CollectionName: testCollection})))
+
+	// roles, bindings & grants are persisted
+	m = newTestMilvusMini(t, rootPath)
+	interceptor = NewPrivilegeChecker(m.meta).UnaryServerInterceptor()
+	assert.NoError(t, check("alice", &milvuspb.SearchRequest{CollectionName: testCollection}))
+	assert.NoError(t, check("bob", &milvuspb.CreateCollectionRequest{CollectionName: "other"}))
+
+	for _, roleName := range []string{"admin", "public", "team_b"} {
+		status, err = m.DropRole(ctx, &milvuspb.DropRoleRequest{RoleName: roleName})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, merr.Error(status), merr.ErrParameterInvalid, roleName)
+	}
+	status, err = m.DropRole(ctx, &milvuspb.DropRoleRequest{RoleName: "team_a"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.Equal(t, codes.PermissionDenied, grpcstatus.Code(check("alice", &milvuspb.SearchRequest{CollectionName: testCollection})))
+	grants, err := m.meta.ListGrants(ctx, "team_a")
+	assert.NoError(t, err)
+	assert.Empty(t, grants)
+	// bindings of users deleted are removed
+	status, err = m.DeleteCredential(ctx, &milvuspb.DeleteCredentialRequest{Username: "bob"})
+	assert.NoError(t, err)
+	assert.True(t, merr.Ok(status), status.GetReason())
+	assert.Equal(t, []*milvuspb.RoleResult{{Role: &milvuspb.RoleEntity{Name: "admin"}}},
+		selectRoles(&milvuspb.SelectRoleRequest{Role: &milvuspb.RoleEntity{Name: "admin"}, IncludeUserInfo: true}))
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/milvus-io/milvus/pkg/common"
 
 	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
@@ -13,6 +16,7 @@ type Field struct {
 	IsPrimaryKey   bool
 	Description    string
 	DataType       schemapb.DataType
+	ElementType    schemapb.DataType // element type of Array field
 	TypeParams     []*commonpb.KeyValuePair
 	IndexParams    []*commonpb.KeyValuePair
 	AutoID         bool
@@ -33,6 +37,7 @@ func (f *Field) Clone() *Field {
 		IsPrimaryKey:   f.IsPrimaryKey,
 		Description:    f.Description,
 		DataType:       f.DataType,
+		ElementType:    f.ElementType,
 		TypeParams:     common.CloneKeyValuePairs(f.TypeParams),
 		IndexParams:    common.CloneKeyValuePairs(f.IndexParams),
 		AutoID:         f.AutoID,
@@ -43,6 +48,39 @@ func (f *Field) Clone() *Field {
 	}
 }
 
+// MarshalJSON marshals the default value by proto, as json can't tell the type of its oneof value on unmarshal
+func (f Field) MarshalJSON() ([]byte, error) {
+	type alias Field
+	var defaultValue []byte
+	if f.DefaultValue != nil {
+		var err error
+		if defaultValue, err = proto.Marshal(f.DefaultValue); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(&struct {
+		alias
+		DefaultValue []byte `json:",omitempty"`
+	}{alias(f), defaultValue})
+}
+
+func (f *Field) UnmarshalJSON(data []byte) error {
+	type alias Field
+	aux := &struct {
+		*alias
+		DefaultValue []byte
+	}{alias: (*alias)(f)}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	f.DefaultValue = nil
+	if aux.DefaultValue != nil {
+		f.DefaultValue = &schemapb.ValueField{}
+		return proto.Unmarshal(aux.DefaultValue, f.DefaultValue)
+	}
+	return nil
+}
+
 func CloneFields(fields []*Field) []*Field {
 	clone := make([]*Field, 0, len(fields))
 	for _, field := range fields {
@@ -62,12 +100,13 @@ func (f *Field) Equal(other Field) bool {
 		f.IsPrimaryKey == other.IsPrimaryKey &&
 		f.Description == other.Description &&
 		f.DataType == other.DataType &&
+		f.ElementType == other.ElementType &&
 		checkParamsEqual(f.TypeParams, other.TypeParams) &&
 		checkParamsEqual(f.IndexParams, other.IndexParams) &&
 		f.AutoID == other.AutoID &&
 		f.IsPartitionKey == other.IsPartitionKey &&
 		f.IsDynamic == other.IsDynamic &&
-		f.DefaultValue == other.DefaultValue
+		proto.Equal(f.DefaultValue, other.DefaultValue)
 }
 
 func CheckFieldsEqual(fieldsA, fieldsB []*Field) bool {
@@ -94,6 +133,7 @@ func MarshalFieldModel(field *Field) *schemapb.FieldSchema {
 		IsPrimaryKey:   field.IsPrimaryKey,
 		Description:    field.Description,
 		DataType:       field.DataType,
+		ElementType:    field.ElementType,
 		TypeParams:     field.TypeParams,
 		IndexParams:    field.IndexParams,
 		AutoID:         field.AutoID,
@@ -126,6 +166,7 @@ func UnmarshalFieldModel(fieldSchema *schemapb.FieldSchema) *Field {
 		IsPrimaryKey:   fieldSchema.IsPrimaryKey,
 		Description:    fieldSchema.Description,
 		DataType:       fieldSchema.DataType,
+		ElementType:    fieldSchema.ElementType,
 		TypeParams:     fieldSchema.TypeParams,
 		IndexParams:    fieldSchema.IndexParams,
 		AutoID:         fieldSchema.AutoID,
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus/pkg/common"
+	pb "github.com/sharding-db/milvus-mini/pkg/etcdpb"
+)
+
+// Index is an index of a field, it's built on each flushed segment of the collection
+type Index struct {
+	CollectionID int64
+	FieldID      int64
+	IndexID      int64
+	IndexName    string
+	IsDeleted    bool
+	CreateTime   uint64
+	// IndexParams are the flattened params, e.g. index_type, metric_type, nlist
+	IndexParams []*commonpb.KeyValuePair
+}
+
+func (i *Index) Clone() *Index {
+	return &Index{
+		CollectionID: i.CollectionID,
+		FieldID:      i.FieldID,
+		IndexID:      i.IndexID,
+		IndexName:    i.IndexName,
+		IsDeleted:    i.IsDeleted,
+		CreateTime:   i.CreateTime,
+		IndexParams:  common.CloneKeyValuePairs(i.IndexParams),
+	}
+}
+
+// GetParams returns the index params as a map
+func (i *Index) GetParams() map[string]string {
+	ret := make(map[string]string, len(i.IndexParams))
+	for _, kv := range i.IndexParams {
+		ret[kv.GetKey()] = kv.GetValue()
+	}
+	return ret
+}
+
+func (i *Index) GetIndexType() string {
+	return i.GetParams()[common.IndexTypeKey]
+}
+
+func (i *Index) GetMetricType() string {
+	return i.GetParams()[common.MetricTypeKey]
+}
+
+func UnmarshalIndexModel(collectionID int64, indexInfo *pb.IndexInfo, fieldIndex *pb.FieldIndexInfo) *Index {
+	if indexInfo == nil || fieldIndex == nil {
+		return nil
+	}
+	return &Index{
+		CollectionID: collectionID,
+		FieldID:      fieldIndex.FiledID,
+		IndexID:      indexInfo.IndexID,
+		IndexName:    indexInfo.IndexName,
+		IsDeleted:    indexInfo.Deleted,
+		CreateTime:   indexInfo.CreateTime,
+		IndexParams:  indexInfo.IndexParams,
+	}
+}
+
+func MarshalIndexModel(index *Index) (*pb.IndexInfo, *pb.FieldIndexInfo) {
+	if index == nil {
+		return nil, nil
+	}
+	return &pb.IndexInfo{
+		IndexName:   index.IndexName,
+		IndexID:     index.IndexID,
+		IndexParams: index.IndexParams,
+		Deleted:     index.IsDeleted,
+		CreateTime:  index.CreateTime,
+	}, &pb.FieldIndexInfo{
+		FiledID: index.FieldID,
+		IndexID: index.IndexID,
+	}
+}
+
+// SegmentIndex is the index built on a segment, the index files are stored under common.SegmentIndexPath
+type SegmentIndex struct {
+	CollectionID  int64
+	PartitionID   int64
+	SegmentID     int64
+	FieldID       int64
+	IndexID       int64
+	BuildID       int64
+	EnableIndex   bool
+	CreateTime    uint64
+	NumRows       int64
+	IndexFileKeys []string
+	IndexSize     int64
+}
+
+func (s *SegmentIndex) Clone() *SegmentIndex {
+	clone := *s
+	clone.IndexFileKeys = append([]string{}, s.IndexFileKeys...)
+	return &clone
+}
+
+func UnmarshalSegmentIndexModel(segmentIndex *pb.SegmentIndexInfo) *SegmentIndex {
+	if segmentIndex == nil {
+		return nil
+	}
+	return &SegmentIndex{
+		CollectionID: segmentIndex.CollectionID,
+		PartitionID:  segmentIndex.PartitionID,
+		SegmentID:    segmentIndex.SegmentID,
+		FieldID:      segmentIndex.FieldID,
+		IndexID:      segmentIndex.IndexID,
+		BuildID:      segmentIndex.BuildID,
+		EnableIndex:  segmentIndex.EnableIndex,
+		CreateTime:   segmentIndex.CreateTime,
+	}
+}
+
+func MarshalSegmentIndexModel(segmentIndex *SegmentIndex) *pb.SegmentIndexInfo {
+	if segmentIndex == nil {
+		return nil
+	}
+	return &pb.SegmentIndexInfo{
+		CollectionID: segmentIndex.CollectionID,
+		PartitionID:  segmentIndex.PartitionID,
+		SegmentID:    segmentIndex.SegmentID,
+		FieldID:      segmentIndex.FieldID,
+		IndexID:      segmentIndex.IndexID,
+		BuildID:      segmentIndex.BuildID,
+		EnableIndex:  segmentIndex.EnableIndex,
+		CreateTime:   segmentIndex.CreateTime,
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+// LoadInfo is the persisted load state of a collection, loaded collections are loaded again after restart
+type LoadInfo struct {
+	CollectionID int64
+	// PartitionIDs are the loaded partitions
+	PartitionIDs []int64
+}
+
+func (l *LoadInfo) Clone() *LoadInfo {
+	return &LoadInfo{
+		CollectionID: l.CollectionID,
+		PartitionIDs: append([]int64{}, l.PartitionIDs...),
+	}
+}
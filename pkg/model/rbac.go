@@ -0,0 +1,29 @@
+package model
+
+// Role is a set of privileges granted, admin & public are the built-in roles
+type Role struct {
+	Name string
+}
+
+// UserRole binds a user to a role, the user gets the privileges granted to the role
+type UserRole struct {
+	Username string
+	RoleName string
+}
+
+// Grant is a privilege on an object granted to a role, same as milvus.
+// ObjectName & DbName are * for all objects & databases, Privilege is the name in meta store, e.g. PrivilegeSearch.
+type Grant struct {
+	RoleName   string
+	ObjectType string
+	ObjectName string
+	DbName     string
+	Privilege  string
+	// Grantor is the user granting the privilege
+	Grantor string
+}
+
+func (g *Grant) Clone() *Grant {
+	clone := *g
+	return &clone
+}
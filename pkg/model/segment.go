@@ -0,0 +1,86 @@
+package model
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+)
+
+type Segment struct {
+	SegmentID      int64
+	CollectionID   int64
+	PartitionID    int64
+	State          commonpb.SegmentState
+	NumOfRows      int64
+	Binlogs        []*FieldBinlog
+	Deltalogs      []*FieldBinlog
+	CompactionFrom []int64
+	DroppedAt      uint64
+}
+
+// FieldBinlog is the binlogs of one field, deltalogs don't have field id
+type FieldBinlog struct {
+	FieldID int64
+	Binlogs []*Binlog
+}
+
+type Binlog struct {
+	EntriesNum    int64
+	TimestampFrom uint64
+	TimestampTo   uint64
+	LogPath       string
+	LogSize       int64
+	LogID         int64
+	MemorySize    int64
+}
+
+func (s *Segment) Clone() *Segment {
+	return &Segment{
+		SegmentID:      s.SegmentID,
+		CollectionID:   s.CollectionID,
+		PartitionID:    s.PartitionID,
+		State:          s.State,
+		NumOfRows:      s.NumOfRows,
+		Binlogs:        CloneFieldBinlogs(s.Binlogs),
+		Deltalogs:      CloneFieldBinlogs(s.Deltalogs),
+		CompactionFrom: append([]int64{}, s.CompactionFrom...),
+		DroppedAt:      s.DroppedAt,
+	}
+}
+
+func (s *Segment) Available() bool {
+	return s.State == commonpb.SegmentState_Flushed
+}
+
+// GetMemorySize returns the memory size of all rows, deletes are not considered
+func (s *Segment) GetMemorySize() int64 {
+	var size int64
+	for _, fieldBinlog := range s.Binlogs {
+		for _, binlog := range fieldBinlog.Binlogs {
+			size += binlog.MemorySize
+		}
+	}
+	return size
+}
+
+// GetDeletedNum returns the number of delete records in deltalogs
+func (s *Segment) GetDeletedNum() int64 {
+	var num int64
+	for _, fieldBinlog := range s.Deltalogs {
+		for _, binlog := range fieldBinlog.Binlogs {
+			num += binlog.EntriesNum
+		}
+	}
+	return num
+}
+
+func CloneFieldBinlogs(fieldBinlogs []*FieldBinlog) []*FieldBinlog {
+	ret := make([]*FieldBinlog, 0, len(fieldBinlogs))
+	for _, fieldBinlog := range fieldBinlogs {
+		binlogs := make([]*Binlog, 0, len(fieldBinlog.Binlogs))
+		for _, binlog := range fieldBinlog.Binlogs {
+			clone := *binlog
+			binlogs = append(binlogs, &clone)
+		}
+		ret = append(ret, &FieldBinlog{FieldID: fieldBinlog.FieldID, Binlogs: binlogs})
+	}
+	return ret
+}
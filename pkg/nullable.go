@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// ValidFieldSuffix is the suffix of the bool column which tells the valid rows of a nullable field, false means null.
+// FieldData has no validity in this version of proto, so the column goes along with the data column in insert requests
+// and results. Rows of null in the data column are placeholders.
+const ValidFieldSuffix = "$valid"
+
+// fillNull returns the column of numRows nulls of field
+func fillNull(field *schemapb.FieldSchema, numRows uint32) (storage.FieldData, error) {
+	ret, err := storage.NewFieldData(field)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numRows; i++ {
+		if err := ret.AppendRow(nil); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// applyValidData marks the null rows of data by the validity column, nulls are replaced by the default value if any
+func applyValidData(field *schemapb.FieldSchema, data storage.FieldData, validColumn *schemapb.FieldData) (storage.FieldData, error) {
+	validData := validColumn.GetScalars().GetBoolData()
+	if validData == nil {
+		return nil, merr.WrapErrParameterInvalidMsg("validity of field %s should be bools, got %s",
+			field.GetName(), validColumn.GetType().String())
+	}
+	if err := storage.SetValidData(data, append([]bool{}, validData.GetData()...)); err != nil {
+		return nil, merr.WrapErrParameterInvalidMsg(err.Error())
+	}
+	if field.GetDefaultValue() == nil {
+		return data, nil
+	}
+
+	defaultValue, err := defaultValueRow(field)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := storage.NewFieldData(field)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < data.RowNum(); i++ {
+		row := data.GetRow(i)
+		if row == nil {
+			row = defaultValue
+		}
+		if err := ret.AppendRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// validFieldData returns the validity column of nullable field in results
+func validFieldData(field *schemapb.FieldSchema, validData []bool) *schemapb.FieldData {
+	return &schemapb.FieldData{
+		Type:      schemapb.DataType_Bool,
+		FieldName: field.GetName() + ValidFieldSuffix,
+		Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_BoolData{BoolData: &schemapb.BoolArray{Data: validData}}}},
+	}
+}
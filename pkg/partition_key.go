@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/expr"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// getPartitionKeyField returns the partition key field of collection, nil if partition key is not used
+func getPartitionKeyField(collection *model.Collection) *schemapb.FieldSchema {
+	field, err := typeutil.GetPartitionKeyFieldSchema(model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema())
+	if err != nil {
+		return nil
+	}
+	return field
+}
+
+// checkPartitionKeyMode fails if partitions are specified when partition key is used, same as milvus
+func checkPartitionKeyMode(collection *model.Collection, partitionNames ...string) error {
+	if getPartitionKeyField(collection) == nil {
+		return nil
+	}
+	for _, name := range partitionNames {
+		if name != "" {
+			return merr.WrapErrParameterInvalidMsg("not support manually specifying the partition names if partition key mode is used")
+		}
+	}
+	return nil
+}
+
+// hashPartitionKeys returns the partitions of partition keys, keys are hashed the same as milvus,
+// murmur3 of VarChar and the int64 hash of integers modulo the number of partitions
+func hashPartitionKeys(collection *model.Collection, keys *schemapb.FieldData) ([]UniqueID, error) {
+	partitions := make(map[string]int64, len(collection.Partitions))
+	for _, partition := range collection.Partitions {
+		partitions[partition.PartitionName] = partition.PartitionID
+	}
+	names, ids, err := typeutil.RearrangePartitionsForPartitionKey(partitions)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := typeutil.HashKey2Partitions(keys, names)
+	if err != nil {
+		return nil, merr.WrapErrParameterInvalidMsg(err.Error())
+	}
+	ret := make([]UniqueID, 0, len(indexes))
+	for _, i := range indexes {
+		ret = append(ret, ids[i])
+	}
+	return ret, nil
+}
+
+// splitByPartitionKey splits the rows to insert by the partitions of their partition keys
+func splitByPartitionKey(collection *model.Collection, field *schemapb.FieldSchema, data *storage.InsertData) (map[UniqueID]*storage.InsertData, error) {
+	keys, err := storage.FieldDataToProto(field, data.Data[field.GetFieldID()])
+	if err != nil {
+		return nil, err
+	}
+	partitionIDs, err := hashPartitionKeys(collection, keys)
+	if err != nil {
+		return nil, err
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	ret := make(map[UniqueID]*storage.InsertData)
+	for row, partitionID := range partitionIDs {
+		partitionData, ok := ret[partitionID]
+		if !ok {
+			partitionData, err = storage.NewInsertData(schema)
+			if err != nil {
+				return nil, err
+			}
+			ret[partitionID] = partitionData
+		}
+		for fieldID, fieldData := range data.Data {
+			if err := partitionData.Data[fieldID].AppendRow(fieldData.GetRow(row)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ret, nil
+}
+
+// prunePartitions returns the partitions which may have rows matching the plan,
+// the partitions of keys are returned if the plan pins the partition key.
+func prunePartitions(collection *model.Collection, plan *expr.Plan, partitionIDs []UniqueID) ([]UniqueID, error) {
+	field := getPartitionKeyField(collection)
+	if field == nil {
+		return partitionIDs, nil
+	}
+	values, ok := plan.PartitionKeyValues(field)
+	if !ok {
+		return partitionIDs, nil
+	}
+	keys := &schemapb.FieldData{Type: field.GetDataType(), FieldName: field.GetName()}
+	if typeutil.IsStringType(field.GetDataType()) {
+		data := make([]string, 0, len(values))
+		for _, value := range values {
+			data = append(data, value.(string))
+		}
+		keys.Field = &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: data}}}}
+	} else {
+		data := make([]int64, 0, len(values))
+		for _, value := range values {
+			data = append(data, value.(int64))
+		}
+		keys.Field = &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+			Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: data}}}}
+	}
+	hashed, err := hashPartitionKeys(collection, keys)
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[UniqueID]struct{}, len(hashed))
+	for _, partitionID := range hashed {
+		pinned[partitionID] = struct{}{}
+	}
+	ret := make([]UniqueID, 0, len(pinned))
+	for _, partitionID := range partitionIDs {
+		if _, ok := pinned[partitionID]; ok {
+			ret = append(ret, partitionID)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,409 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/expr"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+const (
+	LimitKey  = "limit"
+	OffsetKey = "offset"
+	// CountStar is the output field to count the rows matched
+	CountStar = "count(*)"
+)
+
+type QueryTask struct {
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.QueryRequest
+}
+
+func NewQueryTask(
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.QueryRequest) *QueryTask {
+
+	return &QueryTask{
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// Execute returns the rows matching expr ordered by primary key, the collection must be loaded
+func (t QueryTask) Execute(ctx context.Context) (*milvuspb.QueryResults, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	limit, offset, err := parseLimitOffset(t.req.GetQueryParams())
+	if err != nil {
+		return nil, err
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	countOnly := len(t.req.GetOutputFields()) == 1 && strings.ToLower(t.req.GetOutputFields()[0]) == CountStar
+	if t.req.GetExpr() == "" && limit < 0 && !countOnly {
+		return nil, merr.WrapErrParameterInvalidMsg("empty expression should be used with limit")
+	}
+	var outputFields *projection
+	if !countOnly {
+		outputFields, err = translateOutputFields(schema, t.req.GetOutputFields(), true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ts, err := readTimestamp(ctx, t.tsAllocator, t.segmentManager, collection, t.req)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newFilteredReader(ctx, t.segmentManager, collection, t.req.GetPartitionNames(), t.req.GetExpr(), ts)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+
+	rows := reader.Rows()
+	if countOnly {
+		return &milvuspb.QueryResults{
+			Status:         merr.Status(nil),
+			CollectionName: collection.Name,
+			OutputFields:   []string{CountStar},
+			FieldsData: []*schemapb.FieldData{{
+				Type:      schemapb.DataType_Int64,
+				FieldName: CountStar,
+				Field: &schemapb.FieldData_Scalars{Scalars: &schemapb.ScalarField{
+					Data: &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: []int64{int64(len(rows))}}},
+				}},
+			}},
+		}, nil
+	}
+	if offset >= len(rows) {
+		rows = rows[:0]
+	} else {
+		rows = rows[offset:]
+	}
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	fieldsData, err := buildFieldsData(ctx, outputFields, rows)
+	if err != nil {
+		return nil, err
+	}
+	resp := &milvuspb.QueryResults{
+		Status:         merr.Status(nil),
+		CollectionName: collection.Name,
+		FieldsData:     fieldsData,
+	}
+	resp.OutputFields = outputFields.Names()
+	return resp, nil
+}
+
+// parseLimitOffset returns the limit & offset of query params, limit is -1 if not set
+func parseLimitOffset(params []*commonpb.KeyValuePair) (int, int, error) {
+	limit, offset := -1, 0
+	for _, kv := range params {
+		switch kv.GetKey() {
+		case LimitKey:
+			value, err := strconv.Atoi(kv.GetValue())
+			if err != nil || value <= 0 {
+				return 0, 0, merr.WrapErrParameterInvalidMsg("invalid %s: %s", LimitKey, kv.GetValue())
+			}
+			limit = value
+		case OffsetKey:
+			value, err := strconv.Atoi(kv.GetValue())
+			if err != nil || value < 0 {
+				return 0, 0, merr.WrapErrParameterInvalidMsg("invalid %s: %s", OffsetKey, kv.GetValue())
+			}
+			offset = value
+		}
+	}
+	return limit, offset, nil
+}
+
+// projection is the fields to return, dynamicKeys are the keys of dynamic field to return,
+// the whole dynamic field is returned if it's in fields while dynamicKeys is empty.
+type projection struct {
+	fields      []*schemapb.FieldSchema
+	dynamicKeys []string
+}
+
+// Names returns the output names, the keys of dynamic field are returned instead of the dynamic field
+func (p *projection) Names() []string {
+	ret := make([]string, 0, len(p.fields)+len(p.dynamicKeys))
+	for _, field := range p.fields {
+		if !field.GetIsDynamic() || len(p.dynamicKeys) == 0 {
+			ret = append(ret, field.GetName())
+		}
+	}
+	return append(ret, p.dynamicKeys...)
+}
+
+// translateOutputFields returns the fields of output names, `*` means all fields.
+// Names not in schema are the keys of dynamic field if it's enabled.
+// The primary key is always returned if withPK is set.
+func translateOutputFields(schema *schemapb.CollectionSchema, names []string, withPK bool) (*projection, error) {
+	ret := &projection{fields: make([]*schemapb.FieldSchema, 0, len(names))}
+	added := make(map[int64]struct{})
+	add := func(field *schemapb.FieldSchema) {
+		if _, ok := added[field.GetFieldID()]; !ok {
+			added[field.GetFieldID()] = struct{}{}
+			ret.fields = append(ret.fields, field)
+		}
+	}
+	if withPK {
+		pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+		if err != nil {
+			return nil, err
+		}
+		add(pkField)
+	}
+	var dynamicField *schemapb.FieldSchema
+	for _, field := range schema.GetFields() {
+		if field.GetIsDynamic() {
+			dynamicField = field
+		}
+	}
+	wholeDynamic := false
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "*" {
+			for _, field := range schema.GetFields() {
+				if field.GetFieldID() >= common.StartOfUserFieldID {
+					add(field)
+				}
+			}
+			wholeDynamic = true
+			continue
+		}
+		found := false
+		for _, field := range schema.GetFields() {
+			if field.GetName() == name && field.GetFieldID() >= common.StartOfUserFieldID {
+				add(field)
+				wholeDynamic = wholeDynamic || field.GetIsDynamic()
+				found = true
+				break
+			}
+		}
+		if !found {
+			if dynamicField == nil {
+				return nil, merr.WrapErrFieldNotFound(name)
+			}
+			add(dynamicField)
+			ret.dynamicKeys = append(ret.dynamicKeys, name)
+		}
+	}
+	if wholeDynamic {
+		ret.dynamicKeys = nil
+	}
+	return ret, nil
+}
+
+// rowRef is a row of a segment view
+type rowRef struct {
+	view   *segments.SegmentView
+	offset int
+}
+
+// buildFieldsData returns the columns of output fields of rows, nullable fields are followed by their validity columns
+// The fields not in memory are read from binlogs for the segments of rows only.
+func buildFieldsData(ctx context.Context, output *projection, rows []rowRef) ([]*schemapb.FieldData, error) {
+	fieldIDs := make([]UniqueID, 0, len(output.fields))
+	for _, field := range output.fields {
+		fieldIDs = append(fieldIDs, field.GetFieldID())
+	}
+	loaded := make(map[*segments.SegmentView]struct{})
+	for _, row := range rows {
+		if _, ok := loaded[row.view]; ok {
+			continue
+		}
+		if err := row.view.LoadFields(ctx, fieldIDs...); err != nil {
+			return nil, err
+		}
+		loaded[row.view] = struct{}{}
+	}
+	ret := make([]*schemapb.FieldData, 0, len(output.fields))
+	for _, field := range output.fields {
+		column, err := storage.NewFieldData(field)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			value := row.view.Data.Data[field.GetFieldID()].GetRow(row.offset)
+			if field.GetIsDynamic() && len(output.dynamicKeys) > 0 {
+				value, err = projectDynamicKeys(value.([]byte), output.dynamicKeys)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if err := column.AppendRow(value); err != nil {
+				return nil, err
+			}
+		}
+		fieldData, err := storage.FieldDataToProto(field, column)
+		if err != nil {
+			return nil, err
+		}
+		fieldData.IsDynamic = field.GetIsDynamic()
+		ret = append(ret, fieldData)
+		if validData := storage.GetValidData(column); validData != nil {
+			ret = append(ret, validFieldData(field, validData))
+		}
+	}
+	return ret, nil
+}
+
+// projectDynamicKeys returns the json object of dynamic field with only the keys, missing keys are skipped
+func projectDynamicKeys(value []byte, keys []string) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(value, &object); err != nil {
+		return nil, err
+	}
+	projected := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		if v, ok := object[key]; ok {
+			projected[key] = v
+		}
+	}
+	return json.Marshal(projected)
+}
+
+// filteredReader holds the views of loaded segments and the rows matching the filter
+type filteredReader struct {
+	snapshot *segments.Snapshot
+	schema   *schemapb.CollectionSchema
+	pkField  *schemapb.FieldSchema
+	views    []*segments.SegmentView
+	// valid marks the rows not deleted, not expired and matching the filter of each view
+	valid [][]bool
+}
+
+// newFilteredReader acquires the segments of loaded partitions, all loaded partitions if partitionNames is empty.
+// It fails if the collection or partitions are not loaded. The rows are the ones as of ts, rows expired at ts are invisible.
+// Only the partitions of the partition keys are read if the filter pins the partition key.
+func newFilteredReader(ctx context.Context, segmentManager *segments.Manager, collection *model.Collection,
+	partitionNames []string, filter string, ts Timestamp) (*filteredReader, error) {
+	if err := checkPartitionKeyMode(collection, partitionNames...); err != nil {
+		return nil, err
+	}
+	partitionIDs, err := getPartitionIDs(collection, partitionNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := segmentManager.CheckLoaded(collection.CollectionID, partitionIDs); err != nil {
+		return nil, err
+	}
+	if len(partitionIDs) == 0 {
+		partitionIDs = segmentManager.GetLoadedPartitions(collection.CollectionID)
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	var plan *expr.Plan
+	if filter != "" {
+		plan, err = expr.Parse(filter, schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+	partitionIDs, err = prunePartitions(collection, plan, partitionIDs)
+	if err != nil {
+		return nil, err
+	}
+	expireTs, err := segments.GetExpireTimestamp(collection, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &filteredReader{
+		// no partition is read if the partitions of partition keys are not loaded
+		snapshot: &segments.Snapshot{},
+		schema:   schema,
+		pkField:  pkField,
+	}
+	if len(partitionIDs) > 0 {
+		reader.snapshot = segmentManager.Acquire(collection.CollectionID, partitionIDs)
+	}
+	for _, segment := range reader.snapshot.Segments {
+		view, err := segmentManager.ReadView(ctx, segment, expireTs, ts)
+		if err != nil {
+			reader.Release()
+			return nil, err
+		}
+		valid, err := plan.Evaluate(view.Data, view.RowNum, segment.GetScalarIndexes())
+		if err != nil {
+			reader.Release()
+			return nil, err
+		}
+		for i := range valid {
+			valid[i] = valid[i] && !view.Deleted[i]
+		}
+		reader.views = append(reader.views, view)
+		reader.valid = append(reader.valid, valid)
+	}
+	return reader, nil
+}
+
+func (r *filteredReader) Release() {
+	r.snapshot.Release()
+}
+
+// Rows returns the valid rows ordered by primary key,
+// only the latest row is returned if rows share the same primary key.
+func (r *filteredReader) Rows() []rowRef {
+	latest := make(map[any]rowRef)
+	for i, view := range r.views {
+		pks := view.Data.Data[r.pkField.GetFieldID()]
+		tss := view.Data.Data[common.TimeStampField].(*storage.Int64FieldData).Data
+		for offset, valid := range r.valid[i] {
+			if !valid {
+				continue
+			}
+			pk := pks.GetRow(offset)
+			if prev, ok := latest[pk]; ok {
+				prevTs := prev.view.Data.Data[common.TimeStampField].(*storage.Int64FieldData).Data[prev.offset]
+				if prevTs >= tss[offset] {
+					continue
+				}
+			}
+			latest[pk] = rowRef{view: view, offset: offset}
+		}
+	}
+	pks := make([]any, 0, len(latest))
+	for pk := range latest {
+		pks = append(pks, pk)
+	}
+	sort.Slice(pks, func(i, j int) bool {
+		if r.pkField.GetDataType() == schemapb.DataType_VarChar {
+			return pks[i].(string) < pks[j].(string)
+		}
+		return pks[i].(int64) < pks[j].(int64)
+	})
+	ret := make([]rowRef, 0, len(pks))
+	for _, pk := range pks {
+		ret = append(ret, latest[pk])
+	}
+	return ret
+}
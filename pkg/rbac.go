@@ -0,0 +1,324 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus/pkg/util"
+	"github.com/milvus-io/milvus/pkg/util/funcutil"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/samber/lo"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	protov2 "google.golang.org/protobuf/proto"
+)
+
+// CreateRole creates an empty role
+func CreateRole(ctx context.Context, meta metas.MetaTable, req *milvuspb.CreateRoleRequest) error {
+	roleName := req.GetEntity().GetName()
+	if err := validateEntityName(roleName, "role"); err != nil {
+		return err
+	}
+	if hasRole(ctx, meta, roleName) {
+		return merr.WrapErrParameterInvalidMsg("role %s already exists", roleName)
+	}
+	return meta.SaveRole(ctx, &model.Role{Name: roleName})
+}
+
+// DropRole drops the role along with its user bindings & grants, the built-in roles can't be dropped
+func DropRole(ctx context.Context, meta metas.MetaTable, req *milvuspb.DropRoleRequest) error {
+	if lo.Contains(util.DefaultRoles, req.GetRoleName()) {
+		return merr.WrapErrParameterInvalidMsg("role %s is a built-in role, it can't be dropped", req.GetRoleName())
+	}
+	if !hasRole(ctx, meta, req.GetRoleName()) {
+		return merr.WrapErrParameterInvalidMsg("role %s not found", req.GetRoleName())
+	}
+	return meta.RemoveRole(ctx, req.GetRoleName())
+}
+
+// OperateUserRole binds the user to the role or unbinds it, binding twice or unbinding a user not bound is no-op
+func OperateUserRole(ctx context.Context, meta metas.MetaTable, req *milvuspb.OperateUserRoleRequest) error {
+	if _, err := meta.GetCredential(ctx, req.GetUsername()); err != nil {
+		return err
+	}
+	if !hasRole(ctx, meta, req.GetRoleName()) {
+		return merr.WrapErrParameterInvalidMsg("role %s not found", req.GetRoleName())
+	}
+	userRole := &model.UserRole{Username: req.GetUsername(), RoleName: req.GetRoleName()}
+	switch req.GetType() {
+	case milvuspb.OperateUserRoleType_AddUserToRole:
+		return meta.SaveUserRole(ctx, userRole)
+	case milvuspb.OperateUserRoleType_RemoveUserFromRole:
+		return meta.RemoveUserRole(ctx, userRole)
+	}
+	return merr.WrapErrParameterInvalidMsg("invalid operate user role type %s", req.GetType().String())
+}
+
+// SelectRole returns the role of request or all roles, along with their users if IncludeUserInfo is set
+func SelectRole(ctx context.Context, meta metas.MetaTable, req *milvuspb.SelectRoleRequest) (*milvuspb.SelectRoleResponse, error) {
+	roles, err := meta.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetRole() != nil {
+		roles = lo.Filter(roles, func(role *model.Role, _ int) bool { return role.Name == req.GetRole().GetName() })
+		if len(roles) == 0 {
+			return nil, merr.WrapErrParameterInvalidMsg("role %s not found", req.GetRole().GetName())
+		}
+	}
+	userRoles, err := meta.ListUserRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &milvuspb.SelectRoleResponse{Status: merr.Status(nil), Results: make([]*milvuspb.RoleResult, 0, len(roles))}
+	for _, role := range roles {
+		result := &milvuspb.RoleResult{Role: &milvuspb.RoleEntity{Name: role.Name}}
+		if req.GetIncludeUserInfo() {
+			for _, userRole := range userRoles {
+				if userRole.RoleName == role.Name {
+					result.Users = append(result.Users, &milvuspb.UserEntity{Name: userRole.Username})
+				}
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+// SelectUser returns the user of request or all users, along with their roles if IncludeRoleInfo is set
+func SelectUser(ctx context.Context, meta metas.MetaTable, req *milvuspb.SelectUserRequest) (*milvuspb.SelectUserResponse, error) {
+	var usernames []string
+	if req.GetUser() != nil {
+		if _, err := meta.GetCredential(ctx, req.GetUser().GetName()); err != nil {
+			return nil, err
+		}
+		usernames = []string{req.GetUser().GetName()}
+	} else {
+		var err error
+		usernames, err = meta.ListCredentialUsernames(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	userRoles, err := meta.ListUserRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &milvuspb.SelectUserResponse{Status: merr.Status(nil), Results: make([]*milvuspb.UserResult, 0, len(usernames))}
+	for _, username := range usernames {
+		result := &milvuspb.UserResult{User: &milvuspb.UserEntity{Name: username}}
+		if req.GetIncludeRoleInfo() {
+			for _, userRole := range userRoles {
+				if userRole.Username == username {
+					result.Roles = append(result.Roles, &milvuspb.RoleEntity{Name: userRole.RoleName})
+				}
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+// OperatePrivilege grants the privilege on the object to the role or revokes it, revoking a privilege not granted is no-op.
+// The privilege is the name of api, e.g. Search, or * for all privileges of the object type.
+// The object name is * for all objects of the type, the database is the default one if not set.
+func OperatePrivilege(ctx context.Context, meta metas.MetaTable, req *milvuspb.OperatePrivilegeRequest) error {
+	entity := req.GetEntity()
+	if !hasRole(ctx, meta, entity.GetRole().GetName()) {
+		return merr.WrapErrParameterInvalidMsg("role %s not found", entity.GetRole().GetName())
+	}
+	objectType := entity.GetObject().GetName()
+	if _, ok := commonpb.ObjectType_value[objectType]; !ok {
+		return merr.WrapErrParameterInvalidMsg("invalid object type %s", objectType)
+	}
+	if entity.GetObjectName() == "" {
+		return merr.WrapErrParameterInvalidMsg("object name of grant should not be empty")
+	}
+	// names are the components of grant key, they must not escape it by separators or dots
+	if !util.IsAnyWord(entity.GetObjectName()) {
+		if err := validateName(entity.GetObjectName(), "object"); err != nil {
+			return err
+		}
+	}
+	if entity.GetDbName() != "" && !util.IsAnyWord(entity.GetDbName()) {
+		if err := validateName(entity.GetDbName(), "database"); err != nil {
+			return err
+		}
+	}
+	privilegeName := entity.GetGrantor().GetPrivilege().GetName()
+	allPrivileges := util.MetaStore2API(commonpb.ObjectPrivilege_PrivilegeAll.String())
+	if util.IsAnyWord(privilegeName) {
+		privilegeName = allPrivileges
+	}
+	// All could be granted on objects of any type, it covers the privileges of the type only
+	if privilegeName != allPrivileges && !lo.Contains(util.ObjectPrivileges[objectType], privilegeName) {
+		return merr.WrapErrParameterInvalidMsg("invalid privilege %s of object type %s", privilegeName, objectType)
+	}
+	grant := &model.Grant{
+		RoleName:   entity.GetRole().GetName(),
+		ObjectType: objectType,
+		ObjectName: entity.GetObjectName(),
+		DbName:     entity.GetDbName(),
+		Privilege:  util.PrivilegeNameForMetastore(privilegeName),
+		Grantor:    entity.GetGrantor().GetUser().GetName(),
+	}
+	if grant.DbName == "" {
+		grant.DbName = util.DefaultDBName
+	}
+	if username, ok := currentUser(ctx); ok {
+		grant.Grantor = username
+	}
+	switch req.GetType() {
+	case milvuspb.OperatePrivilegeType_Grant:
+		return meta.SaveGrant(ctx, grant)
+	case milvuspb.OperatePrivilegeType_Revoke:
+		return meta.RemoveGrant(ctx, grant)
+	}
+	return merr.WrapErrParameterInvalidMsg("invalid operate privilege type %s", req.GetType().String())
+}
+
+// SelectGrant returns the grants of the role, filtered by the object type, object name & database of request if set
+func SelectGrant(ctx context.Context, meta metas.MetaTable, req *milvuspb.SelectGrantRequest) (*milvuspb.SelectGrantResponse, error) {
+	entity := req.GetEntity()
+	if !hasRole(ctx, meta, entity.GetRole().GetName()) {
+		return nil, merr.WrapErrParameterInvalidMsg("role %s not found", entity.GetRole().GetName())
+	}
+	grants, err := meta.ListGrants(ctx, entity.GetRole().GetName())
+	if err != nil {
+		return nil, err
+	}
+	resp := &milvuspb.SelectGrantResponse{Status: merr.Status(nil), Entities: make([]*milvuspb.GrantEntity, 0, len(grants))}
+	for _, grant := range grants {
+		if (entity.GetObject() != nil && grant.ObjectType != entity.GetObject().GetName()) ||
+			(entity.GetObjectName() != "" && grant.ObjectName != entity.GetObjectName()) ||
+			(entity.GetDbName() != "" && grant.DbName != entity.GetDbName()) {
+			continue
+		}
+		resp.Entities = append(resp.Entities, &milvuspb.GrantEntity{
+			Role:       &milvuspb.RoleEntity{Name: grant.RoleName},
+			Object:     &milvuspb.ObjectEntity{Name: grant.ObjectType},
+			ObjectName: grant.ObjectName,
+			DbName:     grant.DbName,
+			Grantor: &milvuspb.GrantorEntity{
+				User:      &milvuspb.UserEntity{Name: grant.Grantor},
+				Privilege: &milvuspb.PrivilegeEntity{Name: util.MetaStore2API(grant.Privilege)},
+			},
+		})
+	}
+	return resp, nil
+}
+
+func hasRole(ctx context.Context, meta metas.MetaTable, roleName string) bool {
+	roles, err := meta.ListRoles(ctx)
+	if err != nil {
+		return false
+	}
+	return lo.ContainsBy(roles, func(role *model.Role) bool { return role.Name == roleName })
+}
+
+// PrivilegeChecker checks the user of a request has the privilege of the request on its objects.
+// The object type, privilege & object name field of each request are declared by the privilege_ext_obj option
+// of its message, same as milvus, requests without the option need no privilege.
+// root and the users of admin role have all privileges, all users have the privileges of public role.
+type PrivilegeChecker struct {
+	meta metas.MetaTable
+}
+
+func NewPrivilegeChecker(meta metas.MetaTable) *PrivilegeChecker {
+	return &PrivilegeChecker{meta: meta}
+}
+
+// Check returns an error of code PermissionDenied if the user authenticated has no privilege of the request
+func (c *PrivilegeChecker) Check(ctx context.Context, req any) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	options := proto.MessageReflect(msg).Descriptor().Options()
+	if options == nil || !protov2.HasExtension(options, commonpb.E_PrivilegeExtObj) {
+		return nil
+	}
+	ext := protov2.GetExtension(options, commonpb.E_PrivilegeExtObj).(*commonpb.PrivilegeExt)
+	username, ok := currentUser(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, merr.ErrNeedAuthenticate.Error())
+	}
+	if username == util.UserRoot {
+		return nil
+	}
+	userRoles, err := c.meta.ListUserRoles(ctx)
+	if err != nil {
+		return err
+	}
+	roles := []string{util.RolePublic}
+	for _, userRole := range userRoles {
+		if userRole.Username == username {
+			roles = append(roles, userRole.RoleName)
+		}
+	}
+	if lo.Contains(roles, util.RoleAdmin) {
+		return nil
+	}
+
+	objectType := ext.GetObjectType().String()
+	objectNames := []string{funcutil.GetObjectName(msg, ext.GetObjectNameIndex())}
+	if ext.GetObjectNameIndexs() > 0 {
+		objectNames = funcutil.GetObjectNames(msg, ext.GetObjectNameIndexs())
+	}
+	// users could operate themselves, e.g. update their passwords, and select the grants of their roles
+	if objectType == commonpb.ObjectType_User.String() && objectNames[0] == username {
+		return nil
+	}
+	if selectGrant, ok := req.(*milvuspb.SelectGrantRequest); ok && lo.Contains(roles, selectGrant.GetEntity().GetRole().GetName()) {
+		return nil
+	}
+
+	grants, err := c.meta.ListGrants(ctx, "")
+	if err != nil {
+		return err
+	}
+	privilege := ext.GetObjectPrivilege().String()
+	dbName := requestDbName(ctx, req)
+	for _, objectName := range objectNames {
+		permitted := lo.ContainsBy(grants, func(grant *model.Grant) bool {
+			if !lo.Contains(roles, grant.RoleName) || grant.ObjectType != objectType ||
+				!(util.IsAnyWord(grant.ObjectName) || grant.ObjectName == objectName) ||
+				!(util.IsAnyWord(grant.DbName) || grant.DbName == dbName) {
+				return false
+			}
+			// privilege All grants all privileges on the objects of the grant only
+			return grant.Privilege == privilege || grant.Privilege == commonpb.ObjectPrivilege_PrivilegeAll.String()
+		})
+		if !permitted {
+			return status.Errorf(codes.PermissionDenied, "%s: permission deny to %s", util.MetaStore2API(privilege), username)
+		}
+	}
+	return nil
+}
+
+// UnaryServerInterceptor rejects the requests without privilege, it must be chained after the interceptor of Authenticator
+func (c *PrivilegeChecker) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := c.Check(ctx, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// requestDbName returns the database of request, it's the one in metadata if not set, or the default one
+func requestDbName(ctx context.Context, req any) string {
+	if r, ok := req.(interface{ GetDbName() string }); ok && r.GetDbName() != "" {
+		return r.GetDbName()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(util.HeaderDBName)) > 0 && md.Get(util.HeaderDBName)[0] != "" {
+		return md.Get(util.HeaderDBName)[0]
+	}
+	return util.DefaultDBName
+}
@@ -0,0 +1,486 @@
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/milvuspb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/search"
+	"github.com/sharding-db/milvus-mini/pkg/segments"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+const (
+	AnnsFieldKey    = "anns_field"
+	RoundDecimalKey = "round_decimal"
+	// GroupByFieldKey is the scalar field to group hits by, only the best hit of each value is returned
+	GroupByFieldKey = "group_by_field"
+	// RadiusKey & RangeFilterKey are the bounds of range search in params, same as milvus
+	RadiusKey      = "radius"
+	RangeFilterKey = "range_filter"
+	// MaxTopK is the max topK of search, same as milvus
+	MaxTopK = 16384
+	// BruteForceFilterRatio is the ratio of rows filtered out above which a segment is searched by brute force,
+	// ann indexes are likely to miss the few valid rows, same as knowhere
+	BruteForceFilterRatio = 0.93
+)
+
+type SearchTask struct {
+	tsAllocator    allocator.TimestampAllocator
+	meta           metas.MetaTable
+	segmentManager *segments.Manager
+
+	req *milvuspb.SearchRequest
+}
+
+func NewSearchTask(
+	tsAllocator allocator.TimestampAllocator,
+	meta metas.MetaTable,
+	segmentManager *segments.Manager,
+	request *milvuspb.SearchRequest) *SearchTask {
+
+	return &SearchTask{
+		tsAllocator:    tsAllocator,
+		meta:           meta,
+		segmentManager: segmentManager,
+		req:            request,
+	}
+}
+
+// searchParams are the parsed search params of request
+type searchParams struct {
+	field        *schemapb.FieldSchema
+	metricType   string
+	topK         int
+	offset       int
+	roundDecimal int
+	// params are the index specific params, e.g. nprobe
+	params map[string]any
+	// scoreRange is the band of scores of range search, nil if it's not a range search
+	scoreRange *search.Range
+	// groupByField is the field to group hits by, topK & offset are the numbers of groups if it's set
+	groupByField *schemapb.FieldSchema
+}
+
+// Execute searches the topK nearest rows of each query vector among the rows matching expr,
+// the collection must be loaded. It's a range search if radius is in params, rows out of range are not returned.
+func (t SearchTask) Execute(ctx context.Context) (*milvuspb.SearchResults, error) {
+	collection, err := t.meta.GetCollectionByName(ctx, t.req.GetDbName(), t.req.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	schema := model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema()
+	indexes, err := t.meta.ListIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	params, err := parseSearchParams(schema, t.req.GetSearchParams(), indexes)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := parsePlaceholderGroup(params.field, t.req.GetPlaceholderGroup())
+	if err != nil {
+		return nil, err
+	}
+	outputFields, err := translateOutputFields(schema, t.req.GetOutputFields(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := readTimestamp(ctx, t.tsAllocator, t.segmentManager, collection, t.req)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newFilteredReader(ctx, t.segmentManager, collection, t.req.GetPartitionNames(), t.req.GetDsl(), ts)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+
+	reduced, err := searchReader(ctx, reader, queries, params)
+	if err != nil {
+		return nil, err
+	}
+	resultData, err := newSearchResultData(ctx, reader.pkField, reader.views, reduced, params.topK, params.roundDecimal, outputFields)
+	if err != nil {
+		return nil, err
+	}
+	return &milvuspb.SearchResults{
+		Status:         merr.Status(nil),
+		Results:        resultData,
+		CollectionName: collection.Name,
+	}, nil
+}
+
+// searchReader searches the best topK + offset hits of each query among the valid rows of reader,
+// the sources of hits are the indexes of views of reader.
+func searchReader(ctx context.Context, reader *filteredReader, queries storage.FieldData, params *searchParams) ([][]search.Hit, error) {
+	nq := queries.RowNum()
+	results := make([][][]search.Hit, 0, len(reader.views))
+	for i, view := range reader.views {
+		var hits [][]search.Hit
+		var err error
+		if params.groupByField != nil {
+			hits, err = searchSegmentGroups(ctx, view, reader.valid[i], queries, params)
+		} else {
+			hits, err = searchSegment(ctx, view, reader.valid[i], queries, params, params.topK+params.offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		pks := view.Data.Data[reader.pkField.GetFieldID()]
+		for _, queryHits := range hits {
+			for j := range queryHits {
+				queryHits[j].Source = i
+				queryHits[j].PK = pks.GetRow(queryHits[j].Offset)
+			}
+		}
+		results = append(results, hits)
+	}
+	return search.Reduce(results, nq, params.topK, params.offset, params.metricType), nil
+}
+
+// newSearchResultData returns the results of hits of each query with the output fields, the sources of hits are the indexes of views
+func newSearchResultData(ctx context.Context, pkField *schemapb.FieldSchema, views []*segments.SegmentView, hits [][]search.Hit,
+	topK int, roundDecimal int, outputFields *projection) (*schemapb.SearchResultData, error) {
+	resultData := &schemapb.SearchResultData{
+		NumQueries: int64(len(hits)),
+		TopK:       int64(topK),
+		Scores:     make([]float32, 0),
+		Topks:      make([]int64, 0, len(hits)),
+	}
+	rows := make([]rowRef, 0)
+	pks := make([]storage.PrimaryKey, 0)
+	for _, queryHits := range hits {
+		resultData.Topks = append(resultData.Topks, int64(len(queryHits)))
+		for _, hit := range queryHits {
+			resultData.Scores = append(resultData.Scores, roundScore(hit.Score, roundDecimal))
+			pk, err := storage.NewPrimaryKey(hit.PK)
+			if err != nil {
+				return nil, err
+			}
+			pks = append(pks, pk)
+			rows = append(rows, rowRef{view: views[hit.Source], offset: hit.Offset})
+		}
+	}
+	resultData.Ids = storage.PrimaryKeysToIDs(pkField.GetDataType(), pks)
+	fieldsData, err := buildFieldsData(ctx, outputFields, rows)
+	if err != nil {
+		return nil, err
+	}
+	resultData.FieldsData = fieldsData
+	resultData.OutputFields = outputFields.Names()
+	return resultData, nil
+}
+
+// searchSegment searches the valid rows of segment by its vector index with the filter bitset applied.
+// Segments not indexed yet are searched by brute force, so are the segments whose rows are mostly filtered out.
+// The index results are replaced by brute force if they miss any valid row the topK needs,
+// or if any of them is out of the range of range search, then rows in range may be missed.
+func searchSegment(ctx context.Context, view *segments.SegmentView, valid []bool, queries storage.FieldData,
+	params *searchParams, topK int) ([][]search.Hit, error) {
+	bruteForce := func() ([][]search.Hit, error) {
+		if err := view.LoadFields(ctx, params.field.GetFieldID()); err != nil {
+			return nil, err
+		}
+		return search.RangeSearch(view.Data.Data[params.field.GetFieldID()], queries, params.metricType, topK, valid, params.scoreRange)
+	}
+	validNum := countValid(valid)
+	vectorIndex, ok := view.Segment.GetIndex(params.field.GetFieldID()).(index.VectorIndex)
+	if !ok || vectorIndex.MetricType() != params.metricType || float64(view.RowNum-validNum) > BruteForceFilterRatio*float64(view.RowNum) {
+		return bruteForce()
+	}
+	hits, err := vectorIndex.Search(queries, topK, params.params, valid)
+	if err != nil {
+		return nil, err
+	}
+	expected := topK
+	if validNum < expected {
+		expected = validNum
+	}
+	for _, queryHits := range hits {
+		if len(queryHits) < expected {
+			return bruteForce()
+		}
+		for _, hit := range queryHits {
+			if !params.scoreRange.Contains(params.metricType, hit.Score) {
+				return bruteForce()
+			}
+		}
+	}
+	return hits, nil
+}
+
+// searchSegmentGroups searches the best hits of segment for topK + offset groups of each query, groups of hits are set.
+// The segment is searched again with doubled k until there're enough groups or all valid rows are searched.
+func searchSegmentGroups(ctx context.Context, view *segments.SegmentView, valid []bool, queries storage.FieldData,
+	params *searchParams) ([][]search.Hit, error) {
+	topK := params.topK + params.offset
+	groups := view.Data.Data[params.groupByField.GetFieldID()]
+	validNum := countValid(valid)
+	for k := topK; ; k *= 2 {
+		var hits [][]search.Hit
+		var err error
+		if k > MaxTopK {
+			// too many rows share groups, sort all valid rows
+			if err := view.LoadFields(ctx, params.field.GetFieldID()); err != nil {
+				return nil, err
+			}
+			hits, err = search.RangeSearch(view.Data.Data[params.field.GetFieldID()], queries, params.metricType, validNum, valid, params.scoreRange)
+		} else {
+			// graph indexes reject ef & search_list less than k
+			kParams := *params
+			kParams.params = make(map[string]any, len(params.params))
+			for key, value := range params.params {
+				if v, ok := value.(float64); ok && (key == index.EfKey || key == index.SearchListKey) && v < float64(k) {
+					value = float64(k)
+				}
+				kParams.params[key] = value
+			}
+			hits, err = searchSegment(ctx, view, valid, queries, &kParams, k)
+		}
+		if err != nil {
+			return nil, err
+		}
+		enough := true
+		for _, queryHits := range hits {
+			distinct := make(map[any]struct{})
+			for j := range queryHits {
+				queryHits[j].Group = groups.GetRow(queryHits[j].Offset)
+				distinct[queryHits[j].Group] = struct{}{}
+			}
+			// fewer hits than k means all valid rows in range are searched
+			if len(distinct) < topK && len(queryHits) >= k {
+				enough = false
+			}
+		}
+		if enough || k > MaxTopK || k >= validNum {
+			return hits, nil
+		}
+	}
+}
+
+func countValid(valid []bool) int {
+	ret := 0
+	for _, v := range valid {
+		if v {
+			ret++
+		}
+	}
+	return ret
+}
+
+// parseSearchParams parses the search params, the metric type must match the index of the vector field if any
+func parseSearchParams(schema *schemapb.CollectionSchema, kvs []*commonpb.KeyValuePair, indexes []*model.Index) (*searchParams, error) {
+	ret := &searchParams{roundDecimal: -1, params: make(map[string]any)}
+	var annsField, groupByField string
+	for _, kv := range kvs {
+		var err error
+		switch kv.GetKey() {
+		case AnnsFieldKey:
+			annsField = kv.GetValue()
+		case common.TopKKey:
+			ret.topK, err = strconv.Atoi(kv.GetValue())
+			if err == nil && (ret.topK <= 0 || ret.topK > MaxTopK) {
+				err = merr.WrapErrParameterInvalidRange(1, MaxTopK, ret.topK, "invalid topk")
+			}
+		case OffsetKey:
+			ret.offset, err = strconv.Atoi(kv.GetValue())
+		case common.MetricTypeKey:
+			ret.metricType = strings.ToUpper(kv.GetValue())
+		case RoundDecimalKey:
+			ret.roundDecimal, err = strconv.Atoi(kv.GetValue())
+			if err == nil && (ret.roundDecimal < -1 || ret.roundDecimal > 6) {
+				err = merr.WrapErrParameterInvalidRange(-1, 6, ret.roundDecimal, "invalid round_decimal")
+			}
+		case GroupByFieldKey:
+			groupByField = kv.GetValue()
+		case common.IndexParamsKey, common.SearchParamKey:
+			if kv.GetValue() != "" {
+				err = json.Unmarshal([]byte(kv.GetValue()), &ret.params)
+			}
+		}
+		if err != nil {
+			return nil, merr.WrapErrParameterInvalidMsg("invalid search param %s: %s, %s", kv.GetKey(), kv.GetValue(), err.Error())
+		}
+	}
+	if ret.topK == 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("%s not found in search params", common.TopKKey)
+	}
+	if ret.offset < 0 || ret.offset+ret.topK > MaxTopK {
+		return nil, merr.WrapErrParameterInvalidRange(0, MaxTopK-ret.topK, ret.offset, "invalid offset")
+	}
+
+	vectorFields := make([]*schemapb.FieldSchema, 0)
+	for _, field := range schema.GetFields() {
+		if storage.IsVectorType(field.GetDataType()) && (annsField == "" || field.GetName() == annsField) {
+			vectorFields = append(vectorFields, field)
+		}
+	}
+	if len(vectorFields) == 0 {
+		return nil, merr.WrapErrFieldNotFound(annsField, "vector field not found")
+	}
+	if len(vectorFields) > 1 {
+		return nil, merr.WrapErrParameterInvalidMsg("%s is required when there're multiple vector fields", AnnsFieldKey)
+	}
+	ret.field = vectorFields[0]
+	for _, fieldIndex := range indexes {
+		if fieldIndex.FieldID != ret.field.GetFieldID() {
+			continue
+		}
+		if ret.metricType == "" {
+			ret.metricType = fieldIndex.GetMetricType()
+		}
+		if ret.metricType != fieldIndex.GetMetricType() {
+			return nil, merr.WrapErrParameterInvalidMsg("metric type %s mismatches the index %s of %s",
+				ret.metricType, fieldIndex.IndexName, fieldIndex.GetMetricType())
+		}
+	}
+	if ret.metricType == "" {
+		ret.metricType = defaultMetricType(ret.field.GetDataType())
+	}
+	var err error
+	ret.scoreRange, err = parseRange(ret.params, ret.metricType)
+	if err != nil {
+		return nil, err
+	}
+	if groupByField != "" {
+		for _, field := range schema.GetFields() {
+			if field.GetName() == groupByField {
+				ret.groupByField = field
+			}
+		}
+		if ret.groupByField == nil {
+			return nil, merr.WrapErrFieldNotFound(groupByField)
+		}
+		dataType := ret.groupByField.GetDataType()
+		if !typeutil.IsIntegerType(dataType) && !typeutil.IsStringType(dataType) && !typeutil.IsBoolType(dataType) {
+			return nil, merr.WrapErrParameterInvalidMsg("group by field %s of %s is not supported, it must be integer, string or bool",
+				groupByField, dataType.String())
+		}
+	}
+	return ret, nil
+}
+
+// parseRange parses the radius & range_filter of range search, it's nil if radius is not set.
+// range_filter must be better than radius, i.e. smaller for distances and larger for similarities.
+func parseRange(params map[string]any, metricType string) (*search.Range, error) {
+	radius, ok := params[RadiusKey]
+	if !ok {
+		if _, ok := params[RangeFilterKey]; ok {
+			return nil, merr.WrapErrParameterInvalidMsg("%s requires %s", RangeFilterKey, RadiusKey)
+		}
+		return nil, nil
+	}
+	ret := &search.Range{}
+	value, ok := radius.(float64)
+	if !ok {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid %s: %v", RadiusKey, radius)
+	}
+	ret.Radius = float32(value)
+	if rangeFilter, ok := params[RangeFilterKey]; ok {
+		value, ok := rangeFilter.(float64)
+		if !ok {
+			return nil, merr.WrapErrParameterInvalidMsg("invalid %s: %v", RangeFilterKey, rangeFilter)
+		}
+		bound := float32(value)
+		if !search.Less(metricType)(bound, ret.Radius) {
+			return nil, merr.WrapErrParameterInvalidMsg("%s %v must be better than %s %v for metric type %s",
+				RangeFilterKey, bound, RadiusKey, ret.Radius, metricType)
+		}
+		ret.RangeFilter = &bound
+	}
+	return ret, nil
+}
+
+// parsePlaceholderGroup parses the query vectors of request, they must match the vector field
+func parsePlaceholderGroup(field *schemapb.FieldSchema, value []byte) (storage.FieldData, error) {
+	group := &commonpb.PlaceholderGroup{}
+	if err := proto.Unmarshal(value, group); err != nil {
+		return nil, merr.WrapErrParameterInvalidMsg("invalid placeholder group: %s", err.Error())
+	}
+	if len(group.GetPlaceholders()) != 1 || len(group.GetPlaceholders()[0].GetValues()) == 0 {
+		return nil, merr.WrapErrParameterInvalidMsg("no query vector")
+	}
+	placeholder := group.GetPlaceholders()[0]
+	queries, err := storage.NewFieldData(field)
+	if err != nil {
+		return nil, err
+	}
+	dim, err := storage.GetDim(field)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range placeholder.GetValues() {
+		var row any
+		switch {
+		case field.GetDataType() == schemapb.DataType_FloatVector && placeholder.GetType() == commonpb.PlaceholderType_FloatVector:
+			if int64(len(value)) != dim*4 {
+				return nil, merr.WrapErrParameterInvalid(dim, int64(len(value)/4), "dimension mismatch")
+			}
+			vector := make([]float32, dim)
+			for i := range vector {
+				vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(value[i*4:]))
+			}
+			row = vector
+		case field.GetDataType() == schemapb.DataType_Float16Vector && placeholder.GetType() == commonpb.PlaceholderType_Float16Vector,
+			field.GetDataType() == storage.DataType_BFloat16Vector && placeholder.GetType() == storage.PlaceholderType_BFloat16Vector:
+			if int64(len(value)) != dim*2 {
+				return nil, merr.WrapErrParameterInvalid(dim, int64(len(value)/2), "dimension mismatch")
+			}
+			row = value
+		case field.GetDataType() == schemapb.DataType_BinaryVector && placeholder.GetType() == commonpb.PlaceholderType_BinaryVector:
+			if int64(len(value)) != dim/8 {
+				return nil, merr.WrapErrParameterInvalid(dim, int64(len(value)*8), "dimension mismatch")
+			}
+			row = value
+		case storage.IsSparseVectorType(field.GetDataType()) && placeholder.GetType() == storage.PlaceholderType_SparseFloatVector:
+			if err := storage.ValidateSparseRow(value); err != nil {
+				return nil, merr.WrapErrParameterInvalidMsg("invalid query vector: %s", err.Error())
+			}
+			row = value
+		default:
+			return nil, merr.WrapErrParameterInvalidMsg("query vector type %s mismatches field %s of %s",
+				placeholder.GetType().String(), field.GetName(), field.GetDataType().String())
+		}
+		if err := queries.AppendRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return queries, nil
+}
+
+// defaultMetricType returns the metric type of vectors if it's not specified
+func defaultMetricType(dataType schemapb.DataType) string {
+	switch {
+	case dataType == schemapb.DataType_BinaryVector:
+		return metric.HAMMING
+	case storage.IsSparseVectorType(dataType):
+		return metric.IP
+	}
+	return metric.L2
+}
+
+// roundScore rounds the score to decimal places, -1 means no rounding
+func roundScore(score float32, decimal int) float32 {
+	if decimal < 0 {
+		return score
+	}
+	pow := math.Pow(10, float64(decimal))
+	return float32(math.Round(float64(score)*pow) / pow)
+}
@@ -0,0 +1,125 @@
+package search
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// DistanceFunc computes the distance of two vectors, the vectors are []float32 or []byte,
+// float16, bfloat16 and sparse rows are []byte
+type DistanceFunc func(a, b any) float32
+
+// GetDistanceFunc returns the distance function of metric type for vectors of data type
+func GetDistanceFunc(metricType string, dataType schemapb.DataType) (DistanceFunc, error) {
+	switch dataType {
+	case schemapb.DataType_FloatVector:
+		switch strings.ToUpper(metricType) {
+		case metric.L2:
+			return func(a, b any) float32 { return L2(a.([]float32), b.([]float32)) }, nil
+		case metric.IP:
+			return func(a, b any) float32 { return IP(a.([]float32), b.([]float32)) }, nil
+		case metric.COSINE:
+			return func(a, b any) float32 { return Cosine(a.([]float32), b.([]float32)) }, nil
+		}
+	case schemapb.DataType_Float16Vector, storage.DataType_BFloat16Vector:
+		// rows are packed bytes, they're decoded into float32 before computing
+		decode := storage.Float16ToFloat32s
+		if dataType == storage.DataType_BFloat16Vector {
+			decode = storage.BFloat16ToFloat32s
+		}
+		distance, err := GetDistanceFunc(metricType, schemapb.DataType_FloatVector)
+		if err != nil {
+			break
+		}
+		return func(a, b any) float32 { return distance(decode(a.([]byte)), decode(b.([]byte))) }, nil
+	case schemapb.DataType_BinaryVector:
+		switch strings.ToUpper(metricType) {
+		case metric.HAMMING:
+			return func(a, b any) float32 { return Hamming(a.([]byte), b.([]byte)) }, nil
+		case metric.JACCARD:
+			return func(a, b any) float32 { return Jaccard(a.([]byte), b.([]byte)) }, nil
+		}
+	case storage.DataType_SparseFloatVector:
+		if strings.ToUpper(metricType) == metric.IP {
+			return func(a, b any) float32 { return SparseIP(a.([]byte), b.([]byte)) }, nil
+		}
+	}
+	return nil, merr.WrapErrParameterInvalidMsg("metric type %s is not supported for %s", metricType, dataType.String())
+}
+
+// L2 returns the squared euclidean distance, same as milvus
+func L2(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+func IP(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func Cosine(a, b []float32) float32 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
+
+func Hamming(a, b []byte) float32 {
+	var num int
+	for i := range a {
+		num += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return float32(num)
+}
+
+func Jaccard(a, b []byte) float32 {
+	var intersection, union int
+	for i := range a {
+		intersection += bits.OnesCount8(a[i] & b[i])
+		union += bits.OnesCount8(a[i] | b[i])
+	}
+	if union == 0 {
+		return 0
+	}
+	return 1 - float32(intersection)/float32(union)
+}
+
+// SparseIP returns the inner product of two sparse rows, the indexes of both are merged in ascending order
+func SparseIP(a, b []byte) float32 {
+	var sum float32
+	for i, j := 0, 0; i < storage.SparseRowLen(a) && j < storage.SparseRowLen(b); {
+		indexA, valueA := storage.SparseRowAt(a, i)
+		indexB, valueB := storage.SparseRowAt(b, j)
+		switch {
+		case indexA < indexB:
+			i++
+		case indexA > indexB:
+			j++
+		default:
+			sum += valueA * valueB
+			i++
+			j++
+		}
+	}
+	return sum
+}
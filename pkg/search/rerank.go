@@ -0,0 +1,61 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/milvus-io/milvus/pkg/util/metric"
+)
+
+// DefaultRRFK is the default smoothing constant k of reciprocal rank fusion, same as milvus
+const DefaultRRFK = 60
+
+// RRF fuses the ranked hits of several searches of one query by reciprocal rank fusion,
+// the score of a row is the sum of 1 / (k + rank) of the searches hitting it, ranks start from 1.
+// See https://plg.uwaterloo.ca/~gvcormac/cormacksigir09-rrf.pdf.
+func RRF(results [][]Hit, k float64) []Hit {
+	return fuse(results, func(i, rank int, hit Hit) float32 {
+		return float32(1 / (k + float64(rank+1)))
+	})
+}
+
+// WeightedFusion fuses the ranked hits of several searches of one query by the weighted sum of scores,
+// scores of each search are normalized by its metric type into [0, 1] first, larger is better.
+func WeightedFusion(results [][]Hit, metricTypes []string, weights []float64) []Hit {
+	return fuse(results, func(i, rank int, hit Hit) float32 {
+		return float32(weights[i] * NormalizeScore(metricTypes[i], hit.Score))
+	})
+}
+
+// NormalizeScore maps the score of metric type into [0, 1] where larger is better, same as milvus.
+// COSINE is scaled linearly, IP is mapped by arctan, distances are mapped by arctan and reversed.
+func NormalizeScore(metricType string, score float32) float64 {
+	switch strings.ToUpper(metricType) {
+	case metric.COSINE:
+		return (1 + float64(score)) / 2
+	case metric.IP:
+		return 0.5 + math.Atan(float64(score))/math.Pi
+	}
+	return 1 - 2*math.Atan(float64(score))/math.Pi
+}
+
+// fuse sums the scores of the hits of the same primary key, the fused hits are sorted from the best to the worst.
+// The source & offset of a fused hit are the ones of its first hit, hits of the same score keep the order they're seen.
+func fuse(results [][]Hit, score func(i, rank int, hit Hit) float32) []Hit {
+	ret := make([]Hit, 0)
+	positions := make(map[any]int)
+	for i, hits := range results {
+		for rank, hit := range hits {
+			pos, ok := positions[hit.PK]
+			if !ok {
+				pos = len(ret)
+				positions[hit.PK] = pos
+				ret = append(ret, Hit{Source: hit.Source, Offset: hit.Offset, PK: hit.PK})
+			}
+			ret[pos].Score += score(i, rank, hit)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Score > ret[j].Score })
+	return ret
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// Hit is a row matched by search
+type Hit struct {
+	// Source is the index of the searched segment
+	Source int
+	Offset int
+	PK     any
+	Score  float32
+	// Group is the value of group by field, only the best hit of each group is kept by Reduce if it's set
+	Group any
+}
+
+// Less returns whether score a is better than score b for the metric type,
+// larger is better for IP & COSINE, smaller is better for distances.
+func Less(metricType string) func(a, b float32) bool {
+	if metric.PositivelyRelated(metricType) {
+		return func(a, b float32) bool { return a > b }
+	}
+	return func(a, b float32) bool { return a < b }
+}
+
+// Range is the band of scores kept by range search. Distances are in [RangeFilter, Radius),
+// similarities of IP & COSINE are in (Radius, RangeFilter]. RangeFilter is not checked if it's nil.
+type Range struct {
+	Radius      float32
+	RangeFilter *float32
+}
+
+// Contains returns whether the score of metric type is in the band, nil range contains all scores
+func (r *Range) Contains(metricType string, score float32) bool {
+	if r == nil {
+		return true
+	}
+	if metric.PositivelyRelated(metricType) {
+		return score > r.Radius && (r.RangeFilter == nil || score <= *r.RangeFilter)
+	}
+	return score < r.Radius && (r.RangeFilter == nil || score >= *r.RangeFilter)
+}
+
+// BruteForce returns the topK nearest rows of vectors for each query, rows not valid are skipped.
+// valid could be nil if all rows are candidates.
+func BruteForce(vectors, queries storage.FieldData, metricType string, topK int, valid []bool) ([][]Hit, error) {
+	return RangeSearch(vectors, queries, metricType, topK, valid, nil)
+}
+
+// RangeSearch returns the topK nearest rows of vectors whose scores are in the range for each query,
+// it's the same as BruteForce if scoreRange is nil.
+func RangeSearch(vectors, queries storage.FieldData, metricType string, topK int, valid []bool, scoreRange *Range) ([][]Hit, error) {
+	distance, err := GetDistanceFunc(metricType, vectors.GetDataType())
+	if err != nil {
+		return nil, err
+	}
+	ret := make([][]Hit, queries.RowNum())
+	for q := 0; q < queries.RowNum(); q++ {
+		query := queries.GetRow(q)
+		collector := NewTopK(topK, metricType)
+		for i := 0; i < vectors.RowNum(); i++ {
+			if valid != nil && !valid[i] {
+				continue
+			}
+			score := distance(query, vectors.GetRow(i))
+			if scoreRange.Contains(metricType, score) {
+				collector.Push(i, score)
+			}
+		}
+		ret[q] = collector.Sorted()
+	}
+	return ret, nil
+}
+
+// TopK collects the best k hits of one query
+type TopK struct {
+	k    int
+	heap *hitHeap
+}
+
+func NewTopK(k int, metricType string) *TopK {
+	return &TopK{k: k, heap: &hitHeap{less: Less(metricType)}}
+}
+
+// Push adds the row at offset, it's dropped if worse than all of the k hits
+func (t *TopK) Push(offset int, score float32) {
+	h := t.heap
+	if h.Len() < t.k {
+		heap.Push(h, Hit{Offset: offset, Score: score})
+	} else if h.less(score, h.hits[0].Score) {
+		h.hits[0] = Hit{Offset: offset, Score: score}
+		heap.Fix(h, 0)
+	}
+}
+
+// Len returns the number of hits collected
+func (t *TopK) Len() int {
+	return t.heap.Len()
+}
+
+// Worst returns the worst score of the k hits, ok is false if fewer than k hits are collected
+func (t *TopK) Worst() (score float32, ok bool) {
+	if t.heap.Len() < t.k {
+		return 0, false
+	}
+	return t.heap.hits[0].Score, true
+}
+
+// Sorted returns the hits from the best to the worst, the collector is emptied
+func (t *TopK) Sorted() []Hit {
+	return t.heap.sorted()
+}
+
+// Reduce merges the hits of segments for each query, hits of duplicated primary keys are removed,
+// so are the hits of groups seen if hits are grouped. topK & offset are the numbers of groups then.
+// results[i][q] is the hits of segment i for query q, the sources of hits must be set.
+func Reduce(results [][][]Hit, nq, topK, offset int, metricType string) [][]Hit {
+	less := Less(metricType)
+	ret := make([][]Hit, nq)
+	for q := 0; q < nq; q++ {
+		hits := make([]Hit, 0)
+		for _, result := range results {
+			hits = append(hits, result[q]...)
+		}
+		sort.SliceStable(hits, func(i, j int) bool { return less(hits[i].Score, hits[j].Score) })
+		seen := make(map[any]struct{}, len(hits))
+		seenGroups := make(map[any]struct{})
+		merged := make([]Hit, 0, topK)
+		for _, hit := range hits {
+			if _, ok := seen[hit.PK]; ok {
+				continue
+			}
+			seen[hit.PK] = struct{}{}
+			if hit.Group != nil {
+				if _, ok := seenGroups[hit.Group]; ok {
+					continue
+				}
+				seenGroups[hit.Group] = struct{}{}
+			}
+			merged = append(merged, hit)
+			if len(merged) >= offset+topK {
+				break
+			}
+		}
+		if len(merged) > offset {
+			ret[q] = merged[offset:]
+		} else {
+			ret[q] = []Hit{}
+		}
+	}
+	return ret
+}
+
+// hitHeap keeps the worst hit on the top
+type hitHeap struct {
+	hits []Hit
+	less func(a, b float32) bool
+}
+
+func (h *hitHeap) Len() int           { return len(h.hits) }
+func (h *hitHeap) Less(i, j int) bool { return h.less(h.hits[j].Score, h.hits[i].Score) }
+func (h *hitHeap) Swap(i, j int)      { h.hits[i], h.hits[j] = h.hits[j], h.hits[i] }
+func (h *hitHeap) Push(x any)         { h.hits = append(h.hits, x.(Hit)) }
+func (h *hitHeap) Pop() any {
+	last := h.hits[len(h.hits)-1]
+	h.hits = h.hits[:len(h.hits)-1]
+	return last
+}
+
+// sorted returns the hits from the best to the worst
+func (h *hitHeap) sorted() []Hit {
+	ret := make([]Hit, h.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(h).(Hit)
+	}
+	return ret
+}
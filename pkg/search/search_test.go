@@ -0,0 +1,130 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/metric"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	a, b := []float32{1, 0}, []float32{0, 1}
+	assert.Equal(t, float32(2), L2(a, b))
+	assert.Equal(t, float32(0), IP(a, b))
+	assert.Equal(t, float32(1), Cosine(a, a))
+	assert.Equal(t, float32(2), Hamming([]byte{0b0101}, []byte{0b0110}))
+	assert.Equal(t, float32(1)-float32(1)/float32(3), Jaccard([]byte{0b0101}, []byte{0b0110}))
+	for dataType, encode := range map[schemapb.DataType]func([]float32) []byte{
+		schemapb.DataType_Float16Vector: storage.Float32sToFloat16,
+		storage.DataType_BFloat16Vector: storage.Float32sToBFloat16,
+	} {
+		distance, err := GetDistanceFunc(metric.L2, dataType)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(2), distance(encode(a), encode(b)))
+		distance, err = GetDistanceFunc(metric.IP, dataType)
+		assert.NoError(t, err)
+		assert.Equal(t, float32(6.5), distance(encode([]float32{1, 2}), encode([]float32{0.5, 3})))
+		_, err = GetDistanceFunc(metric.HAMMING, dataType)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, float32(6), SparseIP(storage.NewSparseRow(map[uint32]float32{1: 1, 3: 2, 7: 5}), storage.NewSparseRow(map[uint32]float32{0: 1, 3: 3, 8: 1})))
+}
+
+func TestBruteForce(t *testing.T) {
+	vectors := &storage.FloatVectorFieldData{Dim: 2, Data: []float32{0, 0, 1, 1, 2, 2, 3, 3}}
+	queries := &storage.FloatVectorFieldData{Dim: 2, Data: []float32{2.1, 2.1}}
+
+	hits, err := BruteForce(vectors, queries, metric.L2, 2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, hits, 1)
+	assert.Equal(t, 2, hits[0][0].Offset)
+	assert.Equal(t, 3, hits[0][1].Offset)
+
+	hits, err = BruteForce(vectors, queries, metric.L2, 2, []bool{true, true, false, true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, hits[0][0].Offset)
+	assert.Equal(t, 1, hits[0][1].Offset)
+
+	hits, err = BruteForce(vectors, queries, metric.IP, 10, nil)
+	assert.NoError(t, err)
+	assert.Len(t, hits[0], 4)
+	assert.Equal(t, 3, hits[0][0].Offset)
+
+	_, err = BruteForce(vectors, queries, metric.HAMMING, 10, nil)
+	assert.Error(t, err)
+}
+
+func TestRangeSearch(t *testing.T) {
+	vectors := &storage.FloatVectorFieldData{Dim: 2, Data: []float32{0, 0, 1, 1, 2, 2, 3, 3}}
+	queries := &storage.FloatVectorFieldData{Dim: 2, Data: []float32{1, 1}}
+	rangeFilter := float32(1)
+
+	// distances are 2, 0, 2, 8
+	hits, err := RangeSearch(vectors, queries, metric.L2, 10, nil, &Range{Radius: 8})
+	assert.NoError(t, err)
+	assert.Len(t, hits[0], 3)
+	assert.Equal(t, 1, hits[0][0].Offset)
+	hits, err = RangeSearch(vectors, queries, metric.L2, 10, nil, &Range{Radius: 8, RangeFilter: &rangeFilter})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{0, 2}, []int{hits[0][0].Offset, hits[0][1].Offset})
+	hits, err = RangeSearch(vectors, queries, metric.L2, 1, []bool{true, true, false, true}, &Range{Radius: 8, RangeFilter: &rangeFilter})
+	assert.NoError(t, err)
+	assert.Len(t, hits[0], 1)
+	assert.Equal(t, 0, hits[0][0].Offset)
+
+	// similarities are 0, 2, 4, 6
+	rangeFilter = 4
+	hits, err = RangeSearch(vectors, queries, metric.IP, 10, nil, &Range{Radius: 0, RangeFilter: &rangeFilter})
+	assert.NoError(t, err)
+	assert.Len(t, hits[0], 2)
+	assert.Equal(t, []int{2, 1}, []int{hits[0][0].Offset, hits[0][1].Offset})
+	assert.True(t, (*Range)(nil).Contains(metric.IP, -1))
+}
+
+func TestReduce(t *testing.T) {
+	results := [][][]Hit{
+		{{{Source: 0, Offset: 0, PK: int64(1), Score: 0.1}, {Source: 0, Offset: 1, PK: int64(2), Score: 0.5}}},
+		{{{Source: 1, Offset: 0, PK: int64(1), Score: 0.2}, {Source: 1, Offset: 1, PK: int64(3), Score: 0.3}}},
+	}
+	reduced := Reduce(results, 1, 2, 0, metric.L2)
+	assert.Equal(t, []Hit{results[0][0][0], results[1][0][1]}, reduced[0])
+
+	reduced = Reduce(results, 1, 2, 1, metric.L2)
+	assert.Equal(t, []Hit{results[1][0][1], results[0][0][1]}, reduced[0])
+
+	reduced = Reduce(results, 1, 2, 1, metric.IP)
+	assert.Equal(t, []Hit{results[1][0][1], results[1][0][0]}, reduced[0])
+
+	// pk 1 & 3 are of the same group
+	results[0][0][0].Group, results[0][0][1].Group = "a", "b"
+	results[1][0][0].Group, results[1][0][1].Group = "a", "a"
+	reduced = Reduce(results, 1, 2, 0, metric.L2)
+	assert.Equal(t, []Hit{results[0][0][0], results[0][0][1]}, reduced[0])
+}
+
+func TestRerank(t *testing.T) {
+	results := [][]Hit{
+		{{Source: 0, Offset: 0, PK: int64(1), Score: 0.1}, {Source: 0, Offset: 1, PK: int64(2), Score: 0.5}},
+		{{Source: 1, Offset: 3, PK: int64(2), Score: 0.9}, {Source: 1, Offset: 4, PK: int64(3), Score: 0.3}},
+	}
+	fused := RRF(results, 1)
+	assert.Equal(t, []Hit{
+		{Source: 0, Offset: 1, PK: int64(2), Score: float32(1.0/3) + float32(1.0/2)},
+		{Source: 0, Offset: 0, PK: int64(1), Score: float32(1.0 / 2)},
+		{Source: 1, Offset: 4, PK: int64(3), Score: float32(1.0 / 3)},
+	}, fused)
+
+	// L2 distances 0.1 & 0.5 are better than COSINE 0.3
+	fused = WeightedFusion(results, []string{metric.L2, metric.COSINE}, []float64{1, 0.5})
+	assert.Equal(t, []any{int64(2), int64(1), int64(3)}, []any{fused[0].PK, fused[1].PK, fused[2].PK})
+	assert.InDelta(t, NormalizeScore(metric.L2, 0.5)+0.5*0.95, fused[0].Score, 1e-6)
+	assert.InDelta(t, 0.65, fused[2].Score*2, 1e-6)
+
+	assert.Equal(t, float64(1), NormalizeScore(metric.L2, 0))
+	assert.Equal(t, 0.5, NormalizeScore(metric.IP, 0))
+	assert.Equal(t, float64(0), NormalizeScore(metric.COSINE, -1))
+	assert.Less(t, NormalizeScore(metric.IP, 1), NormalizeScore(metric.IP, 2))
+	assert.Greater(t, NormalizeScore(metric.HAMMING, 1), NormalizeScore(metric.HAMMING, 2))
+}
@@ -0,0 +1,118 @@
+package segments
+
+import (
+	"context"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+	pb "github.com/sharding-db/milvus-mini/pkg/etcdpb"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+)
+
+// WriteBinlogs serializes data into one binlog per field and writes them into chunk manager
+func WriteBinlogs(ctx context.Context, cm storage.ChunkManager, idAllocator allocator.Interface,
+	schema *schemapb.CollectionSchema, meta *model.Segment, data *storage.InsertData) ([]*model.FieldBinlog, error) {
+	if data.GetRowNum() == 0 {
+		return nil, nil
+	}
+	codec := storage.NewInsertCodecWithSchema(&pb.CollectionMeta{ID: meta.CollectionID, Schema: schema})
+	blobs, err := codec.Serialize(meta.PartitionID, meta.SegmentID, data)
+	if err != nil {
+		return nil, err
+	}
+	logID, _, err := idAllocator.Alloc(uint32(len(blobs)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to alloc log id")
+	}
+	tss := data.Data[common.TimeStampField].(*storage.Int64FieldData).Data
+	kvs := make(map[string][]byte, len(blobs))
+	ret := make([]*model.FieldBinlog, 0, len(blobs))
+	for i, field := range schema.GetFields() {
+		path := storage.BuildInsertLogPath(meta.CollectionID, meta.PartitionID, meta.SegmentID, field.GetFieldID(), logID+int64(i))
+		kvs[path] = blobs[i].Value
+		ret = append(ret, &model.FieldBinlog{
+			FieldID: field.GetFieldID(),
+			Binlogs: []*model.Binlog{{
+				EntriesNum:    blobs[i].RowNum,
+				TimestampFrom: uint64(minInt64(tss)),
+				TimestampTo:   uint64(maxInt64(tss)),
+				LogPath:       path,
+				LogSize:       int64(len(blobs[i].Value)),
+				LogID:         logID + int64(i),
+				MemorySize:    blobs[i].MemorySize,
+			}},
+		})
+	}
+	if err := cm.MultiWrite(ctx, kvs); err != nil {
+		return nil, errors.Wrapf(err, "failed to write binlogs of segment %d", meta.SegmentID)
+	}
+	return ret, nil
+}
+
+// WriteDeltalog writes the delete records into one deltalog
+func WriteDeltalog(ctx context.Context, cm storage.ChunkManager, idAllocator allocator.Interface,
+	meta *model.Segment, data *storage.DeleteData) (*model.Binlog, error) {
+	blob, err := storage.NewDeleteCodec().Serialize(meta.CollectionID, meta.PartitionID, meta.SegmentID, data)
+	if err != nil {
+		return nil, err
+	}
+	logID, err := idAllocator.AllocOne()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to alloc log id")
+	}
+	path := storage.BuildDeltaLogPath(meta.CollectionID, meta.PartitionID, meta.SegmentID, logID)
+	if err := cm.Write(ctx, path, blob.Value); err != nil {
+		return nil, errors.Wrapf(err, "failed to write deltalog of segment %d", meta.SegmentID)
+	}
+	var from, to uint64
+	for i, ts := range data.Tss {
+		if i == 0 || ts < from {
+			from = ts
+		}
+		if ts > to {
+			to = ts
+		}
+	}
+	return &model.Binlog{
+		EntriesNum:    blob.RowNum,
+		TimestampFrom: from,
+		TimestampTo:   to,
+		LogPath:       path,
+		LogSize:       int64(len(blob.Value)),
+		LogID:         logID,
+		MemorySize:    blob.MemorySize,
+	}, nil
+}
+
+// removeSegmentLogs removes all binlogs & deltalogs of the segment
+func removeSegmentLogs(ctx context.Context, cm storage.ChunkManager, meta *model.Segment) error {
+	for _, prefix := range storage.BuildSegmentPrefixes(meta.CollectionID, meta.PartitionID, meta.SegmentID) {
+		if err := cm.RemoveWithPrefix(ctx, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func minInt64(values []int64) int64 {
+	ret := values[0]
+	for _, v := range values {
+		if v < ret {
+			ret = v
+		}
+	}
+	return ret
+}
+
+func maxInt64(values []int64) int64 {
+	ret := values[0]
+	for _, v := range values {
+		if v > ret {
+			ret = v
+		}
+	}
+	return ret
+}
@@ -0,0 +1,261 @@
+package segments
+
+import (
+	"context"
+	"time"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"go.uber.org/zap"
+)
+
+// buildTask is a queued index build of a flushed segment.
+// Its segment index is persisted with EnableIndex false until the build finishes, so it's recovered after restart.
+type buildTask struct {
+	segment      *Segment
+	fieldIndex   *model.Index
+	segmentIndex *model.SegmentIndex
+	// growingIndex is built while the segment was growing, it has all rows so it's persisted without rebuilding
+	growingIndex index.GrowingIndex
+}
+
+// recoverIndexBuilds queues the builds which were not finished before restart
+func (m *Manager) recoverIndexBuilds(ctx context.Context) error {
+	m.lock.RLock()
+	segments := make([]*Segment, 0)
+	for _, collSegments := range m.collections {
+		for _, segment := range collSegments.sealed {
+			segments = append(segments, segment)
+		}
+	}
+	m.lock.RUnlock()
+	for _, segment := range segments {
+		if err := m.BuildSegmentIndexes(ctx, segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildSegmentIndexes queues the builds of indexes which are not built on the flushed segment,
+// the builds run in background one by one. The indexes are kept in memory if the segment is loaded.
+func (m *Manager) BuildSegmentIndexes(ctx context.Context, segment *Segment) error {
+	m.indexLock.Lock()
+	defer m.indexLock.Unlock()
+	if segment.Meta().State != commonpb.SegmentState_Flushed {
+		return nil
+	}
+	indexes, err := m.meta.ListIndexes(ctx, segment.CollectionID())
+	if err != nil || len(indexes) == 0 {
+		return err
+	}
+	segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, segment.CollectionID())
+	if err != nil {
+		return err
+	}
+	existing := make(map[UniqueID]*model.SegmentIndex)
+	for _, segmentIndex := range segmentIndexes {
+		if segmentIndex.SegmentID == segment.ID() {
+			existing[segmentIndex.IndexID] = segmentIndex
+		}
+	}
+	tasks := make([]*buildTask, 0, len(indexes))
+	for _, fieldIndex := range indexes {
+		segmentIndex, ok := existing[fieldIndex.IndexID]
+		if ok && segmentIndex.EnableIndex {
+			continue
+		}
+		if !ok {
+			segmentIndex, err = m.saveBuildingSegmentIndex(ctx, segment, fieldIndex)
+			if err != nil {
+				return errors.Wrapf(err, "failed to build index %s on segment %d", fieldIndex.IndexName, segment.ID())
+			}
+		}
+		task := &buildTask{segment: segment, fieldIndex: fieldIndex, segmentIndex: segmentIndex}
+		growingIndex, ok := segment.GetIndex(fieldIndex.FieldID).(index.GrowingIndex)
+		if ok && growingIndex.IndexType() == fieldIndex.GetIndexType() && growingIndex.MetricType() == fieldIndex.GetMetricType() {
+			task.growingIndex = growingIndex
+		}
+		tasks = append(tasks, task)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, task := range tasks {
+		buildID := task.segmentIndex.BuildID
+		if _, ok := m.builds[buildID]; ok {
+			continue
+		}
+		// failed builds are retried
+		delete(m.buildErrors, buildID)
+		m.builds[buildID] = task
+		m.buildQueue = append(m.buildQueue, task)
+	}
+	if !m.building && len(m.buildQueue) > 0 {
+		m.building = true
+		go m.runBuilds()
+	}
+	return nil
+}
+
+// saveBuildingSegmentIndex allocates the build id and saves the segment index which is not built yet
+func (m *Manager) saveBuildingSegmentIndex(ctx context.Context, segment *Segment, fieldIndex *model.Index) (*model.SegmentIndex, error) {
+	buildID, err := m.idAllocator.AllocOne()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to alloc build id")
+	}
+	meta := segment.Meta()
+	segmentIndex := &model.SegmentIndex{
+		CollectionID: meta.CollectionID,
+		PartitionID:  meta.PartitionID,
+		SegmentID:    meta.SegmentID,
+		FieldID:      fieldIndex.FieldID,
+		IndexID:      fieldIndex.IndexID,
+		BuildID:      buildID,
+		CreateTime:   tsoutil.ComposeTSByTime(time.Now(), 0),
+		NumRows:      meta.NumOfRows,
+	}
+	if err := m.meta.SaveSegmentIndex(ctx, segmentIndex); err != nil {
+		return nil, err
+	}
+	return segmentIndex, nil
+}
+
+// runBuilds builds the queued indexes until the queue is empty
+func (m *Manager) runBuilds() {
+	for {
+		m.lock.Lock()
+		if len(m.buildQueue) == 0 {
+			m.building = false
+			m.lock.Unlock()
+			return
+		}
+		task := m.buildQueue[0]
+		m.buildQueue = m.buildQueue[1:]
+		m.lock.Unlock()
+
+		err := m.buildSegmentIndex(context.Background(), task)
+		m.lock.Lock()
+		delete(m.builds, task.segmentIndex.BuildID)
+		if err != nil {
+			m.buildErrors[task.segmentIndex.BuildID] = err
+		}
+		m.lock.Unlock()
+		if err != nil {
+			log.Warn("failed to build segment index, the segment is searched by brute force",
+				zap.Int64("segmentID", task.segmentIndex.SegmentID), zap.String("index", task.fieldIndex.IndexName),
+				zap.Int64("buildID", task.segmentIndex.BuildID), zap.Error(err))
+		}
+	}
+}
+
+// GetBuildError returns the error of the failed build, nil if it's not failed
+func (m *Manager) GetBuildError(buildID UniqueID) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.buildErrors[buildID]
+}
+
+// GetPendingBuildNum returns the number of index builds queued or running
+func (m *Manager) GetPendingBuildNum() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.builds)
+}
+
+func (m *Manager) buildSegmentIndex(ctx context.Context, task *buildTask) error {
+	segment, fieldIndex := task.segment, task.fieldIndex
+	buildID := task.segmentIndex.BuildID
+	meta := segment.Meta()
+	// the segment may be compacted or the index dropped before building
+	if meta.State != commonpb.SegmentState_Flushed || !m.hasSegmentIndex(ctx, task.segmentIndex) {
+		return nil
+	}
+	start := time.Now()
+	var segmentIndex index.Index
+	if task.growingIndex != nil {
+		segmentIndex = task.growingIndex
+	} else {
+		view, err := m.ReadView(ctx, segment, 0, 0)
+		if err != nil {
+			return err
+		}
+		if err := view.LoadFields(ctx, fieldIndex.FieldID); err != nil {
+			return err
+		}
+		segmentIndex, err = m.newIndex(segment, fieldIndex)
+		if err != nil {
+			return err
+		}
+		if err := segmentIndex.Build(view.Data.Data[fieldIndex.FieldID]); err != nil {
+			return err
+		}
+	}
+	blob, err := segmentIndex.Serialize()
+	if err != nil {
+		return err
+	}
+	// the serialized index is the first file, followed by the disk file of disk index
+	keys := []string{storage.BuildSegmentIndexPath(buildID, meta.PartitionID, meta.SegmentID, IndexFileName)}
+	contents := map[string][]byte{keys[0]: blob}
+	diskIndex, isDiskIndex := segmentIndex.(index.DiskIndex)
+	if isDiskIndex {
+		diskFile, err := diskIndex.SerializeDiskFile()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, storage.BuildSegmentIndexPath(buildID, meta.PartitionID, meta.SegmentID, DiskIndexFileName))
+		contents[keys[1]] = diskFile
+	}
+	built := task.segmentIndex.Clone()
+	built.EnableIndex = true
+	built.IndexFileKeys = keys
+	for _, content := range contents {
+		built.IndexSize += int64(len(content))
+	}
+	if err := m.chunkManager.MultiWrite(ctx, contents); err != nil {
+		return err
+	}
+	if isDiskIndex {
+		diskIndex.SetDiskFile(m.diskFileOpener(keys[1]))
+	}
+
+	m.indexLock.Lock()
+	defer m.indexLock.Unlock()
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	segment.lock.Lock()
+	defer segment.lock.Unlock()
+	// the segment may be compacted or the index dropped while building
+	if segment.meta.State == commonpb.SegmentState_Dropped || !m.hasSegmentIndex(ctx, built) {
+		return m.chunkManager.RemoveWithPrefix(ctx, storage.BuildSegmentIndexPrefix(buildID))
+	}
+	if err := m.meta.SaveSegmentIndex(ctx, built); err != nil {
+		return err
+	}
+	if segment.data != nil {
+		segment.setIndexLocked(fieldIndex.FieldID, segmentIndex)
+	}
+	log.Info("segment index built", zap.Int64("segmentID", meta.SegmentID), zap.String("index", fieldIndex.IndexName),
+		zap.Int64("buildID", buildID), zap.Int64("rows", built.NumRows), zap.Duration("elapse", time.Since(start)))
+	return nil
+}
+
+// hasSegmentIndex returns whether the segment index is not removed
+func (m *Manager) hasSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) bool {
+	segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, segmentIndex.CollectionID)
+	if err != nil {
+		return false
+	}
+	for _, existing := range segmentIndexes {
+		if existing.BuildID == segmentIndex.BuildID {
+			return true
+		}
+	}
+	return false
+}
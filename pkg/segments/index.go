@@ -0,0 +1,204 @@
+package segments
+
+import (
+	"context"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// IndexFileName is the file name of serialized index under the segment index path
+	IndexFileName = "index"
+	// DiskIndexFileName is the file name of the disk file of disk index, it's read while searching
+	DiskIndexFileName = "disk_index"
+)
+
+// CreateIndex saves the index and queues its builds on all flushed segments of the collection.
+// Segments flushed or compacted later are indexed once they're flushed,
+// growing segments are indexed incrementally if the index type supports adding rows.
+func (m *Manager) CreateIndex(ctx context.Context, fieldIndex *model.Index) error {
+	m.indexLock.Lock()
+	err := m.meta.SaveIndex(ctx, fieldIndex)
+	m.indexLock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := m.addGrowingIndexes(fieldIndex); err != nil {
+		return err
+	}
+	for _, segment := range m.GetFlushedSegments(fieldIndex.CollectionID) {
+		if err := m.BuildSegmentIndexes(ctx, segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropIndex removes the index and its segment indexes, segments are searched by brute force afterwards
+func (m *Manager) DropIndex(ctx context.Context, fieldIndex *model.Index) error {
+	m.indexLock.Lock()
+	defer m.indexLock.Unlock()
+	if err := m.meta.RemoveIndex(ctx, fieldIndex); err != nil {
+		return err
+	}
+	segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, fieldIndex.CollectionID)
+	if err != nil {
+		return err
+	}
+	for _, segmentIndex := range segmentIndexes {
+		if segmentIndex.IndexID == fieldIndex.IndexID {
+			if err := m.removeSegmentIndex(ctx, segmentIndex); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.lock.RLock()
+	segments := make([]*Segment, 0)
+	if collSegments, ok := m.collections[fieldIndex.CollectionID]; ok {
+		for _, group := range []map[UniqueID]*Segment{collSegments.growing, collSegments.sealed} {
+			for _, segment := range group {
+				segments = append(segments, segment)
+			}
+		}
+	}
+	m.lock.RUnlock()
+	for _, segment := range segments {
+		segment.lock.Lock()
+		delete(segment.indexes, fieldIndex.FieldID)
+		segment.lock.Unlock()
+		// the full vectors released for the quantized index are searched by brute force afterwards
+		if err := segment.loadField(ctx, m.chunkManager, fieldIndex.FieldID); err != nil {
+			return err
+		}
+	}
+	log.Info("index dropped", zap.Int64("collectionID", fieldIndex.CollectionID), zap.Int64("indexID", fieldIndex.IndexID))
+	return nil
+}
+
+func (m *Manager) newIndex(segment *Segment, fieldIndex *model.Index) (index.Index, error) {
+	for _, field := range segment.schema.GetFields() {
+		if field.GetFieldID() == fieldIndex.FieldID {
+			return index.NewIndex(field, fieldIndex.GetParams())
+		}
+	}
+	return nil, errors.Errorf("field %d of index %s not found", fieldIndex.FieldID, fieldIndex.IndexName)
+}
+
+// addGrowingIndexes builds the index on the existing growing segments of the collection
+func (m *Manager) addGrowingIndexes(fieldIndex *model.Index) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	collSegments, ok := m.collections[fieldIndex.CollectionID]
+	if !ok {
+		return nil
+	}
+	for _, segment := range collSegments.growing {
+		segment.lock.Lock()
+		err := m.addGrowingIndexLocked(segment, fieldIndex)
+		segment.lock.Unlock()
+		if err != nil {
+			return errors.Wrapf(err, "failed to build index %s on growing segment %d", fieldIndex.IndexName, segment.ID())
+		}
+	}
+	return nil
+}
+
+// addGrowingIndexLocked adds the rows of the growing segment into a new growing index,
+// it's a no-op if the index type doesn't support adding rows. Caller must hold the segment lock.
+func (m *Manager) addGrowingIndexLocked(segment *Segment, fieldIndex *model.Index) error {
+	if segment.meta.State != commonpb.SegmentState_Growing {
+		return nil
+	}
+	if _, ok := segment.indexes[fieldIndex.FieldID]; ok {
+		return nil
+	}
+	vectorIndex, err := m.newIndex(segment, fieldIndex)
+	if err != nil {
+		return err
+	}
+	growingIndex, ok := vectorIndex.(index.GrowingIndex)
+	if !ok {
+		return nil
+	}
+	if err := growingIndex.Add(segment.data.Data[fieldIndex.FieldID]); err != nil {
+		return err
+	}
+	segment.indexes[fieldIndex.FieldID] = growingIndex
+	return nil
+}
+
+// diskFileOpener returns the opener of disk file of a disk index, it's opened by each search
+func (m *Manager) diskFileOpener(key string) func() (storage.FileReader, error) {
+	return func() (storage.FileReader, error) {
+		return m.chunkManager.Reader(context.Background(), key)
+	}
+}
+
+// loadSegmentIndexes reads the index files of segment, caller must hold indexLock
+func (m *Manager) loadSegmentIndexes(ctx context.Context, segment *Segment) (map[UniqueID]index.Index, error) {
+	indexes, err := m.meta.ListIndexes(ctx, segment.CollectionID())
+	if err != nil {
+		return nil, err
+	}
+	segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, segment.CollectionID())
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[UniqueID]index.Index)
+	for _, fieldIndex := range indexes {
+		for _, segmentIndex := range segmentIndexes {
+			if segmentIndex.SegmentID != segment.ID() || segmentIndex.IndexID != fieldIndex.IndexID || !segmentIndex.EnableIndex {
+				continue
+			}
+			vectorIndex, err := m.newIndex(segment, fieldIndex)
+			if err != nil {
+				return nil, err
+			}
+			blob, err := m.chunkManager.Read(ctx, segmentIndex.IndexFileKeys[0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read index file of segment %d", segment.ID())
+			}
+			if err := vectorIndex.Load(blob); err != nil {
+				return nil, err
+			}
+			if diskIndex, ok := vectorIndex.(index.DiskIndex); ok {
+				if len(segmentIndex.IndexFileKeys) < 2 {
+					return nil, errors.Errorf("disk file of index %s on segment %d not found", fieldIndex.IndexName, segment.ID())
+				}
+				diskIndex.SetDiskFile(m.diskFileOpener(segmentIndex.IndexFileKeys[1]))
+			}
+			ret[fieldIndex.FieldID] = vectorIndex
+		}
+	}
+	return ret, nil
+}
+
+// removeSegmentIndexes removes the segment indexes of a dropped segment, no index is built on it any more
+func (m *Manager) removeSegmentIndexes(ctx context.Context, meta *model.Segment) error {
+	segmentIndexes, err := m.meta.ListSegmentIndexes(ctx, meta.CollectionID)
+	if err != nil {
+		return err
+	}
+	for _, segmentIndex := range segmentIndexes {
+		if segmentIndex.SegmentID == meta.SegmentID {
+			if err := m.removeSegmentIndex(ctx, segmentIndex); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manager) removeSegmentIndex(ctx context.Context, segmentIndex *model.SegmentIndex) error {
+	if err := m.chunkManager.RemoveWithPrefix(ctx, storage.BuildSegmentIndexPrefix(segmentIndex.BuildID)); err != nil {
+		return err
+	}
+	return m.meta.RemoveSegmentIndex(ctx, segmentIndex)
+}
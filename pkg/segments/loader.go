@@ -0,0 +1,304 @@
+package segments
+
+import (
+	"context"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"go.uber.org/zap"
+)
+
+// loadState is the load state of a collection, sealed segments of loaded partitions are kept in memory.
+// Growing segments are always in memory, so they're searchable once the partition is loaded.
+type loadState struct {
+	partitions map[UniqueID]struct{}
+	loading    bool
+	// progress is the percentage of loaded segments
+	progress int64
+	err      error
+	cancel   context.CancelFunc
+}
+
+func (s *loadState) partitionIDs() []UniqueID {
+	ret := make([]UniqueID, 0, len(s.partitions))
+	for partitionID := range s.partitions {
+		ret = append(ret, partitionID)
+	}
+	return ret
+}
+
+// recoverLoadStates loads the collections which were loaded before restart
+func (m *Manager) recoverLoadStates(ctx context.Context) error {
+	infos, err := m.meta.ListLoadInfos(ctx)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		collection, err := m.meta.GetCollectionByID(ctx, info.CollectionID)
+		if err != nil {
+			log.Warn("loaded collection not found, remove its load info", zap.Int64("collectionID", info.CollectionID), zap.Error(err))
+			if err := m.meta.RemoveLoadInfo(ctx, info.CollectionID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.Load(ctx, collection, info.PartitionIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load loads the sealed segments of partitions into memory asynchronously, all partitions if partitionIDs is empty.
+// Loading more partitions of a loaded collection is allowed, the load state is persisted.
+func (m *Manager) Load(ctx context.Context, collection *model.Collection, partitionIDs []UniqueID) error {
+	if len(partitionIDs) == 0 {
+		for _, partition := range collection.Partitions {
+			partitionIDs = append(partitionIDs, partition.PartitionID)
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getOrCreateCollection(collection)
+	state, ok := m.loads[collection.CollectionID]
+	if ok {
+		loaded := state.err == nil
+		for _, partitionID := range partitionIDs {
+			_, ok := state.partitions[partitionID]
+			loaded = loaded && ok
+		}
+		if loaded {
+			return nil
+		}
+		state.cancel()
+	}
+
+	newState := &loadState{partitions: make(map[UniqueID]struct{}), loading: true}
+	if state != nil {
+		for partitionID := range state.partitions {
+			newState.partitions[partitionID] = struct{}{}
+		}
+	}
+	for _, partitionID := range partitionIDs {
+		newState.partitions[partitionID] = struct{}{}
+	}
+	info := &model.LoadInfo{CollectionID: collection.CollectionID, PartitionIDs: newState.partitionIDs()}
+	if err := m.meta.SaveLoadInfo(ctx, info); err != nil {
+		return err
+	}
+	loadCtx, cancel := context.WithCancel(context.Background())
+	newState.cancel = cancel
+	m.loads[collection.CollectionID] = newState
+	go m.loadSegments(loadCtx, collection.CollectionID, newState)
+	log.Info("start to load collection", zap.Int64("collectionID", collection.CollectionID), zap.Int64s("partitionIDs", info.PartitionIDs))
+	return nil
+}
+
+func (m *Manager) loadSegments(ctx context.Context, collectionID UniqueID, state *loadState) {
+	m.lock.RLock()
+	segments := make([]*Segment, 0)
+	for _, segment := range m.collections[collectionID].sealed {
+		if _, ok := state.partitions[segment.PartitionID()]; ok && !segment.InMemory() {
+			segments = append(segments, segment)
+		}
+	}
+	m.lock.RUnlock()
+
+	var err error
+	for i, segment := range segments {
+		if ctx.Err() != nil {
+			return
+		}
+		err = m.loadSegment(ctx, collectionID, state, segment)
+		if err != nil {
+			break
+		}
+		m.lock.Lock()
+		state.progress = int64(i+1) * 100 / int64(len(segments))
+		m.lock.Unlock()
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.loads[collectionID] != state {
+		return
+	}
+	state.loading = false
+	if err != nil {
+		state.err = err
+		log.Warn("failed to load collection", zap.Int64("collectionID", collectionID), zap.Error(err))
+		return
+	}
+	state.progress = 100
+	log.Info("collection loaded", zap.Int64("collectionID", collectionID), zap.Int("segments", len(segments)))
+}
+
+func (m *Manager) loadSegment(ctx context.Context, collectionID UniqueID, state *loadState, segment *Segment) error {
+	meta := segment.Meta()
+	if meta.State == commonpb.SegmentState_Dropped {
+		return nil
+	}
+	// indexes built meanwhile are not missed
+	m.indexLock.Lock()
+	defer m.indexLock.Unlock()
+	indexes, err := m.loadSegmentIndexes(ctx, segment)
+	if err != nil {
+		return err
+	}
+	// the full vectors of the fields with quantized indexes stay on disk
+	fieldIDs := make([]UniqueID, 0, len(segment.schema.GetFields()))
+	for _, field := range segment.schema.GetFields() {
+		if fieldIndex, ok := indexes[field.GetFieldID()]; !ok || !index.IsQuantized(fieldIndex.IndexType()) {
+			fieldIDs = append(fieldIDs, field.GetFieldID())
+		}
+	}
+	data, err := readBinlogs(ctx, m.chunkManager, segment.schema, meta, fieldIDs...)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	// the partition may be released meanwhile
+	if _, ok := state.partitions[segment.PartitionID()]; !ok || m.loads[collectionID] != state {
+		return nil
+	}
+	segment.lock.Lock()
+	defer segment.lock.Unlock()
+	if segment.data == nil {
+		segment.data = data
+		segment.indexes = indexes
+	}
+	return nil
+}
+
+// Release releases the sealed segments of partitions from memory, all partitions if partitionIDs is empty
+func (m *Manager) Release(ctx context.Context, collectionID UniqueID, partitionIDs []UniqueID) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	state, ok := m.loads[collectionID]
+	if !ok {
+		return nil
+	}
+	if len(partitionIDs) == 0 {
+		partitionIDs = state.partitionIDs()
+	}
+	released := make(map[UniqueID]struct{})
+	for _, partitionID := range partitionIDs {
+		released[partitionID] = struct{}{}
+		delete(state.partitions, partitionID)
+	}
+
+	if len(state.partitions) == 0 {
+		if err := m.meta.RemoveLoadInfo(ctx, collectionID); err != nil {
+			return err
+		}
+		state.cancel()
+		delete(m.loads, collectionID)
+	} else {
+		info := &model.LoadInfo{CollectionID: collectionID, PartitionIDs: state.partitionIDs()}
+		if err := m.meta.SaveLoadInfo(ctx, info); err != nil {
+			return err
+		}
+	}
+	if collSegments, ok := m.collections[collectionID]; ok {
+		for _, segment := range collSegments.sealed {
+			if _, ok := released[segment.PartitionID()]; ok {
+				segment.releaseData()
+			}
+		}
+	}
+	log.Info("collection released", zap.Int64("collectionID", collectionID), zap.Int64s("partitionIDs", partitionIDs))
+	return nil
+}
+
+// releaseIfNotLoaded releases the flushed segment from memory if its partition isn't loaded
+func (m *Manager) releaseIfNotLoaded(segment *Segment) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if !m.isLoadedLocked(segment.CollectionID(), segment.PartitionID()) {
+		segment.releaseData()
+	}
+}
+
+func (m *Manager) isLoadedLocked(collectionID, partitionID UniqueID) bool {
+	state, ok := m.loads[collectionID]
+	if !ok {
+		return false
+	}
+	_, ok = state.partitions[partitionID]
+	return ok
+}
+
+// GetLoadState returns the load state of partitions, all loaded partitions if partitionIDs is empty
+func (m *Manager) GetLoadState(collectionID UniqueID, partitionIDs []UniqueID) commonpb.LoadState {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	state, ok := m.loads[collectionID]
+	if !ok {
+		return commonpb.LoadState_LoadStateNotLoad
+	}
+	for _, partitionID := range partitionIDs {
+		if _, ok := state.partitions[partitionID]; !ok {
+			return commonpb.LoadState_LoadStateNotLoad
+		}
+	}
+	if state.loading || state.err != nil {
+		return commonpb.LoadState_LoadStateLoading
+	}
+	return commonpb.LoadState_LoadStateLoaded
+}
+
+// GetLoadingProgress returns the loading percentage of the collection
+func (m *Manager) GetLoadingProgress(collectionID UniqueID, partitionIDs []UniqueID) (int64, error) {
+	if err := m.checkLoaded(collectionID, partitionIDs, false); err != nil {
+		return 0, err
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	state := m.loads[collectionID]
+	return state.progress, state.err
+}
+
+// GetLoadedPartitions returns the loaded partitions of collection, it's empty if not loaded
+func (m *Manager) GetLoadedPartitions(collectionID UniqueID) []UniqueID {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	state, ok := m.loads[collectionID]
+	if !ok {
+		return []UniqueID{}
+	}
+	return state.partitionIDs()
+}
+
+// CheckLoaded returns error if the partitions are not fully loaded, all loaded partitions if partitionIDs is empty
+func (m *Manager) CheckLoaded(collectionID UniqueID, partitionIDs []UniqueID) error {
+	return m.checkLoaded(collectionID, partitionIDs, true)
+}
+
+func (m *Manager) checkLoaded(collectionID UniqueID, partitionIDs []UniqueID, fully bool) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	state, ok := m.loads[collectionID]
+	if !ok {
+		return merr.WrapErrCollectionNotLoaded(collectionID)
+	}
+	for _, partitionID := range partitionIDs {
+		if _, ok := state.partitions[partitionID]; !ok {
+			return merr.WrapErrPartitionNotLoaded(partitionID)
+		}
+	}
+	if !fully {
+		return nil
+	}
+	if state.err != nil {
+		return merr.WrapErrCollectionNotFullyLoaded(collectionID, errors.Wrap(state.err, "failed to load").Error())
+	}
+	if state.loading {
+		return merr.WrapErrCollectionNotFullyLoaded(collectionID)
+	}
+	return nil
+}
@@ -0,0 +1,557 @@
+package segments
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+	"github.com/sharding-db/milvus-mini/pkg/metas"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxSegmentSize is the memory size of a growing segment to be sealed & flushed automatically
+const DefaultMaxSegmentSize = 64 << 20
+
+type Config struct {
+	MaxSegmentSize int64
+}
+
+func DefaultConfig() Config {
+	return Config{MaxSegmentSize: DefaultMaxSegmentSize}
+}
+
+// Manager manages the segments of all collections.
+// Readers acquire a snapshot of segments, segments replaced by compaction are removed only when they're not referenced.
+type Manager struct {
+	lock         sync.RWMutex
+	config       Config
+	meta         metas.MetaTable
+	chunkManager storage.ChunkManager
+	idAllocator  allocator.Interface
+	collections  map[UniqueID]*collectionSegments
+	// dropped segments wait for gc
+	dropped []*Segment
+	loads   map[UniqueID]*loadState
+	// builds are the index builds queued or running by build id, buildQueue is the order to build
+	builds     map[UniqueID]*buildTask
+	buildQueue []*buildTask
+	building   bool
+	// buildErrors are the errors of failed builds, they're retried once the segment is flushed again or after restart
+	buildErrors map[UniqueID]error
+
+	// flushLock serializes flushes, so segments are not persisted twice
+	flushLock sync.Mutex
+	// indexLock serializes index builds, so indexes are not built twice.
+	// It must be acquired before lock.
+	indexLock sync.Mutex
+
+	// writes are the writes in flight, reads wait for them by their guarantee timestamps
+	writes *writeTracker
+}
+
+type collectionSegments struct {
+	schema *schemapb.CollectionSchema
+	// growing segment of each partition
+	growing map[UniqueID]*Segment
+	sealed  map[UniqueID]*Segment
+}
+
+func newCollectionSegments(schema *schemapb.CollectionSchema) *collectionSegments {
+	return &collectionSegments{
+		schema:  schema,
+		growing: make(map[UniqueID]*Segment),
+		sealed:  make(map[UniqueID]*Segment),
+	}
+}
+
+func NewManager(ctx context.Context, config Config, meta metas.MetaTable, cm storage.ChunkManager, idAllocator allocator.Interface) (*Manager, error) {
+	m := &Manager{
+		config:       config,
+		meta:         meta,
+		chunkManager: cm,
+		idAllocator:  idAllocator,
+		collections:  make(map[UniqueID]*collectionSegments),
+		loads:        make(map[UniqueID]*loadState),
+		builds:       make(map[UniqueID]*buildTask),
+		buildErrors:  make(map[UniqueID]error),
+		writes:       newWriteTracker(),
+	}
+	if err := m.init(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to init segments")
+	}
+	if err := m.recoverIndexBuilds(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to recover index builds")
+	}
+	if err := m.recoverLoadStates(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to recover load states")
+	}
+	return m, nil
+}
+
+// init recovers the flushed segments and their deletes from meta
+func (m *Manager) init(ctx context.Context) error {
+	collections, err := m.meta.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		segments, err := m.meta.ListSegments(ctx, collection.CollectionID)
+		if err != nil {
+			return err
+		}
+		collSegments := m.getOrCreateCollection(collection)
+		for _, meta := range segments {
+			segment, err := NewFlushedSegment(meta, collSegments.schema, nil)
+			if err != nil {
+				return err
+			}
+			if meta.State == commonpb.SegmentState_Dropped {
+				m.dropped = append(m.dropped, segment)
+				continue
+			}
+			deletes, err := readDeltalogs(ctx, m.chunkManager, meta)
+			if err != nil {
+				return err
+			}
+			for i, pk := range deletes.Pks {
+				segment.deleteLocked(pk, deletes.Tss[i])
+			}
+			segment.flushedDeletes = segment.deleteNum()
+			collSegments.sealed[meta.SegmentID] = segment
+		}
+	}
+	return nil
+}
+
+func (m *Manager) getOrCreateCollection(collection *model.Collection) *collectionSegments {
+	collSegments, ok := m.collections[collection.CollectionID]
+	if !ok {
+		collSegments = newCollectionSegments(model.MarshalCollectionModelWithOption(collection, model.WithFields()).GetSchema())
+		m.collections[collection.CollectionID] = collSegments
+	}
+	return collSegments
+}
+
+// Insert appends rows into the growing segment of partition, the segment is flushed once it's full
+func (m *Manager) Insert(ctx context.Context, collection *model.Collection, partitionID UniqueID, data *storage.InsertData) error {
+	return m.InsertPartitions(ctx, collection, map[UniqueID]*storage.InsertData{partitionID: data})
+}
+
+// InsertPartitions appends the rows of each partition into its growing segment, the rows of all partitions are
+// inserted or none is. The segments full are flushed afterwards, they're flushed by the next Flush if failed.
+func (m *Manager) InsertPartitions(ctx context.Context, collection *model.Collection, partitions map[UniqueID]*storage.InsertData) error {
+	partitionIDs := make([]UniqueID, 0, len(partitions))
+	for partitionID := range partitions {
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	sort.Slice(partitionIDs, func(i, j int) bool { return partitionIDs[i] < partitionIDs[j] })
+
+	m.lock.Lock()
+	collSegments := m.getOrCreateCollection(collection)
+	segments := make([]*Segment, 0, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		segment, err := m.getOrCreateGrowingLocked(ctx, collection, collSegments, partitionID)
+		if err != nil {
+			m.lock.Unlock()
+			return err
+		}
+		segments = append(segments, segment)
+	}
+	for _, segment := range segments {
+		segment.lock.Lock()
+	}
+	var err error
+	backups := make([]map[storage.FieldID]storage.FieldData, 0, len(segments))
+	for i, segment := range segments {
+		var backup map[storage.FieldID]storage.FieldData
+		backup, err = segment.appendLocked(partitions[partitionIDs[i]])
+		if err != nil {
+			break
+		}
+		backups = append(backups, backup)
+	}
+	for i, segment := range segments {
+		if err != nil && i < len(backups) {
+			segment.restoreLocked(backups[i])
+		} else if err == nil {
+			segment.indexLocked(partitions[partitionIDs[i]])
+		}
+		segment.lock.Unlock()
+	}
+	if err != nil {
+		m.lock.Unlock()
+		return err
+	}
+	full := make([]*Segment, 0)
+	for _, segment := range segments {
+		if segment.MemorySize() >= m.config.MaxSegmentSize {
+			m.sealLocked(collSegments, segment)
+			full = append(full, segment)
+		}
+	}
+	m.lock.Unlock()
+
+	if len(full) == 0 {
+		return nil
+	}
+	m.flushLock.Lock()
+	defer m.flushLock.Unlock()
+	for _, segment := range full {
+		// the rows are inserted already, so the insert doesn't fail
+		if err := m.flushSegment(ctx, segment); err != nil {
+			log.Warn("failed to flush full segment, it's flushed by the next flush", zap.Int64("segmentID", segment.ID()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// getOrCreateGrowingLocked returns the growing segment of partition, a new one is created if there's none.
+// The growing indexes of the collection are built on the new segment. Caller must hold the lock.
+func (m *Manager) getOrCreateGrowingLocked(ctx context.Context, collection *model.Collection, collSegments *collectionSegments,
+	partitionID UniqueID) (*Segment, error) {
+	if segment, ok := collSegments.growing[partitionID]; ok {
+		return segment, nil
+	}
+	segmentID, err := m.idAllocator.AllocOne()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to alloc segment id")
+	}
+	segment, err := NewGrowingSegment(segmentID, collection.CollectionID, partitionID, collSegments.schema)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := m.meta.ListIndexes(ctx, collection.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, fieldIndex := range indexes {
+		// the segment is searched by brute force if failed to build the growing index
+		if err := m.addGrowingIndexLocked(segment, fieldIndex); err != nil {
+			log.Warn("failed to build growing index", zap.Int64("segmentID", segmentID), zap.Error(err))
+		}
+	}
+	collSegments.growing[partitionID] = segment
+	return segment, nil
+}
+
+// Delete records the deletes in all segments of the partitions, all partitions if partitionIDs is empty
+func (m *Manager) Delete(ctx context.Context, collectionID UniqueID, partitionIDs []UniqueID, pks []storage.PrimaryKey, ts Timestamp) {
+	snapshot := m.Acquire(collectionID, partitionIDs)
+	defer snapshot.Release()
+	for _, segment := range snapshot.Segments {
+		segment.Delete(pks, ts)
+	}
+}
+
+func (m *Manager) sealLocked(collSegments *collectionSegments, segment *Segment) {
+	segment.lock.Lock()
+	segment.meta.State = commonpb.SegmentState_Sealed
+	segment.lock.Unlock()
+	delete(collSegments.growing, segment.PartitionID())
+	collSegments.sealed[segment.ID()] = segment
+}
+
+// Flush seals the growing segments of the collection, and persists all inserts & deletes.
+// It returns the ids of segments sealed by this flush.
+func (m *Manager) Flush(ctx context.Context, collectionID UniqueID) ([]UniqueID, error) {
+	m.flushLock.Lock()
+	defer m.flushLock.Unlock()
+
+	m.lock.Lock()
+	collSegments, ok := m.collections[collectionID]
+	if !ok {
+		m.lock.Unlock()
+		return []UniqueID{}, nil
+	}
+	sealedIDs := make([]UniqueID, 0, len(collSegments.growing))
+	for _, segment := range collSegments.growing {
+		m.sealLocked(collSegments, segment)
+		sealedIDs = append(sealedIDs, segment.ID())
+	}
+	segments := make([]*Segment, 0, len(collSegments.sealed))
+	for _, segment := range collSegments.sealed {
+		segments = append(segments, segment)
+	}
+	m.lock.Unlock()
+
+	for _, segment := range segments {
+		if err := m.flushSegment(ctx, segment); err != nil {
+			return nil, err
+		}
+	}
+	return sealedIDs, nil
+}
+
+// flushSegment writes binlogs of a sealed segment and deltalogs of pending deletes, caller must hold flushLock
+func (m *Manager) flushSegment(ctx context.Context, segment *Segment) error {
+	segment.lock.RLock()
+	meta := segment.meta.Clone()
+	data := segment.data
+	deleteNum := segment.deleteNum()
+	pending := storage.NewDeleteData(
+		segment.deleteLog.Pks[segment.flushedDeletes:deleteNum],
+		segment.deleteLog.Tss[segment.flushedDeletes:deleteNum],
+	)
+	segment.lock.RUnlock()
+
+	if meta.State == commonpb.SegmentState_Dropped || (meta.State == commonpb.SegmentState_Flushed && pending.RowCount == 0) {
+		return nil
+	}
+
+	if meta.State == commonpb.SegmentState_Sealed {
+		binlogs, err := WriteBinlogs(ctx, m.chunkManager, m.idAllocator, segment.schema, meta, data)
+		if err != nil {
+			return err
+		}
+		meta.Binlogs = binlogs
+		meta.NumOfRows = int64(data.GetRowNum())
+		meta.State = commonpb.SegmentState_Flushed
+	}
+	if pending.RowCount > 0 {
+		deltalog, err := WriteDeltalog(ctx, m.chunkManager, m.idAllocator, meta, pending)
+		if err != nil {
+			return err
+		}
+		if len(meta.Deltalogs) == 0 {
+			meta.Deltalogs = []*model.FieldBinlog{{FieldID: storage.InvalidUniqueID}}
+		}
+		meta.Deltalogs[0].Binlogs = append(meta.Deltalogs[0].Binlogs, deltalog)
+	}
+
+	if err := m.saveFlushedSegment(ctx, segment, meta, deleteNum); err != nil {
+		return err
+	}
+	// the segment is searched by brute force until its indexes are built,
+	// they're queued before releasing data so the growing indexes are persisted without rebuilding
+	if err := m.BuildSegmentIndexes(ctx, segment); err != nil {
+		log.Warn("failed to build indexes of flushed segment", zap.Int64("segmentID", meta.SegmentID), zap.Error(err))
+	}
+	// flushed rows are readable from binlogs, they're kept in memory only if loaded
+	m.releaseIfNotLoaded(segment)
+	return nil
+}
+
+func (m *Manager) saveFlushedSegment(ctx context.Context, segment *Segment, meta *model.Segment, deleteNum int) error {
+	segment.lock.Lock()
+	defer segment.lock.Unlock()
+	// the segment may be compacted while flushing, the logs are removed by gc with the dropped segment
+	if segment.meta.State == commonpb.SegmentState_Dropped {
+		return nil
+	}
+	if err := m.meta.SaveSegments(ctx, meta); err != nil {
+		return err
+	}
+	segment.meta = meta
+	segment.flushedDeletes = deleteNum
+	log.Info("segment flushed", zap.Int64("segmentID", meta.SegmentID),
+		zap.Int64("rows", meta.NumOfRows), zap.Int64("deletes", meta.GetDeletedNum()))
+	return nil
+}
+
+// Snapshot is a set of segments acquired by a reader, they're not removed until released
+type Snapshot struct {
+	Segments []*Segment
+}
+
+func (s *Snapshot) Release() {
+	for _, segment := range s.Segments {
+		segment.decRef()
+	}
+}
+
+// Acquire returns the segments of the partitions, all partitions if partitionIDs is empty
+func (m *Manager) Acquire(collectionID UniqueID, partitionIDs []UniqueID) *Snapshot {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	snapshot := &Snapshot{}
+	collSegments, ok := m.collections[collectionID]
+	if !ok {
+		return snapshot
+	}
+	contains := func(segment *Segment) bool {
+		if len(partitionIDs) == 0 {
+			return true
+		}
+		for _, partitionID := range partitionIDs {
+			if segment.PartitionID() == partitionID {
+				return true
+			}
+		}
+		return false
+	}
+	for _, segments := range []map[UniqueID]*Segment{collSegments.growing, collSegments.sealed} {
+		for _, segment := range segments {
+			if contains(segment) {
+				segment.incRef()
+				snapshot.Segments = append(snapshot.Segments, segment)
+			}
+		}
+	}
+	return snapshot
+}
+
+// GetSegment returns the segment meta, it's nil if the segment doesn't exist or has been removed
+func (m *Manager) GetSegment(segmentID UniqueID) *model.Segment {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, collSegments := range m.collections {
+		for _, segments := range []map[UniqueID]*Segment{collSegments.growing, collSegments.sealed} {
+			if segment, ok := segments[segmentID]; ok {
+				return segment.Meta()
+			}
+		}
+	}
+	for _, segment := range m.dropped {
+		if segment.ID() == segmentID {
+			return segment.Meta()
+		}
+	}
+	return nil
+}
+
+// GetFlushedSegments returns the flushed segments of the collection
+func (m *Manager) GetFlushedSegments(collectionID UniqueID) []*Segment {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ret := make([]*Segment, 0)
+	collSegments, ok := m.collections[collectionID]
+	if !ok {
+		return ret
+	}
+	for _, segment := range collSegments.sealed {
+		if segment.State() == commonpb.SegmentState_Flushed {
+			ret = append(ret, segment)
+		}
+	}
+	return ret
+}
+
+// MarkCompacting marks the segments as compacting, it fails if any of them is compacting or dropped
+func (m *Manager) MarkCompacting(segments []*Segment) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, segment := range segments {
+		if segment.compacting || segment.State() != commonpb.SegmentState_Flushed {
+			return false
+		}
+	}
+	for _, segment := range segments {
+		segment.compacting = true
+	}
+	return true
+}
+
+// UnmarkCompacting is called when the compaction fails
+func (m *Manager) UnmarkCompacting(segments []*Segment) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, segment := range segments {
+		segment.compacting = false
+	}
+}
+
+// ReadView returns the snapshot of segment rows as of readTs, rows are read from binlogs if the segment isn't in memory.
+// readTs is 0 for the latest snapshot. Rows purged by compaction are not visible to any snapshot.
+// The full vectors of the fields with quantized indexes are not in the view, see LoadFields of SegmentView.
+func (m *Manager) ReadView(ctx context.Context, segment *Segment, expireTs, readTs Timestamp) (*SegmentView, error) {
+	return segment.readView(ctx, m.chunkManager, expireTs, readTs)
+}
+
+// SwapSegments replaces the compacted segments by the target segment atomically for readers.
+// The target is kept in memory only if its partition is loaded.
+// deleteNums are the positions of deletes applied by compaction, later deletes are carried to the target.
+// Readers holding the sources are not affected, the sources are removed by gc after released.
+// target could be nil if all rows are purged.
+func (m *Manager) SwapSegments(ctx context.Context, sources []*Segment, deleteNums []int, target *Segment, droppedAt Timestamp) error {
+	if len(sources) == 0 {
+		return errors.New("no segment to swap")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	collSegments, ok := m.collections[sources[0].CollectionID()]
+	if !ok {
+		return errors.Errorf("collection %d not found", sources[0].CollectionID())
+	}
+	for _, source := range sources {
+		source.lock.Lock()
+		defer source.lock.Unlock()
+	}
+
+	droppedMetas := make([]*model.Segment, 0, len(sources))
+	for i, source := range sources {
+		if _, ok := collSegments.sealed[source.ID()]; !ok {
+			return errors.Errorf("segment %d not found", source.ID())
+		}
+		meta := source.meta.Clone()
+		meta.State = commonpb.SegmentState_Dropped
+		meta.DroppedAt = droppedAt
+		droppedMetas = append(droppedMetas, meta)
+		if target == nil {
+			continue
+		}
+		for j := deleteNums[i]; j < source.deleteNum(); j++ {
+			target.deleteLocked(source.deleteLog.Pks[j], source.deleteLog.Tss[j])
+		}
+	}
+	// the target is saved before the sources are dropped, segments are written to disk one by one,
+	// so a failure in the middle leaves duplicated rows after restart rather than lost ones
+	metas := make([]*model.Segment, 0, len(sources)+1)
+	if target != nil {
+		metas = append(metas, target.meta.Clone())
+	}
+	if err := m.meta.SaveSegments(ctx, append(metas, droppedMetas...)...); err != nil {
+		return err
+	}
+
+	for i, source := range sources {
+		source.meta = droppedMetas[i]
+		source.compacting = false
+		delete(collSegments.sealed, source.ID())
+		m.dropped = append(m.dropped, source)
+	}
+	if target != nil {
+		if !m.isLoadedLocked(target.CollectionID(), target.PartitionID()) {
+			target.releaseData()
+		}
+		collSegments.sealed[target.ID()] = target
+	}
+	return nil
+}
+
+// GC removes the logs and meta of dropped segments which are not referenced by readers
+func (m *Manager) GC(ctx context.Context) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	remain := make([]*Segment, 0, len(m.dropped))
+	for _, segment := range m.dropped {
+		if segment.referenced() {
+			remain = append(remain, segment)
+			continue
+		}
+		meta := segment.Meta()
+		if err := m.removeSegmentIndexes(ctx, meta); err != nil {
+			log.Warn("failed to remove segment indexes", zap.Int64("segmentID", meta.SegmentID), zap.Error(err))
+			remain = append(remain, segment)
+			continue
+		}
+		if err := removeSegmentLogs(ctx, m.chunkManager, meta); err != nil {
+			log.Warn("failed to remove segment logs", zap.Int64("segmentID", meta.SegmentID), zap.Error(err))
+			remain = append(remain, segment)
+			continue
+		}
+		if err := m.meta.RemoveSegment(ctx, meta); err != nil {
+			log.Warn("failed to remove segment meta", zap.Int64("segmentID", meta.SegmentID), zap.Error(err))
+			remain = append(remain, segment)
+			continue
+		}
+		log.Info("dropped segment removed", zap.Int64("segmentID", meta.SegmentID))
+	}
+	m.dropped = remain
+}
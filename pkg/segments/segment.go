@@ -0,0 +1,522 @@
+package segments
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/log"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+	pb "github.com/sharding-db/milvus-mini/pkg/etcdpb"
+	"github.com/sharding-db/milvus-mini/pkg/index"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+	"github.com/sharding-db/milvus-mini/pkg/storage"
+	"go.uber.org/zap"
+)
+
+// UniqueID is an alias of typeutil.UniqueID.
+type UniqueID = typeutil.UniqueID
+
+// Timestamp is an alias of typeutil.Timestamp
+type Timestamp = typeutil.Timestamp
+
+// Segment is a set of rows of one partition.
+// A growing segment accepts inserts in memory, it's sealed & persisted into binlogs when flushed.
+type Segment struct {
+	lock    sync.RWMutex
+	meta    *model.Segment
+	schema  *schemapb.CollectionSchema
+	pkField *schemapb.FieldSchema
+
+	// data is nil if the sealed segment is not in memory.
+	// The full vectors of the fields with quantized indexes are not in memory, they're read from binlogs when needed.
+	data *storage.InsertData
+	// indexes are the vector indexes of each field, they're in memory along with data.
+	// A growing segment only has growing indexes, inserted rows are added into them.
+	indexes map[UniqueID]index.Index
+	// deletes saves the max delete timestamp of each primary key,
+	// a row is deleted if its timestamp is less than the delete timestamp
+	deletes   map[any]Timestamp
+	deleteLog *storage.DeleteData
+	// maxDeleteTs is the max timestamp of deleteLog, deletes are filtered by timestamp only if a view is older than it
+	maxDeleteTs Timestamp
+	// flushedDeletes is the number of records in deleteLog which are persisted in deltalogs
+	flushedDeletes int
+
+	refs       int32
+	compacting bool
+}
+
+func newSegment(meta *model.Segment, schema *schemapb.CollectionSchema, data *storage.InsertData) (*Segment, error) {
+	pkField, err := typeutil.GetPrimaryFieldSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &Segment{
+		meta:      meta,
+		schema:    schema,
+		pkField:   pkField,
+		data:      data,
+		indexes:   make(map[UniqueID]index.Index),
+		deletes:   make(map[any]Timestamp),
+		deleteLog: &storage.DeleteData{},
+	}, nil
+}
+
+// NewGrowingSegment creates an empty segment which accepts inserts
+func NewGrowingSegment(segmentID, collectionID, partitionID UniqueID, schema *schemapb.CollectionSchema) (*Segment, error) {
+	data, err := storage.NewInsertData(schema)
+	if err != nil {
+		return nil, err
+	}
+	meta := &model.Segment{
+		SegmentID:    segmentID,
+		CollectionID: collectionID,
+		PartitionID:  partitionID,
+		State:        commonpb.SegmentState_Growing,
+	}
+	return newSegment(meta, schema, data)
+}
+
+// NewFlushedSegment creates a segment from persisted meta, data could be nil if it's not in memory
+func NewFlushedSegment(meta *model.Segment, schema *schemapb.CollectionSchema, data *storage.InsertData) (*Segment, error) {
+	return newSegment(meta, schema, data)
+}
+
+func (s *Segment) ID() UniqueID {
+	return s.meta.SegmentID
+}
+
+func (s *Segment) CollectionID() UniqueID {
+	return s.meta.CollectionID
+}
+
+func (s *Segment) PartitionID() UniqueID {
+	return s.meta.PartitionID
+}
+
+func (s *Segment) State() commonpb.SegmentState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.meta.State
+}
+
+// Meta returns a copy of segment meta
+func (s *Segment) Meta() *model.Segment {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.meta.Clone()
+}
+
+func (s *Segment) Schema() *schemapb.CollectionSchema {
+	return s.schema
+}
+
+func (s *Segment) RowNum() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.data != nil {
+		return int64(s.data.GetRowNum())
+	}
+	return s.meta.NumOfRows
+}
+
+func (s *Segment) MemorySize() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.data != nil {
+		return int64(s.data.GetMemorySize())
+	}
+	return s.meta.GetMemorySize()
+}
+
+// InMemory returns whether the segment rows are in memory
+func (s *Segment) InMemory() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.data != nil
+}
+
+// releaseData drops the rows & indexes of a flushed segment from memory, they're read from binlogs later
+func (s *Segment) releaseData() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.meta.State == commonpb.SegmentState_Flushed {
+		s.data = nil
+		s.indexes = make(map[UniqueID]index.Index)
+	}
+}
+
+// GetIndex returns the index of field, it's nil if the field isn't indexed or the index isn't in memory
+func (s *Segment) GetIndex(fieldID UniqueID) index.Index {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.indexes[fieldID]
+}
+
+// GetScalarIndexes returns the in-memory indexes of scalar fields
+func (s *Segment) GetScalarIndexes() map[UniqueID]index.ScalarIndex {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ret := make(map[UniqueID]index.ScalarIndex)
+	for fieldID, fieldIndex := range s.indexes {
+		if scalarIndex, ok := fieldIndex.(index.ScalarIndex); ok {
+			ret[fieldID] = scalarIndex
+		}
+	}
+	return ret
+}
+
+// DeletedRowNum returns the number of deleted rows,
+// it's the number of deleted primary keys if the segment isn't in memory.
+func (s *Segment) DeletedRowNum() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.data == nil || len(s.deletes) == 0 {
+		return int64(len(s.deletes))
+	}
+	var num int64
+	for _, deleted := range s.viewLocked(s.data, 0, 0).Deleted {
+		if deleted {
+			num++
+		}
+	}
+	return num
+}
+
+// appendLocked appends rows into a growing segment, the rows are not indexed until indexLocked.
+// It returns the field data before appending, the segment is restored by restoreLocked with it if any insert fails.
+func (s *Segment) appendLocked(data *storage.InsertData) (map[storage.FieldID]storage.FieldData, error) {
+	if s.meta.State != commonpb.SegmentState_Growing {
+		return nil, errors.Errorf("can't insert into segment %d of state %s", s.meta.SegmentID, s.meta.State.String())
+	}
+	backup := make(map[storage.FieldID]storage.FieldData, len(s.data.Data))
+	for fieldID, fieldData := range s.data.Data {
+		backup[fieldID] = shallowCopy(fieldData)
+	}
+	for fieldID, fieldData := range s.data.Data {
+		rows, ok := data.Data[fieldID]
+		if !ok {
+			s.restoreLocked(backup)
+			return nil, errors.Errorf("data of field %d not found", fieldID)
+		}
+		for i := 0; i < rows.RowNum(); i++ {
+			if err := fieldData.AppendRow(rows.GetRow(i)); err != nil {
+				s.restoreLocked(backup)
+				return nil, err
+			}
+		}
+	}
+	return backup, nil
+}
+
+// restoreLocked drops the rows appended after the backup is taken by appendLocked
+func (s *Segment) restoreLocked(backup map[storage.FieldID]storage.FieldData) {
+	for fieldID, fieldData := range backup {
+		s.data.Data[fieldID] = fieldData
+	}
+}
+
+// indexLocked adds the rows appended into the growing indexes
+func (s *Segment) indexLocked(data *storage.InsertData) {
+	for fieldID, vectorIndex := range s.indexes {
+		growingIndex, ok := vectorIndex.(index.GrowingIndex)
+		if !ok {
+			continue
+		}
+		// the field is searched by brute force if failed to index the rows
+		if err := growingIndex.Add(data.Data[fieldID]); err != nil {
+			log.Warn("failed to add rows into growing index, the index is removed", zap.Int64("segmentID", s.meta.SegmentID),
+				zap.Int64("fieldID", fieldID), zap.Error(err))
+			delete(s.indexes, fieldID)
+		}
+	}
+}
+
+// Delete records the deletes, rows inserted before ts with the same primary keys become invisible
+func (s *Segment) Delete(pks []storage.PrimaryKey, ts Timestamp) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, pk := range pks {
+		s.deleteLocked(pk, ts)
+	}
+}
+
+func (s *Segment) deleteLocked(pk storage.PrimaryKey, ts Timestamp) {
+	s.deleteLog.Append(pk, ts)
+	if ts > s.deletes[pk.GetValue()] {
+		s.deletes[pk.GetValue()] = ts
+	}
+	if ts > s.maxDeleteTs {
+		s.maxDeleteTs = ts
+	}
+}
+
+// deletesAtLocked returns the max delete timestamp of each primary key of the deletes not after readTs
+func (s *Segment) deletesAtLocked(readTs Timestamp) map[any]Timestamp {
+	if readTs == 0 || readTs >= s.maxDeleteTs {
+		return s.deletes
+	}
+	deletes := make(map[any]Timestamp)
+	for i, pk := range s.deleteLog.Pks {
+		ts := s.deleteLog.Tss[i]
+		if ts <= readTs && ts > deletes[pk.GetValue()] {
+			deletes[pk.GetValue()] = ts
+		}
+	}
+	return deletes
+}
+
+// deleteNum returns the number of delete records, used as a snapshot position of deletes
+func (s *Segment) deleteNum() int {
+	return int(s.deleteLog.RowCount)
+}
+
+// ExpiredRowNum returns the number of rows inserted before expireTs,
+// only binlogs expired entirely are counted if the segment isn't in memory.
+func (s *Segment) ExpiredRowNum(expireTs Timestamp) int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if expireTs == 0 {
+		return 0
+	}
+	var num int64
+	if s.data == nil {
+		for _, fieldBinlog := range s.meta.Binlogs {
+			if fieldBinlog.FieldID != common.TimeStampField {
+				continue
+			}
+			for _, binlog := range fieldBinlog.Binlogs {
+				if binlog.TimestampTo < expireTs {
+					num += binlog.EntriesNum
+				}
+			}
+		}
+		return num
+	}
+	for _, ts := range s.data.Data[common.TimeStampField].(*storage.Int64FieldData).Data {
+		if Timestamp(ts) < expireTs {
+			num++
+		}
+	}
+	return num
+}
+
+// View returns a consistent snapshot of segment data, it's not affected by later inserts and deletes.
+// Rows inserted before expireTs are regarded as deleted, expireTs is 0 if rows never expire.
+// The view is the latest one, see ReadView of Manager for views of older timestamps.
+func (s *Segment) View(expireTs Timestamp) (*SegmentView, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.data == nil {
+		return nil, errors.Errorf("segment %d is not in memory", s.meta.SegmentID)
+	}
+	return s.viewLocked(s.data, expireTs, 0), nil
+}
+
+// viewLocked takes the view of data as of readTs, rows inserted after readTs are invisible and deletes after readTs
+// are not applied. readTs is 0 for the latest view.
+func (s *Segment) viewLocked(data *storage.InsertData, expireTs, readTs Timestamp) *SegmentView {
+	view := &SegmentView{
+		Segment:   s,
+		Data:      &storage.InsertData{Data: make(map[storage.FieldID]storage.FieldData, len(data.Data))},
+		RowNum:    data.GetRowNum(),
+		deleteNum: s.deleteNum(),
+	}
+	for fieldID, fieldData := range data.Data {
+		view.Data.Data[fieldID] = shallowCopy(fieldData)
+	}
+	view.Deleted = make([]bool, view.RowNum)
+	deletes := s.deletesAtLocked(readTs)
+	if len(deletes) > 0 || expireTs > 0 || readTs > 0 {
+		pks := data.Data[s.pkField.GetFieldID()]
+		tss := data.Data[common.TimeStampField].(*storage.Int64FieldData).Data
+		for i := 0; i < view.RowNum; i++ {
+			ts := Timestamp(tss[i])
+			deleteTs, ok := deletes[pks.GetRow(i)]
+			view.Deleted[i] = (ok && ts < deleteTs) || ts < expireTs || (readTs > 0 && ts > readTs)
+		}
+	}
+	return view
+}
+
+// readView reads data from binlogs if the segment isn't in memory
+func (s *Segment) readView(ctx context.Context, cm storage.ChunkManager, expireTs, readTs Timestamp) (*SegmentView, error) {
+	s.lock.RLock()
+	data := s.data
+	meta := s.meta.Clone()
+	s.lock.RUnlock()
+	if data == nil {
+		var err error
+		data, err = readBinlogs(ctx, cm, s.schema, meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	view := s.viewLocked(data, expireTs, readTs)
+	view.cm, view.meta = cm, meta
+	return view, nil
+}
+
+// setIndexLocked sets the index of a segment in memory, the full vectors of field are released if the index is quantized
+func (s *Segment) setIndexLocked(fieldID UniqueID, fieldIndex index.Index) {
+	s.indexes[fieldID] = fieldIndex
+	if s.meta.State == commonpb.SegmentState_Flushed && index.IsQuantized(fieldIndex.IndexType()) {
+		delete(s.data.Data, fieldID)
+	}
+}
+
+// loadField reads the field from binlogs if the segment is in memory but the field isn't
+func (s *Segment) loadField(ctx context.Context, cm storage.ChunkManager, fieldID UniqueID) error {
+	s.lock.RLock()
+	data := s.data
+	meta := s.meta.Clone()
+	// nothing to load if the segment isn't in memory
+	loaded := data == nil
+	if !loaded {
+		_, loaded = data.Data[fieldID]
+	}
+	s.lock.RUnlock()
+	if loaded {
+		return nil
+	}
+	fieldData, err := readBinlogs(ctx, cm, s.schema, meta, fieldID)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data == data {
+		s.data.Data[fieldID] = fieldData.Data[fieldID]
+	}
+	return nil
+}
+
+func (s *Segment) incRef() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+func (s *Segment) decRef() {
+	atomic.AddInt32(&s.refs, -1)
+}
+
+func (s *Segment) referenced() bool {
+	return atomic.LoadInt32(&s.refs) > 0
+}
+
+// SegmentView is a snapshot of segment rows
+type SegmentView struct {
+	Segment *Segment
+	Data    *storage.InsertData
+	RowNum  int
+	// Deleted marks the rows deleted, expired or inserted after the timestamp of the view
+	Deleted   []bool
+	deleteNum int
+	// cm & meta read the fields not in memory from binlogs
+	cm   storage.ChunkManager
+	meta *model.Segment
+}
+
+// LoadFields reads the fields which are not in memory from binlogs, all fields if fieldIDs is empty.
+// Views of segments with quantized indexes lack the full vectors, which must be loaded before read.
+func (v *SegmentView) LoadFields(ctx context.Context, fieldIDs ...UniqueID) error {
+	if len(fieldIDs) == 0 {
+		for _, field := range v.Segment.schema.GetFields() {
+			fieldIDs = append(fieldIDs, field.GetFieldID())
+		}
+	}
+	missing := make([]UniqueID, 0)
+	for _, fieldID := range fieldIDs {
+		if _, ok := v.Data.Data[fieldID]; !ok {
+			missing = append(missing, fieldID)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if v.cm == nil {
+		return errors.Errorf("fields %v of segment %d are not in memory", missing, v.Segment.ID())
+	}
+	data, err := readBinlogs(ctx, v.cm, v.Segment.schema, v.meta, missing...)
+	if err != nil {
+		return err
+	}
+	for _, fieldID := range missing {
+		v.Data.Data[fieldID] = data.Data[fieldID]
+	}
+	return nil
+}
+
+// GetDeleteNum returns the snapshot position of deletes when the view is taken
+func (v *SegmentView) GetDeleteNum() int {
+	return v.deleteNum
+}
+
+// shallowCopy copies the slice headers of field data, so later appends are not visible
+func shallowCopy(data storage.FieldData) storage.FieldData {
+	value := reflect.ValueOf(data).Elem()
+	ret := reflect.New(value.Type())
+	ret.Elem().Set(value)
+	return ret.Interface().(storage.FieldData)
+}
+
+// readBinlogs reads the fields of segment from binlogs, all fields if fieldIDs is empty
+func readBinlogs(ctx context.Context, cm storage.ChunkManager, schema *schemapb.CollectionSchema, meta *model.Segment,
+	fieldIDs ...UniqueID) (*storage.InsertData, error) {
+	blobs := make([]*storage.Blob, 0)
+	for _, fieldBinlog := range meta.Binlogs {
+		if len(fieldIDs) > 0 && !containsField(fieldIDs, fieldBinlog.FieldID) {
+			continue
+		}
+		for _, binlog := range fieldBinlog.Binlogs {
+			value, err := cm.Read(ctx, binlog.LogPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read binlog of segment %d", meta.SegmentID)
+			}
+			blobs = append(blobs, &storage.Blob{Key: binlog.LogPath, Value: value})
+		}
+	}
+	if len(blobs) == 0 {
+		return storage.NewInsertData(schema)
+	}
+	codec := storage.NewInsertCodecWithSchema(&pb.CollectionMeta{ID: meta.CollectionID, Schema: schema})
+	_, _, data, err := codec.Deserialize(blobs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to deserialize binlog of segment %d", meta.SegmentID)
+	}
+	return data, nil
+}
+
+func containsField(fieldIDs []UniqueID, fieldID UniqueID) bool {
+	for _, id := range fieldIDs {
+		if id == fieldID {
+			return true
+		}
+	}
+	return false
+}
+
+func readDeltalogs(ctx context.Context, cm storage.ChunkManager, meta *model.Segment) (*storage.DeleteData, error) {
+	blobs := make([]*storage.Blob, 0)
+	for _, fieldBinlog := range meta.Deltalogs {
+		for _, binlog := range fieldBinlog.Binlogs {
+			value, err := cm.Read(ctx, binlog.LogPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read deltalog of segment %d", meta.SegmentID)
+			}
+			blobs = append(blobs, &storage.Blob{Key: binlog.LogPath, Value: value})
+		}
+	}
+	if len(blobs) == 0 {
+		return &storage.DeleteData{}, nil
+	}
+	_, _, data, err := storage.NewDeleteCodec().Deserialize(blobs)
+	return data, err
+}
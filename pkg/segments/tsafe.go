@@ -0,0 +1,78 @@
+package segments
+
+import (
+	"context"
+	"sync"
+
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/sharding-db/milvus-mini/pkg/allocator"
+)
+
+// writeTracker tracks the timestamps of writes in flight, so reads could wait for the writes before their
+// guarantee timestamp, it's the tsafe of milvus. A timestamp is safe if no write before or at it is in flight.
+type writeTracker struct {
+	lock     sync.Mutex
+	inflight map[Timestamp]int
+	// changed is closed and replaced once a write is done
+	changed chan struct{}
+}
+
+func newWriteTracker() *writeTracker {
+	return &writeTracker{
+		inflight: make(map[Timestamp]int),
+		changed:  make(chan struct{}),
+	}
+}
+
+// BeginWrite allocates the timestamp of a write and tracks it until done is called.
+// The timestamp is allocated under the lock of the tracker, so a timestamp allocated later by a read is never safe
+// before the write is done.
+func (m *Manager) BeginWrite(tsAllocator allocator.TimestampAllocator) (Timestamp, func(), error) {
+	t := m.writes
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ts, err := tsAllocator.AllocTimestamp()
+	if err != nil {
+		return 0, nil, merr.WrapErrServiceUnavailable(err.Error())
+	}
+	t.inflight[ts]++
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			if t.inflight[ts]--; t.inflight[ts] == 0 {
+				delete(t.inflight, ts)
+			}
+			close(t.changed)
+			t.changed = make(chan struct{})
+		})
+	}
+	return ts, done, nil
+}
+
+// WaitWrites waits until the writes before or at ts are done, writes begun after the call are not waited for
+// since their timestamps are larger than the ones allocated before.
+func (m *Manager) WaitWrites(ctx context.Context, ts Timestamp) error {
+	t := m.writes
+	for {
+		t.lock.Lock()
+		safe := true
+		for writeTs := range t.inflight {
+			if writeTs <= ts {
+				safe = false
+				break
+			}
+		}
+		changed := t.changed
+		t.lock.Unlock()
+		if safe {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return merr.WrapErrServiceUnavailable(ctx.Err().Error(), "writes before guarantee timestamp are not done")
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package segments
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/milvus-io/milvus/pkg/common"
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/sharding-db/milvus-mini/pkg/model"
+)
+
+// GetCollectionTTL returns the ttl of collection property, 0 means rows never expire
+func GetCollectionTTL(collection *model.Collection) (time.Duration, error) {
+	for _, kv := range collection.Properties {
+		if kv.GetKey() == common.CollectionTTLConfigKey {
+			return ParseTTL(kv.GetValue())
+		}
+	}
+	return 0, nil
+}
+
+// ParseTTL parses the value of collection.ttl.seconds
+func ParseTTL(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, merr.WrapErrParameterInvalidMsg("invalid %s: %s, it should be a non-negative integer", common.CollectionTTLConfigKey, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// GetExpireTimestamp returns the timestamp before which rows of the collection are expired at ts,
+// it's 0 if rows never expire.
+func GetExpireTimestamp(collection *model.Collection, ts Timestamp) (Timestamp, error) {
+	ttl, err := GetCollectionTTL(collection)
+	if err != nil || ttl == 0 {
+		return 0, err
+	}
+	return tsoutil.AddPhysicalDurationOnTs(ts, -ttl), nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+)
+
+// BinlogReader reads the events of a binlog file written by BinlogWriter or upstream milvus
+type BinlogReader struct {
+	descriptorEvent
+	buffer *bytes.Buffer
+}
+
+func NewBinlogReader(data []byte) (*BinlogReader, error) {
+	reader := &BinlogReader{
+		buffer: bytes.NewBuffer(data),
+	}
+	var magicNumber int32
+	if err := binary.Read(reader.buffer, common.Endian, &magicNumber); err != nil {
+		return nil, errors.Wrap(err, "failed to read magic number")
+	}
+	if magicNumber != MagicNumber {
+		return nil, fmt.Errorf("parse magic number failed, expected: %d, actual: %d", MagicNumber, magicNumber)
+	}
+
+	header, err := readEventHeader(reader.buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read descriptor event header")
+	}
+	descriptor, err := readDescriptorEventData(reader.buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read descriptor event data")
+	}
+	reader.baseEventHeader = *header
+	reader.descriptorEventData = *descriptor
+	return reader, nil
+}
+
+// NextEvent returns the next data event, or nil if there is no more
+func (reader *BinlogReader) NextEvent() (*Event, error) {
+	if reader.buffer.Len() <= 0 {
+		return nil, nil
+	}
+	header, err := readEventHeader(reader.buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read event header")
+	}
+	event := &Event{baseEventHeader: *header}
+	if err := binary.Read(reader.buffer, common.Endian, &event.eventData); err != nil {
+		return nil, errors.Wrap(err, "failed to read event data")
+	}
+	payloadLength := int(header.EventLength - header.GetMemoryUsageInBytes() - event.GetEventDataFixPartSize())
+	if payloadLength < 0 || payloadLength > reader.buffer.Len() {
+		return nil, fmt.Errorf("invalid event length %d", header.EventLength)
+	}
+	event.Payload = reader.buffer.Next(payloadLength)
+	return event, nil
+}
+
+// ReadAll reads and merges the payloads of all events into one field data
+func (reader *BinlogReader) ReadAll(dim int) (FieldData, error) {
+	var ret FieldData
+	for {
+		event, err := reader.NextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if event == nil {
+			break
+		}
+		data, err := ReadPayload(reader.PayloadDataType, dim, event.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if ret == nil {
+			ret = data
+			continue
+		}
+		for i := 0; i < data.RowNum(); i++ {
+			if err := ret.AppendRow(data.GetRow(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ret == nil {
+		return newFieldDataByType(reader.PayloadDataType, dim)
+	}
+	return ret, nil
+}
+
+func (reader *BinlogReader) GetDescriptor() DescriptorEventDataFixPart {
+	return reader.DescriptorEventDataFixPart
+}
+
+func (reader *BinlogReader) GetExtras() map[string]interface{} {
+	return reader.Extras
+}
+
+// Event is a data event read from binlog
+type Event struct {
+	baseEventHeader
+	eventData
+	Payload []byte
+}
+
+func (e *Event) GetTypeCode() EventTypeCode {
+	return e.TypeCode
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+)
+
+// BinlogType is the type of binlog file
+type BinlogType int32
+
+const (
+	InsertBinlog BinlogType = iota
+	DeleteBinlog
+	DDLBinlog
+	IndexFileBinlog
+	StatsBinlog
+)
+
+// MagicNumber is the first 4 bytes of each binlog file
+const MagicNumber int32 = 0xfffabc
+
+// BinlogWriter writes a binlog file:
+// | MagicNumber(4) | DescriptorEvent | Event | Event | ...
+// it's compatible with binlog files of upstream milvus.
+type BinlogWriter struct {
+	*descriptorEvent
+	binlogType   BinlogType
+	eventWriters []*EventWriter
+	buffer       *bytes.Buffer
+	rowNum       int
+}
+
+// NewInsertBinlogWriter creates a writer for the insert binlog of one field
+func NewInsertBinlogWriter(dataType schemapb.DataType, collectionID, partitionID, segmentID, fieldID int64) *BinlogWriter {
+	descriptorEvent := newDescriptorEvent()
+	descriptorEvent.PayloadDataType = dataType
+	descriptorEvent.CollectionID = collectionID
+	descriptorEvent.PartitionID = partitionID
+	descriptorEvent.SegmentID = segmentID
+	descriptorEvent.FieldID = fieldID
+	return &BinlogWriter{
+		descriptorEvent: descriptorEvent,
+		binlogType:      InsertBinlog,
+	}
+}
+
+// NewDeleteBinlogWriter creates a writer for the delta log of one segment
+func NewDeleteBinlogWriter(dataType schemapb.DataType, collectionID, partitionID, segmentID int64) *BinlogWriter {
+	descriptorEvent := newDescriptorEvent()
+	descriptorEvent.PayloadDataType = dataType
+	descriptorEvent.CollectionID = collectionID
+	descriptorEvent.PartitionID = partitionID
+	descriptorEvent.SegmentID = segmentID
+	return &BinlogWriter{
+		descriptorEvent: descriptorEvent,
+		binlogType:      DeleteBinlog,
+	}
+}
+
+// NextEventWriter returns a writer of the event type matching the binlog type
+func (writer *BinlogWriter) NextEventWriter(dim int) (*EventWriter, error) {
+	if writer.buffer != nil {
+		return nil, errors.New("binlog writer is already finished")
+	}
+	var eventType EventTypeCode
+	switch writer.binlogType {
+	case InsertBinlog:
+		eventType = InsertEventType
+	case DeleteBinlog:
+		eventType = DeleteEventType
+	case IndexFileBinlog:
+		eventType = IndexFileEventType
+	default:
+		return nil, errors.Errorf("unsupported binlog type %d", writer.binlogType)
+	}
+	event, err := newEventWriter(eventType, writer.PayloadDataType, dim)
+	if err != nil {
+		return nil, err
+	}
+	writer.eventWriters = append(writer.eventWriters, event)
+	return event, nil
+}
+
+func (writer *BinlogWriter) SetEventTimeStamp(start Timestamp, end Timestamp) {
+	writer.StartTimestamp = start
+	writer.EndTimestamp = end
+}
+
+// Finish encodes all events into buffer, writer can't be modified after finished
+func (writer *BinlogWriter) Finish() error {
+	if writer.buffer != nil {
+		return nil
+	}
+	if writer.StartTimestamp == 0 || writer.EndTimestamp == 0 {
+		return fmt.Errorf("invalid start/end timestamp")
+	}
+
+	var offset int32
+	buffer := new(bytes.Buffer)
+	if err := binary.Write(buffer, common.Endian, MagicNumber); err != nil {
+		return err
+	}
+	offset += int32(binary.Size(MagicNumber))
+	if err := writer.descriptorEvent.Write(buffer); err != nil {
+		return err
+	}
+	offset += writer.descriptorEvent.GetMemoryUsageInBytes()
+
+	writer.rowNum = 0
+	for _, w := range writer.eventWriters {
+		w.SetOffset(offset)
+		if err := w.Finish(); err != nil {
+			return err
+		}
+		if err := w.Write(buffer); err != nil {
+			return err
+		}
+		offset += w.EventLength
+		writer.rowNum += w.payload.GetRowNum()
+	}
+	writer.buffer = buffer
+	return nil
+}
+
+func (writer *BinlogWriter) GetBuffer() ([]byte, error) {
+	if writer.buffer == nil {
+		return nil, errors.New("please call Finish before GetBuffer")
+	}
+	return writer.buffer.Bytes(), nil
+}
+
+func (writer *BinlogWriter) GetRowNum() int {
+	return writer.rowNum
+}
+
+// EventWriter writes one data event:
+// | EventHeader | StartTimestamp(8) | EndTimestamp(8) | Payload(parquet) |
+type EventWriter struct {
+	baseEventHeader
+	eventData
+	payload  *PayloadWriter
+	offset   int32
+	finished bool
+}
+
+func newEventWriter(eventType EventTypeCode, dataType schemapb.DataType, dim int) (*EventWriter, error) {
+	payload, err := NewPayloadWriter(dataType, dim)
+	if err != nil {
+		return nil, err
+	}
+	return &EventWriter{
+		baseEventHeader: *newEventHeader(eventType),
+		payload:         payload,
+	}, nil
+}
+
+func (writer *EventWriter) AddFieldData(data FieldData) error {
+	return writer.payload.AddFieldData(data)
+}
+
+func (writer *EventWriter) AddStrings(values ...string) error {
+	return writer.payload.AddStrings(values...)
+}
+
+func (writer *EventWriter) SetOffset(offset int32) {
+	writer.offset = offset
+}
+
+func (writer *EventWriter) Finish() error {
+	if writer.finished {
+		return nil
+	}
+	writer.finished = true
+	if err := writer.payload.Finish(); err != nil {
+		return err
+	}
+	payload, err := writer.payload.GetPayloadBuffer()
+	if err != nil {
+		return err
+	}
+	writer.EventLength = writer.baseEventHeader.GetMemoryUsageInBytes() + writer.GetEventDataFixPartSize() + int32(len(payload))
+	writer.NextPosition = writer.EventLength + writer.offset
+	return nil
+}
+
+func (writer *EventWriter) Write(buffer *bytes.Buffer) error {
+	if err := writer.baseEventHeader.Write(buffer); err != nil {
+		return err
+	}
+	if err := writer.WriteEventData(buffer); err != nil {
+		return err
+	}
+	payload, err := writer.payload.GetPayloadBuffer()
+	if err != nil {
+		return err
+	}
+	_, err = buffer.Write(payload)
+	return err
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/milvus-io/milvus/pkg/util/merr"
+	"github.com/pkg/errors"
+)
+
+// ChunkManager reads & writes binlog files by their relative path
+type ChunkManager interface {
+	RootPath() string
+	Write(ctx context.Context, filePath string, content []byte) error
+	MultiWrite(ctx context.Context, contents map[string][]byte) error
+	Read(ctx context.Context, filePath string) ([]byte, error)
+	MultiRead(ctx context.Context, filePaths []string) ([][]byte, error)
+	// Reader opens the file for random reads, the reader must be closed after use
+	Reader(ctx context.Context, filePath string) (FileReader, error)
+	Exist(ctx context.Context, filePath string) (bool, error)
+	Remove(ctx context.Context, filePath string) error
+	RemoveWithPrefix(ctx context.Context, prefix string) error
+}
+
+// FileReader reads a file by offset
+type FileReader interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// LocalChunkManager stores files in local disk under rootPath
+type LocalChunkManager struct {
+	rootPath string
+}
+
+func NewLocalChunkManager(rootPath string) (*LocalChunkManager, error) {
+	if err := os.MkdirAll(rootPath, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create root path of chunk manager")
+	}
+	return &LocalChunkManager{rootPath: rootPath}, nil
+}
+
+func (lcm *LocalChunkManager) RootPath() string {
+	return lcm.rootPath
+}
+
+func (lcm *LocalChunkManager) Write(ctx context.Context, filePath string, content []byte) error {
+	fileName := filepath.Join(lcm.rootPath, filePath)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	// write to a temp file and rename, so readers never see a partial file
+	tmpFile := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0644); err != nil {
+		return merr.WrapErrIoFailed(filePath, err.Error())
+	}
+	return os.Rename(tmpFile, fileName)
+}
+
+func (lcm *LocalChunkManager) MultiWrite(ctx context.Context, contents map[string][]byte) error {
+	for filePath, content := range contents {
+		if err := lcm.Write(ctx, filePath, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (lcm *LocalChunkManager) Read(ctx context.Context, filePath string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filepath.Join(lcm.rootPath, filePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, merr.WrapErrIoKeyNotFound(filePath)
+		}
+		return nil, merr.WrapErrIoFailed(filePath, err.Error())
+	}
+	return content, nil
+}
+
+func (lcm *LocalChunkManager) MultiRead(ctx context.Context, filePaths []string) ([][]byte, error) {
+	ret := make([][]byte, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		content, err := lcm.Read(ctx, filePath)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, content)
+	}
+	return ret, nil
+}
+
+func (lcm *LocalChunkManager) Reader(ctx context.Context, filePath string) (FileReader, error) {
+	file, err := os.Open(filepath.Join(lcm.rootPath, filePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, merr.WrapErrIoKeyNotFound(filePath)
+		}
+		return nil, merr.WrapErrIoFailed(filePath, err.Error())
+	}
+	return file, nil
+}
+
+func (lcm *LocalChunkManager) Exist(ctx context.Context, filePath string) (bool, error) {
+	_, err := os.Stat(filepath.Join(lcm.rootPath, filePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (lcm *LocalChunkManager) Remove(ctx context.Context, filePath string) error {
+	err := os.Remove(filepath.Join(lcm.rootPath, filePath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RemoveWithPrefix removes the directory of prefix recursively
+func (lcm *LocalChunkManager) RemoveWithPrefix(ctx context.Context, prefix string) error {
+	return os.RemoveAll(filepath.Join(lcm.rootPath, prefix))
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/pkg/errors"
+)
+
+// FieldDataFromProto converts a column of request into FieldData
+func FieldDataFromProto(field *schemapb.FieldSchema, data *schemapb.FieldData) (FieldData, error) {
+	ret, err := NewFieldData(field)
+	if err != nil {
+		return nil, err
+	}
+	mismatch := errors.Errorf("data type %s mismatches field %s of %s", data.GetType().String(), field.GetName(), field.GetDataType().String())
+	scalars := data.GetScalars()
+	vectors := data.GetVectors()
+	switch d := ret.(type) {
+	case *BoolFieldData:
+		if scalars.GetBoolData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetBoolData().GetData()
+	case *Int8FieldData:
+		if scalars.GetIntData() == nil {
+			return nil, mismatch
+		}
+		for _, v := range scalars.GetIntData().GetData() {
+			d.Data = append(d.Data, int8(v))
+		}
+	case *Int16FieldData:
+		if scalars.GetIntData() == nil {
+			return nil, mismatch
+		}
+		for _, v := range scalars.GetIntData().GetData() {
+			d.Data = append(d.Data, int16(v))
+		}
+	case *Int32FieldData:
+		if scalars.GetIntData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetIntData().GetData()
+	case *Int64FieldData:
+		if scalars.GetLongData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetLongData().GetData()
+	case *FloatFieldData:
+		if scalars.GetFloatData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetFloatData().GetData()
+	case *DoubleFieldData:
+		if scalars.GetDoubleData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetDoubleData().GetData()
+	case *StringFieldData:
+		if scalars.GetStringData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetStringData().GetData()
+	case *ArrayFieldData:
+		if scalars.GetArrayData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetArrayData().GetData()
+	case *JSONFieldData:
+		if scalars.GetJsonData() == nil {
+			return nil, mismatch
+		}
+		d.Data = scalars.GetJsonData().GetData()
+	case *BinaryVectorFieldData:
+		if vectors.GetBinaryVector() == nil || vectors.GetDim() != int64(d.Dim) {
+			return nil, mismatch
+		}
+		d.Data = vectors.GetBinaryVector()
+	case *FloatVectorFieldData:
+		if vectors.GetFloatVector() == nil || vectors.GetDim() != int64(d.Dim) {
+			return nil, mismatch
+		}
+		d.Data = vectors.GetFloatVector().GetData()
+	case *Float16VectorFieldData:
+		if vectors.GetFloat16Vector() == nil || vectors.GetDim() != int64(d.Dim) || len(vectors.GetFloat16Vector())%(d.Dim*2) != 0 {
+			return nil, mismatch
+		}
+		d.Data = vectors.GetFloat16Vector()
+	case *BFloat16VectorFieldData:
+		// bfloat16 vectors share the bytes of float16 vectors in this version of proto
+		if vectors.GetFloat16Vector() == nil || vectors.GetDim() != int64(d.Dim) || len(vectors.GetFloat16Vector())%(d.Dim*2) != 0 {
+			return nil, mismatch
+		}
+		d.Data = vectors.GetFloat16Vector()
+	case *SparseFloatVectorFieldData:
+		if scalars.GetBytesData() == nil {
+			return nil, mismatch
+		}
+		for _, row := range scalars.GetBytesData().GetData() {
+			if err := d.AppendRow(row); err != nil {
+				return nil, errors.Wrapf(err, "invalid data of field %s", field.GetName())
+			}
+		}
+	}
+	// columns of request have no validity, rows are valid until marked by SetValidData
+	if validity := getValidity(ret); validity != nil && validity.Nullable {
+		validity.ValidData = make([]bool, ret.RowNum())
+		for i := range validity.ValidData {
+			validity.ValidData[i] = true
+		}
+	}
+	return ret, nil
+}
+
+// GetPrimaryKeys returns the primary keys of rows in data
+func GetPrimaryKeys(data FieldData) ([]PrimaryKey, error) {
+	ret := make([]PrimaryKey, 0, data.RowNum())
+	for i := 0; i < data.RowNum(); i++ {
+		pk, err := NewPrimaryKey(data.GetRow(i))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, pk)
+	}
+	return ret, nil
+}
+
+// PrimaryKeysToIDs converts primary keys into schemapb.IDs
+func PrimaryKeysToIDs(dataType schemapb.DataType, pks []PrimaryKey) *schemapb.IDs {
+	ret := &schemapb.IDs{}
+	if dataType == schemapb.DataType_VarChar {
+		ret.IdField = &schemapb.IDs_StrId{StrId: &schemapb.StringArray{Data: make([]string, 0, len(pks))}}
+	} else {
+		ret.IdField = &schemapb.IDs_IntId{IntId: &schemapb.LongArray{Data: make([]int64, 0, len(pks))}}
+	}
+	for _, pk := range pks {
+		typeutil.AppendPKs(ret, pk.GetValue())
+	}
+	return ret
+}
+
+// FieldDataToProto converts FieldData into a column of response
+func FieldDataToProto(field *schemapb.FieldSchema, data FieldData) (*schemapb.FieldData, error) {
+	ret := &schemapb.FieldData{
+		Type:      field.GetDataType(),
+		FieldName: field.GetName(),
+		FieldId:   field.GetFieldID(),
+		IsDynamic: field.GetIsDynamic(),
+	}
+	scalars := &schemapb.ScalarField{}
+	switch d := data.(type) {
+	case *BoolFieldData:
+		scalars.Data = &schemapb.ScalarField_BoolData{BoolData: &schemapb.BoolArray{Data: d.Data}}
+	case *Int8FieldData:
+		values := make([]int32, 0, len(d.Data))
+		for _, v := range d.Data {
+			values = append(values, int32(v))
+		}
+		scalars.Data = &schemapb.ScalarField_IntData{IntData: &schemapb.IntArray{Data: values}}
+	case *Int16FieldData:
+		values := make([]int32, 0, len(d.Data))
+		for _, v := range d.Data {
+			values = append(values, int32(v))
+		}
+		scalars.Data = &schemapb.ScalarField_IntData{IntData: &schemapb.IntArray{Data: values}}
+	case *Int32FieldData:
+		scalars.Data = &schemapb.ScalarField_IntData{IntData: &schemapb.IntArray{Data: d.Data}}
+	case *Int64FieldData:
+		scalars.Data = &schemapb.ScalarField_LongData{LongData: &schemapb.LongArray{Data: d.Data}}
+	case *FloatFieldData:
+		scalars.Data = &schemapb.ScalarField_FloatData{FloatData: &schemapb.FloatArray{Data: d.Data}}
+	case *DoubleFieldData:
+		scalars.Data = &schemapb.ScalarField_DoubleData{DoubleData: &schemapb.DoubleArray{Data: d.Data}}
+	case *StringFieldData:
+		scalars.Data = &schemapb.ScalarField_StringData{StringData: &schemapb.StringArray{Data: d.Data}}
+	case *ArrayFieldData:
+		scalars.Data = &schemapb.ScalarField_ArrayData{ArrayData: &schemapb.ArrayArray{Data: d.Data, ElementType: d.ElementType}}
+	case *JSONFieldData:
+		scalars.Data = &schemapb.ScalarField_JsonData{JsonData: &schemapb.JSONArray{Data: d.Data}}
+	case *SparseFloatVectorFieldData:
+		scalars.Data = &schemapb.ScalarField_BytesData{BytesData: &schemapb.BytesArray{Data: d.Data}}
+	case *BinaryVectorFieldData:
+		ret.Field = &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+			Dim:  int64(d.Dim),
+			Data: &schemapb.VectorField_BinaryVector{BinaryVector: d.Data},
+		}}
+		return ret, nil
+	case *FloatVectorFieldData:
+		ret.Field = &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+			Dim:  int64(d.Dim),
+			Data: &schemapb.VectorField_FloatVector{FloatVector: &schemapb.FloatArray{Data: d.Data}},
+		}}
+		return ret, nil
+	case *Float16VectorFieldData:
+		ret.Field = &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+			Dim:  int64(d.Dim),
+			Data: &schemapb.VectorField_Float16Vector{Float16Vector: d.Data},
+		}}
+		return ret, nil
+	case *BFloat16VectorFieldData:
+		ret.Field = &schemapb.FieldData_Vectors{Vectors: &schemapb.VectorField{
+			Dim:  int64(d.Dim),
+			Data: &schemapb.VectorField_Float16Vector{Float16Vector: d.Data},
+		}}
+		return ret, nil
+	default:
+		return nil, errors.Errorf("unsupported data type %s of field %s", field.GetDataType().String(), field.GetName())
+	}
+	ret.Field = &schemapb.FieldData_Scalars{Scalars: scalars}
+	return ret, nil
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+	pb "github.com/sharding-db/milvus-mini/pkg/etcdpb"
+)
+
+// Blob is a pack of key&value, key is the field id for insert binlogs
+type Blob struct {
+	Key        string
+	Value      []byte
+	RowNum     int64
+	MemorySize int64
+}
+
+// InsertCodec serializes InsertData into one binlog per field, and deserializes them back
+type InsertCodec struct {
+	Schema *pb.CollectionMeta
+}
+
+func NewInsertCodecWithSchema(schema *pb.CollectionMeta) *InsertCodec {
+	return &InsertCodec{Schema: schema}
+}
+
+// Serialize writes all fields of data, including system fields, into binlogs.
+// The timestamp field is used to fill the start/end timestamp of events.
+func (codec *InsertCodec) Serialize(partitionID UniqueID, segmentID UniqueID, data *InsertData) ([]*Blob, error) {
+	timeFieldData, ok := data.Data[common.TimeStampField]
+	if !ok {
+		return nil, errors.New("data doesn't contains timestamp field")
+	}
+	if timeFieldData.RowNum() <= 0 {
+		return nil, errors.New("there's no data in InsertData")
+	}
+	startTs, endTs := getTimestampRange(timeFieldData.(*Int64FieldData).Data)
+
+	blobs := make([]*Blob, 0, len(codec.Schema.GetSchema().GetFields()))
+	for _, field := range codec.Schema.GetSchema().GetFields() {
+		fieldData, ok := data.Data[field.GetFieldID()]
+		if !ok {
+			return nil, errors.Errorf("data of field %s not found", field.GetName())
+		}
+		dim := 0
+		if IsVectorType(field.GetDataType()) {
+			vectorDim, err := GetDim(field)
+			if err != nil {
+				return nil, err
+			}
+			dim = int(vectorDim)
+		}
+
+		writer := NewInsertBinlogWriter(field.GetDataType(), codec.Schema.GetID(), partitionID, segmentID, field.GetFieldID())
+		eventWriter, err := writer.NextEventWriter(dim)
+		if err != nil {
+			return nil, err
+		}
+		eventWriter.SetEventTimestamp(startTs, endTs)
+		if err := eventWriter.AddFieldData(fieldData); err != nil {
+			return nil, errors.Wrapf(err, "failed to serialize field %s", field.GetName())
+		}
+		writer.SetEventTimeStamp(startTs, endTs)
+		writer.AddExtra(originalSizeKey, fmt.Sprintf("%v", fieldData.GetMemorySize()))
+		if err := writer.Finish(); err != nil {
+			return nil, err
+		}
+		buffer, err := writer.GetBuffer()
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, &Blob{
+			Key:        strconv.FormatInt(field.GetFieldID(), 10),
+			Value:      buffer,
+			RowNum:     int64(fieldData.RowNum()),
+			MemorySize: int64(fieldData.GetMemorySize()),
+		})
+	}
+	return blobs, nil
+}
+
+// Deserialize reads binlogs back into InsertData, binlogs of the same field are merged in order
+func (codec *InsertCodec) Deserialize(blobs []*Blob) (partitionID UniqueID, segmentID UniqueID, data *InsertData, err error) {
+	if len(blobs) == 0 {
+		return InvalidUniqueID, InvalidUniqueID, nil, errors.New("blobs is empty")
+	}
+	dims := make(map[FieldID]int)
+	for _, field := range codec.Schema.GetSchema().GetFields() {
+		if IsVectorType(field.GetDataType()) {
+			dim, err := GetDim(field)
+			if err != nil {
+				return InvalidUniqueID, InvalidUniqueID, nil, err
+			}
+			dims[field.GetFieldID()] = int(dim)
+		}
+	}
+
+	data = &InsertData{Data: make(map[FieldID]FieldData)}
+	for _, blob := range blobs {
+		reader, err := NewBinlogReader(blob.Value)
+		if err != nil {
+			return InvalidUniqueID, InvalidUniqueID, nil, err
+		}
+		descriptor := reader.GetDescriptor()
+		partitionID, segmentID = descriptor.PartitionID, descriptor.SegmentID
+		fieldData, err := reader.ReadAll(dims[descriptor.FieldID])
+		if err != nil {
+			return InvalidUniqueID, InvalidUniqueID, nil, errors.Wrapf(err, "failed to read binlog of field %d", descriptor.FieldID)
+		}
+		existed, ok := data.Data[descriptor.FieldID]
+		if !ok {
+			data.Data[descriptor.FieldID] = fieldData
+			continue
+		}
+		for i := 0; i < fieldData.RowNum(); i++ {
+			if err := existed.AppendRow(fieldData.GetRow(i)); err != nil {
+				return InvalidUniqueID, InvalidUniqueID, nil, err
+			}
+		}
+	}
+	return partitionID, segmentID, data, nil
+}
+
+// InvalidUniqueID is used when the id is unknown
+const InvalidUniqueID = UniqueID(-1)
+
+// DeleteData saves the deleted primary keys and their timestamps
+type DeleteData struct {
+	Pks      []PrimaryKey
+	Tss      []Timestamp
+	RowCount int64
+}
+
+func NewDeleteData(pks []PrimaryKey, tss []Timestamp) *DeleteData {
+	return &DeleteData{
+		Pks:      pks,
+		Tss:      tss,
+		RowCount: int64(len(pks)),
+	}
+}
+
+func (data *DeleteData) Append(pk PrimaryKey, ts Timestamp) {
+	data.Pks = append(data.Pks, pk)
+	data.Tss = append(data.Tss, ts)
+	data.RowCount++
+}
+
+func (data *DeleteData) Size() int64 {
+	var size int64
+	for _, pk := range data.Pks {
+		size += pk.Size()
+	}
+	return size + int64(len(data.Tss))*8
+}
+
+// DeleteLog is the json entry of delta log payload, same as upstream milvus
+type DeleteLog struct {
+	Pk     PrimaryKey `json:"pk"`
+	Ts     uint64     `json:"ts"`
+	PkType int64      `json:"pkType"`
+}
+
+func (dl *DeleteLog) UnmarshalJSON(data []byte) error {
+	var messageMap map[string]*json.RawMessage
+	if err := json.Unmarshal(data, &messageMap); err != nil {
+		return err
+	}
+	for _, key := range []string{"pk", "ts", "pkType"} {
+		if messageMap[key] == nil {
+			return errors.Errorf("invalid delete log %s, %s is missing", string(data), key)
+		}
+	}
+	if err := json.Unmarshal(*messageMap["pkType"], &dl.PkType); err != nil {
+		return err
+	}
+	switch schemapb.DataType(dl.PkType) {
+	case schemapb.DataType_Int64:
+		dl.Pk = &Int64PrimaryKey{}
+	case schemapb.DataType_VarChar:
+		dl.Pk = &VarCharPrimaryKey{}
+	default:
+		return errors.Errorf("unsupported primary key type %d", dl.PkType)
+	}
+	if err := json.Unmarshal(*messageMap["pk"], dl.Pk); err != nil {
+		return err
+	}
+	return json.Unmarshal(*messageMap["ts"], &dl.Ts)
+}
+
+// DeleteCodec serializes DeleteData into a delta log
+type DeleteCodec struct{}
+
+func NewDeleteCodec() *DeleteCodec {
+	return &DeleteCodec{}
+}
+
+func (codec *DeleteCodec) Serialize(collectionID UniqueID, partitionID UniqueID, segmentID UniqueID, data *DeleteData) (*Blob, error) {
+	if len(data.Pks) != len(data.Tss) {
+		return nil, errors.New("the length of pks, and TimeStamps is not equal")
+	}
+	if len(data.Pks) == 0 {
+		return nil, errors.New("there's no data in DeleteData")
+	}
+	writer := NewDeleteBinlogWriter(schemapb.DataType_String, collectionID, partitionID, segmentID)
+	eventWriter, err := writer.NextEventWriter(0)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeTotal := 0
+	for i, pk := range data.Pks {
+		serialized, err := json.Marshal(&DeleteLog{Pk: pk, Ts: data.Tss[i], PkType: int64(pk.Type())})
+		if err != nil {
+			return nil, err
+		}
+		if err := eventWriter.AddStrings(string(serialized)); err != nil {
+			return nil, err
+		}
+		sizeTotal += len(serialized)
+	}
+	startTs, endTs := getTimestampRange(data.Tss)
+	eventWriter.SetEventTimestamp(startTs, endTs)
+	writer.SetEventTimeStamp(startTs, endTs)
+	writer.AddExtra(originalSizeKey, fmt.Sprintf("%v", sizeTotal))
+	if err := writer.Finish(); err != nil {
+		return nil, err
+	}
+	buffer, err := writer.GetBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return &Blob{
+		Value:      buffer,
+		RowNum:     data.RowCount,
+		MemorySize: data.Size(),
+	}, nil
+}
+
+func (codec *DeleteCodec) Deserialize(blobs []*Blob) (partitionID UniqueID, segmentID UniqueID, data *DeleteData, err error) {
+	if len(blobs) == 0 {
+		return InvalidUniqueID, InvalidUniqueID, nil, errors.New("blobs is empty")
+	}
+	data = &DeleteData{}
+	for _, blob := range blobs {
+		reader, err := NewBinlogReader(blob.Value)
+		if err != nil {
+			return InvalidUniqueID, InvalidUniqueID, nil, err
+		}
+		descriptor := reader.GetDescriptor()
+		partitionID, segmentID = descriptor.PartitionID, descriptor.SegmentID
+		fieldData, err := reader.ReadAll(0)
+		if err != nil {
+			return InvalidUniqueID, InvalidUniqueID, nil, err
+		}
+		stringData, ok := fieldData.(*StringFieldData)
+		if !ok {
+			return InvalidUniqueID, InvalidUniqueID, nil, errors.Errorf("unexpected delta log payload %s", fieldData.GetDataType().String())
+		}
+		for _, entry := range stringData.Data {
+			deleteLog := &DeleteLog{}
+			if err := json.Unmarshal([]byte(entry), deleteLog); err != nil {
+				return InvalidUniqueID, InvalidUniqueID, nil, errors.Wrap(err, "failed to parse delete log")
+			}
+			data.Append(deleteLog.Pk, deleteLog.Ts)
+		}
+	}
+	return partitionID, segmentID, data, nil
+}
+
+func getTimestampRange[T int64 | uint64](tss []T) (Timestamp, Timestamp) {
+	var start, end Timestamp = math.MaxUint64, 0
+	for _, t := range tss {
+		ts := Timestamp(t)
+		if ts < start {
+			start = ts
+		}
+		if ts > end {
+			end = ts
+		}
+	}
+	return start, end
+}
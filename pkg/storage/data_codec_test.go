@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+	pb "github.com/sharding-db/milvus-mini/pkg/etcdpb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCollectionMeta() *pb.CollectionMeta {
+	return &pb.CollectionMeta{
+		ID: 1,
+		Schema: &schemapb.CollectionSchema{
+			Name: "test",
+			Fields: []*schemapb.FieldSchema{
+				{FieldID: common.RowIDField, Name: common.RowIDFieldName, DataType: schemapb.DataType_Int64},
+				{FieldID: common.TimeStampField, Name: common.TimeStampFieldName, DataType: schemapb.DataType_Int64},
+				{FieldID: 100, Name: "pk", DataType: schemapb.DataType_Int64, IsPrimaryKey: true},
+				{FieldID: 101, Name: "name", DataType: schemapb.DataType_VarChar},
+				{FieldID: 102, Name: "vec", DataType: schemapb.DataType_FloatVector,
+					TypeParams: []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}},
+			},
+		},
+	}
+}
+
+func TestInsertCodec(t *testing.T) {
+	codec := NewInsertCodecWithSchema(newTestCollectionMeta())
+	data := &InsertData{Data: map[FieldID]FieldData{
+		common.RowIDField:     &Int64FieldData{Data: []int64{1, 2}},
+		common.TimeStampField: &Int64FieldData{Data: []int64{10, 20}},
+		100:                   &Int64FieldData{Data: []int64{1, 2}},
+		101:                   &StringFieldData{Data: []string{"a", "b"}, DataType: schemapb.DataType_VarChar},
+		102:                   &FloatVectorFieldData{Data: []float32{1, 2, 3, 4}, Dim: 2},
+	}}
+	blobs, err := codec.Serialize(2, 3, data)
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 5)
+
+	// magic number, then descriptor event header with type code 0
+	assert.Equal(t, MagicNumber, int32(common.Endian.Uint32(blobs[0].Value)))
+	assert.Equal(t, byte(DescriptorEventType), blobs[0].Value[4+8])
+
+	reader, err := NewBinlogReader(blobs[4].Value)
+	assert.NoError(t, err)
+	descriptor := reader.GetDescriptor()
+	assert.Equal(t, int64(1), descriptor.CollectionID)
+	assert.Equal(t, int64(102), descriptor.FieldID)
+	assert.Equal(t, Timestamp(10), descriptor.StartTimestamp)
+	assert.Equal(t, Timestamp(20), descriptor.EndTimestamp)
+	assert.Equal(t, schemapb.DataType_FloatVector, descriptor.PayloadDataType)
+
+	partitionID, segmentID, result, err := codec.Deserialize(blobs)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), partitionID)
+	assert.Equal(t, int64(3), segmentID)
+	assert.Equal(t, data.Data, result.Data)
+}
+
+func TestInsertCodecNullable(t *testing.T) {
+	meta := newTestCollectionMeta()
+	field := &schemapb.FieldSchema{FieldID: 103, Name: "age", DataType: schemapb.DataType_Int32,
+		TypeParams: []*commonpb.KeyValuePair{{Key: common.NullableKey, Value: "true"}}}
+	meta.Schema.Fields = append(meta.Schema.Fields, field)
+	ages, err := NewFieldData(field)
+	assert.NoError(t, err)
+	assert.NoError(t, ages.AppendRow(int32(1)))
+	assert.NoError(t, ages.AppendRow(nil))
+	assert.Error(t, (&Int32FieldData{}).AppendRow(nil))
+
+	codec := NewInsertCodecWithSchema(meta)
+	data := &InsertData{Data: map[FieldID]FieldData{
+		common.RowIDField:     &Int64FieldData{Data: []int64{1, 2}},
+		common.TimeStampField: &Int64FieldData{Data: []int64{10, 20}},
+		100:                   &Int64FieldData{Data: []int64{1, 2}},
+		101:                   &StringFieldData{Data: []string{"a", "b"}, DataType: schemapb.DataType_VarChar},
+		102:                   &FloatVectorFieldData{Data: []float32{1, 2, 3, 4}, Dim: 2},
+		103:                   ages,
+	}}
+	blobs, err := codec.Serialize(2, 3, data)
+	assert.NoError(t, err)
+	_, _, result, err := codec.Deserialize(blobs)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, GetValidData(result.Data[103]))
+	assert.Equal(t, int32(1), result.Data[103].GetRow(0))
+	assert.Nil(t, result.Data[103].GetRow(1))
+	assert.Nil(t, GetValidData(result.Data[101]))
+}
+
+func TestInsertCodecSparse(t *testing.T) {
+	meta := newTestCollectionMeta()
+	field := &schemapb.FieldSchema{FieldID: 103, Name: "sparse", DataType: DataType_SparseFloatVector}
+	meta.Schema.Fields = append(meta.Schema.Fields, field)
+	assert.Equal(t, "SparseFloatVector", field.GetDataType().String())
+	sparse, err := NewFieldData(field)
+	assert.NoError(t, err)
+	assert.NoError(t, sparse.AppendRow(NewSparseRow(map[uint32]float32{3: 0.5, 1: 2})))
+	assert.NoError(t, sparse.AppendRow([]byte{}))
+	assert.Equal(t, 4, sparse.(*SparseFloatVectorFieldData).Dim)
+	// indexes must be ascending, values must be non-negative
+	assert.Error(t, sparse.AppendRow(append(NewSparseRow(map[uint32]float32{3: 1}), NewSparseRow(map[uint32]float32{1: 1})...)))
+	assert.Error(t, sparse.AppendRow(NewSparseRow(map[uint32]float32{1: -1})))
+	assert.Error(t, sparse.AppendRow([]byte{1}))
+
+	codec := NewInsertCodecWithSchema(meta)
+	data := &InsertData{Data: map[FieldID]FieldData{
+		common.RowIDField:     &Int64FieldData{Data: []int64{1, 2}},
+		common.TimeStampField: &Int64FieldData{Data: []int64{10, 20}},
+		100:                   &Int64FieldData{Data: []int64{1, 2}},
+		101:                   &StringFieldData{Data: []string{"a", "b"}, DataType: schemapb.DataType_VarChar},
+		102:                   &FloatVectorFieldData{Data: []float32{1, 2, 3, 4}, Dim: 2},
+		103:                   sparse,
+	}}
+	blobs, err := codec.Serialize(2, 3, data)
+	assert.NoError(t, err)
+	_, _, result, err := codec.Deserialize(blobs)
+	assert.NoError(t, err)
+	assert.Equal(t, sparse, result.Data[103])
+
+	proto, err := FieldDataToProto(field, sparse)
+	assert.NoError(t, err)
+	converted, err := FieldDataFromProto(field, proto)
+	assert.NoError(t, err)
+	assert.Equal(t, sparse, converted)
+}
+
+func TestInsertCodecHalfVectors(t *testing.T) {
+	meta := newTestCollectionMeta()
+	dimParams := []*commonpb.KeyValuePair{{Key: common.DimKey, Value: "2"}}
+	float16Field := &schemapb.FieldSchema{FieldID: 103, Name: "f16", DataType: schemapb.DataType_Float16Vector, TypeParams: dimParams}
+	bfloat16Field := &schemapb.FieldSchema{FieldID: 104, Name: "bf16", DataType: DataType_BFloat16Vector, TypeParams: dimParams}
+	meta.Schema.Fields = append(meta.Schema.Fields, float16Field, bfloat16Field)
+	assert.Equal(t, "BFloat16Vector", bfloat16Field.GetDataType().String())
+
+	values := []float32{1, -2.5, 0.099975586, 65504}
+	float16 := &Float16VectorFieldData{Data: Float32sToFloat16(values), Dim: 2}
+	bfloat16 := &BFloat16VectorFieldData{Data: Float32sToBFloat16(values), Dim: 2}
+	assert.Equal(t, values, Float16ToFloat32s(float16.Data))
+	assert.Equal(t, []float32{1, -2.5, 0.10009765625, 65536}, BFloat16ToFloat32s(bfloat16.Data))
+	assert.Error(t, bfloat16.AppendRow([]byte{1, 2}))
+
+	codec := NewInsertCodecWithSchema(meta)
+	data := &InsertData{Data: map[FieldID]FieldData{
+		common.RowIDField:     &Int64FieldData{Data: []int64{1, 2}},
+		common.TimeStampField: &Int64FieldData{Data: []int64{10, 20}},
+		100:                   &Int64FieldData{Data: []int64{1, 2}},
+		101:                   &StringFieldData{Data: []string{"a", "b"}, DataType: schemapb.DataType_VarChar},
+		102:                   &FloatVectorFieldData{Data: []float32{1, 2, 3, 4}, Dim: 2},
+		103:                   float16,
+		104:                   bfloat16,
+	}}
+	blobs, err := codec.Serialize(2, 3, data)
+	assert.NoError(t, err)
+	_, _, result, err := codec.Deserialize(blobs)
+	assert.NoError(t, err)
+	assert.Equal(t, float16, result.Data[103])
+	assert.Equal(t, bfloat16, result.Data[104])
+
+	// bfloat16 vectors travel as the float16 bytes of proto
+	proto, err := FieldDataToProto(bfloat16Field, bfloat16)
+	assert.NoError(t, err)
+	assert.Equal(t, DataType_BFloat16Vector, proto.GetType())
+	converted, err := FieldDataFromProto(bfloat16Field, proto)
+	assert.NoError(t, err)
+	assert.Equal(t, bfloat16, converted)
+	proto.GetVectors().Data = &schemapb.VectorField_Float16Vector{Float16Vector: []byte{1, 2, 3}}
+	_, err = FieldDataFromProto(bfloat16Field, proto)
+	assert.Error(t, err)
+}
+
+func TestFloat16Conversion(t *testing.T) {
+	inf := float32(math.Inf(1))
+	// rounded to nearest even, overflow to Inf, subnormals are kept
+	values := []float32{0, 1.00048828125, 1.00146484375, 65520, inf, -inf, 5.9604645e-08, 2.9802322e-08, 6.1035156e-05}
+	expected := []float32{0, 1, 1.001953125, inf, inf, -inf, 5.9604645e-08, 0, 6.1035156e-05}
+	assert.Equal(t, expected, Float16ToFloat32s(Float32sToFloat16(values)))
+	assert.True(t, math.IsNaN(float64(Float16ToFloat32s(Float32sToFloat16([]float32{float32(math.NaN())}))[0])))
+
+	values = []float32{1.00390625, 1.01171875, 3.4028235e+38, -inf}
+	expected = []float32{1, 1.015625, inf, -inf}
+	assert.Equal(t, expected, BFloat16ToFloat32s(Float32sToBFloat16(values)))
+	assert.True(t, math.IsNaN(float64(BFloat16ToFloat32s(Float32sToBFloat16([]float32{float32(math.NaN())}))[0])))
+}
+
+func TestDeleteCodec(t *testing.T) {
+	codec := NewDeleteCodec()
+	data := NewDeleteData([]PrimaryKey{NewInt64PrimaryKey(1), NewInt64PrimaryKey(2)}, []Timestamp{100, 200})
+	blob, err := codec.Serialize(1, 2, 3, data)
+	assert.NoError(t, err)
+
+	_, segmentID, result, err := codec.Deserialize([]*Blob{blob})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), segmentID)
+	assert.Equal(t, data.Tss, result.Tss)
+	assert.True(t, data.Pks[1].EQ(result.Pks[1]))
+
+	// malformed entries are rejected instead of panicking
+	for _, entry := range []string{`{"pk":1,"ts":100}`, `{"pkType":5,"ts":100}`, `{"pk":1,"pkType":5}`, `{"pk":1,"ts":100,"pkType":null}`} {
+		assert.Error(t, json.Unmarshal([]byte(entry), &DeleteLog{}), entry)
+	}
+}
+
+func TestLocalChunkManager(t *testing.T) {
+	rootPath, err := ioutil.TempDir("/tmp/", "milvus-*")
+	assert.NoError(t, err)
+	cm, err := NewLocalChunkManager(rootPath)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	key := BuildInsertLogPath(1, 2, 3, 100, 4)
+	assert.Equal(t, "insert_log/1/2/3/100/4", key)
+	assert.NoError(t, cm.Write(ctx, key, []byte("data")))
+	content, err := cm.Read(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data"), content)
+
+	assert.NoError(t, cm.RemoveWithPrefix(ctx, BuildSegmentPrefixes(1, 2, 3)[0]))
+	exist, err := cm.Exist(ctx, key)
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
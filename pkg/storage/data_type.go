@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"github.com/milvus-io/milvus-proto/go-api/v2/commonpb"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+)
+
+// The vector types of milvus missing in this version of proto, their names are registered so String() and parsing by name work.
+//
+// FieldData has no variants of them either. Rows of sparse vectors are the BytesData of scalars in requests & results,
+// bfloat16 vectors are the Float16Vector bytes of VectorField, which are told apart from float16 vectors by the type of FieldData.
+// Query vectors are the rows in placeholder values of their placeholder types.
+const (
+	DataType_BFloat16Vector           schemapb.DataType        = 103
+	DataType_SparseFloatVector        schemapb.DataType        = 104
+	PlaceholderType_BFloat16Vector    commonpb.PlaceholderType = 103
+	PlaceholderType_SparseFloatVector commonpb.PlaceholderType = 104
+)
+
+func init() {
+	for name, value := range map[string]int32{
+		"BFloat16Vector":    int32(DataType_BFloat16Vector),
+		"SparseFloatVector": int32(DataType_SparseFloatVector),
+	} {
+		schemapb.DataType_name[value] = name
+		schemapb.DataType_value[name] = value
+		commonpb.PlaceholderType_name[value] = name
+		commonpb.PlaceholderType_value[name] = value
+	}
+}
+
+// IsVectorType returns whether the data type is a vector type, including the types missing in proto
+func IsVectorType(dataType schemapb.DataType) bool {
+	return typeutil.IsVectorType(dataType) || dataType == DataType_BFloat16Vector || dataType == DataType_SparseFloatVector
+}
+
+// IsFloatVectorType returns whether the data type is dense vectors of floats, i.e. float, float16 or bfloat16 vectors
+func IsFloatVectorType(dataType schemapb.DataType) bool {
+	return dataType == schemapb.DataType_FloatVector || dataType == schemapb.DataType_Float16Vector || dataType == DataType_BFloat16Vector
+}
+
+// IsSparseVectorType returns whether the data type is sparse float vector
+func IsSparseVectorType(dataType schemapb.DataType) bool {
+	return dataType == DataType_SparseFloatVector
+}
+
+// GetDim returns the dim of vector field, it's 0 for sparse vectors whose dim is not fixed
+func GetDim(field *schemapb.FieldSchema) (int64, error) {
+	switch field.GetDataType() {
+	case DataType_SparseFloatVector:
+		return 0, nil
+	case DataType_BFloat16Vector:
+		// typeutil doesn't know bfloat16 vectors, their dim is the same as float16 vectors
+		return typeutil.GetDim(&schemapb.FieldSchema{
+			DataType:    schemapb.DataType_Float16Vector,
+			TypeParams:  field.GetTypeParams(),
+			IndexParams: field.GetIndexParams(),
+		})
+	}
+	return typeutil.GetDim(field)
+}
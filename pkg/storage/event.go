@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/tsoutil"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+)
+
+// EventTypeCode is the type of an event in binlog, must be kept the same with upstream milvus
+type EventTypeCode int8
+
+const (
+	DescriptorEventType EventTypeCode = iota
+	InsertEventType
+	DeleteEventType
+	CreateCollectionEventType
+	DropCollectionEventType
+	CreatePartitionEventType
+	DropPartitionEventType
+	IndexFileEventType
+	EventTypeEnd
+)
+
+const originalSizeKey = "original_size"
+
+// baseEventHeader is the header of each event:
+// | Timestamp(8) | TypeCode(1) | EventLength(4) | NextPosition(4) |
+type baseEventHeader struct {
+	Timestamp    Timestamp
+	TypeCode     EventTypeCode
+	EventLength  int32
+	NextPosition int32
+}
+
+func (header *baseEventHeader) GetMemoryUsageInBytes() int32 {
+	return int32(binary.Size(header))
+}
+
+func (header *baseEventHeader) Write(buffer io.Writer) error {
+	return binary.Write(buffer, common.Endian, header)
+}
+
+func readEventHeader(buffer io.Reader) (*baseEventHeader, error) {
+	header := &baseEventHeader{}
+	if err := binary.Read(buffer, common.Endian, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func newEventHeader(eventTypeCode EventTypeCode) *baseEventHeader {
+	return &baseEventHeader{
+		Timestamp:    tsoutil.ComposeTS(time.Now().UnixNano()/int64(time.Millisecond), 0),
+		TypeCode:     eventTypeCode,
+		EventLength:  -1,
+		NextPosition: -1,
+	}
+}
+
+// DescriptorEventDataFixPart is the fixed part of descriptor event data
+type DescriptorEventDataFixPart struct {
+	CollectionID    int64
+	PartitionID     int64
+	SegmentID       int64
+	FieldID         int64
+	StartTimestamp  Timestamp
+	EndTimestamp    Timestamp
+	PayloadDataType schemapb.DataType
+}
+
+// descriptorEventData is the data of descriptor event:
+// | FixPart | PostHeaderLengths | ExtraLength(4) | ExtraBytes(json) |
+type descriptorEventData struct {
+	DescriptorEventDataFixPart
+	ExtraLength       int32
+	ExtraBytes        []byte
+	Extras            map[string]interface{}
+	PostHeaderLengths []uint8
+}
+
+func newDescriptorEventData() *descriptorEventData {
+	data := &descriptorEventData{
+		DescriptorEventDataFixPart: DescriptorEventDataFixPart{
+			CollectionID:    -1,
+			PartitionID:     -1,
+			SegmentID:       -1,
+			FieldID:         -1,
+			StartTimestamp:  0,
+			EndTimestamp:    0,
+			PayloadDataType: -1,
+		},
+		PostHeaderLengths: []uint8{},
+		Extras:            make(map[string]interface{}),
+	}
+	for i := DescriptorEventType; i < EventTypeEnd; i++ {
+		data.PostHeaderLengths = append(data.PostHeaderLengths, uint8(getEventFixPartSize(i)))
+	}
+	return data
+}
+
+func (data *descriptorEventData) GetEventDataFixPartSize() int32 {
+	return int32(binary.Size(data.DescriptorEventDataFixPart))
+}
+
+func (data *descriptorEventData) GetMemoryUsageInBytes() int32 {
+	return data.GetEventDataFixPartSize() + int32(binary.Size(data.PostHeaderLengths)) + int32(binary.Size(data.ExtraLength)) + data.ExtraLength
+}
+
+func (data *descriptorEventData) AddExtra(k string, v interface{}) {
+	data.Extras[k] = v
+}
+
+// FinishExtra encodes the extras into json, the original size is required by upstream
+func (data *descriptorEventData) FinishExtra() error {
+	sizeStored, ok := data.Extras[originalSizeKey]
+	if !ok {
+		return fmt.Errorf("%v not in extra", originalSizeKey)
+	}
+	sizeStr, ok := sizeStored.(string)
+	if !ok {
+		return fmt.Errorf("value of %v must be string", originalSizeKey)
+	}
+	if _, err := strconv.Atoi(sizeStr); err != nil {
+		return fmt.Errorf("value of %v must be able to be converted into int", originalSizeKey)
+	}
+
+	var err error
+	data.ExtraBytes, err = json.Marshal(data.Extras)
+	if err != nil {
+		return err
+	}
+	data.ExtraLength = int32(len(data.ExtraBytes))
+	return nil
+}
+
+func (data *descriptorEventData) Write(buffer io.Writer) error {
+	if err := binary.Write(buffer, common.Endian, data.DescriptorEventDataFixPart); err != nil {
+		return err
+	}
+	if err := binary.Write(buffer, common.Endian, data.PostHeaderLengths); err != nil {
+		return err
+	}
+	if err := binary.Write(buffer, common.Endian, data.ExtraLength); err != nil {
+		return err
+	}
+	_, err := buffer.Write(data.ExtraBytes)
+	return err
+}
+
+func readDescriptorEventData(buffer io.Reader) (*descriptorEventData, error) {
+	event := newDescriptorEventData()
+	if err := binary.Read(buffer, common.Endian, &event.DescriptorEventDataFixPart); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(buffer, common.Endian, &event.PostHeaderLengths); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(buffer, common.Endian, &event.ExtraLength); err != nil {
+		return nil, err
+	}
+	event.ExtraBytes = make([]byte, event.ExtraLength)
+	if _, err := io.ReadFull(buffer, event.ExtraBytes); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(event.ExtraBytes, &event.Extras); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// descriptorEvent is the first event of each binlog file
+type descriptorEvent struct {
+	baseEventHeader
+	descriptorEventData
+}
+
+func newDescriptorEvent() *descriptorEvent {
+	return &descriptorEvent{
+		baseEventHeader: baseEventHeader{
+			Timestamp: tsoutil.ComposeTS(time.Now().UnixNano()/int64(time.Millisecond), 0),
+			TypeCode:  DescriptorEventType,
+		},
+		descriptorEventData: *newDescriptorEventData(),
+	}
+}
+
+func (event *descriptorEvent) GetMemoryUsageInBytes() int32 {
+	return event.baseEventHeader.GetMemoryUsageInBytes() + event.descriptorEventData.GetMemoryUsageInBytes()
+}
+
+func (event *descriptorEvent) Write(buffer io.Writer) error {
+	if err := event.descriptorEventData.FinishExtra(); err != nil {
+		return err
+	}
+	event.EventLength = event.GetMemoryUsageInBytes()
+	event.NextPosition = int32(binary.Size(MagicNumber)) + event.EventLength
+
+	if err := event.baseEventHeader.Write(buffer); err != nil {
+		return err
+	}
+	return event.descriptorEventData.Write(buffer)
+}
+
+// eventData is the fixed part of insert/delete/index events
+type eventData struct {
+	StartTimestamp Timestamp
+	EndTimestamp   Timestamp
+}
+
+func (data *eventData) GetEventDataFixPartSize() int32 {
+	return int32(binary.Size(data))
+}
+
+func (data *eventData) SetEventTimestamp(start Timestamp, end Timestamp) {
+	data.StartTimestamp = start
+	data.EndTimestamp = end
+}
+
+func (data *eventData) WriteEventData(buffer io.Writer) error {
+	if data.StartTimestamp == 0 {
+		return fmt.Errorf("invalid start timestamp")
+	}
+	if data.EndTimestamp == 0 {
+		return fmt.Errorf("invalid end timestamp")
+	}
+	return binary.Write(buffer, common.Endian, data)
+}
+
+func getEventFixPartSize(code EventTypeCode) int32 {
+	switch code {
+	case DescriptorEventType:
+		return (&descriptorEventData{}).GetEventDataFixPartSize()
+	case InsertEventType, DeleteEventType, CreateCollectionEventType, DropCollectionEventType,
+		CreatePartitionEventType, DropPartitionEventType, IndexFileEventType:
+		return (&eventData{}).GetEventDataFixPartSize()
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+)
+
+// BFloat16VectorFieldData is the rows of bfloat16 vectors, each element is 2 bytes in little endian, same as milvus
+type BFloat16VectorFieldData struct {
+	Data []byte
+	Dim  int
+}
+
+func (data *BFloat16VectorFieldData) RowNum() int { return len(data.Data) / 2 / data.Dim }
+
+func (data *BFloat16VectorFieldData) GetRow(i int) any {
+	return data.Data[i*data.Dim*2 : (i+1)*data.Dim*2]
+}
+
+func (data *BFloat16VectorFieldData) GetDataType() schemapb.DataType {
+	return DataType_BFloat16Vector
+}
+
+func (data *BFloat16VectorFieldData) GetMemorySize() int { return len(data.Data) + 4 }
+
+func (data *BFloat16VectorFieldData) AppendRow(row any) error {
+	v, ok := row.([]byte)
+	if !ok || len(v) != data.Dim*2 {
+		return errRowTypeMismatch(row, data.GetDataType())
+	}
+	data.Data = append(data.Data, v...)
+	return nil
+}
+
+func (data *BFloat16VectorFieldData) IsValid(i int) bool { return true }
+
+// ToFloatVectors converts float, float16 and bfloat16 vectors into float vectors, false if data is not one of them
+func ToFloatVectors(data FieldData) (*FloatVectorFieldData, bool) {
+	switch d := data.(type) {
+	case *FloatVectorFieldData:
+		return d, true
+	case *Float16VectorFieldData:
+		return &FloatVectorFieldData{Data: Float16ToFloat32s(d.Data), Dim: d.Dim}, true
+	case *BFloat16VectorFieldData:
+		return &FloatVectorFieldData{Data: BFloat16ToFloat32s(d.Data), Dim: d.Dim}, true
+	}
+	return nil, false
+}
+
+// Float16ToFloat32s decodes the IEEE 754 half precision numbers in little endian
+func Float16ToFloat32s(data []byte) []float32 {
+	ret := make([]float32, len(data)/2)
+	for i := range ret {
+		ret[i] = float16ToFloat32(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return ret
+}
+
+// Float32sToFloat16 encodes the numbers into IEEE 754 half precision in little endian, rounded to nearest even
+func Float32sToFloat16(values []float32) []byte {
+	ret := make([]byte, len(values)*2)
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(ret[i*2:], float32ToFloat16(v))
+	}
+	return ret
+}
+
+// BFloat16ToFloat32s decodes the bfloat16 numbers in little endian, which are the high 16 bits of float32
+func BFloat16ToFloat32s(data []byte) []float32 {
+	ret := make([]float32, len(data)/2)
+	for i := range ret {
+		ret[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(data[i*2:])) << 16)
+	}
+	return ret
+}
+
+// Float32sToBFloat16 encodes the numbers into bfloat16 in little endian, rounded to nearest even
+func Float32sToBFloat16(values []float32) []byte {
+	ret := make([]byte, len(values)*2)
+	for i, v := range values {
+		bits := math.Float32bits(v)
+		if math.IsNaN(float64(v)) {
+			// keep it NaN rather than rounding the mantissa into Inf
+			bits |= 1 << 22
+		} else {
+			bits += 0x7fff + (bits>>16)&1
+		}
+		binary.LittleEndian.PutUint16(ret[i*2:], uint16(bits>>16))
+	}
+	return ret
+}
+
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		// Inf or NaN
+		return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+	case exp == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// subnormal, normalize it
+		exp = 1
+		for mantissa&0x400 == 0 {
+			mantissa <<= 1
+			exp--
+		}
+		mantissa &= 0x3ff
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mantissa<<13)
+}
+
+func float32ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xff
+	mantissa := bits & 0x7fffff
+	if exp == 0xff {
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+	exp = exp - 127 + 15
+	if exp >= 0x1f {
+		return sign | 0x7c00
+	}
+	if exp <= 0 {
+		if exp < -10 {
+			return sign
+		}
+		// subnormal, the implicit leading bit is shifted into the mantissa
+		mantissa |= 0x800000
+		shift := uint32(14 - exp)
+		half := uint32(1) << (shift - 1)
+		rounded := mantissa >> shift
+		if rest := mantissa & (1<<shift - 1); rest > half || (rest == half && rounded&1 == 1) {
+			rounded++
+		}
+		return sign | uint16(rounded)
+	}
+	rounded := uint32(exp)<<10 | mantissa>>13
+	if rest := mantissa & 0x1fff; rest > 0x1000 || (rest == 0x1000 && rounded&1 == 1) {
+		// carrying into the exponent rounds up to the next binade or Inf correctly
+		rounded++
+	}
+	return sign | uint16(rounded)
+}
@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/milvus-io/milvus/pkg/util/typeutil"
+	"github.com/pkg/errors"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+)
+
+// UniqueID is an alias of typeutil.UniqueID.
+type UniqueID = typeutil.UniqueID
+
+// Timestamp is an alias of typeutil.Timestamp
+type Timestamp = typeutil.Timestamp
+
+// FieldID is the id of a collection field
+type FieldID = typeutil.UniqueID
+
+// InsertData is the columnar rows of one segment, indexed by field id
+type InsertData struct {
+	Data map[FieldID]FieldData
+}
+
+func NewInsertData(schema *schemapb.CollectionSchema) (*InsertData, error) {
+	ret := &InsertData{Data: make(map[FieldID]FieldData)}
+	for _, field := range schema.GetFields() {
+		fieldData, err := NewFieldData(field)
+		if err != nil {
+			return nil, err
+		}
+		ret.Data[field.GetFieldID()] = fieldData
+	}
+	return ret, nil
+}
+
+// GetRowNum returns the row num, all fields share the same row num
+func (d *InsertData) GetRowNum() int {
+	if d == nil || len(d.Data) == 0 {
+		return 0
+	}
+	for _, fieldData := range d.Data {
+		return fieldData.RowNum()
+	}
+	return 0
+}
+
+func (d *InsertData) GetMemorySize() int {
+	size := 0
+	for _, fieldData := range d.Data {
+		size += fieldData.GetMemorySize()
+	}
+	return size
+}
+
+// FieldData defines field data interface
+type FieldData interface {
+	GetMemorySize() int
+	RowNum() int
+	GetRow(i int) any
+	AppendRow(row any) error
+	GetDataType() schemapb.DataType
+	// IsValid returns false if the i-th row is null
+	IsValid(i int) bool
+}
+
+func NewFieldData(field *schemapb.FieldSchema) (FieldData, error) {
+	dim := 0
+	if IsVectorType(field.GetDataType()) {
+		vectorDim, err := GetDim(field)
+		if err != nil {
+			return nil, err
+		}
+		dim = int(vectorDim)
+	}
+	ret, err := newFieldDataByType(field.GetDataType(), dim)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create data of field %s", field.GetName())
+	}
+	if arrayData, ok := ret.(*ArrayFieldData); ok {
+		arrayData.ElementType = field.GetElementType()
+	}
+	if validity := getValidity(ret); validity != nil {
+		validity.Nullable = IsNullable(field)
+	}
+	return ret, nil
+}
+
+// IsNullable returns whether the scalar field is nullable, which is set by the nullable type param
+func IsNullable(field *schemapb.FieldSchema) bool {
+	nullable, _ := ParseNullable(field)
+	return nullable
+}
+
+// ParseNullable parses the nullable type param of field, it's false if not set
+func ParseNullable(field *schemapb.FieldSchema) (bool, error) {
+	for _, kv := range field.GetTypeParams() {
+		if kv.GetKey() == common.NullableKey {
+			nullable, err := strconv.ParseBool(kv.GetValue())
+			if err != nil {
+				return false, errors.Errorf("invalid %s of field %s: %s", common.NullableKey, field.GetName(), kv.GetValue())
+			}
+			return nullable, nil
+		}
+	}
+	return false, nil
+}
+
+// Validity is the validity bitmap of nullable scalar fields, rows of null are placeholders of zero values in Data.
+// ValidData is empty if the field isn't nullable.
+type Validity struct {
+	Nullable  bool
+	ValidData []bool
+}
+
+func (v *Validity) IsValid(i int) bool {
+	return !v.Nullable || v.ValidData[i]
+}
+
+func (v *Validity) validity() *Validity {
+	return v
+}
+
+// getValidity returns the validity of scalar field data, nil for vectors
+func getValidity(data FieldData) *Validity {
+	if d, ok := data.(interface{ validity() *Validity }); ok {
+		return d.validity()
+	}
+	return nil
+}
+
+// GetValidData returns the validity of each row, nil if the data isn't nullable
+func GetValidData(data FieldData) []bool {
+	if validity := getValidity(data); validity != nil && validity.Nullable {
+		return validity.ValidData
+	}
+	return nil
+}
+
+// SetValidData marks the rows of false as null, data must be nullable
+func SetValidData(data FieldData, validData []bool) error {
+	validity := getValidity(data)
+	if validity == nil || !validity.Nullable {
+		return errors.Errorf("data of %s is not nullable", data.GetDataType().String())
+	}
+	if len(validData) != data.RowNum() {
+		return errors.Errorf("the number of valid data %d mismatches the number of rows %d", len(validData), data.RowNum())
+	}
+	validity.ValidData = validData
+	return nil
+}
+
+type BoolFieldData struct {
+	Validity
+	Data []bool
+}
+type Int8FieldData struct {
+	Validity
+	Data []int8
+}
+type Int16FieldData struct {
+	Validity
+	Data []int16
+}
+type Int32FieldData struct {
+	Validity
+	Data []int32
+}
+type Int64FieldData struct {
+	Validity
+	Data []int64
+}
+type FloatFieldData struct {
+	Validity
+	Data []float32
+}
+type DoubleFieldData struct {
+	Validity
+	Data []float64
+}
+type StringFieldData struct {
+	Validity
+	Data     []string
+	DataType schemapb.DataType
+}
+type ArrayFieldData struct {
+	Validity
+	ElementType schemapb.DataType
+	Data        []*schemapb.ScalarField
+}
+type JSONFieldData struct {
+	Validity
+	Data [][]byte
+}
+type BinaryVectorFieldData struct {
+	Data []byte
+	Dim  int
+}
+type FloatVectorFieldData struct {
+	Data []float32
+	Dim  int
+}
+type Float16VectorFieldData struct {
+	Data []byte
+	Dim  int
+}
+
+func (data *BoolFieldData) RowNum() int          { return len(data.Data) }
+func (data *Int8FieldData) RowNum() int          { return len(data.Data) }
+func (data *Int16FieldData) RowNum() int         { return len(data.Data) }
+func (data *Int32FieldData) RowNum() int         { return len(data.Data) }
+func (data *Int64FieldData) RowNum() int         { return len(data.Data) }
+func (data *FloatFieldData) RowNum() int         { return len(data.Data) }
+func (data *DoubleFieldData) RowNum() int        { return len(data.Data) }
+func (data *StringFieldData) RowNum() int        { return len(data.Data) }
+func (data *ArrayFieldData) RowNum() int         { return len(data.Data) }
+func (data *JSONFieldData) RowNum() int          { return len(data.Data) }
+func (data *BinaryVectorFieldData) RowNum() int  { return len(data.Data) * 8 / data.Dim }
+func (data *FloatVectorFieldData) RowNum() int   { return len(data.Data) / data.Dim }
+func (data *Float16VectorFieldData) RowNum() int { return len(data.Data) / 2 / data.Dim }
+
+func (data *BoolFieldData) GetRow(i int) any   { return getRow(data.Data, &data.Validity, i) }
+func (data *Int8FieldData) GetRow(i int) any   { return getRow(data.Data, &data.Validity, i) }
+func (data *Int16FieldData) GetRow(i int) any  { return getRow(data.Data, &data.Validity, i) }
+func (data *Int32FieldData) GetRow(i int) any  { return getRow(data.Data, &data.Validity, i) }
+func (data *Int64FieldData) GetRow(i int) any  { return getRow(data.Data, &data.Validity, i) }
+func (data *FloatFieldData) GetRow(i int) any  { return getRow(data.Data, &data.Validity, i) }
+func (data *DoubleFieldData) GetRow(i int) any { return getRow(data.Data, &data.Validity, i) }
+func (data *StringFieldData) GetRow(i int) any { return getRow(data.Data, &data.Validity, i) }
+func (data *ArrayFieldData) GetRow(i int) any  { return getRow(data.Data, &data.Validity, i) }
+func (data *JSONFieldData) GetRow(i int) any   { return getRow(data.Data, &data.Validity, i) }
+func (data *BinaryVectorFieldData) GetRow(i int) any {
+	return data.Data[i*data.Dim/8 : (i+1)*data.Dim/8]
+}
+func (data *FloatVectorFieldData) GetRow(i int) any {
+	return data.Data[i*data.Dim : (i+1)*data.Dim]
+}
+func (data *Float16VectorFieldData) GetRow(i int) any {
+	return data.Data[i*data.Dim*2 : (i+1)*data.Dim*2]
+}
+
+func (data *BoolFieldData) GetDataType() schemapb.DataType   { return schemapb.DataType_Bool }
+func (data *Int8FieldData) GetDataType() schemapb.DataType   { return schemapb.DataType_Int8 }
+func (data *Int16FieldData) GetDataType() schemapb.DataType  { return schemapb.DataType_Int16 }
+func (data *Int32FieldData) GetDataType() schemapb.DataType  { return schemapb.DataType_Int32 }
+func (data *Int64FieldData) GetDataType() schemapb.DataType  { return schemapb.DataType_Int64 }
+func (data *FloatFieldData) GetDataType() schemapb.DataType  { return schemapb.DataType_Float }
+func (data *DoubleFieldData) GetDataType() schemapb.DataType { return schemapb.DataType_Double }
+func (data *StringFieldData) GetDataType() schemapb.DataType {
+	if data.DataType == schemapb.DataType_None {
+		return schemapb.DataType_VarChar
+	}
+	return data.DataType
+}
+func (data *ArrayFieldData) GetDataType() schemapb.DataType { return schemapb.DataType_Array }
+func (data *JSONFieldData) GetDataType() schemapb.DataType  { return schemapb.DataType_JSON }
+func (data *BinaryVectorFieldData) GetDataType() schemapb.DataType {
+	return schemapb.DataType_BinaryVector
+}
+func (data *FloatVectorFieldData) GetDataType() schemapb.DataType {
+	return schemapb.DataType_FloatVector
+}
+func (data *Float16VectorFieldData) GetDataType() schemapb.DataType {
+	return schemapb.DataType_Float16Vector
+}
+
+func (data *BoolFieldData) GetMemorySize() int   { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *Int8FieldData) GetMemorySize() int   { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *Int16FieldData) GetMemorySize() int  { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *Int32FieldData) GetMemorySize() int  { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *Int64FieldData) GetMemorySize() int  { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *FloatFieldData) GetMemorySize() int  { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *DoubleFieldData) GetMemorySize() int { return binary.Size(data.Data) + len(data.ValidData) }
+func (data *StringFieldData) GetMemorySize() int {
+	size := 0
+	for _, val := range data.Data {
+		size += len(val) + 16
+	}
+	return size + len(data.ValidData)
+}
+func (data *ArrayFieldData) GetMemorySize() int {
+	size := 0
+	for _, val := range data.Data {
+		size += proto.Size(val)
+	}
+	return size + len(data.ValidData)
+}
+func (data *JSONFieldData) GetMemorySize() int {
+	size := 0
+	for _, val := range data.Data {
+		size += len(val) + 16
+	}
+	return size + len(data.ValidData)
+}
+func (data *BinaryVectorFieldData) GetMemorySize() int  { return len(data.Data) + 4 }
+func (data *FloatVectorFieldData) GetMemorySize() int   { return binary.Size(data.Data) + 4 }
+func (data *Float16VectorFieldData) GetMemorySize() int { return len(data.Data) + 4 }
+
+func (data *BoolFieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *Int8FieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *Int16FieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *Int32FieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *Int64FieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *FloatFieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *DoubleFieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *StringFieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *ArrayFieldData) AppendRow(row any) error {
+	if err := appendRow(&data.Data, &data.Validity, row, data.GetDataType()); err != nil {
+		return err
+	}
+	// placeholder of null is an empty array rather than nil, which can't be serialized
+	if row == nil {
+		data.Data[len(data.Data)-1] = &schemapb.ScalarField{}
+	}
+	return nil
+}
+
+func (data *JSONFieldData) AppendRow(row any) error {
+	return appendRow(&data.Data, &data.Validity, row, data.GetDataType())
+}
+
+func (data *BinaryVectorFieldData) AppendRow(row any) error {
+	v, ok := row.([]byte)
+	if !ok || len(v) != data.Dim/8 {
+		return errRowTypeMismatch(row, data.GetDataType())
+	}
+	data.Data = append(data.Data, v...)
+	return nil
+}
+
+func (data *FloatVectorFieldData) AppendRow(row any) error {
+	v, ok := row.([]float32)
+	if !ok || len(v) != data.Dim {
+		return errRowTypeMismatch(row, data.GetDataType())
+	}
+	data.Data = append(data.Data, v...)
+	return nil
+}
+
+func (data *Float16VectorFieldData) AppendRow(row any) error {
+	v, ok := row.([]byte)
+	if !ok || len(v) != data.Dim*2 {
+		return errRowTypeMismatch(row, data.GetDataType())
+	}
+	data.Data = append(data.Data, v...)
+	return nil
+}
+
+func (data *BinaryVectorFieldData) IsValid(i int) bool  { return true }
+func (data *FloatVectorFieldData) IsValid(i int) bool   { return true }
+func (data *Float16VectorFieldData) IsValid(i int) bool { return true }
+
+func getRow[T any](values []T, validity *Validity, i int) any {
+	if !validity.IsValid(i) {
+		return nil
+	}
+	return values[i]
+}
+
+// appendRow appends the row to values, nil row is null which appends a zero value placeholder
+func appendRow[T any](values *[]T, validity *Validity, row any, dataType schemapb.DataType) error {
+	var v T
+	if row != nil {
+		var ok bool
+		if v, ok = row.(T); !ok {
+			return errRowTypeMismatch(row, dataType)
+		}
+	} else if !validity.Nullable {
+		return errRowTypeMismatch(row, dataType)
+	}
+	*values = append(*values, v)
+	if validity.Nullable {
+		validity.ValidData = append(validity.ValidData, row != nil)
+	}
+	return nil
+}
+
+func errRowTypeMismatch(row any, dataType schemapb.DataType) error {
+	return errors.Errorf("row %s mismatches data type %s", fmt.Sprintf("%T", row), dataType.String())
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"path"
+
+	"github.com/milvus-io/milvus/pkg/util/metautil"
+	"github.com/sharding-db/milvus-mini/pkg/common"
+)
+
+// BuildInsertLogPath returns `insert_log/<coll>/<part>/<seg>/<field>/<log>`, the same layout as upstream milvus
+func BuildInsertLogPath(collectionID, partitionID, segmentID, fieldID, logID UniqueID) string {
+	return path.Join(common.SegmentInsertLogPath, metautil.JoinIDPath(collectionID, partitionID, segmentID, fieldID, logID))
+}
+
+// BuildDeltaLogPath returns `delta_log/<coll>/<part>/<seg>/<log>`
+func BuildDeltaLogPath(collectionID, partitionID, segmentID, logID UniqueID) string {
+	return path.Join(common.SegmentDeltaLogPath, metautil.JoinIDPath(collectionID, partitionID, segmentID, logID))
+}
+
+// BuildSegmentPrefixes returns the insert & delta log prefix of a segment
+func BuildSegmentPrefixes(collectionID, partitionID, segmentID UniqueID) []string {
+	return []string{
+		path.Join(common.SegmentInsertLogPath, metautil.JoinIDPath(collectionID, partitionID, segmentID)),
+		path.Join(common.SegmentDeltaLogPath, metautil.JoinIDPath(collectionID, partitionID, segmentID)),
+	}
+}
+
+// BuildSegmentIndexPath returns `index_files/<build>/<part>/<seg>/<file>`. It's not the layout of upstream milvus,
+// which has the index version after the build id, and the index files are not of the same format either.
+func BuildSegmentIndexPath(buildID, partitionID, segmentID UniqueID, fileName string) string {
+	return path.Join(common.SegmentIndexPath, metautil.JoinIDPath(buildID, partitionID, segmentID), fileName)
+}
+
+// BuildSegmentIndexPrefix returns the prefix of all files of a segment index
+func BuildSegmentIndexPrefix(buildID UniqueID) string {
+	return path.Join(common.SegmentIndexPath, metautil.JoinIDPath(buildID))
+}
@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/compress"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"github.com/golang/protobuf/proto"
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/pkg/errors"
+)
+
+// payloadColumnName is the only column name of a payload parquet file, same as upstream
+const payloadColumnName = "val"
+
+// PayloadWriter encodes a column into a single column parquet file, the same as milvus
+type PayloadWriter struct {
+	dataType  schemapb.DataType
+	arrowType arrow.DataType
+	builder   array.Builder
+	finished  bool
+	// nullable is true if nullable data is added, nulls are written into the validity bitmap of parquet
+	nullable bool
+	rows     int
+	output   *bytes.Buffer
+}
+
+func NewPayloadWriter(dataType schemapb.DataType, dim int) (*PayloadWriter, error) {
+	arrowType, err := milvusDataTypeToArrowType(dataType, dim)
+	if err != nil {
+		return nil, err
+	}
+	return &PayloadWriter{
+		dataType:  dataType,
+		arrowType: arrowType,
+		builder:   array.NewBuilder(memory.DefaultAllocator, arrowType),
+		output:    new(bytes.Buffer),
+	}, nil
+}
+
+// AddFieldData appends all rows of the field data to payload
+func (w *PayloadWriter) AddFieldData(data FieldData) error {
+	if w.finished {
+		return errors.New("can't append data to a finished payload writer")
+	}
+	if data.GetDataType() != w.dataType {
+		return errors.Errorf("payload of %s can't accept data of %s", w.dataType.String(), data.GetDataType().String())
+	}
+	switch d := data.(type) {
+	case *BoolFieldData:
+		w.builder.(*array.BooleanBuilder).AppendValues(d.Data, d.ValidData)
+	case *Int8FieldData:
+		w.builder.(*array.Int8Builder).AppendValues(d.Data, d.ValidData)
+	case *Int16FieldData:
+		w.builder.(*array.Int16Builder).AppendValues(d.Data, d.ValidData)
+	case *Int32FieldData:
+		w.builder.(*array.Int32Builder).AppendValues(d.Data, d.ValidData)
+	case *Int64FieldData:
+		w.builder.(*array.Int64Builder).AppendValues(d.Data, d.ValidData)
+	case *FloatFieldData:
+		w.builder.(*array.Float32Builder).AppendValues(d.Data, d.ValidData)
+	case *DoubleFieldData:
+		w.builder.(*array.Float64Builder).AppendValues(d.Data, d.ValidData)
+	case *StringFieldData:
+		w.builder.(*array.StringBuilder).AppendValues(d.Data, d.ValidData)
+	case *ArrayFieldData:
+		builder := w.builder.(*array.BinaryBuilder)
+		for i, row := range d.Data {
+			if !d.IsValid(i) {
+				builder.AppendNull()
+				continue
+			}
+			bytes, err := proto.Marshal(row)
+			if err != nil {
+				return err
+			}
+			builder.Append(bytes)
+		}
+	case *JSONFieldData:
+		w.builder.(*array.BinaryBuilder).AppendValues(d.Data, d.ValidData)
+	case *BinaryVectorFieldData:
+		w.appendFixedSizeRows(d.Data, d.Dim/8)
+	case *FloatVectorFieldData:
+		w.appendFixedSizeRows(float32ToBytes(d.Data), d.Dim*4)
+	case *Float16VectorFieldData:
+		w.appendFixedSizeRows(d.Data, d.Dim*2)
+	case *BFloat16VectorFieldData:
+		w.appendFixedSizeRows(d.Data, d.Dim*2)
+	case *SparseFloatVectorFieldData:
+		w.builder.(*array.BinaryBuilder).AppendValues(d.Data, nil)
+	default:
+		return errors.Errorf("unsupported field data %T", data)
+	}
+	if GetValidData(data) != nil {
+		w.nullable = true
+	}
+	w.rows += data.RowNum()
+	return nil
+}
+
+// AddStrings appends string rows, used by delta logs
+func (w *PayloadWriter) AddStrings(values ...string) error {
+	builder, ok := w.builder.(*array.StringBuilder)
+	if !ok {
+		return errors.Errorf("payload of %s can't accept strings", w.dataType.String())
+	}
+	builder.AppendValues(values, nil)
+	w.rows += len(values)
+	return nil
+}
+
+func (w *PayloadWriter) appendFixedSizeRows(data []byte, width int) {
+	builder := w.builder.(*array.FixedSizeBinaryBuilder)
+	for i := 0; i+width <= len(data); i += width {
+		builder.Append(data[i : i+width])
+	}
+}
+
+func (w *PayloadWriter) Finish() error {
+	if w.finished {
+		return errors.New("can't reuse a finished payload writer")
+	}
+	w.finished = true
+	field := arrow.Field{
+		Name:     payloadColumnName,
+		Type:     w.arrowType,
+		Nullable: w.nullable,
+	}
+	schema := arrow.NewSchema([]arrow.Field{field}, nil)
+	data := w.builder.NewArray()
+	defer data.Release()
+	column := arrow.NewColumnFromArr(field, data)
+	defer column.Release()
+	table := array.NewTable(schema, []arrow.Column{column}, int64(column.Len()))
+	defer table.Release()
+
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Zstd),
+		parquet.WithCompressionLevel(3),
+	)
+	return pqarrow.WriteTable(table, w.output, 1024*1024*1024, props, pqarrow.DefaultWriterProps())
+}
+
+func (w *PayloadWriter) GetPayloadBuffer() ([]byte, error) {
+	if !w.finished {
+		return nil, errors.New("payload writer is not finished")
+	}
+	return w.output.Bytes(), nil
+}
+
+func (w *PayloadWriter) GetRowNum() int {
+	return w.rows
+}
+
+// ReadPayload decodes a payload parquet file into field data
+func ReadPayload(dataType schemapb.DataType, dim int, buf []byte) (FieldData, error) {
+	table, err := pqarrow.ReadTable(context.Background(), bytes.NewReader(buf), parquet.NewReaderProperties(memory.DefaultAllocator),
+		pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read payload")
+	}
+	defer table.Release()
+	if table.NumCols() != 1 {
+		return nil, errors.Errorf("payload should have exactly 1 column, got %d", table.NumCols())
+	}
+
+	ret, err := newFieldDataByType(dataType, dim)
+	if err != nil {
+		return nil, err
+	}
+	validity := getValidity(ret)
+	if validity != nil {
+		validity.Nullable = table.Schema().Field(0).Nullable
+	}
+	for _, chunk := range table.Column(0).Data().Chunks() {
+		if err := appendArrowChunk(ret, chunk); err != nil {
+			return nil, err
+		}
+		if validity != nil && validity.Nullable {
+			for i := 0; i < chunk.Len(); i++ {
+				validity.ValidData = append(validity.ValidData, chunk.IsValid(i))
+			}
+		}
+	}
+	return ret, nil
+}
+
+func newFieldDataByType(dataType schemapb.DataType, dim int) (FieldData, error) {
+	switch dataType {
+	case schemapb.DataType_Bool:
+		return &BoolFieldData{}, nil
+	case schemapb.DataType_Int8:
+		return &Int8FieldData{}, nil
+	case schemapb.DataType_Int16:
+		return &Int16FieldData{}, nil
+	case schemapb.DataType_Int32:
+		return &Int32FieldData{}, nil
+	case schemapb.DataType_Int64:
+		return &Int64FieldData{}, nil
+	case schemapb.DataType_Float:
+		return &FloatFieldData{}, nil
+	case schemapb.DataType_Double:
+		return &DoubleFieldData{}, nil
+	case schemapb.DataType_String, schemapb.DataType_VarChar:
+		return &StringFieldData{DataType: dataType}, nil
+	case schemapb.DataType_Array:
+		return &ArrayFieldData{}, nil
+	case schemapb.DataType_JSON:
+		return &JSONFieldData{}, nil
+	case schemapb.DataType_BinaryVector:
+		return &BinaryVectorFieldData{Dim: dim}, nil
+	case schemapb.DataType_FloatVector:
+		return &FloatVectorFieldData{Dim: dim}, nil
+	case schemapb.DataType_Float16Vector:
+		return &Float16VectorFieldData{Dim: dim}, nil
+	case DataType_BFloat16Vector:
+		return &BFloat16VectorFieldData{Dim: dim}, nil
+	case DataType_SparseFloatVector:
+		return &SparseFloatVectorFieldData{}, nil
+	default:
+		return nil, errors.Errorf("unsupported payload data type %s", dataType.String())
+	}
+}
+
+func appendArrowChunk(data FieldData, chunk arrow.Array) error {
+	var ok bool
+	switch d := data.(type) {
+	case *BoolFieldData:
+		var arr *array.Boolean
+		if arr, ok = chunk.(*array.Boolean); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, arr.Value(i))
+			}
+		}
+	case *Int8FieldData:
+		var arr *array.Int8
+		if arr, ok = chunk.(*array.Int8); ok {
+			d.Data = append(d.Data, arr.Int8Values()...)
+		}
+	case *Int16FieldData:
+		var arr *array.Int16
+		if arr, ok = chunk.(*array.Int16); ok {
+			d.Data = append(d.Data, arr.Int16Values()...)
+		}
+	case *Int32FieldData:
+		var arr *array.Int32
+		if arr, ok = chunk.(*array.Int32); ok {
+			d.Data = append(d.Data, arr.Int32Values()...)
+		}
+	case *Int64FieldData:
+		var arr *array.Int64
+		if arr, ok = chunk.(*array.Int64); ok {
+			d.Data = append(d.Data, arr.Int64Values()...)
+		}
+	case *FloatFieldData:
+		var arr *array.Float32
+		if arr, ok = chunk.(*array.Float32); ok {
+			d.Data = append(d.Data, arr.Float32Values()...)
+		}
+	case *DoubleFieldData:
+		var arr *array.Float64
+		if arr, ok = chunk.(*array.Float64); ok {
+			d.Data = append(d.Data, arr.Float64Values()...)
+		}
+	case *StringFieldData:
+		var arr *array.String
+		if arr, ok = chunk.(*array.String); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, arr.Value(i))
+			}
+		}
+	case *ArrayFieldData:
+		var arr *array.Binary
+		if arr, ok = chunk.(*array.Binary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				value := &schemapb.ScalarField{}
+				if err := proto.Unmarshal(arr.Value(i), value); err != nil {
+					return err
+				}
+				d.Data = append(d.Data, value)
+			}
+		}
+	case *JSONFieldData:
+		var arr *array.Binary
+		if arr, ok = chunk.(*array.Binary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, append([]byte{}, arr.Value(i)...))
+			}
+		}
+	case *BinaryVectorFieldData:
+		var arr *array.FixedSizeBinary
+		if arr, ok = chunk.(*array.FixedSizeBinary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, arr.Value(i)...)
+			}
+		}
+	case *FloatVectorFieldData:
+		var arr *array.FixedSizeBinary
+		if arr, ok = chunk.(*array.FixedSizeBinary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, bytesToFloat32(arr.Value(i))...)
+			}
+		}
+	case *Float16VectorFieldData:
+		var arr *array.FixedSizeBinary
+		if arr, ok = chunk.(*array.FixedSizeBinary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, arr.Value(i)...)
+			}
+		}
+	case *BFloat16VectorFieldData:
+		var arr *array.FixedSizeBinary
+		if arr, ok = chunk.(*array.FixedSizeBinary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				d.Data = append(d.Data, arr.Value(i)...)
+			}
+		}
+	case *SparseFloatVectorFieldData:
+		var arr *array.Binary
+		if arr, ok = chunk.(*array.Binary); ok {
+			for i := 0; i < arr.Len(); i++ {
+				if err := d.AppendRow(append([]byte{}, arr.Value(i)...)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if !ok {
+		return errors.Errorf("payload column type %s mismatches %s", chunk.DataType().Name(), data.GetDataType().String())
+	}
+	return nil
+}
+
+func milvusDataTypeToArrowType(dataType schemapb.DataType, dim int) (arrow.DataType, error) {
+	switch dataType {
+	case schemapb.DataType_Bool:
+		return &arrow.BooleanType{}, nil
+	case schemapb.DataType_Int8:
+		return &arrow.Int8Type{}, nil
+	case schemapb.DataType_Int16:
+		return &arrow.Int16Type{}, nil
+	case schemapb.DataType_Int32:
+		return &arrow.Int32Type{}, nil
+	case schemapb.DataType_Int64:
+		return &arrow.Int64Type{}, nil
+	case schemapb.DataType_Float:
+		return &arrow.Float32Type{}, nil
+	case schemapb.DataType_Double:
+		return &arrow.Float64Type{}, nil
+	case schemapb.DataType_VarChar, schemapb.DataType_String:
+		return &arrow.StringType{}, nil
+	case schemapb.DataType_Array, schemapb.DataType_JSON, DataType_SparseFloatVector:
+		return &arrow.BinaryType{}, nil
+	case schemapb.DataType_FloatVector:
+		return &arrow.FixedSizeBinaryType{ByteWidth: dim * 4}, nil
+	case schemapb.DataType_BinaryVector:
+		return &arrow.FixedSizeBinaryType{ByteWidth: dim / 8}, nil
+	case schemapb.DataType_Float16Vector, DataType_BFloat16Vector:
+		return &arrow.FixedSizeBinaryType{ByteWidth: dim * 2}, nil
+	default:
+		return nil, errors.Errorf("unsupported payload data type %s", dataType.String())
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/pkg/errors"
+)
+
+// PrimaryKey is the value of a primary key field, either int64 or varchar
+type PrimaryKey interface {
+	GT(key PrimaryKey) bool
+	LT(key PrimaryKey) bool
+	EQ(key PrimaryKey) bool
+	GetValue() interface{}
+	Type() schemapb.DataType
+	Size() int64
+}
+
+type Int64PrimaryKey struct {
+	Value int64 `json:"pkValue"`
+}
+
+func NewInt64PrimaryKey(v int64) *Int64PrimaryKey {
+	return &Int64PrimaryKey{Value: v}
+}
+
+func (ip *Int64PrimaryKey) GT(key PrimaryKey) bool {
+	pk, ok := key.(*Int64PrimaryKey)
+	return ok && ip.Value > pk.Value
+}
+
+func (ip *Int64PrimaryKey) LT(key PrimaryKey) bool {
+	pk, ok := key.(*Int64PrimaryKey)
+	return ok && ip.Value < pk.Value
+}
+
+func (ip *Int64PrimaryKey) EQ(key PrimaryKey) bool {
+	pk, ok := key.(*Int64PrimaryKey)
+	return ok && ip.Value == pk.Value
+}
+
+// MarshalJSON encodes the key as a bare number, same as upstream delta logs
+func (ip *Int64PrimaryKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ip.Value)
+}
+
+func (ip *Int64PrimaryKey) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &ip.Value)
+}
+
+func (ip *Int64PrimaryKey) GetValue() interface{} {
+	return ip.Value
+}
+
+func (ip *Int64PrimaryKey) Type() schemapb.DataType {
+	return schemapb.DataType_Int64
+}
+
+func (ip *Int64PrimaryKey) Size() int64 {
+	// 8 bytes value + 8 bytes interface overhead
+	return 16
+}
+
+type VarCharPrimaryKey struct {
+	Value string
+}
+
+func NewVarCharPrimaryKey(v string) *VarCharPrimaryKey {
+	return &VarCharPrimaryKey{Value: v}
+}
+
+func (vcp *VarCharPrimaryKey) GT(key PrimaryKey) bool {
+	pk, ok := key.(*VarCharPrimaryKey)
+	return ok && vcp.Value > pk.Value
+}
+
+func (vcp *VarCharPrimaryKey) LT(key PrimaryKey) bool {
+	pk, ok := key.(*VarCharPrimaryKey)
+	return ok && vcp.Value < pk.Value
+}
+
+func (vcp *VarCharPrimaryKey) EQ(key PrimaryKey) bool {
+	pk, ok := key.(*VarCharPrimaryKey)
+	return ok && vcp.Value == pk.Value
+}
+
+// MarshalJSON encodes the key as a bare string, same as upstream delta logs
+func (vcp *VarCharPrimaryKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vcp.Value)
+}
+
+func (vcp *VarCharPrimaryKey) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &vcp.Value)
+}
+
+func (vcp *VarCharPrimaryKey) GetValue() interface{} {
+	return vcp.Value
+}
+
+func (vcp *VarCharPrimaryKey) Type() schemapb.DataType {
+	return schemapb.DataType_VarChar
+}
+
+func (vcp *VarCharPrimaryKey) Size() int64 {
+	return int64(len(vcp.Value) + 16)
+}
+
+// NewPrimaryKey wraps a raw int64 or string value
+func NewPrimaryKey(value any) (PrimaryKey, error) {
+	switch v := value.(type) {
+	case int64:
+		return NewInt64PrimaryKey(v), nil
+	case string:
+		return NewVarCharPrimaryKey(v), nil
+	default:
+		return nil, errors.Errorf("unsupported primary key type %s", fmt.Sprintf("%T", value))
+	}
+}
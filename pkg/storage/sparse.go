@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/milvus-io/milvus-proto/go-api/v2/schemapb"
+	"github.com/pkg/errors"
+)
+
+// SparseFloatVectorFieldData is the rows of sparse float vectors, Dim is the max index plus one of all rows.
+// Rows are the pairs of uint32 index & float32 value in little endian sorted by index, same as milvus.
+type SparseFloatVectorFieldData struct {
+	Data [][]byte
+	Dim  int
+}
+
+func (data *SparseFloatVectorFieldData) RowNum() int { return len(data.Data) }
+
+func (data *SparseFloatVectorFieldData) GetRow(i int) any { return data.Data[i] }
+
+func (data *SparseFloatVectorFieldData) GetDataType() schemapb.DataType {
+	return DataType_SparseFloatVector
+}
+
+func (data *SparseFloatVectorFieldData) GetMemorySize() int {
+	size := 0
+	for _, row := range data.Data {
+		size += len(row)
+	}
+	return size + 4
+}
+
+func (data *SparseFloatVectorFieldData) AppendRow(row any) error {
+	v, ok := row.([]byte)
+	if !ok {
+		return errRowTypeMismatch(row, data.GetDataType())
+	}
+	if err := ValidateSparseRow(v); err != nil {
+		return err
+	}
+	data.Data = append(data.Data, v)
+	if dim := SparseRowDim(v); dim > data.Dim {
+		data.Dim = dim
+	}
+	return nil
+}
+
+func (data *SparseFloatVectorFieldData) IsValid(i int) bool { return true }
+
+// SparseRowLen returns the number of non-zero elements of the sparse row
+func SparseRowLen(row []byte) int {
+	return len(row) / 8
+}
+
+// SparseRowAt returns the index & value of the i-th non-zero element of the sparse row
+func SparseRowAt(row []byte, i int) (uint32, float32) {
+	return binary.LittleEndian.Uint32(row[i*8:]), math.Float32frombits(binary.LittleEndian.Uint32(row[i*8+4:]))
+}
+
+// SparseRowDim returns the max index plus one of the sparse row, 0 if it's empty
+func SparseRowDim(row []byte) int {
+	if SparseRowLen(row) == 0 {
+		return 0
+	}
+	index, _ := SparseRowAt(row, SparseRowLen(row)-1)
+	return int(index) + 1
+}
+
+// ValidateSparseRow checks the indexes of the sparse row are ascending and unique, and the values are finite and non-negative
+func ValidateSparseRow(row []byte) error {
+	if len(row)%8 != 0 {
+		return errors.Errorf("invalid length %d of sparse float vector, it should be a multiple of 8", len(row))
+	}
+	for i := 0; i < SparseRowLen(row); i++ {
+		index, value := SparseRowAt(row, i)
+		if index == math.MaxUint32 {
+			return errors.Errorf("index %d of sparse float vector is out of range", index)
+		}
+		if i > 0 {
+			if prev, _ := SparseRowAt(row, i-1); prev >= index {
+				return errors.New("indexes of sparse float vector should be ascending and unique")
+			}
+		}
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) || value < 0 {
+			return errors.Errorf("value %v of sparse float vector should be a finite non-negative number", value)
+		}
+	}
+	return nil
+}
+
+// NewSparseRow encodes the map of index to value into a sparse row, zeros are dropped
+func NewSparseRow(values map[uint32]float32) []byte {
+	indexes := make([]uint32, 0, len(values))
+	for index, value := range values {
+		if value != 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	ret := make([]byte, len(indexes)*8)
+	for i, index := range indexes {
+		binary.LittleEndian.PutUint32(ret[i*8:], index)
+		binary.LittleEndian.PutUint32(ret[i*8+4:], math.Float32bits(values[index]))
+	}
+	return ret
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"math"
+
+	"github.com/sharding-db/milvus-mini/pkg/common"
+)
+
+func float32ToBytes(data []float32) []byte {
+	ret := make([]byte, len(data)*4)
+	for i, v := range data {
+		common.Endian.PutUint32(ret[i*4:], math.Float32bits(v))
+	}
+	return ret
+}
+
+func bytesToFloat32(data []byte) []float32 {
+	ret := make([]float32, len(data)/4)
+	for i := range ret {
+		ret[i] = math.Float32frombits(common.Endian.Uint32(data[i*4:]))
+	}
+	return ret
+}